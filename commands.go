@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/snykk/beego-presence-api/database"
+	"github.com/snykk/beego-presence-api/helpers"
+)
+
+// runCommand runs a maintenance command instead of the server, e.g. "go run . purge", and returns the exit code
+func runCommand(args []string) int {
+	switch args[0] {
+	case "migrate":
+		return migrateCommand(args[1:])
+	case "rollback":
+		return rollbackCommand(args[1:])
+	case "status":
+		return statusCommand(args[1:])
+	case "purge":
+		return purgeCommand(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q, available commands: migrate, rollback, status, purge\n", args[0])
+		return 2
+	}
+}
+
+// purgeCommand permanently deletes the users, departments and schedules deleted before the retention period
+func purgeCommand(args []string) int {
+	flags := flag.NewFlagSet("purge", flag.ContinueOnError)
+	retentionDays := flags.Int("retention-days", helpers.SoftDeleteRetentionDays(), "purge rows deleted more than this many days ago")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *retentionDays < 0 {
+		fmt.Fprintln(os.Stderr, "retention-days can't be negative")
+		return 2
+	}
+
+	result, err := helpers.PurgeDeleted(*retentionDays, time.Now())
+	if err != nil {
+		log.Printf("Purge failed: %v", err)
+		return 1
+	}
+	log.Printf("Purged %d users, %d departments and %d schedules deleted more than %d days ago, %d still referenced rows kept",
+		result.Users, result.Departments, result.Schedules, *retentionDays, result.Skipped)
+	return 0
+}
+
+// migrateCommand applies the pending migrations
+func migrateCommand(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	migrations, err := database.Migrate()
+	for _, migration := range migrations {
+		log.Printf("Applied migration %d_%s", migration.Version, migration.Name)
+	}
+	if err != nil {
+		log.Printf("Migrate failed: %v", err)
+		return 1
+	}
+	if len(migrations) == 0 {
+		log.Println("No pending migrations")
+	}
+	return 0
+}
+
+// rollbackCommand reverts the last applied migrations
+func rollbackCommand(args []string) int {
+	flags := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	steps := flags.Int("steps", 1, "number of migrations to revert, newest first")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *steps < 1 {
+		fmt.Fprintln(os.Stderr, "steps must be at least 1")
+		return 2
+	}
+
+	migrations, err := database.Rollback(*steps)
+	for _, migration := range migrations {
+		log.Printf("Reverted migration %d_%s", migration.Version, migration.Name)
+	}
+	if err != nil {
+		log.Printf("Rollback failed: %v", err)
+		return 1
+	}
+	if len(migrations) == 0 {
+		log.Println("No applied migrations")
+	}
+	return 0
+}
+
+// statusCommand lists the migrations and whether they are applied
+func statusCommand(args []string) int {
+	flags := flag.NewFlagSet("status", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	statuses, err := database.Status()
+	if err != nil {
+		log.Printf("Status failed: %v", err)
+		return 1
+	}
+	for _, status := range statuses {
+		state := "pending"
+		if status.AppliedAt != nil {
+			state = "applied " + status.AppliedAt.Format(time.RFC3339)
+		}
+		if status.Missing {
+			state += ", files missing"
+		}
+		fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, state)
+	}
+	return 0
+}
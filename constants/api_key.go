@@ -0,0 +1,38 @@
+package constants
+
+import "time"
+
+const (
+	ApiKeyPrefix           = "bpa_" // Tells API keys apart from JWTs in the Authorization header
+	ApiKeyBytes            = 32     // Random bytes of a key, sent hex encoded after the prefix
+	ApiKeyDisplayLength    = 12     // Leading characters of a key stored in clear to identify it in listings
+	ApiKeyDefaultTTLDays   = 90
+	ApiKeyMaxTTLDays       = 365
+	ApiKeyLastUsedInterval = time.Minute // Last use is recorded at most once per interval, not on every request
+	ApiKeyMaxPerUser       = 20
+
+	// Service accounts have no mailbox, their generated emails use a reserved domain
+	ServiceAccountEmailDomain = "service-accounts.invalid"
+)
+
+// Scopes grant API keys read (GET) or write access to the endpoints of a resource
+const (
+	ApiKeyScopeRead  = "read"
+	ApiKeyScopeWrite = "write"
+)
+
+// ApiKeyScopes lists the scopes API keys can be granted, named after the first path segment of the endpoints
+var ApiKeyScopes = []string{
+	"users:read", "users:write",
+	"departments:read", "departments:write",
+	"schedules:read", "schedules:write",
+	"presences:read", "presences:write",
+	"holidays:read", "holidays:write",
+	"leaves:read", "leaves:write",
+	"reports:read",
+	"payroll:read", "payroll:write",
+	"notifications:read", "notifications:write",
+	"webhooks:read", "webhooks:write",
+	"login-attempts:read",
+	"audit-logs:read",
+}
@@ -0,0 +1,22 @@
+package constants
+
+// Actions recorded in the audit log
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+)
+
+// Entities recorded in the audit log
+const (
+	AuditEntityUser       = "user"
+	AuditEntityDepartment = "department"
+	AuditEntitySchedule   = "schedule"
+	AuditEntityPresence   = "presence"
+)
+
+const (
+	AuditLogDefaultLimit = 50
+	AuditLogMaxLimit     = 500
+)
@@ -0,0 +1,53 @@
+package constants
+
+import "time"
+
+const (
+	PasswordResetTokenBytes = 32               // Random bytes of a reset token, sent hex encoded
+	PasswordResetTokenTTL   = 30 * time.Minute // Time a reset token can be used
+	PasswordResetRateLimit  = 3                // Reset requests accepted per email within the window
+	PasswordResetRateWindow = time.Hour
+)
+
+const (
+	PasswordHistorySize = 5 // Previous passwords that can't be reused, besides the current one
+
+	// Path of the only endpoint available to users who must change their initial password
+	ChangePasswordPath = "/users/me/password"
+)
+
+const (
+	EmailVerificationTokenTTL   = 72 * time.Hour // Time a verification link stays valid
+	EmailVerificationRateLimit  = 3              // Resend requests accepted per email within the window
+	EmailVerificationRateWindow = time.Hour
+)
+
+const (
+	LoginDefaultMaxFailures   = 5                // Failed passwords in a row that lock the account
+	LoginDefaultLockout       = 15 * time.Minute // Time the account stays locked
+	LoginDefaultIpMaxFailures = 20               // Failed logins from an IP within the window that block it
+	LoginDefaultIpWindow      = 15 * time.Minute
+	LoginDelayBase            = time.Second // Wait after the first failed password, doubled with every further failure
+	LoginDelayMax             = 30 * time.Second
+
+	LoginAttemptDefaultLimit = 50
+	LoginAttemptMaxLimit     = 500
+
+	LoginAttemptStatusSuccess = "success"
+	LoginAttemptStatusFailure = "failure"
+)
+
+// Reasons recorded with the login attempts
+const (
+	LoginReasonTwoFactorPending = "two_factor_pending" // First step passed, the login continues with the second factor
+	LoginReasonLoggedIn         = "logged_in"
+	LoginReasonInvalidPassword  = "invalid_password"
+	LoginReasonUnknownEmail     = "unknown_email"
+	LoginReasonInvalidCode      = "invalid_code"
+	LoginReasonUnverified       = "unverified"
+	LoginReasonLocked           = "locked"
+	LoginReasonThrottled        = "throttled"
+	LoginReasonIpBlocked        = "ip_blocked"
+	LoginReasonDeactivated      = "deactivated"
+	LoginReasonServiceAccount   = "service_account"
+)
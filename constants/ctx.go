@@ -3,4 +3,5 @@ package constants
 const (
 	CtxAuthenticatedUserId   = "authenticated_userId"
 	CtxAuthenticatedUserRole = "authenticated_userRole"
+	CtxAuthenticatedApiKeyId = "authenticated_apiKeyId"
 )
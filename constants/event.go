@@ -0,0 +1,36 @@
+package constants
+
+import "time"
+
+const (
+	// Domain events recorded in the outbox
+	EventUserCreated     = "user.created"
+	EventUserUpdated     = "user.updated"
+	EventUserDeleted     = "user.deleted"
+	EventUserLoggedIn    = "user.logged_in"
+	EventUserVerified    = "user.email_verified" // Recorded when a user confirms the email address with the verification link
+	EventPresenceCreated = "presence.created"
+	EventPresenceLate    = "presence.late" // Recorded in addition to presence.created for late check-ins
+	EventPresenceUpdated = "presence.updated"
+	EventPresenceDeleted = "presence.deleted"
+	EventAll             = "*" // Matches every event when registering handlers
+
+	OutboxStatusPending   = "pending"
+	OutboxStatusPublished = "published" // Every sink received the event
+	OutboxStatusDead      = "dead"      // Given up after OutboxMaxAttempts, the last error names the failing sinks
+
+	OutboxBaseBackoff     = 5 * time.Second // Delay before the first retry, doubled on every further attempt
+	OutboxMaxBackoff      = time.Hour
+	OutboxMaxAttempts     = 20 // Attempts before an event is given up, about 12 hours with the backoff
+	OutboxPollInterval    = 2 * time.Second
+	OutboxBatchSize       = 50
+	OutboxLease           = time.Minute // Time a claimed event is hidden from other dispatchers
+	OutboxRequestTimeout  = 10 * time.Second
+	OutboxEventHeader     = "X-Event-Name"
+	OutboxEventIdHeader   = "X-Event-Id"
+	OutboxDefaultSubject  = "presence.events" // Subject prefix of the events published to NATS
+	OutboxSinkHandlers    = "handlers"
+	OutboxSinkHTTP        = "http"
+	OutboxSinkNATS        = "nats"
+	OutboxNATSDialTimeout = 5 * time.Second
+)
@@ -0,0 +1,12 @@
+package constants
+
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatXLSX = "xlsx"
+
+	ExportMimeCSV  = "text/csv"
+	ExportMimeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+	ExportBatchSize     = 500   // Number of rows fetched from the database per export batch
+	ExportDefaultLocale = "iso" // Locale used when none or an unknown one is requested
+)
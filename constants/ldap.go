@@ -0,0 +1,23 @@
+package constants
+
+import "time"
+
+// Backends AuthController.Login checks passwords with, selected with auth_backend
+const (
+	AuthBackendLocal = "local" // bcrypt hashes of models.User
+	AuthBackendLDAP  = "ldap"  // bind to the directory, accounts that aren't managed by the directory keep their local password
+)
+
+const (
+	LDAPRequestTimeout = 10 * time.Second
+	LDAPPageSize       = 500 // Entries requested per page when the sync lists the directory
+
+	LDAPDefaultUserFilter        = "(objectClass=person)"
+	LDAPDefaultUsernameAttribute = "uid"
+	LDAPDefaultEmailAttribute    = "mail"
+	LDAPDefaultNameAttribute     = "cn"
+	LDAPDefaultGroupAttribute    = "memberOf"
+
+	LDAPSyncTaskName    = "ldap_sync"
+	LDAPDefaultSyncSpec = "0 0 * * * *" // Every hour
+)
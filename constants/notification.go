@@ -0,0 +1,42 @@
+package constants
+
+import "time"
+
+const (
+	NotificationTypeWelcome           = "welcome"
+	NotificationTypeLateDigest        = "late_digest"        // Daily digest of the late arrivals of a department, sent to its managers
+	NotificationTypeMissingCheckout   = "missing_checkout"   // Reminder for a check-in without check-out
+	NotificationTypeShiftReminder     = "shift_reminder"     // Sent shortly before the shift starts
+	NotificationTypeMissedCheckIn     = "missed_checkin"     // Sent when there is no check-in by the late threshold
+	NotificationTypePasswordReset     = "password_reset"     // Security notification, users can't opt out of it
+	NotificationTypeEmailVerification = "email_verification" // Sent on self-registration, users can't opt out of it
+
+	NotificationStatusSent   = "sent"
+	NotificationStatusFailed = "failed"
+
+	MailDriverLog  = "log" // Writes the emails to files instead of sending them, for development
+	MailDriverSMTP = "smtp"
+
+	NotificationChannelEmail = "email"
+	NotificationChannelChat  = "chat" // Generic chat webhook accepting {"text": ...}, e.g. Slack or Mattermost
+	NotificationChannelPush  = "push"
+
+	ShiftReminderDefaultMinutes  = 15
+	ShiftSchedulerSpec           = "0 * * * * *" // The shift scheduler checks every minute
+	ShiftNotificationMaxAttempts = 5             // Failed attempts before a shift notification is given up
+	ShiftNotificationBaseBackoff = time.Minute   // Delay before the first retry, doubled on every further attempt
+	ShiftNotificationMaxBackoff  = 15 * time.Minute
+	ShiftNotificationLockId      = 20241202 // Advisory lock letting a single instance send the shift notifications at a time
+
+	NotificationDefaultLogLimit = 50
+	NotificationMaxLogLimit     = 500
+)
+
+// NotificationTypes lists the notifications users can opt out of
+var NotificationTypes = []string{
+	NotificationTypeWelcome,
+	NotificationTypeLateDigest,
+	NotificationTypeMissingCheckout,
+	NotificationTypeShiftReminder,
+	NotificationTypeMissedCheckIn,
+}
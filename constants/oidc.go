@@ -0,0 +1,14 @@
+package constants
+
+import "time"
+
+const (
+	OIDCStateTTL          = 10 * time.Minute // Time to complete the login at the identity provider
+	OIDCStateBytes        = 32               // Random bytes of the state and the nonce, sent hex encoded
+	OIDCCodeVerifierBytes = 32               // Random bytes of the PKCE code verifier, sent base64url encoded
+	OIDCRequestTimeout    = 10 * time.Second
+	OIDCClockSkew         = time.Minute // Tolerance for the issued at and expiry times of ID tokens
+
+	OIDCDefaultScopes          = "openid email profile"
+	OIDCDefaultDepartmentClaim = "department"
+)
@@ -0,0 +1,36 @@
+package constants
+
+const (
+	PayrollFormatCSV        = "csv"
+	PayrollFormatFixedWidth = "fixed"
+
+	PayrollRoundingNone    = "none"
+	PayrollRoundingUp      = "up"
+	PayrollRoundingDown    = "down"
+	PayrollRoundingNearest = "nearest"
+
+	PayrollUnitMinutes = "minutes"
+	PayrollUnitHours   = "hours"
+
+	PayrollAlignLeft  = "left"
+	PayrollAlignRight = "right"
+
+	// Sources a payroll template field can be mapped from
+	PayrollSourceEmployeeId      = "employee_id"
+	PayrollSourceEmployeeName    = "employee_name"
+	PayrollSourceEmployeeEmail   = "employee_email"
+	PayrollSourceDepartmentId    = "department_id"
+	PayrollSourcePeriod          = "period"
+	PayrollSourceWorkingDays     = "working_days"
+	PayrollSourceDaysPresent     = "days_present"
+	PayrollSourceAbsentDays      = "absent_days"
+	PayrollSourceLeaveDays       = "leave_days"
+	PayrollSourceLateCount       = "late_count"
+	PayrollSourceLateMinutes     = "late_minutes"
+	PayrollSourceWorkedMinutes   = "worked_minutes"
+	PayrollSourceOvertimeMinutes = "overtime_minutes"
+	PayrollSourceLateDeduction   = "late_deduction"   // Sum of the deductions of all lateness buckets
+	PayrollSourceBucketCount     = "bucket_count"     // Late days that fell into the bucket of the field
+	PayrollSourceBucketDeduction = "bucket_deduction" // Deduction of the bucket of the field
+	PayrollSourceLiteral         = "literal"          // Fixed value of the field
+)
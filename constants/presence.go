@@ -2,9 +2,30 @@ package constants
 
 const (
 	PresenceLateThreshold = 15 // Define late threshold in minutes
+	PresenceTimezone      = "Asia/Jakarta"
 	PresenceTypeIn        = "in"
 	PresenceTypeOut       = "out"
 	PresenceStatusEarly   = "early"
 	PresenceStatusLate    = "late"
 	PresenceStatusOnTime  = "ontime"
 )
+
+// Actions of the presence revisions
+const (
+	PresenceRevisionCreated   = "created"
+	PresenceRevisionUpdated   = "updated"
+	PresenceRevisionDeleted   = "deleted"
+	PresenceRevisionBaseline  = "baseline"    // State of a presence changed for the first time since its history is tracked
+	PresenceRevisionOutOfBand = "out_of_band" // State of a presence changed outside the API, found when it was changed next
+	PresenceVerifyBatchSize   = 500
+)
+
+// Problems found by the verification of the presence history
+const (
+	PresenceProblemRevisionHash  = "revision_hash_mismatch" // A revision was changed
+	PresenceProblemRevisionChain = "revision_chain_broken"  // A revision was removed or reordered
+	PresenceProblemChanged       = "presence_changed"       // The presence differs from its last revision
+	PresenceProblemDeleted       = "presence_deleted"       // The presence is gone but its history doesn't end with a deletion
+	PresenceProblemUntracked     = "presence_untracked"     // The presence has no history, it predates the history or was inserted outside the API
+	PresenceProblemOutOfBand     = "out_of_band_change"     // A change outside the API was found when the presence was changed next
+)
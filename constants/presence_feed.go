@@ -0,0 +1,13 @@
+package constants
+
+import "time"
+
+const (
+	PresenceFeedHistorySize       = 1000             // Number of recent events kept to resume streams via Last-Event-ID
+	PresenceFeedSubscriberBuffer  = 64               // Events buffered per client before a slow client is disconnected
+	PresenceFeedHeartbeatInterval = 25 * time.Second // Keeps idle connections open through proxies
+	PresenceFeedWriteTimeout      = 10 * time.Second // Deadline of a single WebSocket write
+
+	PresenceFeedTokenParam       = "access_token"  // Query parameter of the JWT for clients that can't set headers
+	PresenceFeedLastEventIdParam = "last_event_id" // Query parameter alternative to the Last-Event-ID header
+)
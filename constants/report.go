@@ -0,0 +1,18 @@
+package constants
+
+const (
+	ReportMonthLayout            = "2006-01"    // Layout of the month query parameter (YYYY-MM)
+	ReportDateLayout             = "2006-01-02" // Layout of dates in reports (YYYY-MM-DD)
+	ReportMaxRangeDays           = 366          // Maximum number of days a range report may span
+	ReportDefaultTopLateArrivals = 5            // Default number of users listed as top late arrivals
+	ReportMaxTopLateArrivals     = 50
+
+	ReportDayStatusPresent = "present"
+	ReportDayStatusLate    = "late"
+	ReportDayStatusAbsent  = "absent"
+	ReportDayStatusPending = "pending" // The day has not ended yet and there is no check-in
+
+	ReportDayMarkerWeekend = "weekend"
+	ReportDayMarkerHoliday = "holiday"
+	ReportDayMarkerLeave   = "leave"
+)
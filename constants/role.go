@@ -2,5 +2,6 @@ package constants
 
 const (
 	RoleEmployee = "EMPLOYEE"
+	RoleManager  = "MANAGER" // Employee who follows the attendance of the own department
 	RoleAdmin    = "ADMIN"
 )
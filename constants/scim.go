@@ -0,0 +1,39 @@
+package constants
+
+// SCIM 2.0 schema URNs (RFC 7643, RFC 7644)
+const (
+	ScimSchemaUser           = "urn:ietf:params:scim:schemas:core:2.0:User"
+	ScimSchemaEnterpriseUser = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+	ScimSchemaGroup          = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	ScimSchemaListResponse   = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	ScimSchemaPatchOp        = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ScimSchemaError          = "urn:ietf:params:scim:api:messages:2.0:Error"
+	ScimSchemaServiceConfig  = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+const (
+	ScimPath              = "/scim/v2"
+	ScimContentType       = "application/scim+json"
+	ScimResourceTypeUser  = "User"
+	ScimResourceTypeGroup = "Group" // Departments are provisioned as groups
+	ScimDefaultCount      = 100     // Resources per page when the client doesn't ask for a count
+	ScimMaxCount          = 1000
+)
+
+// Operations of a PATCH request
+const (
+	ScimPatchOpAdd     = "add"
+	ScimPatchOpReplace = "replace"
+	ScimPatchOpRemove  = "remove"
+)
+
+// Error types of SCIM error responses
+const (
+	ScimErrorInvalidFilter = "invalidFilter"
+	ScimErrorInvalidValue  = "invalidValue"
+	ScimErrorInvalidPath   = "invalidPath"
+	ScimErrorInvalidSyntax = "invalidSyntax"
+	ScimErrorNoTarget      = "noTarget"
+	ScimErrorMutability    = "mutability"
+	ScimErrorUniqueness    = "uniqueness"
+)
@@ -0,0 +1,4 @@
+package constants
+
+// Deleted users, departments and schedules are kept for the retention period before the purge command removes them
+const SoftDeleteDefaultRetentionDays = 30
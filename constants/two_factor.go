@@ -0,0 +1,24 @@
+package constants
+
+import "time"
+
+const (
+	TOTPIssuer      = "Beego Presence API"
+	TOTPPeriod      = 30 * time.Second // Time step of the codes
+	TOTPDigits      = 6
+	TOTPSkew        = 1  // Steps accepted before and after the current one, to tolerate clock drift
+	TOTPSecretBytes = 20 // Length of the shared secret, the size of a SHA-1 block key recommended by RFC 4226
+
+	RecoveryCodeCount = 10 // Recovery codes issued on activation, each can be used once
+	RecoveryCodeBytes = 5  // Random bytes of a recovery code, shown as two groups of five hex digits
+
+	PreAuthTokenTTL     = 5 * time.Minute // Time to complete the second login step
+	TwoFactorRateLimit  = 5               // Code attempts accepted per user within the window
+	TwoFactorRateWindow = 5 * time.Minute
+
+	// Scope of the tokens issued after the password step, they can only verify a code or enroll in two-factor authentication
+	TokenScopePreAuth = "2fa"
+
+	// Path of the endpoints managing two-factor authentication, the only ones available to pre-auth tokens
+	TwoFactorPath = "/users/me/2fa"
+)
@@ -0,0 +1,39 @@
+package constants
+
+import "time"
+
+const (
+	WebhookEventAll = "*" // Subscribes a webhook to every event
+
+	WebhookDeliveryStatusPending   = "pending"
+	WebhookDeliveryStatusDelivered = "delivered"
+	WebhookDeliveryStatusFailed    = "failed" // All attempts were used up
+
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+
+	WebhookMaxAttempts     = 8                // Attempts before a delivery is marked as failed
+	WebhookBaseBackoff     = 30 * time.Second // Delay before the first retry, doubled on every further attempt
+	WebhookMaxBackoff      = 6 * time.Hour
+	WebhookRequestTimeout  = 10 * time.Second
+	WebhookPollInterval    = 5 * time.Second // Interval the dispatcher checks the queue for due deliveries
+	WebhookBatchSize       = 20              // Deliveries claimed per poll
+	WebhookDeliveryLease   = 2 * time.Minute // Time a claimed delivery is hidden from other dispatchers
+	WebhookDefaultLogLimit = 50
+	WebhookMaxLogLimit     = 500
+)
+
+// WebhookEvents lists the events webhooks can subscribe to
+var WebhookEvents = []string{
+	EventUserCreated,
+	EventUserUpdated,
+	EventUserDeleted,
+	EventUserLoggedIn,
+	EventUserVerified,
+	EventPresenceCreated,
+	EventPresenceLate,
+	EventPresenceUpdated,
+	EventPresenceDeleted,
+}
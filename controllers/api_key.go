@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/client/orm"
+	beego "github.com/beego/beego/v2/server/web"
+	beecontext "github.com/beego/beego/v2/server/web/context"
+)
+
+// ApiKeyController handles the personal API keys of the authenticated user
+type ApiKeyController struct {
+	beego.Controller
+}
+
+// URLMapping maps HTTP methods to controller functions
+// This function binds the URLs for each handler to its corresponding method.
+func (c *ApiKeyController) URLMapping() {
+	c.Mapping("GetAll", c.GetAll) // Maps GET /api-keys to GetAll method for retrieving the keys of the authenticated user
+	c.Mapping("Create", c.Create) // Maps POST /api-keys to Create method for creating a personal key
+	c.Mapping("Revoke", c.Revoke) // Maps DELETE /api-keys/:id to Revoke method for revoking a key
+}
+
+// @Title GetAll
+// @Description Retrieve the API keys of the authenticated user including revoked and expired ones. The keys themselves are not included.
+// @Produce  json
+// @Success 200 {object} dto.ApiKeyResponse "API keys retrieved successfully"
+// @Failure 401 Unauthorized
+// @Failure 500 Internal server error
+// @router / [get]
+func (c *ApiKeyController) GetAll() {
+	userId, ok := c.Ctx.Input.GetData(constants.CtxAuthenticatedUserId).(int)
+	if !ok {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Bad context", errors.New("can't retrieve user id from context"))
+		return
+	}
+
+	keys, err := models.GetApiKeysByUserId(userId)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch API keys", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "API keys retrieved successfully", dto.FromApiKeyModelListToApiKeyResponseList(keys, time.Now()))
+}
+
+// @Title Create
+// @Description Create a personal API key. Keys are sent as "Authorization: Bearer <key>", act with the role of the user and are limited to the granted scopes. The key is only returned in this response.
+// @Accept  json
+// @Produce  json
+// @Param   body	body	dto.ApiKeyRequest	true		"API key data"
+// @Success 201 {object} dto.ApiKeyResponse "API key created successfully"
+// @Failure 400 Invalid input
+// @Failure 401 Unauthorized
+// @Failure 409 Too many API keys
+// @Failure 500 Internal server error
+// @router / [post]
+func (c *ApiKeyController) Create() {
+	userId, ok := c.Ctx.Input.GetData(constants.CtxAuthenticatedUserId).(int)
+	if !ok {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Bad context", errors.New("can't retrieve user id from context"))
+		return
+	}
+
+	user, err := models.GetUserAccountById(userId)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch user", err)
+		return
+	}
+
+	createApiKey(c.Ctx, user)
+}
+
+// @Title Revoke
+// @Description Revoke an API key of the authenticated user, admins can revoke every key. Revoked keys stay listed.
+// @Produce  json
+// @Param   id		path	int	true		"API key ID"
+// @Success 200 {object} dto.ApiKeyResponse "API key revoked successfully"
+// @Failure 400 Invalid API key ID
+// @Failure 401 Unauthorized
+// @Failure 404 API key not found
+// @Failure 500 Internal server error
+// @router /:id [delete]
+func (c *ApiKeyController) Revoke() {
+	userId, ok := c.Ctx.Input.GetData(constants.CtxAuthenticatedUserId).(int)
+	if !ok {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Bad context", errors.New("can't retrieve user id from context"))
+		return
+	}
+	userRole, _ := c.Ctx.Input.GetData(constants.CtxAuthenticatedUserRole).(string)
+
+	id, err := c.GetInt(":id")
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid API key id", err)
+		return
+	}
+
+	key, err := models.GetApiKeyById(id)
+	// Keys of other users are reported as missing
+	if err == orm.ErrNoRows || (err == nil && key.User.Id != userId && userRole != constants.RoleAdmin) {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "API key not found", fmt.Errorf("API key '%d' not found", id))
+		return
+	}
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch API key with id %d", id), err)
+		return
+	}
+
+	now := time.Now()
+	if key.RevokedAt == nil {
+		if err := models.RevokeApiKey(key, now); err != nil {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to revoke API key", err)
+			return
+		}
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "API key revoked successfully", dto.FromApiKeyModelToApiKeyResponse(key, now))
+}
+
+// createApiKey creates a key for the account from the request body, shared by personal keys and keys of service accounts
+func createApiKey(ctx *beecontext.Context, user *models.User) {
+	var req dto.ApiKeyRequest
+	if err := json.Unmarshal(ctx.Input.RequestBody, &req); err != nil {
+		helpers.ErrorResponse(ctx.ResponseWriter, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	// Validate payload for any errors
+	if errorsMap, err := helpers.ValidatePayloads(req); err != nil {
+		helpers.ErrorResponse(ctx.ResponseWriter, http.StatusBadRequest, constants.ErrValidationMessage, errorsMap)
+		return
+	}
+
+	now := time.Now()
+	key, plain, err := helpers.GenerateApiKey(user, req.Name, req.Scopes, req.ExpiresInDays, now)
+	if err != nil {
+		if err == helpers.ErrApiKeyLimit {
+			helpers.ErrorResponse(ctx.ResponseWriter, http.StatusConflict, "Too many API keys", err)
+			return
+		}
+		helpers.ErrorResponse(ctx.ResponseWriter, http.StatusInternalServerError, "Failed to create API key", err)
+		return
+	}
+
+	apiKeyResponse := dto.FromApiKeyModelToApiKeyResponse(key, now)
+	apiKeyResponse.Key = plain
+	helpers.SuccessResponse(ctx.ResponseWriter, http.StatusCreated, "API key created successfully", apiKeyResponse)
+}
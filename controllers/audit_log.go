@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	beego "github.com/beego/beego/v2/server/web"
+	beecontext "github.com/beego/beego/v2/server/web/context"
+)
+
+// AuditLogController handles the audit records of the changes to users, departments, schedules and presences (admin only)
+type AuditLogController struct {
+	beego.Controller
+}
+
+// URLMapping maps HTTP methods to controller functions
+// This function binds the URLs for each handler to its corresponding method.
+func (c *AuditLogController) URLMapping() {
+	c.Mapping("GetAll", c.GetAll) // Maps GET /audit-logs to GetAll method for retrieving the audit records
+}
+
+// @Title GetAll
+// @Description Retrieve the most recent changes to users, departments, schedules and presences with the actor, the changed fields before and after, and the IP.
+// @Produce  json
+// @Param   actor_id	query	int		false		"Filter by the user who made the change"
+// @Param   action		query	string	false		"Filter by action: create, update, delete or restore"
+// @Param   entity_type	query	string	false		"Filter by entity: user, department, schedule or presence"
+// @Param   entity_id	query	int		false		"Filter by the ID of the changed entity"
+// @Param   from		query	string	false		"First day (YYYY-MM-DD)"
+// @Param   to			query	string	false		"Last day (YYYY-MM-DD)"
+// @Param   limit		query	int		false		"Maximum number of entries (default 50, max 500)"
+// @Success 200 {object} dto.AuditLogResponse "Audit logs retrieved successfully"
+// @Failure 400 Bad request
+// @Failure 500 Internal server error
+// @router / [get]
+func (c *AuditLogController) GetAll() {
+	var filter models.AuditLogFilter
+	var err error
+
+	if filter.ActorId, err = c.GetInt("actor_id", 0); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for actor_id", err)
+		return
+	}
+	if filter.EntityId, err = c.GetInt("entity_id", 0); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for entity_id", err)
+		return
+	}
+
+	filter.Action = c.GetString("action")
+	switch filter.Action {
+	case "", constants.AuditActionCreate, constants.AuditActionUpdate, constants.AuditActionDelete, constants.AuditActionRestore:
+	default:
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for action", fmt.Errorf("unknown audit action: %s", filter.Action))
+		return
+	}
+
+	filter.EntityType = c.GetString("entity_type")
+	switch filter.EntityType {
+	case "", constants.AuditEntityUser, constants.AuditEntityDepartment, constants.AuditEntitySchedule, constants.AuditEntityPresence:
+	default:
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for entity_type", fmt.Errorf("unknown audit entity: %s", filter.EntityType))
+		return
+	}
+
+	if filter.From, filter.To, err = helpers.ParseAuditDateRange(c.GetString("from"), c.GetString("to")); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid date range", err)
+		return
+	}
+
+	filter.Limit, err = c.GetInt("limit", constants.AuditLogDefaultLimit)
+	if err != nil || filter.Limit < 1 || filter.Limit > constants.AuditLogMaxLimit {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for limit", fmt.Errorf("limit must be between 1 and %d", constants.AuditLogMaxLimit))
+		return
+	}
+
+	entries, err := models.GetAuditLogs(filter)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch audit logs", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Audit logs retrieved successfully", dto.FromAuditLogModelListToAuditLogResponseList(entries))
+}
+
+// auditActor returns the authenticated user and the IP of the request, SCIM requests have no authenticated user
+func auditActor(ctx *beecontext.Context) models.AuditActor {
+	actorId, _ := ctx.Input.GetData(constants.CtxAuthenticatedUserId).(int)
+	return models.AuditActor{Id: actorId, Ip: ctx.Input.IP()}
+}
+
+// auditEntry records a change made by the authenticated user in the audit log, in the same transaction as the change
+func auditEntry(ctx *beecontext.Context, action, entityType string, change func() (int, interface{}, interface{})) models.AuditEntry {
+	return helpers.NewAuditEntry(auditActor(ctx), action, entityType, change)
+}
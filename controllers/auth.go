@@ -2,14 +2,21 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/snykk/beego-presence-api/constants"
 	"github.com/snykk/beego-presence-api/dto"
 	"github.com/snykk/beego-presence-api/helpers"
 	"github.com/snykk/beego-presence-api/models"
-	"golang.org/x/crypto/bcrypt"
 
+	"github.com/beego/beego/v2/client/orm"
 	beego "github.com/beego/beego/v2/server/web"
 )
 
@@ -18,17 +25,25 @@ type AuthController struct {
 }
 
 func (c *AuthController) URLMapping() {
-	c.Mapping("Register", c.Register) // Maps POST /register to Register method
-	c.Mapping("Login", c.Login)       // Maps POST /login to Login method
+	c.Mapping("Register", c.Register)             // Maps POST /register to Register method
+	c.Mapping("Login", c.Login)                   // Maps POST /login to Login method
+	c.Mapping("ForgotPassword", c.ForgotPassword) // Maps POST /forgot-password to ForgotPassword method
+	c.Mapping("ResetPassword", c.ResetPassword)   // Maps POST /reset-password to ResetPassword method
+
+	c.Mapping("VerifyEmail", c.VerifyEmail)               // Maps GET /verify-email to VerifyEmail method
+	c.Mapping("ResendVerification", c.ResendVerification) // Maps POST /resend-verification to ResendVerification method
+	c.Mapping("VerifyTwoFactor", c.VerifyTwoFactor)       // Maps POST /2fa/verify to VerifyTwoFactor method
+	c.Mapping("OidcLogin", c.OidcLogin)                   // Maps GET /oidc/login to OidcLogin method
+	c.Mapping("OidcCallback", c.OidcCallback)             // Maps GET /oidc/callback to OidcCallback method
 }
 
 // @Title Register User
-// @Description Register a new user with the provided data
+// @Description Register a new user with the provided data. The account can be used once the email is verified with the link sent to it.
 // @Accept  json
 // @Produce  json
 // @Param registerRequest body dto.RegisterRequest true "Registration Data"
 // @Success 201 {object} dto.UserResponse "User registered successfully"
-// @Failure 400 Invalid input data
+// @Failure 400 Invalid input data or email domain not allowed for the department
 // @Failure 500 Failed to register user
 // @router /regis [post]
 func (c *AuthController) Register() {
@@ -51,6 +66,12 @@ func (c *AuthController) Register() {
 		return
 	}
 
+	// Check the email against the domains the department allows.
+	if !department.AllowsEmail(req.Email) {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Email domain is not allowed for this department", fmt.Errorf("allowed domains: %s", strings.Join(department.GetAllowedEmailDomains(), ", ")))
+		return
+	}
+
 	// Hash the password before saving.
 	hashedPassword, err := helpers.HashPassword(req.Password)
 	if err != nil {
@@ -61,25 +82,40 @@ func (c *AuthController) Register() {
 	// Convert the request data to a user model.
 	user := req.ToUserModel(department)
 	user.Password = hashedPassword
+	user.EmailVerified = false
 
-	// Create the user in the database.
-	if err := models.CreateUser(user); err != nil {
+	// Create the user in the database together with its event.
+	userCreated := helpers.NewDomainEvent(constants.EventUserCreated, func() interface{} {
+		return dto.FromUserModelToUserResponse(user, false, false, false)
+	})
+	if err := models.CreateUser(user, userCreated); err != nil {
 		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to register user", err)
 		return
 	}
 
+	// Send the verification link in the background, it can be requested again if the email doesn't arrive.
+	go func() {
+		if err := helpers.SendEmailVerification(user); err != nil {
+			log.Printf("Failed to send email verification to user %d: %v", user.Id, err)
+		}
+	}()
+
 	// Return the registered user.
-	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusCreated, "User registered successfully", map[string]interface{}{"users": dto.FromUserModelToRegisterResponse(user)})
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusCreated, "User registered successfully, check your email to verify the address", map[string]interface{}{"users": dto.FromUserModelToRegisterResponse(user)})
 }
 
 // @Title User Login
-// @Description Authenticate user and generate a JWT token
+// @Description Authenticate user and generate a JWT token. With the ldap auth_backend the directory checks the password and syncs the account. Users with two-factor authentication, or whose role requires it, receive a pre-auth token to complete the login with /auth/2fa/verify or to set up two-factor authentication.
 // @Accept  json
 // @Produce  json
 // @Param loginRequest body dto.LoginRequest true "Login Data"
 // @Success 200 {object} dto.LoginResponse "Login successful"
 // @Failure 400 Invalid credentials
+// @Failure 403 Email address not verified or account deactivated
+// @Failure 423 Account temporarily locked after too many failed passwords
+// @Failure 429 Too many failed login attempts, retry after the delay in the Retry-After header
 // @Failure 500 Failed to generate token
+// @Failure 502 Directory unavailable
 // @router /login [post]
 func (c *AuthController) Login() {
 	var req dto.LoginRequest
@@ -94,26 +130,426 @@ func (c *AuthController) Login() {
 		return
 	}
 
-	// Fetch the user by email.
+	now := time.Now()
+	ip, userAgent := c.Ctx.Input.IP(), c.Ctx.Input.UserAgent()
+	policy := helpers.GetLoginPolicy()
+
+	// Reject IPs that guessed too many passwords recently.
+	blocked, err := policy.IsIpBlocked(ip, now)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to check login attempts", err)
+		return
+	}
+	if blocked {
+		helpers.RecordLoginAttempt(nil, req.Email, ip, userAgent, false, constants.LoginReasonIpBlocked)
+		c.Ctx.Output.Header("Retry-After", strconv.Itoa(int(policy.IpWindow.Seconds())))
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusTooManyRequests, "Too many failed login attempts", errors.New("try again later"))
+		return
+	}
+
+	// Fetch the user by email, users of the directory may not have been synced yet.
+	var account *models.User
 	user, err := models.GetUserByEmail(req.Email)
+	if err == nil {
+		account = &user
+	} else if err != orm.ErrNoRows {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch user", err)
+		return
+	}
+
+	// Locked accounts and accounts that have to wait after a failed password are rejected before the password is checked.
+	if account != nil {
+		if retryAfter, reason := policy.AccountRetryAfter(account, now); retryAfter > 0 {
+			helpers.RecordLoginAttempt(account, req.Email, ip, userAgent, false, reason)
+			c.Ctx.Output.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			if reason == constants.LoginReasonLocked {
+				helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusLocked, "Account temporarily locked", errors.New("too many failed login attempts"))
+				return
+			}
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusTooManyRequests, "Too many failed login attempts", fmt.Errorf("try again in %d seconds", int(math.Ceil(retryAfter.Seconds()))))
+			return
+		}
+	}
+
+	// Verify the password with the configured backend.
+	authenticated, err := helpers.AuthenticatePassword(account, req.Email, req.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, helpers.ErrUnknownAccount), errors.Is(err, helpers.ErrInvalidCredentials):
+			if account == nil {
+				helpers.RecordLoginAttempt(nil, req.Email, ip, userAgent, false, constants.LoginReasonUnknownEmail)
+			} else {
+				helpers.RecordLoginAttempt(account, req.Email, ip, userAgent, false, constants.LoginReasonInvalidPassword)
+				if err := models.RegisterFailedLogin(account, now, policy.MaxFailures, policy.Lockout); err != nil {
+					log.Printf("Failed to count failed login of user %d: %v", account.Id, err)
+				}
+			}
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Invalid credentials", nil)
+		case errors.Is(err, helpers.ErrLDAPAccountLinked), errors.Is(err, helpers.ErrLDAPNoDepartment):
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusForbidden, "Account can't be signed in with the directory", err)
+		default:
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadGateway, "Failed to authenticate with the directory", err)
+		}
+		return
+	}
+	if err := models.ResetFailedLogins(authenticated); err != nil {
+		log.Printf("Failed to reset failed logins of user %d: %v", authenticated.Id, err)
+	}
+
+	// Only verified accounts can log in.
+	if !authenticated.EmailVerified {
+		helpers.RecordLoginAttempt(authenticated, req.Email, ip, userAgent, false, constants.LoginReasonUnverified)
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusForbidden, "Email address not verified", errors.New("verify the email address with the link sent to it"))
+		return
+	}
+
+	c.continueLogin(authenticated)
+}
+
+// @Title OIDC Login
+// @Description Start the single sign-on at the identity provider. Redirects to the provider, which returns to /auth/oidc/callback.
+// @Success 302 Redirect to the identity provider
+// @Failure 404 Single sign-on is not configured
+// @Failure 502 Identity provider unavailable
+// @router /oidc/login [get]
+func (c *AuthController) OidcLogin() {
+	client := helpers.GetOIDCClient()
+	if client == nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "Single sign-on is not configured", errors.New("oidc_issuer is not set"))
+		return
+	}
+
+	authorizationUrl, err := helpers.StartOIDCLogin(client, time.Now())
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadGateway, "Failed to start single sign-on", err)
+		return
+	}
+
+	c.Redirect(authorizationUrl, http.StatusFound)
+}
+
+// @Title OIDC Callback
+// @Description Complete the single sign-on with the authorization code returned by the identity provider. Unknown users are provisioned, existing accounts are linked by their verified email.
+// @Produce  json
+// @Param code query string true "Authorization code"
+// @Param state query string true "State of the started login"
+// @Success 200 {object} dto.LoginResponse "Login successful"
+// @Failure 400 Invalid or expired login state
+// @Failure 401 Sign-in rejected by the identity provider or invalid ID token
+// @Failure 403 Account can't be linked or provisioned
+// @Failure 404 Single sign-on is not configured
+// @router /oidc/callback [get]
+func (c *AuthController) OidcCallback() {
+	client := helpers.GetOIDCClient()
+	if client == nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "Single sign-on is not configured", errors.New("oidc_issuer is not set"))
+		return
+	}
+
+	// The provider reports a cancelled or refused sign-in with an error parameter.
+	if providerError := c.GetString("error"); providerError != "" {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Sign-in rejected by the identity provider", fmt.Errorf("%s: %s", providerError, c.GetString("error_description")))
+		return
+	}
+
+	user, err := helpers.CompleteOIDCLogin(client, c.GetString("state"), c.GetString("code"), time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrOidcStateInvalid):
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid or expired login state", err)
+		case errors.Is(err, helpers.ErrOIDCEmailNotVerified), errors.Is(err, helpers.ErrOIDCAccountLinked), errors.Is(err, helpers.ErrOIDCNoDepartment):
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusForbidden, "Account can't be signed in with single sign-on", err)
+		default:
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Single sign-on failed", err)
+		}
+		return
+	}
+
+	// Locked accounts stay locked, the lock protects the account and not only its password.
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		helpers.RecordLoginAttempt(user, user.Email, c.Ctx.Input.IP(), c.Ctx.Input.UserAgent(), false, constants.LoginReasonLocked)
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusLocked, "Account temporarily locked", errors.New("too many failed login attempts"))
+		return
+	}
+
+	c.continueLogin(user)
+}
+
+// continueLogin completes the login of a user who proved their identity. Users with two-factor authentication,
+// or whose role requires it, continue with a pre-auth token.
+func (c *AuthController) continueLogin(user *models.User) {
+	// Deactivated accounts keep their history but can't log in.
+	if !user.IsActive() {
+		helpers.RecordLoginAttempt(user, user.Email, c.Ctx.Input.IP(), c.Ctx.Input.UserAgent(), false, constants.LoginReasonDeactivated)
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusForbidden, "Account deactivated", errors.New("the account was deactivated"))
+		return
+	}
+
+	// Service accounts authenticate with API keys only.
+	if user.ServiceAccount {
+		helpers.RecordLoginAttempt(user, user.Email, c.Ctx.Input.IP(), c.Ctx.Input.UserAgent(), false, constants.LoginReasonServiceAccount)
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusForbidden, "Service accounts can't log in", errors.New("use an API key of the service account"))
+		return
+	}
+
+	if !user.TwoFactorEnabled && !helpers.IsTwoFactorRequired(user.Role) {
+		c.completeLogin(user)
+		return
+	}
+
+	token, err := helpers.GeneratePreAuthJWT(user.Id, user.Email, user.Role, user.TokenVersion)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to generate token", err)
+		return
+	}
+	helpers.RecordLoginAttempt(user, user.Email, c.Ctx.Input.IP(), c.Ctx.Input.UserAgent(), true, constants.LoginReasonTwoFactorPending)
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Two-factor authentication required", dto.LoginResponse{
+		Token:                  token,
+		MustChangePassword:     user.MustChangePassword,
+		TwoFactorRequired:      user.TwoFactorEnabled,
+		TwoFactorSetupRequired: !user.TwoFactorEnabled,
+	})
+}
+
+// @Title Verify Two-Factor
+// @Description Complete the login of a user with two-factor authentication with a code of the authenticator app or a recovery code
+// @Accept  json
+// @Produce  json
+// @Param twoFactorVerifyRequest body dto.TwoFactorVerifyRequest true "Pre-auth token and code"
+// @Success 200 {object} dto.LoginResponse "Login successful"
+// @Failure 400 Invalid input data
+// @Failure 401 Invalid or expired token or code
+// @Failure 429 Too many attempts
+// @Failure 500 Failed to generate token
+// @router /2fa/verify [post]
+func (c *AuthController) VerifyTwoFactor() {
+	var req dto.TwoFactorVerifyRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	// Validate the request payload.
+	if errorsMap, err := helpers.ValidatePayloads(req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, constants.ErrValidationMessage, errorsMap)
+		return
+	}
+
+	// Only pre-auth tokens of the current token version are accepted.
+	claims, err := helpers.ParseJWT(req.Token)
+	if err != nil || helpers.GetScopeFromMapClaims(claims) != constants.TokenScopePreAuth {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Unauthorized or expired token", errors.New("invalid pre-auth token"))
+		return
+	}
+	userId, err := helpers.GetUseridFromMapClaims(claims)
 	if err != nil {
-		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Invalid credentials", nil)
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Unauthorized or expired token", err)
+		return
+	}
+	user, err := models.GetUserAccountById(userId)
+	if err != nil || helpers.GetTokenVersionFromMapClaims(claims) != user.TokenVersion || !user.IsActive() {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Unauthorized or expired token", errors.New("invalid pre-auth token"))
+		return
+	}
+	if !user.TwoFactorEnabled {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Two-factor authentication is not enabled", errors.New("set up two-factor authentication first"))
 		return
 	}
 
-	// Verify the password.
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Invalid credentials", nil)
+	// Limit the guesses per user.
+	if !helpers.AllowTwoFactorAttempt(user.Id, time.Now()) {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusTooManyRequests, "Too many attempts", errors.New("try again later"))
 		return
 	}
 
+	valid, err := helpers.VerifyTwoFactorCode(user, req.Code, time.Now())
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to verify code", err)
+		return
+	}
+	if !valid {
+		helpers.RecordLoginAttempt(user, user.Email, c.Ctx.Input.IP(), c.Ctx.Input.UserAgent(), false, constants.LoginReasonInvalidCode)
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Invalid two-factor code", errors.New("the code is wrong, expired or was already used"))
+		return
+	}
+
+	c.completeLogin(user)
+}
+
+// completeLogin hands out a token with full access and records the login
+func (c *AuthController) completeLogin(user *models.User) {
 	// Generate a JWT token for the user.
-	token, err := helpers.GenerateJWT(user.Id, user.Email, user.Role)
+	token, err := helpers.GenerateJWT(user.Id, user.Email, user.Role, user.TokenVersion)
 	if err != nil {
 		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to generate token", err)
 		return
 	}
 
+	// Record the login, the token is still handed out if the event can't be recorded.
+	helpers.RecordLoginAttempt(user, user.Email, c.Ctx.Input.IP(), c.Ctx.Input.UserAgent(), true, constants.LoginReasonLoggedIn)
+	userLoggedIn := helpers.NewDomainEvent(constants.EventUserLoggedIn, func() interface{} {
+		return map[string]interface{}{"id": user.Id, "email": user.Email, "role": user.Role}
+	})
+	if err := models.RecordEvents(userLoggedIn); err != nil {
+		log.Printf("Failed to record login of user %d: %v", user.Id, err)
+	}
+
 	// Return the token.
-	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Login successful", dto.LoginResponse{Token: token})
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Login successful", dto.LoginResponse{Token: token, MustChangePassword: user.MustChangePassword})
+}
+
+// @Title Forgot Password
+// @Description Email a single-use password reset link. The response is the same whether or not the email is registered.
+// @Accept  json
+// @Produce  json
+// @Param forgotPasswordRequest body dto.ForgotPasswordRequest true "Email of the account"
+// @Success 200 {object} helpers.BaseResponse "Password reset requested"
+// @Failure 400 Invalid input data
+// @Failure 429 Too many password reset requests
+// @router /forgot-password [post]
+func (c *AuthController) ForgotPassword() {
+	var req dto.ForgotPasswordRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	// Validate the request payload.
+	if errorsMap, err := helpers.ValidatePayloads(req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, constants.ErrValidationMessage, errorsMap)
+		return
+	}
+
+	// Limit the requests per email, registered or not.
+	if !helpers.AllowPasswordResetRequest(req.Email, time.Now()) {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusTooManyRequests, "Too many password reset requests", errors.New("try again later"))
+		return
+	}
+
+	// Look up the account and send the email in the background, so the response doesn't reveal whether it exists.
+	go helpers.SendPasswordResetToken(req.Email)
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "If the email is registered, a password reset link has been sent", nil)
+}
+
+// @Title Reset Password
+// @Description Set a new password with the token from the reset email. The token can be used once.
+// @Accept  json
+// @Produce  json
+// @Param resetPasswordRequest body dto.ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} helpers.BaseResponse "Password reset successfully"
+// @Failure 400 Invalid input data, invalid token or recently used password
+// @Failure 500 Failed to reset password
+// @router /reset-password [post]
+func (c *AuthController) ResetPassword() {
+	var req dto.ResetPasswordRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	// Validate the request payload.
+	if errorsMap, err := helpers.ValidatePayloads(req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, constants.ErrValidationMessage, errorsMap)
+		return
+	}
+
+	// Look up the token, so the new password can be checked against the previous ones of its owner.
+	resetToken, err := models.GetValidPasswordResetToken(helpers.HashToken(req.Token), time.Now())
+	if err != nil {
+		if errors.Is(err, models.ErrPasswordResetTokenInvalid) {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid or expired token", err)
+			return
+		}
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to reset password", err)
+		return
+	}
+	user, err := models.GetUserAccountById(resetToken.User.Id)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to reset password", err)
+		return
+	}
+	reused, err := helpers.IsPasswordReused(user, req.Password)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to reset password", err)
+		return
+	}
+	if reused {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Password was used recently", fmt.Errorf("the new password must differ from the last %d passwords", constants.PasswordHistorySize+1))
+		return
+	}
+
+	// Hash the new password.
+	hashedPassword, err := helpers.HashPassword(req.Password)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to hash password", err)
+		return
+	}
+
+	// Use the token and set the password, which also signs out the sessions of the user.
+	if _, err := models.ResetPasswordWithToken(helpers.HashToken(req.Token), hashedPassword, time.Now()); err != nil {
+		if errors.Is(err, models.ErrPasswordResetTokenInvalid) {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid or expired token", err)
+			return
+		}
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to reset password", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Password reset successfully", nil)
+}
+
+// @Title Verify Email
+// @Description Verify the email address with the signed link sent on registration
+// @Produce  json
+// @Param token query string true "Token from the verification email"
+// @Success 200 {object} helpers.BaseResponse "Email verified successfully"
+// @Failure 400 Invalid or expired token
+// @Failure 500 Failed to verify email
+// @router /verify-email [get]
+func (c *AuthController) VerifyEmail() {
+	if _, err := helpers.VerifyEmail(c.GetString("token"), time.Now()); err != nil {
+		if errors.Is(err, helpers.ErrEmailVerificationTokenInvalid) {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid or expired token", err)
+			return
+		}
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to verify email", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Email verified successfully", nil)
+}
+
+// @Title Resend Verification
+// @Description Email a new verification link. The response is the same whether or not the email is registered or already verified.
+// @Accept  json
+// @Produce  json
+// @Param resendVerificationRequest body dto.ResendVerificationRequest true "Email of the account"
+// @Success 200 {object} helpers.BaseResponse "Verification requested"
+// @Failure 400 Invalid input data
+// @Failure 429 Too many verification requests
+// @router /resend-verification [post]
+func (c *AuthController) ResendVerification() {
+	var req dto.ResendVerificationRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	// Validate the request payload.
+	if errorsMap, err := helpers.ValidatePayloads(req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, constants.ErrValidationMessage, errorsMap)
+		return
+	}
+
+	// Limit the requests per email, registered or not.
+	if !helpers.AllowEmailVerificationRequest(req.Email, time.Now()) {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusTooManyRequests, "Too many verification requests", errors.New("try again later"))
+		return
+	}
+
+	// Look up the account and send the email in the background, so the response doesn't reveal whether it exists.
+	go helpers.ResendEmailVerification(req.Email)
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "If the email is registered and not verified yet, a verification link has been sent", nil)
 }
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -28,6 +29,9 @@ func (c *DepartmentController) URLMapping() {
 	c.Mapping("Create", c.Create)   // Maps POST /departments to Create method for adding a new department
 	c.Mapping("Update", c.Update)   // Maps PUT /departments/:id to Update method for updating an existing department by ID
 	c.Mapping("Delete", c.Delete)   // Maps DELETE /departments/:id to Delete method for deleting a specific department by ID
+
+	c.Mapping("GetDeleted", c.GetDeleted) // Maps GET /departments/deleted to GetDeleted method for retrieving the deleted departments (admin only)
+	c.Mapping("Restore", c.Restore)       // Maps POST /departments/:id/restore to Restore method for restoring a deleted department (admin only)
 }
 
 // @Title GetAll
@@ -131,7 +135,10 @@ func (c *DepartmentController) Create() {
 
 	// Convert the request object to department model and create it in the database
 	department := req.ToDepartmentModel()
-	if err := models.CreateDepartment(department); err != nil {
+	audit := auditEntry(c.Ctx, constants.AuditActionCreate, constants.AuditEntityDepartment, func() (int, interface{}, interface{}) {
+		return department.Id, nil, dto.FromDepartmentModelToDepartmentResponse(department, false, false)
+	})
+	if err := models.CreateDepartment(department, audit); err != nil {
 		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to create department", err)
 		return
 	}
@@ -178,8 +185,12 @@ func (c *DepartmentController) Update() {
 	}
 
 	// Update department in the database
+	before := dto.FromDepartmentModelToDepartmentResponse(existedDepartment, false, false)
 	department := req.ToDepartmentModelWithValue(existedDepartment)
-	if err := models.UpdateDepartment(department); err != nil {
+	audit := auditEntry(c.Ctx, constants.AuditActionUpdate, constants.AuditEntityDepartment, func() (int, interface{}, interface{}) {
+		return department.Id, before, dto.FromDepartmentModelToDepartmentResponse(department, false, false)
+	})
+	if err := models.UpdateDepartment(department, audit); err != nil {
 		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to update department", err)
 		return
 	}
@@ -189,11 +200,13 @@ func (c *DepartmentController) Update() {
 }
 
 // @Title Delete
-// @Description Delete an existing department by ID.
-// @Param   id		path	int	true		"Department ID"
-// @Success 200 {string} "Department deleted successfully"
-// @Failure 400 Invalid department ID
+// @Description Delete an existing department by ID together with its schedules. Departments with users are only deleted when reassignTo names the department the users and schedules are moved to, in the same transaction. The department is hidden until an admin restores it or it is purged after the retention period.
+// @Param   id			path	int	true		"Department ID"
+// @Param   reassignTo	query	int	false		"Department the users and schedules are moved to"
+// @Success 200 {object} dto.DependentsResponse "Department deleted successfully"
+// @Failure 400 Invalid department ID or reassignTo department
 // @Failure 404 Department not found
+// @Failure 409 {object} dto.DependentsResponse "Department still has users"
 // @Failure 500 Internal server error
 // @router /:id [delete]
 func (c *DepartmentController) Delete() {
@@ -204,8 +217,52 @@ func (c *DepartmentController) Delete() {
 		return
 	}
 
-	// Delete department from database
-	affectedRows, err := models.DeleteDepartment(id)
+	// Fetch the department to record it in the audit log
+	department, err := models.GetDepartmentById(id, false, false)
+	if err == orm.ErrNoRows {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "Department not found", fmt.Errorf("department '%d' not found", id))
+		return
+	}
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch department", err)
+		return
+	}
+
+	// Fetch the department the users and schedules are moved to, if any
+	reassignToId, err := c.GetInt("reassignTo", 0)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for reassignTo", err)
+		return
+	}
+	var reassignTo *models.Department
+	if reassignToId != 0 {
+		if reassignToId == id {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for reassignTo", errors.New("users can't be reassigned to the deleted department"))
+			return
+		}
+		reassignTo, err = models.GetDepartmentById(reassignToId, false, false)
+		if err != nil {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for reassignTo", fmt.Errorf("department '%d' not found", reassignToId))
+			return
+		}
+	}
+
+	// Count the dependents to report them
+	dependents, err := models.CountDepartmentDependents(id)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to count department dependents", err)
+		return
+	}
+
+	// Delete department from database, the reassigned users and schedules are recorded in the audit log too
+	audit := auditEntry(c.Ctx, constants.AuditActionDelete, constants.AuditEntityDepartment, func() (int, interface{}, interface{}) {
+		return id, dto.FromDepartmentModelToDepartmentResponse(department, false, false), nil
+	})
+	affectedRows, err := models.DeleteDepartment(id, reassignTo, auditActor(c.Ctx), audit)
+	if err == models.ErrUsersAssigned {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusConflict, "Department still has users, reassign them with reassignTo", dto.FromDependentsModelToDependentsResponse(dependents, 0))
+		return
+	}
 	if err != nil {
 		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to delete department", err)
 		return
@@ -217,6 +274,60 @@ func (c *DepartmentController) Delete() {
 		return
 	}
 
-	// Return success response indicating department has been deleted
-	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Department deleted successfully", nil)
+	// Return success response with the reassigned and deleted dependents
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Department deleted successfully", dto.FromDependentsModelToDependentsResponse(dependents, reassignToId))
+}
+
+// @Title GetDeleted
+// @Description Retrieve the deleted departments, most recently deleted first (admin only)
+// @Produce  json
+// @Success 200 {object} dto.DepartmentResponse "Deleted departments retrieved successfully"
+// @Failure 500 Internal server error
+// @router /deleted [get]
+func (c *DepartmentController) GetDeleted() {
+	departments, err := models.GetDeletedDepartments()
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch deleted departments", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Deleted departments retrieved successfully", dto.FromDepartmentModelListToDepartmentResponseList(departments, false, false))
+}
+
+// @Title Restore
+// @Description Restore a deleted department (admin only)
+// @Produce  json
+// @Param   id		path	int	true		"Department ID"
+// @Success 200 {object} dto.DepartmentResponse "Department restored successfully"
+// @Failure 400 Invalid department ID
+// @Failure 404 Deleted department not found
+// @Failure 500 Internal server error
+// @router /:id/restore [post]
+func (c *DepartmentController) Restore() {
+	id, err := c.GetInt(":id")
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid department id", err)
+		return
+	}
+
+	department, err := models.GetDeletedDepartmentById(id)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "Deleted department not found", fmt.Errorf("deleted department '%d' not found", id))
+			return
+		}
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch department with id %d", id), err)
+		return
+	}
+
+	before := dto.FromDepartmentModelToDepartmentResponse(department, false, false)
+	audit := auditEntry(c.Ctx, constants.AuditActionRestore, constants.AuditEntityDepartment, func() (int, interface{}, interface{}) {
+		return department.Id, before, dto.FromDepartmentModelToDepartmentResponse(department, false, false)
+	})
+	if err := models.RestoreDepartment(department, audit); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to restore department", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Department restored successfully", dto.FromDepartmentModelToDepartmentResponse(department, false, false))
 }
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// LoginAttemptController handles the login audit records (admin only)
+type LoginAttemptController struct {
+	beego.Controller
+}
+
+// URLMapping maps HTTP methods to controller functions
+// This function binds the URLs for each handler to its corresponding method.
+func (c *LoginAttemptController) URLMapping() {
+	c.Mapping("GetAll", c.GetAll) // Maps GET /login-attempts to GetAll method for retrieving the login audit records
+}
+
+// @Title GetAll
+// @Description Retrieve the most recent login attempts with their outcome, IP and user agent.
+// @Produce  json
+// @Param   user_id	query	int		false		"Filter by user ID"
+// @Param   email	query	string	false		"Filter by email"
+// @Param   ip		query	string	false		"Filter by IP address"
+// @Param   status	query	string	false		"Filter by outcome: success or failure"
+// @Param   limit	query	int		false		"Maximum number of entries (default 50, max 500)"
+// @Success 200 {object} dto.LoginAttemptResponse "Login attempts retrieved successfully"
+// @Failure 400 Bad request
+// @Failure 500 Internal server error
+// @router / [get]
+func (c *LoginAttemptController) GetAll() {
+	userId, err := c.GetInt("user_id", 0)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for user_id", err)
+		return
+	}
+
+	var success *bool
+	status := c.GetString("status")
+	switch status {
+	case "":
+	case constants.LoginAttemptStatusSuccess, constants.LoginAttemptStatusFailure:
+		value := status == constants.LoginAttemptStatusSuccess
+		success = &value
+	default:
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for status", fmt.Errorf("unknown login attempt status: %s", status))
+		return
+	}
+
+	limit, err := c.GetInt("limit", constants.LoginAttemptDefaultLimit)
+	if err != nil || limit < 1 || limit > constants.LoginAttemptMaxLimit {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for limit", fmt.Errorf("limit must be between 1 and %d", constants.LoginAttemptMaxLimit))
+		return
+	}
+
+	attempts, err := models.GetLoginAttempts(userId, c.GetString("email"), c.GetString("ip"), success, limit)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch login attempts", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Login attempts retrieved successfully", dto.FromLoginAttemptModelListToLoginAttemptResponseList(attempts))
+}
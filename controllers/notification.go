@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// NotificationController handles the notification preferences of the authenticated user and the send log (admin only)
+type NotificationController struct {
+	beego.Controller
+}
+
+// URLMapping maps HTTP methods to controller functions
+// This function binds the URLs for each handler to its corresponding method.
+func (c *NotificationController) URLMapping() {
+	c.Mapping("GetPreferences", c.GetPreferences)       // Maps GET /notifications/preferences to GetPreferences method for retrieving the preferences of the authenticated user
+	c.Mapping("UpdatePreferences", c.UpdatePreferences) // Maps PUT /notifications/preferences to UpdatePreferences method for opting in or out of notifications
+	c.Mapping("GetLogs", c.GetLogs)                     // Maps GET /notifications/logs to GetLogs method for retrieving the send log (admin only)
+}
+
+// @Title GetPreferences
+// @Description Retrieve the notification preferences of the authenticated user. Notifications are enabled unless the user opted out.
+// @Produce  json
+// @Success 200 {object} dto.NotificationPreferencesResponse "Notification preferences retrieved successfully"
+// @Failure 401 Unauthorized
+// @Failure 500 Internal server error
+// @router /preferences [get]
+func (c *NotificationController) GetPreferences() {
+	userId, ok := c.Ctx.Input.GetData(constants.CtxAuthenticatedUserId).(int)
+	if !ok {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Bad context", errors.New("can't retrieve user id from context"))
+		return
+	}
+
+	preferences, err := models.GetNotificationPreferences(userId)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch notification preferences", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Notification preferences retrieved successfully", dto.FromNotificationPreferenceModelListToNotificationPreferencesResponse(preferences))
+}
+
+// @Title UpdatePreferences
+// @Description Opt in or out of notifications, omitted notification types are left unchanged.
+// @Accept  json
+// @Produce  json
+// @Param   body	body	dto.NotificationPreferencesRequest	true		"Notification preferences"
+// @Success 200 {object} dto.NotificationPreferencesResponse "Notification preferences updated successfully"
+// @Failure 400 Invalid input
+// @Failure 401 Unauthorized
+// @Failure 500 Internal server error
+// @router /preferences [put]
+func (c *NotificationController) UpdatePreferences() {
+	userId, ok := c.Ctx.Input.GetData(constants.CtxAuthenticatedUserId).(int)
+	if !ok {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Bad context", errors.New("can't retrieve user id from context"))
+		return
+	}
+
+	var req dto.NotificationPreferencesRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	if err := models.SaveNotificationPreferences(userId, req.ToPreferenceMap()); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to update notification preferences", err)
+		return
+	}
+
+	preferences, err := models.GetNotificationPreferences(userId)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch notification preferences", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Notification preferences updated successfully", dto.FromNotificationPreferenceModelListToNotificationPreferencesResponse(preferences))
+}
+
+// @Title GetLogs
+// @Description Retrieve the most recent notifications that were sent or failed to send.
+// @Produce  json
+// @Param   user_id	query	int		false		"Filter by recipient user ID"
+// @Param   type	query	string	false		"Filter by type: welcome, late_digest, missing_checkout, shift_reminder or missed_checkin"
+// @Param   status	query	string	false		"Filter by status: sent or failed"
+// @Param   limit	query	int		false		"Maximum number of entries (default 50, max 500)"
+// @Success 200 {object} dto.NotificationLogResponse "Notification log retrieved successfully"
+// @Failure 400 Bad request
+// @Failure 500 Internal server error
+// @router /logs [get]
+func (c *NotificationController) GetLogs() {
+	userId, err := c.GetInt("user_id", 0)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for user_id", err)
+		return
+	}
+
+	notificationType := c.GetString("type")
+	switch notificationType {
+	case "", constants.NotificationTypeWelcome, constants.NotificationTypeLateDigest, constants.NotificationTypeMissingCheckout,
+		constants.NotificationTypeShiftReminder, constants.NotificationTypeMissedCheckIn:
+	default:
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for type", fmt.Errorf("unknown notification type: %s", notificationType))
+		return
+	}
+
+	status := c.GetString("status")
+	switch status {
+	case "", constants.NotificationStatusSent, constants.NotificationStatusFailed:
+	default:
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for status", fmt.Errorf("unknown notification status: %s", status))
+		return
+	}
+
+	limit, err := c.GetInt("limit", constants.NotificationDefaultLogLimit)
+	if err != nil || limit < 1 || limit > constants.NotificationMaxLogLimit {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for limit", fmt.Errorf("limit must be between 1 and %d", constants.NotificationMaxLogLimit))
+		return
+	}
+
+	entries, err := models.GetNotificationLogs(userId, notificationType, status, limit)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch notification log", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Notification log retrieved successfully", dto.FromNotificationLogModelListToNotificationLogResponseList(entries))
+}
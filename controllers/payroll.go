@@ -0,0 +1,410 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/client/orm"
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// PayrollController handles payroll templates and the payroll period exports (admin only)
+type PayrollController struct {
+	beego.Controller
+}
+
+// URLMapping maps HTTP methods to controller functions
+// This function binds the URLs for each handler to its corresponding method.
+func (c *PayrollController) URLMapping() {
+	c.Mapping("GetAllTemplates", c.GetAllTemplates) // Maps GET /payroll/templates to GetAllTemplates method for retrieving all payroll templates
+	c.Mapping("GetTemplateById", c.GetTemplateById) // Maps GET /payroll/templates/:id to GetTemplateById method for retrieving a payroll template
+	c.Mapping("CreateTemplate", c.CreateTemplate)   // Maps POST /payroll/templates to CreateTemplate method for adding a payroll template
+	c.Mapping("UpdateTemplate", c.UpdateTemplate)   // Maps PUT /payroll/templates/:id to UpdateTemplate method for updating a payroll template
+	c.Mapping("DeleteTemplate", c.DeleteTemplate)   // Maps DELETE /payroll/templates/:id to DeleteTemplate method for deleting a payroll template
+	c.Mapping("GetAllExports", c.GetAllExports)     // Maps GET /payroll/exports to GetAllExports method for listing the exported periods
+	c.Mapping("CreateExport", c.CreateExport)       // Maps POST /payroll/exports to CreateExport method for exporting the payroll period of a department
+	c.Mapping("DownloadExport", c.DownloadExport)   // Maps GET /payroll/exports/:id/file to DownloadExport method for downloading an exported file
+	c.Mapping("DeleteExport", c.DeleteExport)       // Maps DELETE /payroll/exports/:id to DeleteExport method for allowing a period to be exported again
+}
+
+// @Title GetAllTemplates
+// @Description Retrieve all payroll templates.
+// @Produce  json
+// @Success 200 {object} dto.PayrollTemplateResponse "Payroll templates retrieved successfully"
+// @Failure 500 Internal server error
+// @router /templates [get]
+func (c *PayrollController) GetAllTemplates() {
+	templates, err := models.GetAllPayrollTemplates()
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch payroll templates", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Payroll templates retrieved successfully", dto.FromPayrollTemplateModelListToPayrollTemplateResponseList(templates))
+}
+
+// @Title GetTemplateById
+// @Description Retrieve a payroll template by ID.
+// @Produce  json
+// @Param   id		path	int	true		"Payroll template ID"
+// @Success 200 {object} dto.PayrollTemplateResponse "Payroll template retrieved successfully"
+// @Failure 400 Invalid payroll template ID
+// @Failure 404 Payroll template not found
+// @Failure 500 Internal server error
+// @router /templates/:id [get]
+func (c *PayrollController) GetTemplateById() {
+	template, ok := c.fetchTemplate(":id")
+	if !ok {
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Payroll template retrieved successfully", dto.FromPayrollTemplateModelToPayrollTemplateResponse(template))
+}
+
+// @Title CreateTemplate
+// @Description Create a payroll template describing the field mapping, rounding and lateness deductions of a payroll file.
+// @Accept  json
+// @Produce  json
+// @Param   body	body	dto.PayrollTemplateRequest	true		"Payroll template data"
+// @Success 201 {object} dto.PayrollTemplateResponse "Payroll template created successfully"
+// @Failure 400 Invalid input
+// @Failure 500 Internal server error
+// @router /templates [post]
+func (c *PayrollController) CreateTemplate() {
+	template, ok := c.parseTemplateRequest()
+	if !ok {
+		return
+	}
+
+	if err := models.CreatePayrollTemplate(template); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to create payroll template", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusCreated, "Payroll template created successfully", dto.FromPayrollTemplateModelToPayrollTemplateResponse(template))
+}
+
+// @Title UpdateTemplate
+// @Description Replace an existing payroll template. Files that were already exported are not affected.
+// @Accept  json
+// @Produce  json
+// @Param   id		path	int	true		"Payroll template ID"
+// @Param   body	body	dto.PayrollTemplateRequest	true		"Payroll template data"
+// @Success 200 {object} dto.PayrollTemplateResponse "Payroll template updated successfully"
+// @Failure 400 Invalid input
+// @Failure 404 Payroll template not found
+// @Failure 500 Internal server error
+// @router /templates/:id [put]
+func (c *PayrollController) UpdateTemplate() {
+	existedTemplate, ok := c.fetchTemplate(":id")
+	if !ok {
+		return
+	}
+
+	template, ok := c.parseTemplateRequest()
+	if !ok {
+		return
+	}
+	template.Id = existedTemplate.Id
+	template.CreatedAt = existedTemplate.CreatedAt
+
+	if err := models.UpdatePayrollTemplate(template); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to update payroll template", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Payroll template updated successfully", dto.FromPayrollTemplateModelToPayrollTemplateResponse(template))
+}
+
+// @Title DeleteTemplate
+// @Description Delete a payroll template by ID. Exports made with the template are kept.
+// @Param   id		path	int	true		"Payroll template ID"
+// @Success 200 {string} "Payroll template deleted successfully"
+// @Failure 400 Invalid payroll template ID
+// @Failure 404 Payroll template not found
+// @Failure 500 Internal server error
+// @router /templates/:id [delete]
+func (c *PayrollController) DeleteTemplate() {
+	id, err := c.GetInt(":id")
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid payroll template id", err)
+		return
+	}
+
+	affectedRows, err := models.DeletePayrollTemplate(id)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to delete payroll template", err)
+		return
+	}
+
+	if affectedRows == 0 {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "Payroll template not found", fmt.Errorf("payroll template '%d' not found", id))
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Payroll template deleted successfully", nil)
+}
+
+// @Title GetAllExports
+// @Description List the exported payroll periods, optionally filtered by department.
+// @Produce  json
+// @Param   department_id	query	int	false		"Department ID"
+// @Success 200 {object} dto.PayrollExportResponse "Payroll exports retrieved successfully"
+// @Failure 400 Bad request
+// @Failure 500 Internal server error
+// @router /exports [get]
+func (c *PayrollController) GetAllExports() {
+	departmentId, err := c.GetInt("department_id", 0)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for department_id", err)
+		return
+	}
+
+	exports, err := models.GetPayrollExports(departmentId)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch payroll exports", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Payroll exports retrieved successfully", dto.FromPayrollExportModelListToPayrollExportResponseList(exports))
+}
+
+// @Title CreateExport
+// @Description Generate the payroll file of a department for a finished month and record the period as exported. A period can only be exported once.
+// @Accept  json
+// @Produce  json
+// @Param   body	body	dto.PayrollExportRequest	true		"Payroll export data"
+// @Success 201 {object} dto.PayrollExportResponse "Payroll period exported successfully"
+// @Failure 400 Invalid input
+// @Failure 404 Payroll template or department not found
+// @Failure 409 Payroll period already exported
+// @Failure 500 Internal server error
+// @router /exports [post]
+func (c *PayrollController) CreateExport() {
+	// Parse request body to payroll export request object
+	var req dto.PayrollExportRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	// Validate payload for any errors
+	if errorsMap, err := helpers.ValidatePayloads(req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, constants.ErrValidationMessage, errorsMap)
+		return
+	}
+
+	// Only finished months can be exported, a running month would be locked with incomplete data
+	monthStart, err := helpers.ParseReportMonth(req.Period, time.Now())
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for period, expected YYYY-MM", err)
+		return
+	}
+	if monthStart.AddDate(0, 1, 0).After(time.Now()) {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Payroll period has not ended yet", fmt.Errorf("period '%s' has not ended yet", req.Period))
+		return
+	}
+
+	template, err := models.GetPayrollTemplateById(req.TemplateId)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "Payroll template not found", fmt.Errorf("payroll template '%d' not found", req.TemplateId))
+			return
+		}
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch payroll template", err)
+		return
+	}
+
+	department, err := models.GetDepartmentById(req.DepartmentId, false, false)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "Department not found", fmt.Errorf("department '%d' not found", req.DepartmentId))
+			return
+		}
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch department", err)
+		return
+	}
+
+	// Reject periods that were already exported before doing the work
+	if models.IsPayrollPeriodExported(department.Id, req.Period) {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusConflict, "Payroll period already exported", models.ErrPayrollPeriodExported)
+		return
+	}
+
+	// Compute the payroll record of every user of the department
+	users, err := models.GetUsersByDepartmentId(department.Id)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch department users", err)
+		return
+	}
+
+	records := make([]*helpers.PayrollRecord, 0, len(users))
+	for _, user := range users {
+		report, err := helpers.LoadMonthlyAttendance(user, monthStart, time.Now())
+		if err != nil {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, fmt.Sprintf("Failed to compute attendance of user %d", user.Id), err)
+			return
+		}
+
+		record, err := helpers.BuildPayrollRecord(user, report, template)
+		if err != nil {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to compute payroll record", err)
+			return
+		}
+		records = append(records, record)
+	}
+
+	var file bytes.Buffer
+	if err := helpers.WritePayrollFile(&file, template, records); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Failed to generate payroll file with this template", err)
+		return
+	}
+
+	// Record the export, the unique department and period pair guards against concurrent exports
+	export := &models.PayrollExport{
+		Template:    template,
+		Department:  department,
+		Period:      req.Period,
+		RecordCount: len(records),
+		Filename:    helpers.PayrollFilename(template, department.Id, req.Period),
+		Content:     file.String(),
+	}
+	if err := models.CreatePayrollExport(export); err != nil {
+		if errors.Is(err, models.ErrPayrollPeriodExported) {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusConflict, "Payroll period already exported", err)
+			return
+		}
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to record payroll export", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusCreated, "Payroll period exported successfully", dto.FromPayrollExportModelToPayrollExportResponse(export))
+}
+
+// @Title DownloadExport
+// @Description Download the file of a payroll export as it was generated.
+// @Produce  text/csv
+// @Produce  text/plain
+// @Param   id		path	int	true		"Payroll export ID"
+// @Success 200 {file} file "Payroll file"
+// @Failure 400 Invalid payroll export ID
+// @Failure 404 Payroll export not found
+// @Failure 500 Internal server error
+// @router /exports/:id/file [get]
+func (c *PayrollController) DownloadExport() {
+	id, err := c.GetInt(":id")
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid payroll export id", err)
+		return
+	}
+
+	export, err := models.GetPayrollExportById(id)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "Payroll export not found", fmt.Errorf("payroll export '%d' not found", id))
+			return
+		}
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch payroll export", err)
+		return
+	}
+
+	contentType := "text/plain; charset=utf-8"
+	if strings.HasSuffix(export.Filename, ".csv") {
+		contentType = constants.ExportMimeCSV + "; charset=utf-8"
+	}
+
+	c.Ctx.ResponseWriter.Header().Set("Content-Type", contentType)
+	c.Ctx.ResponseWriter.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, export.Filename))
+	c.Ctx.ResponseWriter.WriteHeader(http.StatusOK)
+	if _, err := c.Ctx.ResponseWriter.Write([]byte(export.Content)); err != nil {
+		log.Printf("Failed to write payroll export %d: %v", export.Id, err)
+	}
+}
+
+// @Title DeleteExport
+// @Description Delete a payroll export record so its period can be exported again, e.g. after correcting attendance data.
+// @Param   id		path	int	true		"Payroll export ID"
+// @Success 200 {string} "Payroll export deleted successfully"
+// @Failure 400 Invalid payroll export ID
+// @Failure 404 Payroll export not found
+// @Failure 500 Internal server error
+// @router /exports/:id [delete]
+func (c *PayrollController) DeleteExport() {
+	id, err := c.GetInt(":id")
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid payroll export id", err)
+		return
+	}
+
+	affectedRows, err := models.DeletePayrollExport(id)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to delete payroll export", err)
+		return
+	}
+
+	if affectedRows == 0 {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "Payroll export not found", fmt.Errorf("payroll export '%d' not found", id))
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Payroll export deleted successfully", nil)
+}
+
+// fetchTemplate reads the payroll template identified by the given path parameter.
+// It writes the error response itself and reports whether the caller may continue.
+func (c *PayrollController) fetchTemplate(param string) (*models.PayrollTemplate, bool) {
+	id, err := c.GetInt(param)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid payroll template id", err)
+		return nil, false
+	}
+
+	template, err := models.GetPayrollTemplateById(id)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "Payroll template not found", fmt.Errorf("payroll template '%d' not found", id))
+			return nil, false
+		}
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch payroll template with id %d", id), err)
+		return nil, false
+	}
+
+	return template, true
+}
+
+// parseTemplateRequest parses and validates the payroll template of the request body.
+// It writes the error response itself and reports whether the caller may continue.
+func (c *PayrollController) parseTemplateRequest() (*models.PayrollTemplate, bool) {
+	// Parse request body to payroll template request object
+	var req dto.PayrollTemplateRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid input", err)
+		return nil, false
+	}
+
+	// Validate payload for any errors
+	if errorsMap, err := helpers.ValidatePayloads(req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, constants.ErrValidationMessage, errorsMap)
+		return nil, false
+	}
+
+	// Validate the rules spanning several fields, e.g. buckets referenced by fields
+	template := req.ToPayrollTemplateModel()
+	if err := helpers.ValidatePayrollTemplate(template); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid payroll template", err)
+		return nil, false
+	}
+
+	return template, true
+}
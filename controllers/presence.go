@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/snykk/beego-presence-api/constants"
@@ -14,6 +16,7 @@ import (
 
 	"github.com/beego/beego/v2/client/orm"
 	beego "github.com/beego/beego/v2/server/web"
+	"github.com/gorilla/websocket"
 )
 
 // PresenceController handles requests related to presences (attendance) management.
@@ -26,15 +29,21 @@ type PresenceController struct {
 func (c *PresenceController) URLMapping() {
 	c.Mapping("GetAll", c.GetAll)   // Maps GET /presences to GetAll method for retrieving all presences or a user's presences based on the role
 	c.Mapping("GetById", c.GetById) // Maps GET /presences/:id to GetById method for retrieving a specific presence by ID
+	c.Mapping("Stream", c.Stream)   // Maps GET /presences/stream to Stream method for following new and updated presences live (SSE or WebSocket)
 	c.Mapping("Create", c.Create)   // Maps POST /presences to Create method for creating a new presence entry for a user (employee only)
 	c.Mapping("Update", c.Update)   // Maps PUT /presences/:id to Update method for updating an existing presence entry by ID (admin only)
 	c.Mapping("Delete", c.Delete)   // Maps DELETE /presences/:id to Delete method for deleting a specific presence entry by ID (admin only)
+	c.Mapping("History", c.History) // Maps GET /presences/:id/history to History method for retrieving the edit history of a presence
+	c.Mapping("Verify", c.Verify)   // Maps GET /presences/verify to Verify method for detecting changes made outside the API (admin only)
 }
 
 // @Title GetAll
 // @Description Retrieve all presences or the presences of a specific user based on the role.
 // @Param isIncludeUser query bool false "Include user data in the response"
 // @Param isIncludeSchedule query bool false "Include schedule data in the response"
+// @Param format query string false "Export format (csv or xlsx), also negotiated through the Accept header"
+// @Param columns query string false "Comma separated export columns, defaults to all columns"
+// @Param locale query string false "Locale of exported dates (e.g. en-US, en-GB, id-ID), defaults to ISO 8601"
 // @Success 200 {object} dto.PresenceResponseList "Success"
 // @Failure 400 Bad Request
 // @Failure 401 Unauthorized
@@ -67,10 +76,33 @@ func (c *PresenceController) GetAll() {
 		return
 	}
 
+	// Stream the presences as a file when CSV or XLSX is requested
+	format, err := helpers.NegotiateExportFormat(c.GetString("format"), c.Ctx.Input.Header("Accept"))
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for format", err)
+		return
+	}
+	if format != "" {
+		// Admin exports all presences, other users only their own
+		exportUserId := userId
+		if userRole == constants.RoleAdmin {
+			exportUserId = 0
+		}
+		c.exportPresences(format, exportUserId)
+		return
+	}
+
 	var presences []*models.Presence
 	if userRole == constants.RoleAdmin {
 		// Admin can fetch all presences
 		presences, err = models.GetAllPresences()
+	} else if userRole == constants.RoleManager {
+		// Managers can fetch the presences of their department
+		var user *models.User
+		user, err = models.GetUserById(userId, false)
+		if err == nil {
+			presences, err = models.GetPresencesByDepartmentId(user.Department.Id)
+		}
 	} else if userRole == constants.RoleEmployee {
 		// Employees can only fetch their own presences
 		presences, err = models.GetPresencesByUserId(userId)
@@ -162,6 +194,12 @@ func (c *PresenceController) Create() {
 		return
 	}
 
+	// Only verified accounts can check in
+	if !user.EmailVerified {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusForbidden, "Email address not verified", fmt.Errorf("user '%d' didn't verify the email address", userId))
+		return
+	}
+
 	// Check if the user is assigned to the specified schedule
 	if user.Schedule.Id != req.ScheduleId {
 		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "User is not assigned to the schedule", fmt.Errorf("user '%d' is not assigned to the schedule '%d'", userId, req.ScheduleId))
@@ -202,12 +240,26 @@ func (c *PresenceController) Create() {
 	}
 	presence.Status = status
 
-	// Save the presence to the database
-	if err := models.CreatePresence(presence); err != nil {
+	// Save the presence to the database together with its events and audit entry, late check-ins are announced separately
+	presenceData := func() interface{} {
+		return dto.FromPresenceModelToPresenceResponse(presence, true, false)
+	}
+	records := []models.ChangeRecord{
+		helpers.NewDomainEvent(constants.EventPresenceCreated, presenceData),
+		auditEntry(c.Ctx, constants.AuditActionCreate, constants.AuditEntityPresence, func() (int, interface{}, interface{}) {
+			return presence.Id, nil, dto.FromPresenceModelToPresenceResponse(presence, false, false)
+		}),
+	}
+	if presence.Status == constants.PresenceStatusLate {
+		records = append(records, helpers.NewDomainEvent(constants.EventPresenceLate, presenceData))
+	}
+	if err := models.CreatePresence(presence, helpers.SignPresenceRevision, records...); err != nil {
 		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to create presence", err)
 		return
 	}
 
+	helpers.PublishPresence(constants.EventPresenceCreated, presence)
+
 	// Return success response
 	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusCreated, "Presence created successfully", dto.FromPresenceModelToPresenceResponse(presence, false, false))
 }
@@ -267,14 +319,24 @@ func (c *PresenceController) Update() {
 	}
 
 	// Create the updated presence model
+	before := dto.FromPresenceModelToPresenceResponse(presence, false, false)
 	updatedPresence := req.ToPresenceModelWithValue(presence, user, schedule)
 
-	// Update the presence in the database
-	if err := models.UpdatePresence(updatedPresence); err != nil {
+	// Update the presence in the database, the previous state is kept in its history
+	actorId, _ := c.Ctx.Input.GetData(constants.CtxAuthenticatedUserId).(int)
+	presenceUpdated := helpers.NewDomainEvent(constants.EventPresenceUpdated, func() interface{} {
+		return dto.FromPresenceModelToPresenceResponse(updatedPresence, true, false)
+	})
+	audit := auditEntry(c.Ctx, constants.AuditActionUpdate, constants.AuditEntityPresence, func() (int, interface{}, interface{}) {
+		return updatedPresence.Id, before, dto.FromPresenceModelToPresenceResponse(updatedPresence, false, false)
+	})
+	if err := models.UpdatePresence(updatedPresence, actorId, helpers.SignPresenceRevision, presenceUpdated, audit); err != nil {
 		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to update presence", err)
 		return
 	}
 
+	helpers.PublishPresence(constants.EventPresenceUpdated, updatedPresence)
+
 	// Return success response
 	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Presence updated successfully", dto.FromPresenceModelToPresenceResponse(updatedPresence, false, false))
 }
@@ -296,8 +358,22 @@ func (c *PresenceController) Delete() {
 		return
 	}
 
-	// Delete the presence from the database
-	affectedRows, err := models.DeletePresence(id)
+	// Fetch the presence to record it in the audit log
+	presence, err := models.GetPresenceById(id)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "Presence not found", err)
+		return
+	}
+
+	// Delete the presence from the database, its history is kept
+	actorId, _ := c.Ctx.Input.GetData(constants.CtxAuthenticatedUserId).(int)
+	presenceDeleted := helpers.NewDomainEvent(constants.EventPresenceDeleted, func() interface{} {
+		return map[string]interface{}{"id": id}
+	})
+	audit := auditEntry(c.Ctx, constants.AuditActionDelete, constants.AuditEntityPresence, func() (int, interface{}, interface{}) {
+		return id, dto.FromPresenceModelToPresenceResponse(presence, false, false), nil
+	})
+	affectedRows, err := models.DeletePresence(id, actorId, helpers.SignPresenceRevision, presenceDeleted, audit)
 	if err != nil {
 		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to delete presence", err)
 		return
@@ -312,3 +388,308 @@ func (c *PresenceController) Delete() {
 	// Return success response
 	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Presence deleted successfully", nil)
 }
+
+// @Title History
+// @Description Retrieve the edit history of a presence, also after it was deleted, with the verification of its hash chain. Employees see the history of their own presences.
+// @Param id path int true "Presence ID"
+// @Success 200 {object} dto.PresenceHistoryResponse "Success"
+// @Failure 400 Bad Request
+// @Failure 401 Unauthorized
+// @Failure 404 Not Found
+// @Failure 500 Internal Server Error
+// @router /:id/history [get]
+func (c *PresenceController) History() {
+	userRole, ok := c.Ctx.Input.GetData(constants.CtxAuthenticatedUserRole).(string)
+	if !ok {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Bad context", errors.New("can't retrieve user role from context"))
+		return
+	}
+	userId, ok := c.Ctx.Input.GetData(constants.CtxAuthenticatedUserId).(int)
+	if !ok {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Bad context", errors.New("can't retrieve user id from context"))
+		return
+	}
+
+	id, err := c.GetInt(":id")
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid presence id", err)
+		return
+	}
+
+	// Fetch the history and the presence, which is gone once deleted
+	revisions, err := models.GetPresenceRevisions(id)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch presence history", err)
+		return
+	}
+	presence, err := models.GetPresenceById(id)
+	if err != nil && err != orm.ErrNoRows {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch presence", err)
+		return
+	}
+	if presence == nil && len(revisions) == 0 {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "Presence not found", fmt.Errorf("presence '%d' not found", id))
+		return
+	}
+
+	// Only admins see the history of other users' presences
+	var ownerId int
+	if presence != nil {
+		ownerId = presence.User.Id
+	} else {
+		ownerId = revisions[len(revisions)-1].UserId
+	}
+	if userRole != constants.RoleAdmin && ownerId != userId {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "Presence not found", fmt.Errorf("presence '%d' not found", id))
+		return
+	}
+
+	problems := helpers.VerifyPresenceHistory(id, presence, revisions)
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Presence history retrieved successfully", dto.FromPresenceRevisionModelListToPresenceHistoryResponse(id, revisions, problems))
+}
+
+// @Title Verify
+// @Description Verify the history of every presence, including deleted ones, to detect presences and revisions changed, inserted or deleted outside the API.
+// @Success 200 {object} dto.PresenceVerificationResponse "Success"
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 500 Internal Server Error
+// @router /verify [get]
+func (c *PresenceController) Verify() {
+	result, err := helpers.VerifyPresences()
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to verify presences", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Presences verified successfully", result)
+}
+
+// @Title Stream
+// @Description Follow new and updated presences live, as Server-Sent Events or over a WebSocket when the connection is upgraded.
+// @Description Admins receive every presence, managers those of their department and employees their own.
+// @Description Clients that can't set the Authorization header pass the token in the access_token query parameter.
+// @Produce text/event-stream
+// @Param access_token query string false "JWT, alternative to the Authorization header"
+// @Param Last-Event-ID header int false "Id of the last received event, the missed events are sent first"
+// @Param last_event_id query int false "Alternative to the Last-Event-ID header"
+// @Success 200 {object} dto.PresenceResponse "Stream of presence.created and presence.updated events"
+// @Failure 400 Bad Request
+// @Failure 401 Unauthorized
+// @Failure 500 Internal Server Error
+// @router /stream [get]
+func (c *PresenceController) Stream() {
+	filter, ok := c.resolveFeedFilter()
+	if !ok {
+		return
+	}
+
+	// Resume after the last received event, browsers send the header when an EventSource reconnects
+	lastEventIdParam := c.Ctx.Input.Header("Last-Event-ID")
+	if lastEventIdParam == "" {
+		lastEventIdParam = c.GetString(constants.PresenceFeedLastEventIdParam)
+	}
+	var lastEventId int64
+	if lastEventIdParam != "" {
+		var err error
+		lastEventId, err = strconv.ParseInt(lastEventIdParam, 10, 64)
+		if err != nil {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for Last-Event-ID", err)
+			return
+		}
+	}
+
+	if websocket.IsWebSocketUpgrade(c.Ctx.Request) {
+		c.streamWebSocket(filter, lastEventId)
+		return
+	}
+	c.streamEvents(filter, lastEventId)
+}
+
+// resolveFeedFilter limits the live feed to the presences the authenticated user may see
+func (c *PresenceController) resolveFeedFilter() (helpers.PresenceFeedFilter, bool) {
+	userRole, ok := c.Ctx.Input.GetData(constants.CtxAuthenticatedUserRole).(string)
+	if !ok {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Bad context", errors.New("can't retrieve user role from context"))
+		return helpers.PresenceFeedFilter{}, false
+	}
+
+	userId, ok := c.Ctx.Input.GetData(constants.CtxAuthenticatedUserId).(int)
+	if !ok {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Bad context", errors.New("can't retrieve user id from context"))
+		return helpers.PresenceFeedFilter{}, false
+	}
+
+	switch userRole {
+	case constants.RoleAdmin:
+		return helpers.PresenceFeedFilter{}, true
+	case constants.RoleManager:
+		user, err := models.GetUserById(userId, false)
+		if err != nil {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch user with id %d", userId), err)
+			return helpers.PresenceFeedFilter{}, false
+		}
+		return helpers.PresenceFeedFilter{DepartmentId: user.Department.Id}, true
+	default:
+		return helpers.PresenceFeedFilter{UserId: userId}, true
+	}
+}
+
+// streamEvents writes the feed as Server-Sent Events until the client disconnects
+func (c *PresenceController) streamEvents(filter helpers.PresenceFeedFilter, lastEventId int64) {
+	subscription, missed := helpers.GetPresenceFeed().Subscribe(filter, lastEventId)
+	defer subscription.Close()
+
+	w := c.Ctx.ResponseWriter
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable response buffering of nginx
+	w.WriteHeader(http.StatusOK)
+
+	writeEvent := func(event *helpers.PresenceFeedEvent) error {
+		_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Id, event.Event, event.Data)
+		return err
+	}
+
+	fmt.Fprint(w, "retry: 3000\n\n")
+	for _, event := range missed {
+		if err := writeEvent(event); err != nil {
+			return
+		}
+	}
+	w.Flush()
+
+	heartbeat := time.NewTicker(constants.PresenceFeedHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Ctx.Request.Context().Done():
+			return
+		case event, ok := <-subscription.Events:
+			if !ok {
+				// Dropped for falling behind, the client reconnects with its Last-Event-ID
+				return
+			}
+			if err := writeEvent(event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		w.Flush()
+	}
+}
+
+// presenceFeedUpgrader accepts cross-origin connections, clients authenticate with the token rather than cookies
+var presenceFeedUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// presenceFeedMessage is a WebSocket message of the live feed
+type presenceFeedMessage struct {
+	Id    int64           `json:"id"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// streamWebSocket upgrades the connection and writes the feed as JSON messages until the client disconnects
+func (c *PresenceController) streamWebSocket(filter helpers.PresenceFeedFilter, lastEventId int64) {
+	conn, err := presenceFeedUpgrader.Upgrade(c.Ctx.ResponseWriter, c.Ctx.Request, nil)
+	if err != nil {
+		// The upgrader already answered the request
+		log.Printf("Failed to upgrade presence feed connection: %v", err)
+		return
+	}
+	// The connection is hijacked, beego must not write a response anymore
+	c.Ctx.ResponseWriter.Started = true
+	defer conn.Close()
+
+	subscription, missed := helpers.GetPresenceFeed().Subscribe(filter, lastEventId)
+	defer subscription.Close()
+
+	// Messages from the client are ignored, reading only detects the close and answers pings
+	closed := make(chan struct{})
+	conn.SetReadLimit(512)
+	conn.SetReadDeadline(time.Now().Add(2 * constants.PresenceFeedHeartbeatInterval))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * constants.PresenceFeedHeartbeatInterval))
+	})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	writeEvent := func(event *helpers.PresenceFeedEvent) error {
+		conn.SetWriteDeadline(time.Now().Add(constants.PresenceFeedWriteTimeout))
+		return conn.WriteJSON(presenceFeedMessage{Id: event.Id, Event: event.Event, Data: event.Data})
+	}
+
+	for _, event := range missed {
+		if err := writeEvent(event); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(constants.PresenceFeedHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event, ok := <-subscription.Events:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow"), time.Now().Add(constants.PresenceFeedWriteTimeout))
+				return
+			}
+			if err := writeEvent(event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(constants.PresenceFeedWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// exportPresences streams the presences of a user (or of all users when userId is 0) as a CSV or XLSX file
+func (c *PresenceController) exportPresences(format string, userId int) {
+	columns, err := helpers.SelectExportColumns(helpers.PresenceExportColumns, c.GetString("columns"))
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for columns", err)
+		return
+	}
+	layouts := helpers.GetExportLayouts(c.GetString("locale"))
+
+	filename := fmt.Sprintf("presences-%s", time.Now().Format("20060102"))
+	writer, err := helpers.StartExport(c.Ctx.ResponseWriter, format, filename, columns)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to start the export", err)
+		return
+	}
+
+	// The response is already committed, so failures from here on can only be logged
+	err = models.ForEachPresenceBatch(userId, constants.ExportBatchSize, func(batch []*models.Presence) error {
+		for _, presence := range batch {
+			if err := writer.WriteRow(helpers.ExportRowValues(columns, helpers.PresenceExportRow(presence, layouts))); err != nil {
+				return err
+			}
+		}
+		return writer.Flush()
+	})
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		log.Printf("Failed to export presences: %v", err)
+	}
+}
@@ -0,0 +1,293 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/client/orm"
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// ReportController handles attendance reporting endpoints
+type ReportController struct {
+	beego.Controller
+}
+
+// URLMapping maps routes to specific handler functions for the ReportController
+// This is typically used by the Beego framework to map HTTP methods to controller methods.
+func (c *ReportController) URLMapping() {
+	c.Mapping("GetUserMonthly", c.GetUserMonthly)                 // Maps GET /reports/users/:id/monthly to GetUserMonthly method for a user's monthly attendance report
+	c.Mapping("GetUserTimesheet", c.GetUserTimesheet)             // Maps GET /reports/users/:id/timesheet to GetUserTimesheet method for a user's signable PDF timesheet
+	c.Mapping("GetDepartmentDashboard", c.GetDepartmentDashboard) // Maps GET /reports/departments/:id/dashboard to GetDepartmentDashboard method for a department's attendance dashboard (admin only)
+}
+
+// @Title GetUserMonthly
+// @Description Retrieve the monthly attendance report of a user. Employees can only retrieve their own report.
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param month query string false "Reported month (YYYY-MM), defaults to the current month"
+// @Param format query string false "Export format (csv or xlsx), also negotiated through the Accept header"
+// @Param columns query string false "Comma separated export columns, defaults to all columns"
+// @Param locale query string false "Locale of exported dates (e.g. en-US, en-GB, id-ID), defaults to ISO 8601"
+// @Success 200 {object} dto.MonthlyAttendanceResponse "Monthly report retrieved successfully"
+// @Failure 400 Bad Request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 User not found
+// @Failure 500 Internal Server Error
+// @router /users/:id/monthly [get]
+func (c *ReportController) GetUserMonthly() {
+	// Resolve whether the report is returned as JSON or exported as a file
+	format, err := helpers.NegotiateExportFormat(c.GetString("format"), c.Ctx.Input.Header("Accept"))
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for format", err)
+		return
+	}
+
+	report, ok := c.loadUserMonthlyReport()
+	if !ok {
+		return
+	}
+
+	// Export the daily rows as a file when requested
+	if format != "" {
+		filename := fmt.Sprintf("attendance-%d-%s", report.User.Id, report.Month)
+		c.exportRows(format, filename, helpers.AttendanceDayExportColumns, func(layouts helpers.ExportLayouts) []map[string]string {
+			rows := make([]map[string]string, 0, len(report.Days))
+			for _, day := range report.Days {
+				rows = append(rows, helpers.AttendanceDayExportRow(day, layouts))
+			}
+			return rows
+		})
+		return
+	}
+
+	// Return the computed report
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Monthly report retrieved successfully", report)
+}
+
+// @Title GetUserTimesheet
+// @Description Render the monthly attendance of a user as a PDF timesheet for signing. Employees can only retrieve their own timesheet.
+// @Produce  application/pdf
+// @Param id path int true "User ID"
+// @Param month query string false "Reported month (YYYY-MM), defaults to the current month"
+// @Success 200 {file} file "PDF timesheet"
+// @Failure 400 Bad Request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 User not found
+// @Failure 500 Internal Server Error
+// @router /users/:id/timesheet [get]
+func (c *ReportController) GetUserTimesheet() {
+	report, ok := c.loadUserMonthlyReport()
+	if !ok {
+		return
+	}
+
+	// Render the PDF in memory first so rendering errors can still be reported as JSON
+	var document bytes.Buffer
+	if err := helpers.RenderTimesheetPDF(&document, report, helpers.GetTimesheetCompany(), time.Now()); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to render timesheet", err)
+		return
+	}
+
+	c.Ctx.ResponseWriter.Header().Set("Content-Type", "application/pdf")
+	c.Ctx.ResponseWriter.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="timesheet-%d-%s.pdf"`, report.User.Id, report.Month))
+	c.Ctx.ResponseWriter.WriteHeader(http.StatusOK)
+	if _, err := document.WriteTo(c.Ctx.ResponseWriter); err != nil {
+		log.Printf("Failed to write timesheet: %v", err)
+	}
+}
+
+// @Title GetDepartmentDashboard
+// @Description Retrieve the attendance dashboard of a department over a date range.
+// @Produce  json
+// @Param id path int true "Department ID"
+// @Param from query string false "First reported day (YYYY-MM-DD), defaults to the first day of the current month"
+// @Param to query string false "Last reported day (YYYY-MM-DD), defaults to today"
+// @Param top query int false "Number of top late arrivals to return, defaults to 5"
+// @Param format query string false "Export format (csv or xlsx), also negotiated through the Accept header"
+// @Param columns query string false "Comma separated export columns, defaults to all columns"
+// @Param locale query string false "Locale of exported dates (e.g. en-US, en-GB, id-ID), defaults to ISO 8601"
+// @Success 200 {object} dto.DepartmentDashboardResponse "Department dashboard retrieved successfully"
+// @Failure 400 Bad Request
+// @Failure 401 Unauthorized
+// @Failure 403 Forbidden
+// @Failure 404 Department not found
+// @Failure 500 Internal Server Error
+// @router /departments/:id/dashboard [get]
+func (c *ReportController) GetDepartmentDashboard() {
+	// Fetch the department ID from the URL.
+	id, err := c.GetInt(":id")
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid department id", err)
+		return
+	}
+
+	// Resolve whether the dashboard is returned as JSON or exported as a file
+	format, err := helpers.NegotiateExportFormat(c.GetString("format"), c.Ctx.Input.Header("Accept"))
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for format", err)
+		return
+	}
+
+	// Parse the reported date range
+	from, to, err := helpers.ParseReportDateRange(c.GetString("from"), c.GetString("to"), time.Now())
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid date range", err)
+		return
+	}
+
+	top, err := c.GetInt("top", constants.ReportDefaultTopLateArrivals)
+	if err != nil || top < 1 || top > constants.ReportMaxTopLateArrivals {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for top", fmt.Errorf("top must be between 1 and %d", constants.ReportMaxTopLateArrivals))
+		return
+	}
+
+	// Fetch the department
+	department, err := models.GetDepartmentById(id, false, false)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "Department not found", fmt.Errorf("department '%d' not found", id))
+			return
+		}
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch department with id %d", id), err)
+		return
+	}
+
+	// Aggregate the dashboard figures in the database
+	headcount, err := models.CountUsersByDepartmentId(id)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to count department users", err)
+		return
+	}
+
+	days, err := models.GetDepartmentDailyAttendance(id, from, to)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to aggregate daily attendance", err)
+		return
+	}
+
+	stats, err := models.GetDepartmentCheckInStats(id, from, to)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to aggregate check-ins", err)
+		return
+	}
+
+	lateArrivals, err := models.GetDepartmentTopLateArrivals(id, from, to, top)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to aggregate late arrivals", err)
+		return
+	}
+
+	dashboard := dto.FromDepartmentDashboardModelsToDashboardResponse(department, from.Format(constants.ReportDateLayout), to.Format(constants.ReportDateLayout), headcount, days, stats, lateArrivals)
+
+	// Export the daily rows as a file when requested
+	if format != "" {
+		filename := fmt.Sprintf("department-%d-%s-%s", department.Id, dashboard.From, dashboard.To)
+		c.exportRows(format, filename, helpers.DepartmentDayExportColumns, func(layouts helpers.ExportLayouts) []map[string]string {
+			rows := make([]map[string]string, 0, len(dashboard.Days))
+			for _, day := range dashboard.Days {
+				rows = append(rows, helpers.DepartmentDayExportRow(day, layouts))
+			}
+			return rows
+		})
+		return
+	}
+
+	// Return the dashboard
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Department dashboard retrieved successfully", dashboard)
+}
+
+// exportRows writes report rows as a CSV or XLSX file restricted to the requested columns
+func (c *ReportController) exportRows(format, filename string, available []helpers.ExportColumn, buildRows func(layouts helpers.ExportLayouts) []map[string]string) {
+	columns, err := helpers.SelectExportColumns(available, c.GetString("columns"))
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for columns", err)
+		return
+	}
+
+	writer, err := helpers.StartExport(c.Ctx.ResponseWriter, format, filename, columns)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to start the export", err)
+		return
+	}
+
+	// The response is already committed, so failures from here on can only be logged
+	for _, row := range buildRows(helpers.GetExportLayouts(c.GetString("locale"))) {
+		if err = writer.WriteRow(helpers.ExportRowValues(columns, row)); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		log.Printf("Failed to export report: %v", err)
+	}
+}
+
+// loadUserMonthlyReport authorizes the request and computes the monthly report of the user in the URL.
+// It writes the error response itself and reports whether the caller may continue.
+func (c *ReportController) loadUserMonthlyReport() (*dto.MonthlyAttendanceResponse, bool) {
+	// Retrieve user role and ID from the context
+	userRole, ok := c.Ctx.Input.GetData(constants.CtxAuthenticatedUserRole).(string)
+	if !ok {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Bad context", errors.New("can't retrieve user role from context"))
+		return nil, false
+	}
+
+	userId, ok := c.Ctx.Input.GetData(constants.CtxAuthenticatedUserId).(int)
+	if !ok {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Bad context", errors.New("can't retrieve user id from context"))
+		return nil, false
+	}
+
+	// Fetch the user ID from the URL.
+	id, err := c.GetInt(":id")
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid user id", err)
+		return nil, false
+	}
+
+	// Employees can only retrieve their own report
+	if userRole != constants.RoleAdmin && userId != id {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusForbidden, "You are not allowed to view another user's report", errors.New("forbidden access"))
+		return nil, false
+	}
+
+	// Parse the reported month
+	monthStart, err := helpers.ParseReportMonth(c.GetString("month"), time.Now())
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for month, expected YYYY-MM", err)
+		return nil, false
+	}
+
+	// Fetch the user together with the assigned schedule
+	user, err := models.GetUserById(id, false)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "User not found", fmt.Errorf("user '%d' not found", id))
+			return nil, false
+		}
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch user with id %d", id), err)
+		return nil, false
+	}
+
+	report, err := helpers.LoadMonthlyAttendance(user, monthStart, time.Now())
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to compute monthly report", err)
+		return nil, false
+	}
+
+	return report, true
+}
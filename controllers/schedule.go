@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -28,6 +29,9 @@ func (c *ScheduleController) URLMapping() {
 	c.Mapping("Create", c.Create)   // Maps POST /schedules to Create method for adding a new schedule
 	c.Mapping("Update", c.Update)   // Maps PUT /schedules/:id to Update method for updating an existing schedule by ID
 	c.Mapping("Delete", c.Delete)   // Maps DELETE /schedules/:id to Delete method for deleting a specific schedule by ID
+
+	c.Mapping("GetDeleted", c.GetDeleted) // Maps GET /schedules/deleted to GetDeleted method for retrieving the deleted schedules (admin only)
+	c.Mapping("Restore", c.Restore)       // Maps POST /schedules/:id/restore to Restore method for restoring a deleted schedule (admin only)
 }
 
 // @Title GetAll
@@ -157,7 +161,10 @@ func (c *ScheduleController) Create() {
 	schedule.Department = department
 
 	// Create the new schedule in the database
-	if err := models.CreateSchedule(schedule); err != nil {
+	audit := auditEntry(c.Ctx, constants.AuditActionCreate, constants.AuditEntitySchedule, func() (int, interface{}, interface{}) {
+		return schedule.Id, nil, dto.FromScheduleModelToScheduleResponse(schedule, false, false, false)
+	})
+	if err := models.CreateSchedule(schedule, audit); err != nil {
 		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to create schedule", err)
 		return
 	}
@@ -215,10 +222,14 @@ func (c *ScheduleController) Update() {
 	}
 
 	// Apply the changes to the existing schedule
+	before := dto.FromScheduleModelToScheduleResponse(existedSchedule, false, false, false)
 	updatedSchedule := req.ToScheduleModelWithValue(existedSchedule, department)
 
 	// Save the updated schedule in the database
-	if err := models.UpdateSchedule(updatedSchedule); err != nil {
+	audit := auditEntry(c.Ctx, constants.AuditActionUpdate, constants.AuditEntitySchedule, func() (int, interface{}, interface{}) {
+		return updatedSchedule.Id, before, dto.FromScheduleModelToScheduleResponse(updatedSchedule, false, false, false)
+	})
+	if err := models.UpdateSchedule(updatedSchedule, audit); err != nil {
 		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to update schedule", err)
 		return
 	}
@@ -228,13 +239,15 @@ func (c *ScheduleController) Update() {
 }
 
 // @Title Delete
-// @Description Delete an existing schedule by ID
+// @Description Delete an existing schedule by ID. Schedules with users are only deleted when reassignTo names the schedule of the same department the users are moved to, in the same transaction. The schedule is hidden until an admin restores it or it is purged after the retention period, presences keep referencing it.
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Schedule ID"
-// @Success 200 {string} string "Schedule deleted successfully"
-// @Failure 400 Invalid schedule ID
+// @Param reassignTo query int false "Schedule of the same department the users are moved to"
+// @Success 200 {object} dto.DependentsResponse "Schedule deleted successfully"
+// @Failure 400 Invalid schedule ID or reassignTo schedule
 // @Failure 404 Schedule not found
+// @Failure 409 {object} dto.DependentsResponse "Schedule still has users"
 // @Failure 500 Failed to delete schedule
 // @router /:id [delete]
 func (c *ScheduleController) Delete() {
@@ -245,8 +258,56 @@ func (c *ScheduleController) Delete() {
 		return
 	}
 
-	// Delete the schedule from the database
-	affectedRows, err := models.DeleteSchedule(id)
+	// Fetch the schedule, the users can only be reassigned within its department
+	schedule, err := models.GetScheduleById(id, false, false)
+	if err == orm.ErrNoRows {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "Schedule not found", fmt.Errorf("schedule '%d' not found", id))
+		return
+	}
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch schedule", err)
+		return
+	}
+
+	// Fetch the schedule the users are moved to, if any
+	reassignToId, err := c.GetInt("reassignTo", 0)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for reassignTo", err)
+		return
+	}
+	var reassignTo *models.Schedule
+	if reassignToId != 0 {
+		if reassignToId == id {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for reassignTo", errors.New("users can't be reassigned to the deleted schedule"))
+			return
+		}
+		reassignTo, err = models.GetScheduleById(reassignToId, false, false)
+		if err != nil {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for reassignTo", fmt.Errorf("schedule '%d' not found", reassignToId))
+			return
+		}
+		if reassignTo.Department.Id != schedule.Department.Id {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for reassignTo", fmt.Errorf("schedule '%d' belongs to another department", reassignToId))
+			return
+		}
+	}
+
+	// Count the dependents to report them
+	dependents, err := models.CountScheduleDependents(id)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to count schedule dependents", err)
+		return
+	}
+
+	// Delete the schedule from the database, the reassigned users are recorded in the audit log too
+	audit := auditEntry(c.Ctx, constants.AuditActionDelete, constants.AuditEntitySchedule, func() (int, interface{}, interface{}) {
+		return id, dto.FromScheduleModelToScheduleResponse(schedule, false, false, false), nil
+	})
+	affectedRows, err := models.DeleteSchedule(id, reassignTo, auditActor(c.Ctx), audit)
+	if err == models.ErrUsersAssigned {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusConflict, "Schedule still has users, reassign them with reassignTo", dto.FromDependentsModelToDependentsResponse(dependents, 0))
+		return
+	}
 	if err != nil {
 		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to delete schedule", err)
 		return
@@ -258,6 +319,71 @@ func (c *ScheduleController) Delete() {
 		return
 	}
 
-	// Return success response indicating schedule was deleted.
-	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Schedule deleted successfully", nil)
+	// Return success response with the reassigned dependents
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Schedule deleted successfully", dto.FromDependentsModelToDependentsResponse(dependents, reassignToId))
+}
+
+// @Title GetDeleted
+// @Description Retrieve the deleted schedules, most recently deleted first (admin only)
+// @Produce  json
+// @Success 200 {object} dto.ScheduleResponse "Deleted schedules retrieved successfully"
+// @Failure 500 Failed to fetch deleted schedules
+// @router /deleted [get]
+func (c *ScheduleController) GetDeleted() {
+	schedules, err := models.GetDeletedSchedules()
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch deleted schedules", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Deleted schedules retrieved successfully", dto.FromScheduleModelListToScheduleResponseList(schedules, false, false, false))
+}
+
+// @Title Restore
+// @Description Restore a deleted schedule (admin only). The department of the schedule has to be restored first.
+// @Produce  json
+// @Param id path int true "Schedule ID"
+// @Success 200 {object} dto.ScheduleResponse "Schedule restored successfully"
+// @Failure 400 Invalid schedule ID
+// @Failure 404 Deleted schedule not found
+// @Failure 409 The department of the schedule is deleted
+// @Failure 500 Failed to restore schedule
+// @router /:id/restore [post]
+func (c *ScheduleController) Restore() {
+	id, err := c.GetInt(":id")
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid schedule ID", err)
+		return
+	}
+
+	schedule, err := models.GetDeletedScheduleById(id)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "Deleted schedule not found", fmt.Errorf("deleted schedule '%d' not found", id))
+			return
+		}
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch schedule with id %d", id), err)
+		return
+	}
+
+	// A restored schedule can't belong to a deleted department.
+	if _, err := models.GetDepartmentById(schedule.Department.Id, false, false); err != nil {
+		if err == orm.ErrNoRows {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusConflict, "Restore the department of the schedule first", fmt.Errorf("department '%d' is deleted", schedule.Department.Id))
+			return
+		}
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch department", err)
+		return
+	}
+
+	before := dto.FromScheduleModelToScheduleResponse(schedule, false, false, false)
+	audit := auditEntry(c.Ctx, constants.AuditActionRestore, constants.AuditEntitySchedule, func() (int, interface{}, interface{}) {
+		return schedule.Id, before, dto.FromScheduleModelToScheduleResponse(schedule, false, false, false)
+	})
+	if err := models.RestoreSchedule(schedule, audit); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to restore schedule", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Schedule restored successfully", dto.FromScheduleModelToScheduleResponse(schedule, false, false, false))
 }
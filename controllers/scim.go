@@ -0,0 +1,518 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/client/orm"
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// ScimController provisions users and departments (as groups) for the identity platform over SCIM 2.0
+type ScimController struct {
+	beego.Controller
+}
+
+// URLMapping maps HTTP methods to controller functions
+// This function binds the URLs for each handler to its corresponding method.
+func (c *ScimController) URLMapping() {
+	c.Mapping("GetServiceProviderConfig", c.GetServiceProviderConfig) // Maps GET /ServiceProviderConfig to GetServiceProviderConfig method
+	c.Mapping("GetUsers", c.GetUsers)                                 // Maps GET /Users to GetUsers method for listing users
+	c.Mapping("GetUser", c.GetUser)                                   // Maps GET /Users/:id to GetUser method
+	c.Mapping("CreateUser", c.CreateUser)                             // Maps POST /Users to CreateUser method
+	c.Mapping("ReplaceUser", c.ReplaceUser)                           // Maps PUT /Users/:id to ReplaceUser method
+	c.Mapping("PatchUser", c.PatchUser)                               // Maps PATCH /Users/:id to PatchUser method
+	c.Mapping("DeleteUser", c.DeleteUser)                             // Maps DELETE /Users/:id to DeleteUser method for deactivating a user
+	c.Mapping("GetGroups", c.GetGroups)                               // Maps GET /Groups to GetGroups method for listing departments
+	c.Mapping("GetGroup", c.GetGroup)                                 // Maps GET /Groups/:id to GetGroup method
+	c.Mapping("CreateGroup", c.CreateGroup)                           // Maps POST /Groups to CreateGroup method
+	c.Mapping("ReplaceGroup", c.ReplaceGroup)                         // Maps PUT /Groups/:id to ReplaceGroup method
+	c.Mapping("PatchGroup", c.PatchGroup)                             // Maps PATCH /Groups/:id to PatchGroup method
+	c.Mapping("DeleteGroup", c.DeleteGroup)                           // Maps DELETE /Groups/:id to DeleteGroup method
+}
+
+// @Title GetServiceProviderConfig
+// @Description Describe the SCIM features supported by the API
+// @Produce  application/scim+json
+// @Success 200 {object} map[string]interface{} "Service provider configuration"
+// @router /ServiceProviderConfig [get]
+func (c *ScimController) GetServiceProviderConfig() {
+	helpers.ScimResponse(c.Ctx.ResponseWriter, http.StatusOK, map[string]interface{}{
+		"schemas":        []string{constants.ScimSchemaServiceConfig},
+		"patch":          map[string]bool{"supported": true},
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": constants.ScimMaxCount},
+		"changePassword": map[string]bool{"supported": true},
+		"sort":           map[string]bool{"supported": false},
+		"etag":           map[string]bool{"supported": false},
+		"authenticationSchemes": []map[string]string{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "The scim_token configured in app.conf",
+		}},
+	})
+}
+
+// @Title GetUsers
+// @Description List the users, optionally filtered, e.g. userName eq "jane@example.com"
+// @Produce  application/scim+json
+// @Param   filter		query	string	false	"SCIM filter"
+// @Param   startIndex	query	int		false	"1-based index of the first user (default 1)"
+// @Param   count		query	int		false	"Maximum number of users (default 100, max 1000)"
+// @Success 200 {object} dto.ScimListResponse "Users"
+// @Failure 400 {object} dto.ScimErrorResponse "Invalid filter or pagination"
+// @Failure 401 {object} dto.ScimErrorResponse "Invalid SCIM token"
+// @router /Users [get]
+func (c *ScimController) GetUsers() {
+	cond, err := helpers.ParseScimFilterCondition(c.GetString("filter"), helpers.ScimUserFilterAttributes)
+	if err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, err)
+		return
+	}
+	startIndex, count, err := helpers.ParseScimPagination(c.GetString("startIndex"), c.GetString("count"))
+	if err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, err)
+		return
+	}
+
+	users, total, err := models.QueryScimUsers(cond, startIndex-1, count)
+	if err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, err)
+		return
+	}
+
+	baseUrl := helpers.ScimBaseUrl(c.Ctx.Request)
+	resources := make([]*dto.ScimUserResponse, 0, len(users))
+	for _, user := range users {
+		resources = append(resources, dto.FromUserModelToScimUserResponse(user, baseUrl))
+	}
+	helpers.ScimResponse(c.Ctx.ResponseWriter, http.StatusOK, dto.ScimListResponse{
+		Schemas:      []string{constants.ScimSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+// @Title GetUser
+// @Description Retrieve a user
+// @Produce  application/scim+json
+// @Param   id	path	string	true	"User ID"
+// @Success 200 {object} dto.ScimUserResponse "User"
+// @Failure 401 {object} dto.ScimErrorResponse "Invalid SCIM token"
+// @Failure 404 {object} dto.ScimErrorResponse "User not found"
+// @router /Users/:id [get]
+func (c *ScimController) GetUser() {
+	user, ok := c.getUser()
+	if !ok {
+		return
+	}
+	helpers.ScimResponse(c.Ctx.ResponseWriter, http.StatusOK, dto.FromUserModelToScimUserResponse(user, helpers.ScimBaseUrl(c.Ctx.Request)))
+}
+
+// @Title CreateUser
+// @Description Provision a user. The department is taken from the enterprise extension or scim_default_department, the role from the primary role.
+// @Accept  application/scim+json
+// @Produce  application/scim+json
+// @Param   body	body	dto.ScimUserRequest	true	"User"
+// @Success 201 {object} dto.ScimUserResponse "User created"
+// @Failure 400 {object} dto.ScimErrorResponse "Invalid user"
+// @Failure 401 {object} dto.ScimErrorResponse "Invalid SCIM token"
+// @Failure 409 {object} dto.ScimErrorResponse "userName already taken"
+// @router /Users [post]
+func (c *ScimController) CreateUser() {
+	var req dto.ScimUserRequest
+	if !c.parseBody(&req) {
+		return
+	}
+
+	user, err := helpers.SaveScimUser(nil, &req, auditActor(c.Ctx), time.Now())
+	if err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, err)
+		return
+	}
+
+	c.respondUser(http.StatusCreated, user.Id)
+}
+
+// @Title ReplaceUser
+// @Description Replace the attributes of a user. Users with active false are deactivated.
+// @Accept  application/scim+json
+// @Produce  application/scim+json
+// @Param   id		path	string				true	"User ID"
+// @Param   body	body	dto.ScimUserRequest	true	"User"
+// @Success 200 {object} dto.ScimUserResponse "User replaced"
+// @Failure 400 {object} dto.ScimErrorResponse "Invalid user"
+// @Failure 401 {object} dto.ScimErrorResponse "Invalid SCIM token"
+// @Failure 404 {object} dto.ScimErrorResponse "User not found"
+// @Failure 409 {object} dto.ScimErrorResponse "userName already taken"
+// @router /Users/:id [put]
+func (c *ScimController) ReplaceUser() {
+	user, ok := c.getUser()
+	if !ok {
+		return
+	}
+	var req dto.ScimUserRequest
+	if !c.parseBody(&req) {
+		return
+	}
+
+	if _, err := helpers.SaveScimUser(user, &req, auditActor(c.Ctx), time.Now()); err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, err)
+		return
+	}
+
+	c.respondUser(http.StatusOK, user.Id)
+}
+
+// @Title PatchUser
+// @Description Change attributes of a user, e.g. {"op": "replace", "path": "active", "value": false} to offboard the user
+// @Accept  application/scim+json
+// @Produce  application/scim+json
+// @Param   id		path	string					true	"User ID"
+// @Param   body	body	dto.ScimPatchRequest	true	"Operations"
+// @Success 200 {object} dto.ScimUserResponse "User changed"
+// @Failure 400 {object} dto.ScimErrorResponse "Invalid operation"
+// @Failure 401 {object} dto.ScimErrorResponse "Invalid SCIM token"
+// @Failure 404 {object} dto.ScimErrorResponse "User not found"
+// @Failure 409 {object} dto.ScimErrorResponse "userName already taken"
+// @router /Users/:id [patch]
+func (c *ScimController) PatchUser() {
+	user, ok := c.getUser()
+	if !ok {
+		return
+	}
+	req, ok := c.parsePatch()
+	if !ok {
+		return
+	}
+
+	// The operations are applied to the current state, which then replaces the user
+	replacement := dto.FromUserModelToScimUserResponse(user, "").ToScimUserRequest()
+	if err := helpers.ApplyScimUserPatch(replacement, req.Operations); err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, err)
+		return
+	}
+	if _, err := helpers.SaveScimUser(user, replacement, auditActor(c.Ctx), time.Now()); err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, err)
+		return
+	}
+
+	c.respondUser(http.StatusOK, user.Id)
+}
+
+// @Title DeleteUser
+// @Description Offboard a user. The user is deactivated and can't log in anymore, the presence history is kept.
+// @Param   id	path	string	true	"User ID"
+// @Success 204 User deactivated
+// @Failure 401 {object} dto.ScimErrorResponse "Invalid SCIM token"
+// @Failure 404 {object} dto.ScimErrorResponse "User not found"
+// @router /Users/:id [delete]
+func (c *ScimController) DeleteUser() {
+	user, ok := c.getUser()
+	if !ok {
+		return
+	}
+
+	if user.IsActive() {
+		before := dto.FromUserModelToUserResponse(user, false, false, false)
+		userUpdated := helpers.NewDomainEvent(constants.EventUserUpdated, func() interface{} {
+			return dto.FromUserModelToUserResponse(user, false, false, false)
+		})
+		audit := auditEntry(c.Ctx, constants.AuditActionUpdate, constants.AuditEntityUser, func() (int, interface{}, interface{}) {
+			return user.Id, before, dto.FromUserModelToUserResponse(user, false, false, false)
+		})
+		if err := models.DeactivateUser(user, time.Now(), userUpdated, audit); err != nil {
+			helpers.ScimErrorResponse(c.Ctx.ResponseWriter, err)
+			return
+		}
+	}
+
+	c.Ctx.ResponseWriter.WriteHeader(http.StatusNoContent)
+}
+
+// @Title GetGroups
+// @Description List the departments as groups, optionally filtered, e.g. displayName eq "Engineering"
+// @Produce  application/scim+json
+// @Param   filter				query	string	false	"SCIM filter"
+// @Param   startIndex			query	int		false	"1-based index of the first group (default 1)"
+// @Param   count				query	int		false	"Maximum number of groups (default 100, max 1000)"
+// @Param   excludedAttributes	query	string	false	"members to list the groups without their members"
+// @Success 200 {object} dto.ScimListResponse "Groups"
+// @Failure 400 {object} dto.ScimErrorResponse "Invalid filter or pagination"
+// @Failure 401 {object} dto.ScimErrorResponse "Invalid SCIM token"
+// @router /Groups [get]
+func (c *ScimController) GetGroups() {
+	cond, err := helpers.ParseScimFilterCondition(c.GetString("filter"), helpers.ScimGroupFilterAttributes)
+	if err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, err)
+		return
+	}
+	startIndex, count, err := helpers.ParseScimPagination(c.GetString("startIndex"), c.GetString("count"))
+	if err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, err)
+		return
+	}
+
+	departments, total, err := models.QueryScimDepartments(cond, startIndex-1, count)
+	if err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, err)
+		return
+	}
+
+	includeMembers := !strings.Contains(strings.ToLower(c.GetString("excludedAttributes")), "members")
+	baseUrl := helpers.ScimBaseUrl(c.Ctx.Request)
+	resources := make([]*dto.ScimGroupResponse, 0, len(departments))
+	for _, department := range departments {
+		if includeMembers {
+			if department.Users, err = models.GetDepartmentMembers(department.Id); err != nil {
+				helpers.ScimErrorResponse(c.Ctx.ResponseWriter, err)
+				return
+			}
+		}
+		resources = append(resources, dto.FromDepartmentModelToScimGroupResponse(department, baseUrl))
+	}
+	helpers.ScimResponse(c.Ctx.ResponseWriter, http.StatusOK, dto.ScimListResponse{
+		Schemas:      []string{constants.ScimSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+// @Title GetGroup
+// @Description Retrieve a department as group with its members
+// @Produce  application/scim+json
+// @Param   id	path	string	true	"Department ID"
+// @Success 200 {object} dto.ScimGroupResponse "Group"
+// @Failure 401 {object} dto.ScimErrorResponse "Invalid SCIM token"
+// @Failure 404 {object} dto.ScimErrorResponse "Group not found"
+// @router /Groups/:id [get]
+func (c *ScimController) GetGroup() {
+	department, ok := c.getDepartment()
+	if !ok {
+		return
+	}
+	c.respondGroup(http.StatusOK, department.Id)
+}
+
+// @Title CreateGroup
+// @Description Create a department, the members are moved into it
+// @Accept  application/scim+json
+// @Produce  application/scim+json
+// @Param   body	body	dto.ScimGroupRequest	true	"Group"
+// @Success 201 {object} dto.ScimGroupResponse "Group created"
+// @Failure 400 {object} dto.ScimErrorResponse "Invalid group"
+// @Failure 401 {object} dto.ScimErrorResponse "Invalid SCIM token"
+// @Failure 409 {object} dto.ScimErrorResponse "displayName already taken"
+// @router /Groups [post]
+func (c *ScimController) CreateGroup() {
+	var req dto.ScimGroupRequest
+	if !c.parseBody(&req) {
+		return
+	}
+
+	department, err := helpers.SaveScimGroup(nil, &req, auditActor(c.Ctx))
+	if err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, err)
+		return
+	}
+
+	c.respondGroup(http.StatusCreated, department.Id)
+}
+
+// @Title ReplaceGroup
+// @Description Rename a department and replace its members. Removed members are moved into scim_default_department.
+// @Accept  application/scim+json
+// @Produce  application/scim+json
+// @Param   id		path	string					true	"Department ID"
+// @Param   body	body	dto.ScimGroupRequest	true	"Group"
+// @Success 200 {object} dto.ScimGroupResponse "Group replaced"
+// @Failure 400 {object} dto.ScimErrorResponse "Invalid group"
+// @Failure 401 {object} dto.ScimErrorResponse "Invalid SCIM token"
+// @Failure 404 {object} dto.ScimErrorResponse "Group not found"
+// @Failure 409 {object} dto.ScimErrorResponse "displayName already taken"
+// @router /Groups/:id [put]
+func (c *ScimController) ReplaceGroup() {
+	department, ok := c.getDepartment()
+	if !ok {
+		return
+	}
+	var req dto.ScimGroupRequest
+	if !c.parseBody(&req) {
+		return
+	}
+
+	if _, err := helpers.SaveScimGroup(department, &req, auditActor(c.Ctx)); err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, err)
+		return
+	}
+
+	c.respondGroup(http.StatusOK, department.Id)
+}
+
+// @Title PatchGroup
+// @Description Rename a department or add and remove members. Removed members are moved into scim_default_department.
+// @Accept  application/scim+json
+// @Produce  application/scim+json
+// @Param   id		path	string					true	"Department ID"
+// @Param   body	body	dto.ScimPatchRequest	true	"Operations"
+// @Success 200 {object} dto.ScimGroupResponse "Group changed"
+// @Failure 400 {object} dto.ScimErrorResponse "Invalid operation"
+// @Failure 401 {object} dto.ScimErrorResponse "Invalid SCIM token"
+// @Failure 404 {object} dto.ScimErrorResponse "Group not found"
+// @Failure 409 {object} dto.ScimErrorResponse "displayName already taken"
+// @router /Groups/:id [patch]
+func (c *ScimController) PatchGroup() {
+	department, ok := c.getDepartment()
+	if !ok {
+		return
+	}
+	req, ok := c.parsePatch()
+	if !ok {
+		return
+	}
+
+	if err := helpers.ApplyScimGroupPatch(department, req.Operations, auditActor(c.Ctx)); err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, err)
+		return
+	}
+
+	c.respondGroup(http.StatusOK, department.Id)
+}
+
+// @Title DeleteGroup
+// @Description Delete a department without members
+// @Param   id	path	string	true	"Department ID"
+// @Success 204 Group deleted
+// @Failure 401 {object} dto.ScimErrorResponse "Invalid SCIM token"
+// @Failure 404 {object} dto.ScimErrorResponse "Group not found"
+// @Failure 409 {object} dto.ScimErrorResponse "The department still has users"
+// @router /Groups/:id [delete]
+func (c *ScimController) DeleteGroup() {
+	department, ok := c.getDepartment()
+	if !ok {
+		return
+	}
+
+	users, err := models.GetDepartmentMembers(department.Id)
+	if err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, err)
+		return
+	}
+	if len(users) > 0 {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, helpers.NewScimError(http.StatusConflict, "", "the department still has %d users, move them to another group first", len(users)))
+		return
+	}
+
+	audit := auditEntry(c.Ctx, constants.AuditActionDelete, constants.AuditEntityDepartment, func() (int, interface{}, interface{}) {
+		return department.Id, dto.FromDepartmentModelToDepartmentResponse(department, false, false), nil
+	})
+	_, err = models.DeleteDepartment(department.Id, nil, auditActor(c.Ctx), audit)
+	if err == models.ErrUsersAssigned {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, helpers.NewScimError(http.StatusConflict, "", "the department still has users, move them to another group first"))
+		return
+	}
+	if err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, err)
+		return
+	}
+
+	c.Ctx.ResponseWriter.WriteHeader(http.StatusNoContent)
+}
+
+// parseBody reads the JSON body of the request
+func (c *ScimController) parseBody(req interface{}) bool {
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, req); err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, helpers.NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidSyntax, "%s", err.Error()))
+		return false
+	}
+	return true
+}
+
+// parsePatch reads and validates a PATCH request
+func (c *ScimController) parsePatch() (*dto.ScimPatchRequest, bool) {
+	var req dto.ScimPatchRequest
+	if !c.parseBody(&req) {
+		return nil, false
+	}
+	if _, err := helpers.ValidatePayloads(req); err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, helpers.NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidSyntax, "%s", err.Error()))
+		return nil, false
+	}
+	return &req, true
+}
+
+// getUser retrieves the user of the id path parameter with its department
+func (c *ScimController) getUser() (*models.User, bool) {
+	id, err := c.GetInt(":id")
+	if err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, helpers.NewScimError(http.StatusNotFound, "", "user %s not found", c.Ctx.Input.Param(":id")))
+		return nil, false
+	}
+	user, err := models.GetUserById(id, false)
+	if err == orm.ErrNoRows {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, helpers.NewScimError(http.StatusNotFound, "", "user %d not found", id))
+		return nil, false
+	}
+	if err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, err)
+		return nil, false
+	}
+	return user, true
+}
+
+// getDepartment retrieves the department of the id path parameter
+func (c *ScimController) getDepartment() (*models.Department, bool) {
+	id, err := c.GetInt(":id")
+	if err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, helpers.NewScimError(http.StatusNotFound, "", "group %s not found", c.Ctx.Input.Param(":id")))
+		return nil, false
+	}
+	department, err := models.GetDepartmentById(id, false, false)
+	if err == orm.ErrNoRows {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, helpers.NewScimError(http.StatusNotFound, "", "group %d not found", id))
+		return nil, false
+	}
+	if err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, err)
+		return nil, false
+	}
+	return department, true
+}
+
+// respondUser responds with the saved user, read again with its department
+func (c *ScimController) respondUser(status, id int) {
+	user, err := models.GetUserById(id, false)
+	if err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, err)
+		return
+	}
+	resource := dto.FromUserModelToScimUserResponse(user, helpers.ScimBaseUrl(c.Ctx.Request))
+	c.Ctx.Output.Header("Location", resource.Meta.Location)
+	helpers.ScimResponse(c.Ctx.ResponseWriter, status, resource)
+}
+
+// respondGroup responds with the saved department, read again with its members
+func (c *ScimController) respondGroup(status, id int) {
+	department, err := models.GetDepartmentById(id, false, false)
+	if err == nil {
+		department.Users, err = models.GetDepartmentMembers(id)
+	}
+	if err != nil {
+		helpers.ScimErrorResponse(c.Ctx.ResponseWriter, err)
+		return
+	}
+	resource := dto.FromDepartmentModelToScimGroupResponse(department, helpers.ScimBaseUrl(c.Ctx.Request))
+	c.Ctx.Output.Header("Location", resource.Meta.Location)
+	helpers.ScimResponse(c.Ctx.ResponseWriter, status, resource)
+}
@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/client/orm"
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// ServiceAccountController handles the accounts of integrations and their API keys (admin only)
+type ServiceAccountController struct {
+	beego.Controller
+}
+
+// URLMapping maps HTTP methods to controller functions
+// This function binds the URLs for each handler to its corresponding method.
+func (c *ServiceAccountController) URLMapping() {
+	c.Mapping("GetAll", c.GetAll)             // Maps GET /service-accounts to GetAll method for retrieving all service accounts
+	c.Mapping("Create", c.Create)             // Maps POST /service-accounts to Create method for creating a service account
+	c.Mapping("Delete", c.Delete)             // Maps DELETE /service-accounts/:id to Delete method for deactivating a service account and revoking its keys
+	c.Mapping("GetApiKeys", c.GetApiKeys)     // Maps GET /service-accounts/:id/api-keys to GetApiKeys method for retrieving the keys of a service account
+	c.Mapping("CreateApiKey", c.CreateApiKey) // Maps POST /service-accounts/:id/api-keys to CreateApiKey method for creating a key of a service account
+}
+
+// @Title GetAll
+// @Description Retrieve all service accounts including deactivated ones
+// @Produce  json
+// @Success 200 {object} dto.ServiceAccountResponse "Service accounts retrieved successfully"
+// @Failure 500 Internal server error
+// @router / [get]
+func (c *ServiceAccountController) GetAll() {
+	users, err := models.GetServiceAccounts()
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch service accounts", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Service accounts retrieved successfully", dto.FromUserModelListToServiceAccountResponseList(users))
+}
+
+// @Title Create
+// @Description Create the account of an integration. Service accounts can't log in, they authenticate with API keys created for them.
+// @Accept  json
+// @Produce  json
+// @Param   body	body	dto.ServiceAccountRequest	true		"Service account data"
+// @Success 201 {object} dto.ServiceAccountResponse "Service account created successfully"
+// @Failure 400 Invalid input
+// @Failure 500 Internal server error
+// @router / [post]
+func (c *ServiceAccountController) Create() {
+	var req dto.ServiceAccountRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	// Validate payload for any errors
+	if errorsMap, err := helpers.ValidatePayloads(req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, constants.ErrValidationMessage, errorsMap)
+		return
+	}
+
+	department, err := models.GetDepartmentById(req.DepartmentId, false, false)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid department ID", err)
+		return
+	}
+
+	user, err := helpers.CreateServiceAccount(req.Name, req.Role, department, auditActor(c.Ctx))
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to create service account", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusCreated, "Service account created successfully", dto.FromUserModelToServiceAccountResponse(user))
+}
+
+// @Title Delete
+// @Description Deactivate a service account and revoke its API keys. The account is kept for the history of its changes.
+// @Produce  json
+// @Param   id		path	int	true		"Service account ID"
+// @Success 200 {object} dto.ServiceAccountResponse "Service account deactivated successfully"
+// @Failure 400 Invalid service account ID
+// @Failure 404 Service account not found
+// @Failure 500 Internal server error
+// @router /:id [delete]
+func (c *ServiceAccountController) Delete() {
+	user, ok := c.fetchServiceAccount()
+	if !ok {
+		return
+	}
+
+	before := dto.FromUserModelToServiceAccountResponse(user)
+	audit := auditEntry(c.Ctx, constants.AuditActionUpdate, constants.AuditEntityUser, func() (int, interface{}, interface{}) {
+		return user.Id, before, dto.FromUserModelToServiceAccountResponse(user)
+	})
+	if err := helpers.DeactivateAccount(user, time.Now(), audit); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to deactivate service account", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Service account deactivated successfully", dto.FromUserModelToServiceAccountResponse(user))
+}
+
+// @Title GetApiKeys
+// @Description Retrieve the API keys of a service account including revoked and expired ones. The keys themselves are not included.
+// @Produce  json
+// @Param   id		path	int	true		"Service account ID"
+// @Success 200 {object} dto.ApiKeyResponse "API keys retrieved successfully"
+// @Failure 400 Invalid service account ID
+// @Failure 404 Service account not found
+// @Failure 500 Internal server error
+// @router /:id/api-keys [get]
+func (c *ServiceAccountController) GetApiKeys() {
+	user, ok := c.fetchServiceAccount()
+	if !ok {
+		return
+	}
+
+	keys, err := models.GetApiKeysByUserId(user.Id)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch API keys", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "API keys retrieved successfully", dto.FromApiKeyModelListToApiKeyResponseList(keys, time.Now()))
+}
+
+// @Title CreateApiKey
+// @Description Create an API key of a service account. The key acts with the role of the account, is limited to the granted scopes and only returned in this response.
+// @Accept  json
+// @Produce  json
+// @Param   id		path	int	true		"Service account ID"
+// @Param   body	body	dto.ApiKeyRequest	true		"API key data"
+// @Success 201 {object} dto.ApiKeyResponse "API key created successfully"
+// @Failure 400 Invalid input
+// @Failure 404 Service account not found
+// @Failure 409 Too many API keys or the service account was deactivated
+// @Failure 500 Internal server error
+// @router /:id/api-keys [post]
+func (c *ServiceAccountController) CreateApiKey() {
+	user, ok := c.fetchServiceAccount()
+	if !ok {
+		return
+	}
+	if !user.IsActive() {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusConflict, "Service account deactivated", fmt.Errorf("service account '%d' was deactivated", user.Id))
+		return
+	}
+
+	createApiKey(c.Ctx, user)
+}
+
+// fetchServiceAccount retrieves the service account of the :id path parameter, other users are reported as missing
+func (c *ServiceAccountController) fetchServiceAccount() (*models.User, bool) {
+	id, err := c.GetInt(":id")
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid service account id", err)
+		return nil, false
+	}
+
+	user, err := models.GetUserAccountById(id)
+	if err == orm.ErrNoRows || (err == nil && !user.ServiceAccount) {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "Service account not found", fmt.Errorf("service account '%d' not found", id))
+		return nil, false
+	}
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch service account with id %d", id), err)
+		return nil, false
+	}
+
+	return user, true
+}
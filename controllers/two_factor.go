@@ -0,0 +1,303 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// TwoFactorController handles the TOTP based two-factor authentication of the authenticated user
+type TwoFactorController struct {
+	beego.Controller
+}
+
+// URLMapping maps HTTP methods to controller functions
+// This function binds the URLs for each handler to its corresponding method.
+func (c *TwoFactorController) URLMapping() {
+	c.Mapping("GetStatus", c.GetStatus)                             // Maps GET /users/me/2fa to GetStatus method for retrieving the two-factor status
+	c.Mapping("Enroll", c.Enroll)                                   // Maps POST /users/me/2fa to Enroll method for starting the enrollment
+	c.Mapping("Activate", c.Activate)                               // Maps POST /users/me/2fa/activate to Activate method for confirming the enrollment with a code
+	c.Mapping("Disable", c.Disable)                                 // Maps DELETE /users/me/2fa to Disable method for turning two-factor authentication off
+	c.Mapping("RegenerateRecoveryCodes", c.RegenerateRecoveryCodes) // Maps POST /users/me/2fa/recovery-codes to RegenerateRecoveryCodes method for replacing the recovery codes
+}
+
+// @Title GetStatus
+// @Description Retrieve whether two-factor authentication is enabled or required and how many recovery codes are left
+// @Produce  json
+// @Success 200 {object} dto.TwoFactorStatusResponse "Two-factor status retrieved successfully"
+// @Failure 401 Unauthorized
+// @Failure 500 Internal server error
+// @router / [get]
+func (c *TwoFactorController) GetStatus() {
+	user, ok := c.getAuthenticatedUser()
+	if !ok {
+		return
+	}
+
+	codesLeft, err := models.CountUnusedRecoveryCodes(user.Id)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch recovery codes", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Two-factor status retrieved successfully", dto.TwoFactorStatusResponse{
+		Enabled:           user.TwoFactorEnabled,
+		Required:          helpers.IsTwoFactorRequired(user.Role),
+		RecoveryCodesLeft: codesLeft,
+	})
+}
+
+// @Title Enroll
+// @Description Start the enrollment with a new secret for the authenticator app. Two-factor authentication is enabled once a code is confirmed with /activate.
+// @Produce  json
+// @Success 200 {object} dto.TwoFactorEnrollmentResponse "Two-factor enrollment started"
+// @Failure 401 Unauthorized
+// @Failure 409 Two-factor authentication is already enabled
+// @Failure 500 Internal server error
+// @router / [post]
+func (c *TwoFactorController) Enroll() {
+	user, ok := c.getAuthenticatedUser()
+	if !ok {
+		return
+	}
+
+	// Replacing the secret of an active enrollment would bypass the second factor
+	if user.TwoFactorEnabled {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusConflict, "Two-factor authentication is already enabled", errors.New("disable two-factor authentication to enroll again"))
+		return
+	}
+
+	secret, err := helpers.GenerateTOTPSecret()
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to generate secret", err)
+		return
+	}
+	encryptedSecret, err := helpers.EncryptSecret(secret)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to encrypt secret", err)
+		return
+	}
+	if err := models.SetTwoFactorSecret(user, encryptedSecret); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to start enrollment", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Two-factor enrollment started", dto.TwoFactorEnrollmentResponse{
+		Secret:     secret,
+		OtpauthUri: helpers.TOTPUri(secret, user.Email),
+	})
+}
+
+// @Title Activate
+// @Description Enable two-factor authentication with a code of the enrolled authenticator app. The response carries the recovery codes, shown only once, and a token with full access.
+// @Accept  json
+// @Produce  json
+// @Param   body	body	dto.TwoFactorCodeRequest	true		"Code of the authenticator app"
+// @Success 200 {object} dto.TwoFactorRecoveryCodesResponse "Two-factor authentication enabled"
+// @Failure 400 Invalid input, no pending enrollment or invalid code
+// @Failure 401 Unauthorized
+// @Failure 429 Too many attempts
+// @Failure 500 Internal server error
+// @router /activate [post]
+func (c *TwoFactorController) Activate() {
+	user, ok := c.getAuthenticatedUser()
+	if !ok {
+		return
+	}
+
+	req, ok := c.parseCodeRequest(user)
+	if !ok {
+		return
+	}
+
+	if user.TwoFactorEnabled || user.TwoFactorSecret == "" {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "No pending two-factor enrollment", errors.New("start the enrollment first"))
+		return
+	}
+
+	secret, err := helpers.DecryptSecret(user.TwoFactorSecret)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to decrypt secret", err)
+		return
+	}
+	step, valid := helpers.ValidateTOTP(secret, req.Code, time.Now())
+	if !valid {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid two-factor code", errors.New("the code doesn't match the enrolled secret"))
+		return
+	}
+
+	codes, hashes, err := helpers.GenerateRecoveryCodes()
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to generate recovery codes", err)
+		return
+	}
+	if err := models.EnableTwoFactor(user, step, hashes); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to enable two-factor authentication", err)
+		return
+	}
+
+	// The code just confirmed the second factor, so a pre-auth token used for the setup is exchanged for full access
+	token, err := helpers.GenerateJWT(user.Id, user.Email, user.Role, user.TokenVersion)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to generate token", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Two-factor authentication enabled", dto.TwoFactorRecoveryCodesResponse{Token: token, RecoveryCodes: codes})
+}
+
+// @Title Disable
+// @Description Disable two-factor authentication with the password and a code. Not available to roles the policy requires two-factor authentication for.
+// @Accept  json
+// @Produce  json
+// @Param   body	body	dto.TwoFactorDisableRequest	true		"Password and code"
+// @Success 200 {object} helpers.BaseResponse "Two-factor authentication disabled"
+// @Failure 400 Invalid input, wrong password or invalid code
+// @Failure 401 Unauthorized
+// @Failure 403 Two-factor authentication is required for the role
+// @Failure 429 Too many attempts
+// @Failure 500 Internal server error
+// @router / [delete]
+func (c *TwoFactorController) Disable() {
+	user, ok := c.getAuthenticatedUser()
+	if !ok {
+		return
+	}
+
+	var req dto.TwoFactorDisableRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+	if errorsMap, err := helpers.ValidatePayloads(req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, constants.ErrValidationMessage, errorsMap)
+		return
+	}
+
+	if helpers.IsTwoFactorRequired(user.Role) {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusForbidden, "Two-factor authentication is required for your role", errors.New("two-factor authentication can't be disabled"))
+		return
+	}
+	if !user.TwoFactorEnabled {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Two-factor authentication is not enabled", errors.New("nothing to disable"))
+		return
+	}
+	if !helpers.AllowTwoFactorAttempt(user.Id, time.Now()) {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusTooManyRequests, "Too many attempts", errors.New("try again later"))
+		return
+	}
+
+	if err := helpers.ComparePassword(user.Password, req.Password); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Password is incorrect", errors.New("invalid credentials"))
+		return
+	}
+	valid, err := helpers.VerifyTwoFactorCode(user, req.Code, time.Now())
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to verify code", err)
+		return
+	}
+	if !valid {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid two-factor code", errors.New("the code is wrong, expired or was already used"))
+		return
+	}
+
+	if err := models.DisableTwoFactor(user); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to disable two-factor authentication", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Two-factor authentication disabled", nil)
+}
+
+// @Title RegenerateRecoveryCodes
+// @Description Replace the recovery codes, confirmed with a code of the authenticator app. The previous codes stop working.
+// @Accept  json
+// @Produce  json
+// @Param   body	body	dto.TwoFactorCodeRequest	true		"Code of the authenticator app"
+// @Success 200 {object} dto.TwoFactorRecoveryCodesResponse "Recovery codes regenerated"
+// @Failure 400 Invalid input, two-factor authentication not enabled or invalid code
+// @Failure 401 Unauthorized
+// @Failure 429 Too many attempts
+// @Failure 500 Internal server error
+// @router /recovery-codes [post]
+func (c *TwoFactorController) RegenerateRecoveryCodes() {
+	user, ok := c.getAuthenticatedUser()
+	if !ok {
+		return
+	}
+
+	req, ok := c.parseCodeRequest(user)
+	if !ok {
+		return
+	}
+
+	if !user.TwoFactorEnabled {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Two-factor authentication is not enabled", errors.New("enable two-factor authentication first"))
+		return
+	}
+
+	valid, err := helpers.VerifyTOTPCode(user, req.Code, time.Now())
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to verify code", err)
+		return
+	}
+	if !valid {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid two-factor code", errors.New("the code is wrong, expired or was already used"))
+		return
+	}
+
+	codes, hashes, err := helpers.GenerateRecoveryCodes()
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to generate recovery codes", err)
+		return
+	}
+	if err := models.ReplaceRecoveryCodes(user, hashes); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to store recovery codes", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Recovery codes regenerated", dto.TwoFactorRecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// getAuthenticatedUser loads the account of the authenticated user
+func (c *TwoFactorController) getAuthenticatedUser() (*models.User, bool) {
+	userId, ok := c.Ctx.Input.GetData(constants.CtxAuthenticatedUserId).(int)
+	if !ok {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Bad context", errors.New("can't retrieve user id from context"))
+		return nil, false
+	}
+
+	user, err := models.GetUserAccountById(userId)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch user", err)
+		return nil, false
+	}
+	return user, true
+}
+
+// parseCodeRequest parses and validates a request confirmed with a code, limiting the attempts of the user
+func (c *TwoFactorController) parseCodeRequest(user *models.User) (*dto.TwoFactorCodeRequest, bool) {
+	var req dto.TwoFactorCodeRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid input", err)
+		return nil, false
+	}
+	if errorsMap, err := helpers.ValidatePayloads(req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, constants.ErrValidationMessage, errorsMap)
+		return nil, false
+	}
+
+	if !helpers.AllowTwoFactorAttempt(user.Id, time.Now()) {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusTooManyRequests, "Too many attempts", errors.New("try again later"))
+		return nil, false
+	}
+	return &req, true
+}
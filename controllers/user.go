@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/snykk/beego-presence-api/constants"
 	"github.com/snykk/beego-presence-api/dto"
@@ -25,8 +28,17 @@ type UserController struct {
 func (c *UserController) URLMapping() {
 	c.Mapping("GetAll", c.GetAll)   // Maps GET /users to GetAll method for retrieving all users
 	c.Mapping("GetById", c.GetById) // Maps GET /users/:id to GetById method for retrieving a specific user by ID
+	c.Mapping("Create", c.Create)   // Maps POST /users to Create method for creating a user with a role (admin only)
 	c.Mapping("Update", c.Update)   // Maps PUT /users/:id to Update method for updating a specific user by ID
 	c.Mapping("Delete", c.Delete)   // Maps DELETE /users/:id to Delete method for deleting a specific user by ID
+
+	c.Mapping("ChangePassword", c.ChangePassword) // Maps PUT /users/me/password to ChangePassword method for changing the password of the authenticated user
+	c.Mapping("Unlock", c.Unlock)                 // Maps POST /users/:id/unlock to Unlock method for lifting a login lockout (admin only)
+	c.Mapping("ChangeRole", c.ChangeRole)         // Maps PUT /users/:id/role to ChangeRole method for promoting or demoting a user (admin only)
+	c.Mapping("Deactivate", c.Deactivate)         // Maps POST /users/:id/deactivate to Deactivate method for deactivating a user (admin only)
+	c.Mapping("Reactivate", c.Reactivate)         // Maps POST /users/:id/reactivate to Reactivate method for reactivating a user (admin only)
+	c.Mapping("GetDeleted", c.GetDeleted)         // Maps GET /users/deleted to GetDeleted method for retrieving the deleted users (admin only)
+	c.Mapping("Restore", c.Restore)               // Maps POST /users/:id/restore to Restore method for restoring a deleted user (admin only)
 }
 
 // @Title GetAll
@@ -116,15 +128,92 @@ func (c *UserController) GetById() {
 	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "User retrieved successfully", map[string]interface{}{"users": dto.FromUserModelToUserResponse(user, isIncludeDepartment, isIncludePresenceList, isIncludeSchedule)})
 }
 
+// @Title Create
+// @Description Create a user with a role, department and optional schedule (admin only). The user changes the initial password at the first login.
+// @Accept  json
+// @Produce  json
+// @Param createUserRequest body dto.CreateUserRequest true "User Data"
+// @Success 201 {object} dto.UserResponse "User created successfully"
+// @Failure 400 Invalid input data, department or schedule
+// @Failure 409 Email already registered
+// @Failure 500 Failed to create user
+// @router / [post]
+func (c *UserController) Create() {
+	var req dto.CreateUserRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid input format", err)
+		return
+	}
+
+	// Validate the request payload.
+	if errorsMap, err := helpers.ValidatePayloads(req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, constants.ErrValidationMessage, errorsMap)
+		return
+	}
+
+	// The email is the login of the user and has to stay unique, deleted users keep it until they are purged.
+	registered, err := models.IsEmailRegistered(req.Email)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch user", err)
+		return
+	}
+	if registered {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusConflict, "Email already registered", fmt.Errorf("a user with email '%s' already exists", req.Email))
+		return
+	}
+
+	// Fetch the department and the schedule, which has to belong to the department.
+	department, err := models.GetDepartmentById(req.DepartmentId, false, false)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid department ID", err)
+		return
+	}
+	var schedule *models.Schedule
+	if req.ScheduleId != 0 {
+		schedule, err = models.GetScheduleById(req.ScheduleId, false, false)
+		if err != nil {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid schedule ID", err)
+			return
+		}
+		if schedule.Department == nil || schedule.Department.Id != department.Id {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid schedule ID", fmt.Errorf("schedule '%d' doesn't belong to department '%d'", schedule.Id, department.Id))
+			return
+		}
+	}
+
+	// Hash the initial password before saving.
+	hashedPassword, err := helpers.HashPassword(req.Password)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to hash password", err)
+		return
+	}
+
+	user := req.ToUserModel(department, schedule)
+	user.Password = hashedPassword
+	userCreated := helpers.NewDomainEvent(constants.EventUserCreated, func() interface{} {
+		return dto.FromUserModelToUserResponse(user, false, false, false)
+	})
+	audit := auditEntry(c.Ctx, constants.AuditActionCreate, constants.AuditEntityUser, func() (int, interface{}, interface{}) {
+		return user.Id, nil, dto.FromUserModelToUserResponse(user, false, false, false)
+	})
+	if err := models.CreateUser(user, userCreated, audit); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to create user", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusCreated, "User created successfully", map[string]interface{}{"user": dto.FromUserModelToUserResponse(user, false, false, false)})
+}
+
 // @Title Update
-// @Description Update an existing user's details
+// @Description Update an existing user's details. Users update their own details, admins those of every user. Users changing their email verify the new address with the link sent to it, and like on registration the email has to be allowed by the department.
 // @Accept  json
 // @Produce  json
 // @Param id path int true "User ID"
 // @Param userRequest body dto.UserRequest true "User Data"
 // @Success 200 {object} dto.UserResponse "User updated successfully"
-// @Failure 400 Invalid input data
+// @Failure 400 Invalid input data or email domain not allowed for the department
 // @Failure 404 User not found
+// @Failure 409 Email already registered
 // @Failure 500 Failed to update user
 // @router /:id [put]
 func (c *UserController) Update() {
@@ -142,8 +231,9 @@ func (c *UserController) Update() {
 		return
 	}
 
-	// Ensure the user can only update their own data.
-	if userId != id {
+	// Ensure the user can only update their own data, unless an admin updates it.
+	userRole, _ := c.Ctx.Input.GetData(constants.CtxAuthenticatedUserRole).(string)
+	if userId != id && userRole != constants.RoleAdmin {
 		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusForbidden, "You are not allowed to update another user's data", errors.New("forbidden access"))
 		return
 	}
@@ -179,18 +269,62 @@ func (c *UserController) Update() {
 		return
 	}
 
-	// Update user model and save to database.
+	// Users changing their email or department are held to the domains the department allows, as on registration.
+	selfService := userId == id
+	emailChanged := !strings.EqualFold(user.Email, req.Email)
+	departmentChanged := user.Department == nil || user.Department.Id != department.Id
+	if selfService && (emailChanged || departmentChanged) && !department.AllowsEmail(req.Email) {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Email domain is not allowed for this department", fmt.Errorf("allowed domains: %s", strings.Join(department.GetAllowedEmailDomains(), ", ")))
+		return
+	}
+
+	// The email is the login of the user and has to stay unique.
+	if emailChanged {
+		registered, err := models.IsEmailRegistered(req.Email)
+		if err != nil {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch user", err)
+			return
+		}
+		if registered {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusConflict, "Email already registered", fmt.Errorf("a user with email '%s' already exists", req.Email))
+			return
+		}
+	}
+
+	// Update user model and save to database. An email the users changed themselves has to be verified again.
+	before := dto.FromUserModelToUserResponse(user, false, false, false)
 	updatedUser := req.ToUserModel(user, department)
-	if err := models.UpdateUser(updatedUser); err != nil {
+	reverify := selfService && emailChanged
+	if reverify {
+		updatedUser.EmailVerified = false
+	}
+	userUpdated := helpers.NewDomainEvent(constants.EventUserUpdated, func() interface{} {
+		return dto.FromUserModelToUserResponse(updatedUser, false, false, false)
+	})
+	audit := auditEntry(c.Ctx, constants.AuditActionUpdate, constants.AuditEntityUser, func() (int, interface{}, interface{}) {
+		return updatedUser.Id, before, dto.FromUserModelToUserResponse(updatedUser, false, false, false)
+	})
+	if err := models.UpdateUser(updatedUser, userUpdated, audit); err != nil {
 		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to update user", err)
 		return
 	}
 
+	if reverify {
+		// Send the verification link in the background, it can be requested again if the email doesn't arrive.
+		go func() {
+			if err := helpers.SendEmailVerification(updatedUser); err != nil {
+				log.Printf("Failed to send email verification to user %d: %v", updatedUser.Id, err)
+			}
+		}()
+		helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "User updated successfully, check your email to verify the new address", map[string]interface{}{"user": dto.FromUserModelToUserResponse(updatedUser, false, false, false)})
+		return
+	}
+
 	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "User updated successfully", map[string]interface{}{"user": dto.FromUserModelToUserResponse(updatedUser, false, false, false)})
 }
 
 // @Title Delete
-// @Description Delete a user by ID
+// @Description Delete a user by ID. The user is hidden and can't log in until an admin restores it, the presence history is kept until the user is purged after the retention period.
 // @Accept  json
 // @Produce  json
 // @Param id path int true "User ID"
@@ -220,8 +354,21 @@ func (c *UserController) Delete() {
 		return
 	}
 
+	// Fetch the user to record it in the audit log.
+	user, err := models.GetUserById(id, false)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "User not found", err)
+		return
+	}
+
 	// Attempt to delete the user by ID.
-	affectedRows, err := models.DeleteUser(id)
+	userDeleted := helpers.NewDomainEvent(constants.EventUserDeleted, func() interface{} {
+		return map[string]interface{}{"id": id}
+	})
+	audit := auditEntry(c.Ctx, constants.AuditActionDelete, constants.AuditEntityUser, func() (int, interface{}, interface{}) {
+		return id, dto.FromUserModelToUserResponse(user, false, false, false), nil
+	})
+	affectedRows, err := models.DeleteUser(id, userDeleted, audit)
 	if err != nil {
 		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to delete user", err)
 		return
@@ -236,3 +383,345 @@ func (c *UserController) Delete() {
 	// Return success response indicating user was deleted.
 	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "User deleted successfully", nil)
 }
+
+// @Title ChangePassword
+// @Description Change the password of the authenticated user. The current password is required and the new one must differ from the recently used passwords. Every other session is signed out, the response carries a new token.
+// @Accept  json
+// @Produce  json
+// @Param changePasswordRequest body dto.ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} dto.LoginResponse "Password changed successfully"
+// @Failure 400 Invalid input data, wrong current password or recently used password
+// @Failure 401 Unauthorized
+// @Failure 500 Failed to change password
+// @router /me/password [put]
+func (c *UserController) ChangePassword() {
+	// Fetch the authenticated user's ID.
+	userId, ok := c.Ctx.Input.GetData(constants.CtxAuthenticatedUserId).(int)
+	if !ok {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Bad context", errors.New("can't retrieve user id from context"))
+		return
+	}
+
+	var req dto.ChangePasswordRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid input format", err)
+		return
+	}
+
+	// Validate the request payload.
+	if errorsMap, err := helpers.ValidatePayloads(req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, constants.ErrValidationMessage, errorsMap)
+		return
+	}
+
+	user, err := models.GetUserAccountById(userId)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch user", err)
+		return
+	}
+
+	// Verify the current password.
+	if err := helpers.ComparePassword(user.Password, req.CurrentPassword); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Current password is incorrect", errors.New("invalid credentials"))
+		return
+	}
+
+	// Reject the current and the recently used passwords.
+	reused, err := helpers.IsPasswordReused(user, req.NewPassword)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to change password", err)
+		return
+	}
+	if reused {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Password was used recently", fmt.Errorf("the new password must differ from the last %d passwords", constants.PasswordHistorySize+1))
+		return
+	}
+
+	hashedPassword, err := helpers.HashPassword(req.NewPassword)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to hash password", err)
+		return
+	}
+
+	// Changing the password raises the token version, which signs out every session including the current one.
+	if err := models.ChangeUserPassword(user, hashedPassword, time.Now()); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to change password", err)
+		return
+	}
+
+	// Hand out a token for the new version, so the current session continues.
+	token, err := helpers.GenerateJWT(user.Id, user.Email, user.Role, user.TokenVersion)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to generate token", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Password changed successfully", dto.LoginResponse{Token: token, MustChangePassword: user.MustChangePassword})
+}
+
+// @Title Unlock
+// @Description Lift the lockout of a user after too many failed logins and clear the failed attempts (admin only)
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {object} helpers.BaseResponse "User unlocked successfully"
+// @Failure 400 Invalid user ID
+// @Failure 404 User not found
+// @Failure 500 Failed to unlock user
+// @router /:id/unlock [post]
+func (c *UserController) Unlock() {
+	id, err := c.GetInt(":id")
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	affectedRows, err := models.UnlockUser(id)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to unlock user", err)
+		return
+	}
+	if affectedRows == 0 {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "User not found", fmt.Errorf("user '%d' not found", id))
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "User unlocked successfully", nil)
+}
+
+// @Title ChangeRole
+// @Description Promote or demote a user (admin only). The sessions of the user are signed out, the last active admin can't be demoted.
+// @Accept  json
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param changeRoleRequest body dto.ChangeRoleRequest true "New role"
+// @Success 200 {object} dto.UserResponse "User role changed successfully"
+// @Failure 400 Invalid input data
+// @Failure 404 User not found
+// @Failure 409 The last active admin can't be demoted
+// @Failure 500 Failed to change user role
+// @router /:id/role [put]
+func (c *UserController) ChangeRole() {
+	user, ok := c.fetchUser()
+	if !ok {
+		return
+	}
+
+	var req dto.ChangeRoleRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid input format", err)
+		return
+	}
+
+	// Validate the request payload.
+	if errorsMap, err := helpers.ValidatePayloads(req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, constants.ErrValidationMessage, errorsMap)
+		return
+	}
+
+	if req.Role != user.Role {
+		before := dto.FromUserModelToUserResponse(user, false, false, false)
+		if req.Role != constants.RoleAdmin {
+			if err := helpers.EnsureAdminRemains(user); err != nil {
+				c.respondAdminRemainsError(err)
+				return
+			}
+		}
+
+		userUpdated := helpers.NewDomainEvent(constants.EventUserUpdated, func() interface{} {
+			return dto.FromUserModelToUserResponse(user, false, false, false)
+		})
+		audit := auditEntry(c.Ctx, constants.AuditActionUpdate, constants.AuditEntityUser, func() (int, interface{}, interface{}) {
+			return user.Id, before, dto.FromUserModelToUserResponse(user, false, false, false)
+		})
+		if err := models.ChangeUserRole(user, req.Role, userUpdated, audit); err != nil {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to change user role", err)
+			return
+		}
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "User role changed successfully", map[string]interface{}{"user": dto.FromUserModelToUserResponse(user, false, false, false)})
+}
+
+// @Title Deactivate
+// @Description Deactivate a user instead of deleting it (admin only). Deactivated users can't log in or check in, their sessions and API keys are revoked and their presence history is kept.
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {object} dto.UserResponse "User deactivated successfully"
+// @Failure 400 Invalid user ID or own account
+// @Failure 404 User not found
+// @Failure 409 The last active admin can't be deactivated
+// @Failure 500 Failed to deactivate user
+// @router /:id/deactivate [post]
+func (c *UserController) Deactivate() {
+	userId, ok := c.Ctx.Input.GetData(constants.CtxAuthenticatedUserId).(int)
+	if !ok {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusUnauthorized, "Bad context", errors.New("can't retrieve user id from context"))
+		return
+	}
+
+	user, ok := c.fetchUser()
+	if !ok {
+		return
+	}
+
+	// Admins can't lock themselves out.
+	if user.Id == userId {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "You can't deactivate your own account", errors.New("ask another admin to deactivate the account"))
+		return
+	}
+	if err := helpers.EnsureAdminRemains(user); err != nil {
+		c.respondAdminRemainsError(err)
+		return
+	}
+
+	before := dto.FromUserModelToUserResponse(user, false, false, false)
+	audit := auditEntry(c.Ctx, constants.AuditActionUpdate, constants.AuditEntityUser, func() (int, interface{}, interface{}) {
+		return user.Id, before, dto.FromUserModelToUserResponse(user, false, false, false)
+	})
+	if err := helpers.DeactivateAccount(user, time.Now(), audit); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to deactivate user", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "User deactivated successfully", map[string]interface{}{"user": dto.FromUserModelToUserResponse(user, false, false, false)})
+}
+
+// @Title Reactivate
+// @Description Reactivate a deactivated user (admin only). The user logs in again, revoked API keys stay revoked.
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {object} dto.UserResponse "User reactivated successfully"
+// @Failure 400 Invalid user ID
+// @Failure 404 User not found
+// @Failure 500 Failed to reactivate user
+// @router /:id/reactivate [post]
+func (c *UserController) Reactivate() {
+	user, ok := c.fetchUser()
+	if !ok {
+		return
+	}
+
+	if !user.IsActive() {
+		before := dto.FromUserModelToUserResponse(user, false, false, false)
+		userUpdated := helpers.NewDomainEvent(constants.EventUserUpdated, func() interface{} {
+			return dto.FromUserModelToUserResponse(user, false, false, false)
+		})
+		audit := auditEntry(c.Ctx, constants.AuditActionUpdate, constants.AuditEntityUser, func() (int, interface{}, interface{}) {
+			return user.Id, before, dto.FromUserModelToUserResponse(user, false, false, false)
+		})
+		if err := models.ReactivateUser(user, userUpdated, audit); err != nil {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to reactivate user", err)
+			return
+		}
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "User reactivated successfully", map[string]interface{}{"user": dto.FromUserModelToUserResponse(user, false, false, false)})
+}
+
+// @Title GetDeleted
+// @Description Fetch the deleted users, most recently deleted first (admin only)
+// @Produce  json
+// @Success 200 {object} dto.UserResponse "Deleted users retrieved successfully"
+// @Failure 500 Failed to fetch deleted users
+// @router /deleted [get]
+func (c *UserController) GetDeleted() {
+	users, err := models.GetDeletedUsers()
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch deleted users", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Deleted users retrieved successfully", dto.FromUserModelListToUserResponseList(users, false, false, false))
+}
+
+// @Title Restore
+// @Description Restore a deleted user (admin only). The department and the schedule of the user have to be restored first.
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {object} dto.UserResponse "User restored successfully"
+// @Failure 400 Invalid user ID
+// @Failure 404 Deleted user not found
+// @Failure 409 The department or the schedule of the user is deleted
+// @Failure 500 Failed to restore user
+// @router /:id/restore [post]
+func (c *UserController) Restore() {
+	id, err := c.GetInt(":id")
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	user, err := models.GetDeletedUserById(id)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "Deleted user not found", fmt.Errorf("deleted user '%d' not found", id))
+			return
+		}
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch user with id %d", id), err)
+		return
+	}
+
+	// A restored user can't belong to a deleted department or schedule.
+	if _, err := models.GetDepartmentById(user.Department.Id, false, false); err != nil {
+		if err == orm.ErrNoRows {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusConflict, "Restore the department of the user first", fmt.Errorf("department '%d' is deleted", user.Department.Id))
+			return
+		}
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch department", err)
+		return
+	}
+	if user.Schedule != nil {
+		if _, err := models.GetScheduleById(user.Schedule.Id, false, false); err != nil {
+			if err == orm.ErrNoRows {
+				helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusConflict, "Restore the schedule of the user first", fmt.Errorf("schedule '%d' is deleted", user.Schedule.Id))
+				return
+			}
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch schedule", err)
+			return
+		}
+	}
+
+	before := dto.FromUserModelToUserResponse(user, false, false, false)
+	userUpdated := helpers.NewDomainEvent(constants.EventUserUpdated, func() interface{} {
+		return dto.FromUserModelToUserResponse(user, false, false, false)
+	})
+	audit := auditEntry(c.Ctx, constants.AuditActionRestore, constants.AuditEntityUser, func() (int, interface{}, interface{}) {
+		return user.Id, before, dto.FromUserModelToUserResponse(user, false, false, false)
+	})
+	if err := models.RestoreUser(user, userUpdated, audit); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to restore user", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "User restored successfully", map[string]interface{}{"user": dto.FromUserModelToUserResponse(user, false, false, false)})
+}
+
+// fetchUser retrieves the account of the :id path parameter
+func (c *UserController) fetchUser() (*models.User, bool) {
+	id, err := c.GetInt(":id")
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid user ID", err)
+		return nil, false
+	}
+
+	user, err := models.GetUserAccountById(id)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "User not found", fmt.Errorf("user '%d' not found", id))
+			return nil, false
+		}
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch user with id %d", id), err)
+		return nil, false
+	}
+
+	return user, true
+}
+
+// respondAdminRemainsError writes the response of a failed helpers.EnsureAdminRemains check
+func (c *UserController) respondAdminRemainsError(err error) {
+	if err == helpers.ErrLastAdmin {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusConflict, "At least one active admin is required", err)
+		return
+	}
+	helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to count admins", err)
+}
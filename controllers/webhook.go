@@ -0,0 +1,294 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/client/orm"
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// WebhookController handles webhook subscriptions and their delivery log (admin only)
+type WebhookController struct {
+	beego.Controller
+}
+
+// URLMapping maps HTTP methods to controller functions
+// This function binds the URLs for each handler to its corresponding method.
+func (c *WebhookController) URLMapping() {
+	c.Mapping("GetAll", c.GetAll)               // Maps GET /webhooks to GetAll method for retrieving all webhooks
+	c.Mapping("GetById", c.GetById)             // Maps GET /webhooks/:id to GetById method for retrieving a webhook
+	c.Mapping("Create", c.Create)               // Maps POST /webhooks to Create method for subscribing a URL to events
+	c.Mapping("Update", c.Update)               // Maps PUT /webhooks/:id to Update method for updating a webhook
+	c.Mapping("Delete", c.Delete)               // Maps DELETE /webhooks/:id to Delete method for deleting a webhook and its deliveries
+	c.Mapping("GetDeliveries", c.GetDeliveries) // Maps GET /webhooks/:id/deliveries to GetDeliveries method for retrieving the delivery log of a webhook
+	c.Mapping("RetryDelivery", c.RetryDelivery) // Maps POST /webhooks/deliveries/:id/retry to RetryDelivery method for queueing a delivery again
+}
+
+// @Title GetAll
+// @Description Retrieve all webhooks. Secrets are not included.
+// @Produce  json
+// @Success 200 {object} dto.WebhookResponse "Webhooks retrieved successfully"
+// @Failure 500 Internal server error
+// @router / [get]
+func (c *WebhookController) GetAll() {
+	webhooks, err := models.GetAllWebhooks()
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch webhooks", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Webhooks retrieved successfully", dto.FromWebhookModelListToWebhookResponseList(webhooks))
+}
+
+// @Title GetById
+// @Description Retrieve a webhook by ID. The secret is not included.
+// @Produce  json
+// @Param   id		path	int	true		"Webhook ID"
+// @Success 200 {object} dto.WebhookResponse "Webhook retrieved successfully"
+// @Failure 400 Invalid webhook ID
+// @Failure 404 Webhook not found
+// @Failure 500 Internal server error
+// @router /:id [get]
+func (c *WebhookController) GetById() {
+	webhook, ok := c.fetchWebhook()
+	if !ok {
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Webhook retrieved successfully", dto.FromWebhookModelToWebhookResponse(webhook, ""))
+}
+
+// @Title Create
+// @Description Subscribe a URL to events. Payloads are signed with HMAC-SHA256 of "<timestamp>.<body>" using the secret, which is generated when omitted and only returned in this response.
+// @Accept  json
+// @Produce  json
+// @Param   body	body	dto.WebhookRequest	true		"Webhook data"
+// @Success 201 {object} dto.WebhookResponse "Webhook created successfully"
+// @Failure 400 Invalid input
+// @Failure 500 Internal server error
+// @router / [post]
+func (c *WebhookController) Create() {
+	// Parse request body to webhook request object
+	var req dto.WebhookRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	// Validate payload for any errors
+	if errorsMap, err := helpers.ValidatePayloads(req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, constants.ErrValidationMessage, errorsMap)
+		return
+	}
+
+	// Generate a signing secret unless one was provided
+	webhook := req.ToWebhookModel()
+	secret := webhook.Secret
+	if secret == "" {
+		var err error
+		if secret, err = helpers.GenerateRandomHex(32); err != nil {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to generate webhook secret", err)
+			return
+		}
+	}
+
+	// The secret is stored encrypted and only returned in this response
+	encryptedSecret, err := helpers.EncryptSecret(secret)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to encrypt webhook secret", err)
+		return
+	}
+	webhook.Secret = encryptedSecret
+
+	if err := models.CreateWebhook(webhook); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to create webhook", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusCreated, "Webhook created successfully", dto.FromWebhookModelToWebhookResponse(webhook, secret))
+}
+
+// @Title Update
+// @Description Update a webhook by ID. The secret is kept unless a new one is provided.
+// @Accept  json
+// @Produce  json
+// @Param   id		path	int	true		"Webhook ID"
+// @Param   body	body	dto.WebhookRequest	true		"Webhook data"
+// @Success 200 {object} dto.WebhookResponse "Webhook updated successfully"
+// @Failure 400 Invalid input
+// @Failure 404 Webhook not found
+// @Failure 500 Internal server error
+// @router /:id [put]
+func (c *WebhookController) Update() {
+	existedWebhook, ok := c.fetchWebhook()
+	if !ok {
+		return
+	}
+
+	// Parse request body to webhook request object
+	var req dto.WebhookRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	// Validate payload for any errors
+	if errorsMap, err := helpers.ValidatePayloads(req); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, constants.ErrValidationMessage, errorsMap)
+		return
+	}
+
+	// A new secret is stored encrypted, otherwise the current one is kept
+	webhook := req.ToWebhookModelWithValue(existedWebhook)
+	if req.Secret != "" {
+		encryptedSecret, err := helpers.EncryptSecret(req.Secret)
+		if err != nil {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to encrypt webhook secret", err)
+			return
+		}
+		webhook.Secret = encryptedSecret
+	}
+
+	if err := models.UpdateWebhook(webhook); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to update webhook", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Webhook updated successfully", dto.FromWebhookModelToWebhookResponse(webhook, ""))
+}
+
+// @Title Delete
+// @Description Delete a webhook by ID together with its delivery log.
+// @Param   id		path	int	true		"Webhook ID"
+// @Success 200 {string} "Webhook deleted successfully"
+// @Failure 400 Invalid webhook ID
+// @Failure 404 Webhook not found
+// @Failure 500 Internal server error
+// @router /:id [delete]
+func (c *WebhookController) Delete() {
+	id, err := c.GetInt(":id")
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid webhook id", err)
+		return
+	}
+
+	affectedRows, err := models.DeleteWebhook(id)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to delete webhook", err)
+		return
+	}
+
+	if affectedRows == 0 {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "Webhook not found", fmt.Errorf("webhook '%d' not found", id))
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Webhook deleted successfully", nil)
+}
+
+// @Title GetDeliveries
+// @Description Retrieve the most recent deliveries of a webhook, including the status and error of the last attempt.
+// @Produce  json
+// @Param   id		path	int		true		"Webhook ID"
+// @Param   status	query	string	false		"Filter by status: pending, delivered or failed"
+// @Param   limit	query	int		false		"Maximum number of deliveries (default 50, max 500)"
+// @Success 200 {object} dto.WebhookDeliveryResponse "Webhook deliveries retrieved successfully"
+// @Failure 400 Bad request
+// @Failure 404 Webhook not found
+// @Failure 500 Internal server error
+// @router /:id/deliveries [get]
+func (c *WebhookController) GetDeliveries() {
+	webhook, ok := c.fetchWebhook()
+	if !ok {
+		return
+	}
+
+	status := c.GetString("status")
+	switch status {
+	case "", constants.WebhookDeliveryStatusPending, constants.WebhookDeliveryStatusDelivered, constants.WebhookDeliveryStatusFailed:
+	default:
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for status", fmt.Errorf("unknown delivery status: %s", status))
+		return
+	}
+
+	limit, err := c.GetInt("limit", constants.WebhookDefaultLogLimit)
+	if err != nil || limit < 1 || limit > constants.WebhookMaxLogLimit {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid value for limit", fmt.Errorf("limit must be between 1 and %d", constants.WebhookMaxLogLimit))
+		return
+	}
+
+	deliveries, err := models.GetWebhookDeliveries(webhook.Id, status, limit)
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch webhook deliveries", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Webhook deliveries retrieved successfully", dto.FromWebhookDeliveryModelListToWebhookDeliveryResponseList(deliveries))
+}
+
+// @Title RetryDelivery
+// @Description Queue a delivery again with a fresh set of attempts, e.g. after the receiver of a failed delivery was fixed.
+// @Produce  json
+// @Param   id		path	int	true		"Delivery ID"
+// @Success 200 {object} dto.WebhookDeliveryResponse "Webhook delivery queued successfully"
+// @Failure 400 Invalid delivery ID
+// @Failure 404 Delivery not found
+// @Failure 500 Internal server error
+// @router /deliveries/:id/retry [post]
+func (c *WebhookController) RetryDelivery() {
+	id, err := c.GetInt(":id")
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid delivery id", err)
+		return
+	}
+
+	delivery, err := models.GetWebhookDeliveryById(id)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "Delivery not found", fmt.Errorf("delivery '%d' not found", id))
+			return
+		}
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to fetch delivery", err)
+		return
+	}
+
+	delivery.Status = constants.WebhookDeliveryStatusPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+	if err := models.UpdateWebhookDelivery(delivery); err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, "Failed to queue delivery", err)
+		return
+	}
+
+	helpers.SuccessResponse(c.Ctx.ResponseWriter, http.StatusOK, "Webhook delivery queued successfully", dto.FromWebhookDeliveryModelToWebhookDeliveryResponse(delivery))
+}
+
+// fetchWebhook reads the webhook identified by the :id path parameter.
+// It writes the error response itself and reports whether the caller may continue.
+func (c *WebhookController) fetchWebhook() (*models.Webhook, bool) {
+	id, err := c.GetInt(":id")
+	if err != nil {
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusBadRequest, "Invalid webhook id", err)
+		return nil, false
+	}
+
+	webhook, err := models.GetWebhookById(id)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusNotFound, "Webhook not found", fmt.Errorf("webhook '%d' not found", id))
+			return nil, false
+		}
+		helpers.ErrorResponse(c.Ctx.ResponseWriter, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch webhook with id %d", id), err)
+		return nil, false
+	}
+
+	return webhook, true
+}
@@ -21,25 +21,47 @@ func InitDB() {
 
 	// Connection string
 	dsn := fmt.Sprintf("user=%s password=%s dbname=%s host=%s port=%s sslmode=disable", user, password, dbname, host, port)
+	if err := Connect(dsn); err != nil {
+		panic(err)
+	}
 
+	log.Println("Daatbase connected successfully!")
+}
+
+// Connect registers the PostgreSQL database of the DSN as the default database together with the models
+func Connect(dsn string) error {
 	// Register driver and database
 	orm.RegisterDriver("postgres", orm.DRPostgres)
-	err := orm.RegisterDataBase("default", "postgres", dsn)
-	if err != nil {
-		panic(err)
+	if err := orm.RegisterDataBase("default", "postgres", dsn); err != nil {
+		return err
 	}
 
 	// Register Models
-	orm.RegisterModel(new(models.User), new(models.Department), new(models.Schedule), new(models.Presence))
+	orm.RegisterModel(new(models.User), new(models.Department), new(models.Schedule), new(models.Presence), new(models.Holiday), new(models.Leave), new(models.PayrollTemplate), new(models.PayrollExport), new(models.Webhook), new(models.WebhookDelivery), new(models.OutboxEvent), new(models.NotificationPreference), new(models.NotificationLog), new(models.PasswordResetToken), new(models.PasswordHistory), new(models.RecoveryCode), new(models.LoginAttempt), new(models.OidcLoginState), new(models.ApiKey), new(models.AuditLog), new(models.PresenceRevision))
+	return nil
+}
+
+// PrepareDB makes sure the schema is up to date before the server starts and seeds the empty tables. The pending
+// migrations are applied when auto_migrate is enabled, otherwise the server refuses to start until they are.
+func PrepareDB() {
+	if web.AppConfig.DefaultBool("auto_migrate", false) {
+		migrations, err := Migrate()
+		if err != nil {
+			panic(err)
+		}
+		for _, migration := range migrations {
+			log.Printf("Applied migration %d_%s", migration.Version, migration.Name)
+		}
+	}
 
-	// Auto Create Tables
-	err = orm.RunSyncdb("default", false, true)
+	pending, err := CountPendingMigrations()
 	if err != nil {
 		panic(err)
 	}
+	if pending > 0 {
+		panic(fmt.Sprintf("%d pending migrations, apply them with \"go run . migrate\"", pending))
+	}
 
 	// Run Seeder
 	RunAllSeeds()
-
-	log.Println("Daatbase connected successfully!")
 }
@@ -0,0 +1,283 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// The migrations are named <version>_<name>.up.sql and <version>_<name>.down.sql and applied in version order
+//
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// migrationLockId is the key of the advisory lock serializing concurrent migration runs
+const migrationLockId = 20241201
+
+const createSchemaMigrationsSQL = `CREATE TABLE IF NOT EXISTS "schema_migrations" (
+    "version" bigint NOT NULL PRIMARY KEY,
+    "name" varchar(255) NOT NULL,
+    "applied_at" timestamp with time zone NOT NULL DEFAULT now()
+)`
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a versioned schema change, applied with its up SQL and reverted with its down SQL
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus is a migration with the time it was applied, AppliedAt is nil while it's pending.
+// Missing migrations are recorded as applied but their files no longer exist.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	Missing   bool
+}
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	Version   int       `orm:"column(version)"`
+	Name      string    `orm:"column(name)"`
+	AppliedAt time.Time `orm:"column(applied_at)"`
+}
+
+// ParseMigrations reads the migrations of the directory, ordered by version. Every version needs an up and a down file.
+func ParseMigrations(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid version of migration file %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// LoadMigrations returns the migrations embedded into the binary
+func LoadMigrations() ([]*Migration, error) {
+	return ParseMigrations(migrationFS, "migrations")
+}
+
+// Migrate applies the pending migrations in version order, each in its own transaction, and returns the applied ones
+func Migrate() ([]*Migration, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	o := orm.NewOrm()
+	applied, err := getAppliedMigrations(o)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []*Migration
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		ran, err := runMigration(o, migration, true)
+		if err != nil {
+			return done, err
+		}
+		if ran {
+			done = append(done, migration)
+		}
+	}
+	return done, nil
+}
+
+// Rollback reverts the last steps applied migrations, newest first, and returns the reverted ones
+func Rollback(steps int) ([]*Migration, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration, len(migrations))
+	for _, migration := range migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	o := orm.NewOrm()
+	applied, err := getAppliedMigrations(o)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+	if steps < len(versions) {
+		versions = versions[:steps]
+	}
+
+	var done []*Migration
+	for _, version := range versions {
+		migration, ok := byVersion[version]
+		if !ok {
+			return done, fmt.Errorf("migration %d_%s is applied but its files are missing", version, applied[version].Name)
+		}
+		ran, err := runMigration(o, migration, false)
+		if err != nil {
+			return done, err
+		}
+		if ran {
+			done = append(done, migration)
+		}
+	}
+	return done, nil
+}
+
+// Status lists the migrations with the time they were applied, ordered by version
+func Status() ([]*MigrationStatus, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := getAppliedMigrations(orm.NewOrm())
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]*MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := &MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &row.AppliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, row := range applied {
+		appliedAt := row.AppliedAt
+		statuses = append(statuses, &MigrationStatus{Version: row.Version, Name: row.Name, AppliedAt: &appliedAt, Missing: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// CountPendingMigrations counts the migrations that aren't applied yet
+func CountPendingMigrations() (int, error) {
+	statuses, err := Status()
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// getAppliedMigrations reads schema_migrations, which is created on first use. The creation holds the advisory lock,
+// concurrent CREATE TABLE IF NOT EXISTS statements can fail on each other.
+func getAppliedMigrations(o orm.Ormer) (map[int]*appliedMigration, error) {
+	err := o.DoTx(func(ctx context.Context, tx orm.TxOrmer) error {
+		if _, err := tx.Raw("SELECT pg_advisory_xact_lock(?)", migrationLockId).Exec(); err != nil {
+			return err
+		}
+		_, err := tx.Raw(createSchemaMigrationsSQL).Exec()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []*appliedMigration
+	if _, err := o.Raw(`SELECT "version", "name", "applied_at" FROM "schema_migrations"`).QueryRows(&rows); err != nil {
+		return nil, err
+	}
+	applied := make(map[int]*appliedMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// runMigration applies or reverts a migration and records it in schema_migrations in a single transaction. The
+// advisory lock serializes concurrent runs, a migration another run already applied or reverted is skipped.
+func runMigration(o orm.Ormer, migration *Migration, up bool) (bool, error) {
+	ran := false
+	err := o.DoTx(func(ctx context.Context, tx orm.TxOrmer) error {
+		if _, err := tx.Raw("SELECT pg_advisory_xact_lock(?)", migrationLockId).Exec(); err != nil {
+			return err
+		}
+		var count int
+		if err := tx.Raw(`SELECT COUNT(*) FROM "schema_migrations" WHERE "version" = ?`, migration.Version).QueryRow(&count); err != nil {
+			return err
+		}
+		if (count > 0) == up {
+			return nil
+		}
+
+		if up {
+			if _, err := tx.Raw(migration.Up).Exec(); err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			if _, err := tx.Raw(`INSERT INTO "schema_migrations" ("version", "name") VALUES (?, ?)`, migration.Version, migration.Name).Exec(); err != nil {
+				return err
+			}
+		} else {
+			if _, err := tx.Raw(migration.Down).Exec(); err != nil {
+				return fmt.Errorf("rollback of migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			if _, err := tx.Raw(`DELETE FROM "schema_migrations" WHERE "version" = ?`, migration.Version).Exec(); err != nil {
+				return err
+			}
+		}
+		ran = true
+		return nil
+	})
+	return ran, err
+}
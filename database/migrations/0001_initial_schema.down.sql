@@ -0,0 +1,6 @@
+-- Drops every table of the initial schema, including the data, the referencing tables first
+
+DROP TABLE IF EXISTS "presence";
+DROP TABLE IF EXISTS "user";
+DROP TABLE IF EXISTS "schedule";
+DROP TABLE IF EXISTS "department";
@@ -0,0 +1,45 @@
+-- Schema of the tables created by orm.RunSyncdb before the migrations were introduced, databases created by it are
+-- adopted as they are. The columns and tables added since then are added by the following migrations.
+
+-- User
+CREATE TABLE IF NOT EXISTS "user" (
+    "id" serial NOT NULL PRIMARY KEY,
+    "name" varchar(100) NOT NULL DEFAULT '',
+    "email" varchar(100) NOT NULL DEFAULT '' UNIQUE,
+    "password" varchar(255) NOT NULL DEFAULT '',
+    "role" varchar(10) NOT NULL DEFAULT '',
+    "department_id" integer NOT NULL,
+    "schedule_id" integer,
+    "created_at" timestamp with time zone NOT NULL,
+    "updated_at" timestamp with time zone NOT NULL
+);
+
+-- Department
+CREATE TABLE IF NOT EXISTS "department" (
+    "id" serial NOT NULL PRIMARY KEY,
+    "name" varchar(100) NOT NULL DEFAULT '',
+    "created_at" timestamp with time zone NOT NULL,
+    "updated_at" timestamp with time zone NOT NULL
+);
+
+-- Schedule
+CREATE TABLE IF NOT EXISTS "schedule" (
+    "id" serial NOT NULL PRIMARY KEY,
+    "name" varchar(100) NOT NULL DEFAULT '',
+    "department_id" integer NOT NULL,
+    "in_time" varchar(8) NOT NULL DEFAULT '',
+    "out_time" varchar(8) NOT NULL DEFAULT '',
+    "created_at" timestamp with time zone NOT NULL,
+    "updated_at" timestamp with time zone NOT NULL
+);
+
+-- Presence
+CREATE TABLE IF NOT EXISTS "presence" (
+    "id" serial NOT NULL PRIMARY KEY,
+    "user_id" integer NOT NULL,
+    "schedule_id" integer NOT NULL,
+    "type" varchar(10) NOT NULL DEFAULT '',
+    "status" varchar(50) NOT NULL DEFAULT '',
+    "created_at" timestamp with time zone NOT NULL,
+    "updated_at" timestamp with time zone NOT NULL
+);
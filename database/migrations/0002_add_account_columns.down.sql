@@ -0,0 +1,26 @@
+-- Drops the columns added to the tables of the initial schema along with their indexes
+
+DROP INDEX IF EXISTS "presence_user_id_created_at";
+
+ALTER TABLE "schedule" DROP COLUMN IF EXISTS "deleted_at";
+
+ALTER TABLE "department" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "department" DROP COLUMN IF EXISTS "scim_external_id";
+ALTER TABLE "department" DROP COLUMN IF EXISTS "allowed_email_domains";
+
+ALTER TABLE "user" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "service_account";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "scim_external_id";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "deactivated_at";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "ldap_username";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "oidc_subject";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "locked_until";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "last_failed_login_at";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "failed_login_attempts";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "two_factor_last_step";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "two_factor_enabled";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "two_factor_secret";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "email_verified";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "password_changed_at";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "must_change_password";
+ALTER TABLE "user" DROP COLUMN IF EXISTS "token_version";
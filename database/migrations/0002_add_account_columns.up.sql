@@ -0,0 +1,39 @@
+-- Columns added to the tables of the initial schema, databases created by orm.RunSyncdb may have some of them already.
+-- The defaults are the ones of the ORM, so the existing rows get the values the ORM gives new rows, e.g. the existing
+-- users keep logging in as verified. The presences are additionally indexed by user and creation time for the period
+-- queries.
+
+-- User
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "token_version" integer NOT NULL DEFAULT 0;
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "must_change_password" bool NOT NULL DEFAULT false;
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "password_changed_at" timestamp with time zone;
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "email_verified" bool NOT NULL DEFAULT true;
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "two_factor_secret" varchar(255) NOT NULL DEFAULT '';
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "two_factor_enabled" bool NOT NULL DEFAULT false;
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "two_factor_last_step" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "failed_login_attempts" integer NOT NULL DEFAULT 0;
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "last_failed_login_at" timestamp with time zone;
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "locked_until" timestamp with time zone;
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "oidc_subject" varchar(255) NOT NULL DEFAULT '';
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "ldap_username" varchar(100) NOT NULL DEFAULT '';
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "deactivated_at" timestamp with time zone;
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "scim_external_id" varchar(255) NOT NULL DEFAULT '';
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "service_account" bool NOT NULL DEFAULT false;
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "deleted_at" timestamp with time zone;
+CREATE INDEX IF NOT EXISTS "user_oidc_subject" ON "user" ("oidc_subject");
+CREATE INDEX IF NOT EXISTS "user_ldap_username" ON "user" ("ldap_username");
+CREATE INDEX IF NOT EXISTS "user_scim_external_id" ON "user" ("scim_external_id");
+CREATE INDEX IF NOT EXISTS "user_deleted_at" ON "user" ("deleted_at");
+
+-- Department
+ALTER TABLE "department" ADD COLUMN IF NOT EXISTS "allowed_email_domains" varchar(500) NOT NULL DEFAULT '';
+ALTER TABLE "department" ADD COLUMN IF NOT EXISTS "scim_external_id" varchar(255) NOT NULL DEFAULT '';
+ALTER TABLE "department" ADD COLUMN IF NOT EXISTS "deleted_at" timestamp with time zone;
+CREATE INDEX IF NOT EXISTS "department_deleted_at" ON "department" ("deleted_at");
+
+-- Schedule
+ALTER TABLE "schedule" ADD COLUMN IF NOT EXISTS "deleted_at" timestamp with time zone;
+CREATE INDEX IF NOT EXISTS "schedule_deleted_at" ON "schedule" ("deleted_at");
+
+-- Presence
+CREATE INDEX IF NOT EXISTS "presence_user_id_created_at" ON "presence" ("user_id", "created_at");
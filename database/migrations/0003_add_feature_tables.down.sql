@@ -0,0 +1,19 @@
+-- Drops the tables of the features, including the data, the referencing tables first
+
+DROP TABLE IF EXISTS "presence_revision";
+DROP TABLE IF EXISTS "audit_log";
+DROP TABLE IF EXISTS "api_key";
+DROP TABLE IF EXISTS "oidc_login_state";
+DROP TABLE IF EXISTS "login_attempt";
+DROP TABLE IF EXISTS "recovery_code";
+DROP TABLE IF EXISTS "password_history";
+DROP TABLE IF EXISTS "password_reset_token";
+DROP TABLE IF EXISTS "notification_log";
+DROP TABLE IF EXISTS "notification_preference";
+DROP TABLE IF EXISTS "outbox_event";
+DROP TABLE IF EXISTS "webhook_delivery";
+DROP TABLE IF EXISTS "webhook";
+DROP TABLE IF EXISTS "payroll_export";
+DROP TABLE IF EXISTS "payroll_template";
+DROP TABLE IF EXISTS "leave";
+DROP TABLE IF EXISTS "holiday";
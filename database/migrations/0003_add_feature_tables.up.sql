@@ -0,0 +1,230 @@
+-- Tables of the features added after the initial schema. Databases created by orm.RunSyncdb may have them already,
+-- those are adopted as they are and get the columns and sizes that changed since.
+
+-- Holiday
+CREATE TABLE IF NOT EXISTS "holiday" (
+    "id" serial NOT NULL PRIMARY KEY,
+    "date" date NOT NULL UNIQUE,
+    "name" varchar(100) NOT NULL DEFAULT '',
+    "created_at" timestamp with time zone NOT NULL,
+    "updated_at" timestamp with time zone NOT NULL
+);
+
+-- Leave
+CREATE TABLE IF NOT EXISTS "leave" (
+    "id" serial NOT NULL PRIMARY KEY,
+    "user_id" integer NOT NULL,
+    "start_date" date NOT NULL,
+    "end_date" date NOT NULL,
+    "reason" varchar(255) NOT NULL DEFAULT '',
+    "created_at" timestamp with time zone NOT NULL,
+    "updated_at" timestamp with time zone NOT NULL
+);
+
+-- PayrollTemplate
+CREATE TABLE IF NOT EXISTS "payroll_template" (
+    "id" serial NOT NULL PRIMARY KEY,
+    "name" varchar(100) NOT NULL DEFAULT '' UNIQUE,
+    "format" varchar(10) NOT NULL DEFAULT '',
+    "delimiter" varchar(1) NOT NULL DEFAULT '',
+    "include_header" bool NOT NULL DEFAULT true,
+    "rounding_mode" varchar(10) NOT NULL DEFAULT '',
+    "rounding_minutes" integer NOT NULL DEFAULT 1,
+    "fields" text NOT NULL,
+    "late_buckets" text NOT NULL,
+    "created_at" timestamp with time zone NOT NULL,
+    "updated_at" timestamp with time zone NOT NULL
+);
+
+-- PayrollExport
+CREATE TABLE IF NOT EXISTS "payroll_export" (
+    "id" serial NOT NULL PRIMARY KEY,
+    "template_id" integer,
+    "department_id" integer NOT NULL,
+    "period" varchar(7) NOT NULL DEFAULT '',
+    "record_count" integer NOT NULL DEFAULT 0,
+    "filename" varchar(255) NOT NULL DEFAULT '',
+    "content" text NOT NULL,
+    "created_at" timestamp with time zone NOT NULL,
+    UNIQUE ("department_id", "period")
+);
+
+-- Webhook
+CREATE TABLE IF NOT EXISTS "webhook" (
+    "id" serial NOT NULL PRIMARY KEY,
+    "url" varchar(500) NOT NULL DEFAULT '',
+    "events" varchar(500) NOT NULL DEFAULT '',
+    "secret" varchar(255) NOT NULL DEFAULT '',
+    "description" varchar(255) NOT NULL DEFAULT '',
+    "active" bool NOT NULL DEFAULT true,
+    "created_at" timestamp with time zone NOT NULL,
+    "updated_at" timestamp with time zone NOT NULL
+);
+
+-- WebhookDelivery
+CREATE TABLE IF NOT EXISTS "webhook_delivery" (
+    "id" serial NOT NULL PRIMARY KEY,
+    "webhook_id" integer NOT NULL,
+    "event" varchar(50) NOT NULL DEFAULT '',
+    "payload" text NOT NULL,
+    "status" varchar(20) NOT NULL DEFAULT '',
+    "attempts" integer NOT NULL DEFAULT 0,
+    "next_attempt_at" timestamp with time zone NOT NULL,
+    "last_status_code" integer NOT NULL DEFAULT 0,
+    "last_error" text NOT NULL,
+    "delivered_at" timestamp with time zone,
+    "created_at" timestamp with time zone NOT NULL,
+    "updated_at" timestamp with time zone NOT NULL
+);
+CREATE INDEX IF NOT EXISTS "webhook_delivery_status" ON "webhook_delivery" ("status");
+CREATE INDEX IF NOT EXISTS "webhook_delivery_next_attempt_at" ON "webhook_delivery" ("next_attempt_at");
+
+-- OutboxEvent
+CREATE TABLE IF NOT EXISTS "outbox_event" (
+    "id" serial NOT NULL PRIMARY KEY,
+    "event" varchar(50) NOT NULL DEFAULT '',
+    "payload" text NOT NULL,
+    "status" varchar(20) NOT NULL DEFAULT '',
+    "delivered_sinks" varchar(255) NOT NULL DEFAULT '',
+    "attempts" integer NOT NULL DEFAULT 0,
+    "next_attempt_at" timestamp with time zone NOT NULL,
+    "last_error" text NOT NULL,
+    "published_at" timestamp with time zone,
+    "created_at" timestamp with time zone NOT NULL
+);
+CREATE INDEX IF NOT EXISTS "outbox_event_status" ON "outbox_event" ("status");
+CREATE INDEX IF NOT EXISTS "outbox_event_next_attempt_at" ON "outbox_event" ("next_attempt_at");
+
+-- NotificationPreference
+CREATE TABLE IF NOT EXISTS "notification_preference" (
+    "id" serial NOT NULL PRIMARY KEY,
+    "user_id" integer NOT NULL,
+    "type" varchar(30) NOT NULL DEFAULT '',
+    "enabled" bool NOT NULL DEFAULT true,
+    "updated_at" timestamp with time zone NOT NULL,
+    UNIQUE ("user_id", "type")
+);
+
+-- NotificationLog
+CREATE TABLE IF NOT EXISTS "notification_log" (
+    "id" serial NOT NULL PRIMARY KEY,
+    "user_id" integer,
+    "type" varchar(30) NOT NULL DEFAULT '',
+    "reference" varchar(100) NOT NULL DEFAULT '',
+    "channel" varchar(20) NOT NULL DEFAULT 'email',
+    "recipient" varchar(100) NOT NULL DEFAULT '',
+    "subject" varchar(255) NOT NULL DEFAULT '',
+    "status" varchar(20) NOT NULL DEFAULT '',
+    "error" text NOT NULL,
+    "created_at" timestamp with time zone NOT NULL
+);
+CREATE INDEX IF NOT EXISTS "notification_log_type" ON "notification_log" ("type");
+CREATE INDEX IF NOT EXISTS "notification_log_reference" ON "notification_log" ("reference");
+CREATE INDEX IF NOT EXISTS "notification_log_status" ON "notification_log" ("status");
+
+-- PasswordResetToken
+CREATE TABLE IF NOT EXISTS "password_reset_token" (
+    "id" serial NOT NULL PRIMARY KEY,
+    "user_id" integer NOT NULL,
+    "token_hash" varchar(64) NOT NULL DEFAULT '' UNIQUE,
+    "expires_at" timestamp with time zone NOT NULL,
+    "used_at" timestamp with time zone,
+    "created_at" timestamp with time zone NOT NULL
+);
+
+-- PasswordHistory
+CREATE TABLE IF NOT EXISTS "password_history" (
+    "id" serial NOT NULL PRIMARY KEY,
+    "user_id" integer NOT NULL,
+    "password_hash" varchar(255) NOT NULL DEFAULT '',
+    "created_at" timestamp with time zone NOT NULL
+);
+
+-- RecoveryCode
+CREATE TABLE IF NOT EXISTS "recovery_code" (
+    "id" serial NOT NULL PRIMARY KEY,
+    "user_id" integer NOT NULL,
+    "code_hash" varchar(64) NOT NULL DEFAULT '',
+    "used_at" timestamp with time zone,
+    "created_at" timestamp with time zone NOT NULL
+);
+
+-- LoginAttempt
+CREATE TABLE IF NOT EXISTS "login_attempt" (
+    "id" serial NOT NULL PRIMARY KEY,
+    "user_id" integer,
+    "email" varchar(100) NOT NULL DEFAULT '',
+    "ip" varchar(45) NOT NULL DEFAULT '',
+    "user_agent" varchar(255) NOT NULL DEFAULT '',
+    "success" bool NOT NULL DEFAULT false,
+    "reason" varchar(50) NOT NULL DEFAULT '',
+    "created_at" timestamp with time zone NOT NULL
+);
+CREATE INDEX IF NOT EXISTS "login_attempt_email" ON "login_attempt" ("email");
+CREATE INDEX IF NOT EXISTS "login_attempt_ip" ON "login_attempt" ("ip");
+CREATE INDEX IF NOT EXISTS "login_attempt_created_at" ON "login_attempt" ("created_at");
+
+-- OidcLoginState
+CREATE TABLE IF NOT EXISTS "oidc_login_state" (
+    "id" serial NOT NULL PRIMARY KEY,
+    "state" varchar(64) NOT NULL DEFAULT '' UNIQUE,
+    "nonce" varchar(64) NOT NULL DEFAULT '',
+    "code_verifier" varchar(128) NOT NULL DEFAULT '',
+    "expires_at" timestamp with time zone NOT NULL,
+    "created_at" timestamp with time zone NOT NULL
+);
+
+-- ApiKey
+CREATE TABLE IF NOT EXISTS "api_key" (
+    "id" serial NOT NULL PRIMARY KEY,
+    "user_id" integer NOT NULL,
+    "name" varchar(100) NOT NULL DEFAULT '',
+    "prefix" varchar(20) NOT NULL DEFAULT '',
+    "key_hash" varchar(64) NOT NULL DEFAULT '' UNIQUE,
+    "scopes" varchar(500) NOT NULL DEFAULT '',
+    "expires_at" timestamp with time zone NOT NULL,
+    "last_used_at" timestamp with time zone,
+    "last_used_ip" varchar(45) NOT NULL DEFAULT '',
+    "revoked_at" timestamp with time zone,
+    "created_at" timestamp with time zone NOT NULL
+);
+
+-- AuditLog
+CREATE TABLE IF NOT EXISTS "audit_log" (
+    "id" serial NOT NULL PRIMARY KEY,
+    "actor_id" integer NOT NULL DEFAULT 0,
+    "action" varchar(20) NOT NULL DEFAULT '',
+    "entity_type" varchar(50) NOT NULL DEFAULT '',
+    "entity_id" integer NOT NULL DEFAULT 0,
+    "changes" text NOT NULL,
+    "ip" varchar(45) NOT NULL DEFAULT '',
+    "created_at" timestamp with time zone NOT NULL
+);
+CREATE INDEX IF NOT EXISTS "audit_log_actor_id" ON "audit_log" ("actor_id");
+CREATE INDEX IF NOT EXISTS "audit_log_action" ON "audit_log" ("action");
+CREATE INDEX IF NOT EXISTS "audit_log_entity_type" ON "audit_log" ("entity_type");
+CREATE INDEX IF NOT EXISTS "audit_log_entity_id" ON "audit_log" ("entity_id");
+CREATE INDEX IF NOT EXISTS "audit_log_created_at" ON "audit_log" ("created_at");
+
+-- PresenceRevision
+CREATE TABLE IF NOT EXISTS "presence_revision" (
+    "id" serial NOT NULL PRIMARY KEY,
+    "presence_id" integer NOT NULL DEFAULT 0,
+    "revision" integer NOT NULL DEFAULT 0,
+    "action" varchar(20) NOT NULL DEFAULT '',
+    "user_id" integer NOT NULL DEFAULT 0,
+    "schedule_id" integer NOT NULL DEFAULT 0,
+    "type" varchar(10) NOT NULL DEFAULT '',
+    "status" varchar(50) NOT NULL DEFAULT '',
+    "presence_created_at" timestamp with time zone NOT NULL,
+    "actor_id" integer NOT NULL DEFAULT 0,
+    "previous_hash" varchar(64) NOT NULL DEFAULT '',
+    "hash" varchar(64) NOT NULL DEFAULT '',
+    "created_at" timestamp with time zone NOT NULL,
+    UNIQUE ("presence_id", "revision")
+);
+CREATE INDEX IF NOT EXISTS "presence_revision_presence_id" ON "presence_revision" ("presence_id");
+
+-- Columns changed since the tables were first created by orm.RunSyncdb
+ALTER TABLE "notification_log" ADD COLUMN IF NOT EXISTS "channel" varchar(20) NOT NULL DEFAULT 'email';
+ALTER TABLE "webhook" ALTER COLUMN "secret" TYPE varchar(255);
@@ -0,0 +1,18 @@
+-- Drops the foreign keys, the rows stay as they are
+
+ALTER TABLE "api_key" DROP CONSTRAINT IF EXISTS "api_key_user_id_fkey";
+ALTER TABLE "login_attempt" DROP CONSTRAINT IF EXISTS "login_attempt_user_id_fkey";
+ALTER TABLE "recovery_code" DROP CONSTRAINT IF EXISTS "recovery_code_user_id_fkey";
+ALTER TABLE "password_history" DROP CONSTRAINT IF EXISTS "password_history_user_id_fkey";
+ALTER TABLE "password_reset_token" DROP CONSTRAINT IF EXISTS "password_reset_token_user_id_fkey";
+ALTER TABLE "notification_log" DROP CONSTRAINT IF EXISTS "notification_log_user_id_fkey";
+ALTER TABLE "notification_preference" DROP CONSTRAINT IF EXISTS "notification_preference_user_id_fkey";
+ALTER TABLE "webhook_delivery" DROP CONSTRAINT IF EXISTS "webhook_delivery_webhook_id_fkey";
+ALTER TABLE "payroll_export" DROP CONSTRAINT IF EXISTS "payroll_export_department_id_fkey";
+ALTER TABLE "payroll_export" DROP CONSTRAINT IF EXISTS "payroll_export_template_id_fkey";
+ALTER TABLE "leave" DROP CONSTRAINT IF EXISTS "leave_user_id_fkey";
+ALTER TABLE "presence" DROP CONSTRAINT IF EXISTS "presence_schedule_id_fkey";
+ALTER TABLE "presence" DROP CONSTRAINT IF EXISTS "presence_user_id_fkey";
+ALTER TABLE "schedule" DROP CONSTRAINT IF EXISTS "schedule_department_id_fkey";
+ALTER TABLE "user" DROP CONSTRAINT IF EXISTS "user_schedule_id_fkey";
+ALTER TABLE "user" DROP CONSTRAINT IF EXISTS "user_department_id_fkey";
@@ -0,0 +1,40 @@
+-- Foreign keys, which orm.RunSyncdb doesn't create, so the adopted databases get them as well. The ORM cascades
+-- deletions of users and webhooks itself, the database does the same so the order doesn't matter. Departments and
+-- schedules are only purged once nothing references them, so their keys restrict the deletion.
+--
+-- A key that already exists is kept. The keys are added without checking the existing rows first, so they hold for
+-- every new row right away, and validated afterwards. Rows of an adopted database referencing rows deleted before the
+-- keys existed leave their key unvalidated with a warning instead of failing the migration, the key is validated with
+-- ALTER TABLE ... VALIDATE CONSTRAINT once those rows are cleaned up.
+
+CREATE FUNCTION pg_temp.add_foreign_key(target_table text, key_name text, definition text) RETURNS void AS $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = key_name AND conrelid = format('%I', target_table)::regclass) THEN
+        EXECUTE format('ALTER TABLE %I ADD CONSTRAINT %I %s NOT VALID', target_table, key_name, definition);
+    END IF;
+    BEGIN
+        EXECUTE format('ALTER TABLE %I VALIDATE CONSTRAINT %I', target_table, key_name);
+    EXCEPTION WHEN foreign_key_violation THEN
+        RAISE WARNING 'foreign key % is not validated, rows of % reference missing rows', key_name, target_table;
+    END;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT pg_temp.add_foreign_key('user', 'user_department_id_fkey', 'FOREIGN KEY ("department_id") REFERENCES "department" ("id")');
+SELECT pg_temp.add_foreign_key('user', 'user_schedule_id_fkey', 'FOREIGN KEY ("schedule_id") REFERENCES "schedule" ("id")');
+SELECT pg_temp.add_foreign_key('schedule', 'schedule_department_id_fkey', 'FOREIGN KEY ("department_id") REFERENCES "department" ("id")');
+SELECT pg_temp.add_foreign_key('presence', 'presence_user_id_fkey', 'FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON DELETE CASCADE');
+SELECT pg_temp.add_foreign_key('presence', 'presence_schedule_id_fkey', 'FOREIGN KEY ("schedule_id") REFERENCES "schedule" ("id")');
+SELECT pg_temp.add_foreign_key('leave', 'leave_user_id_fkey', 'FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON DELETE CASCADE');
+SELECT pg_temp.add_foreign_key('payroll_export', 'payroll_export_template_id_fkey', 'FOREIGN KEY ("template_id") REFERENCES "payroll_template" ("id") ON DELETE SET NULL');
+SELECT pg_temp.add_foreign_key('payroll_export', 'payroll_export_department_id_fkey', 'FOREIGN KEY ("department_id") REFERENCES "department" ("id")');
+SELECT pg_temp.add_foreign_key('webhook_delivery', 'webhook_delivery_webhook_id_fkey', 'FOREIGN KEY ("webhook_id") REFERENCES "webhook" ("id") ON DELETE CASCADE');
+SELECT pg_temp.add_foreign_key('notification_preference', 'notification_preference_user_id_fkey', 'FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON DELETE CASCADE');
+SELECT pg_temp.add_foreign_key('notification_log', 'notification_log_user_id_fkey', 'FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON DELETE SET NULL');
+SELECT pg_temp.add_foreign_key('password_reset_token', 'password_reset_token_user_id_fkey', 'FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON DELETE CASCADE');
+SELECT pg_temp.add_foreign_key('password_history', 'password_history_user_id_fkey', 'FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON DELETE CASCADE');
+SELECT pg_temp.add_foreign_key('recovery_code', 'recovery_code_user_id_fkey', 'FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON DELETE CASCADE');
+SELECT pg_temp.add_foreign_key('login_attempt', 'login_attempt_user_id_fkey', 'FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON DELETE SET NULL');
+SELECT pg_temp.add_foreign_key('api_key', 'api_key_user_id_fkey', 'FOREIGN KEY ("user_id") REFERENCES "user" ("id") ON DELETE CASCADE');
+
+DROP FUNCTION pg_temp.add_foreign_key(text, text, text);
@@ -73,9 +73,10 @@ func SeedUsers() {
 
 		// Prepare users
 		users := []models.User{
-			{Name: "Admin", Role: constants.RoleAdmin, Email: "admin@example.com", Password: "1234", Department: &departments[0]},
-			{Name: "Employee1", Role: constants.RoleEmployee, Email: "employee1@example.com", Password: "1234", Department: &departments[1]},
-			{Name: "Employee2", Role: constants.RoleEmployee, Email: "employee2@example.com", Password: "1234", Department: &departments[2]},
+			{Name: "Admin", Role: constants.RoleAdmin, Email: "admin@example.com", Password: "1234", Department: &departments[0], MustChangePassword: true, EmailVerified: true},
+			{Name: "Employee1", Role: constants.RoleEmployee, Email: "employee1@example.com", Password: "1234", Department: &departments[1], MustChangePassword: true, EmailVerified: true},
+			{Name: "Employee2", Role: constants.RoleEmployee, Email: "employee2@example.com", Password: "1234", Department: &departments[2], MustChangePassword: true, EmailVerified: true},
+			{Name: "Manager1", Role: constants.RoleManager, Email: "manager1@example.com", Password: "1234", Department: &departments[1], MustChangePassword: true, EmailVerified: true},
 		}
 
 		// Seed users and assign schedules
@@ -86,7 +87,7 @@ func SeedUsers() {
 			}
 			user.Password = hashedPassword
 
-			if user.Role == constants.RoleEmployee {
+			if user.Role == constants.RoleEmployee || user.Role == constants.RoleManager {
 				// Assign a schedule before inserting user
 				err = assignScheduleToUser(&user, o)
 				if err != nil {
@@ -0,0 +1,17 @@
+package dto
+
+// ApiKeyRequest represents the structure of a request to create an API key
+// @Description ApiKeyRequest represents the structure of a request to create an API key
+type ApiKeyRequest struct {
+	Name          string   `json:"name" validate:"required,max=100" example:"Payroll export"`                                                                                                                                                                                                                                                                                                                                          // Name to recognize the key by
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=users:read users:write departments:read departments:write schedules:read schedules:write presences:read presences:write holidays:read holidays:write leaves:read leaves:write reports:read payroll:read payroll:write notifications:read notifications:write webhooks:read webhooks:write login-attempts:read audit-logs:read" example:"presences:read"` // Granted scopes, read grants GET and write the other methods of a resource
+	ExpiresInDays int      `json:"expires_in_days" validate:"omitempty,min=1,max=365" example:"90"`                                                                                                                                                                                                                                                                                                                                    // Lifetime of the key, defaults to 90 days
+}
+
+// ServiceAccountRequest represents the structure of a request to create a service account
+// @Description ServiceAccountRequest represents the structure of a request to create a service account
+type ServiceAccountRequest struct {
+	Name         string `json:"name" validate:"required,max=100" example:"HRIS sync"`                    // Name of the integration
+	Role         string `json:"role" validate:"required,oneof=EMPLOYEE MANAGER ADMIN" example:"MANAGER"` // Role the account's API keys act with
+	DepartmentId int    `json:"department_id" validate:"required" example:"1"`                           // Department of the account
+}
@@ -0,0 +1,94 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/snykk/beego-presence-api/models"
+)
+
+// ApiKeyResponse represents the structure of an API key response
+// @Description ApiKeyResponse represents the structure of an API key response
+type ApiKeyResponse struct {
+	Id         int        `json:"id" example:"1"`                                        // API key ID
+	UserId     int        `json:"user_id" example:"7"`                                   // Account the key authenticates as
+	Name       string     `json:"name" example:"Payroll export"`                         // Name to recognize the key by
+	Prefix     string     `json:"prefix" example:"bpa_3f9a1c2e"`                         // Leading characters of the key
+	Key        string     `json:"key,omitempty" example:"bpa_3f9a1c2e..."`               // The key, only returned when it is created
+	Scopes     []string   `json:"scopes" example:"presences:read"`                       // Granted scopes
+	ExpiresAt  time.Time  `json:"expires_at" example:"2025-03-01T00:00:00Z"`             // Expiry of the key
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" example:"2024-12-02T09:30:00Z"` // Last time the key was used
+	LastUsedIp string     `json:"last_used_ip,omitempty" example:"203.0.113.10"`         // Address the key was last used from
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" example:"2024-12-03T00:00:00Z"`   // Time the key was revoked
+	Active     bool       `json:"active" example:"true"`                                 // Whether the key is neither revoked nor expired
+	CreatedAt  time.Time  `json:"created_at" example:"2024-12-01T00:00:00Z"`             // Creation timestamp
+}
+
+// ServiceAccountResponse represents the structure of a service account response
+// @Description ServiceAccountResponse represents the structure of a service account response
+type ServiceAccountResponse struct {
+	Id           int       `json:"id" example:"7"`                                                    // Account ID
+	Name         string    `json:"name" example:"HRIS sync"`                                          // Name of the integration
+	Email        string    `json:"email" example:"service-account-3f9a1c2e@service-accounts.invalid"` // Generated email of the account
+	Role         string    `json:"role" example:"MANAGER"`                                            // Role the account's API keys act with
+	DepartmentId int       `json:"department_id" example:"1"`                                         // Department of the account
+	Active       bool      `json:"active" example:"true"`                                             // Whether the account was not deactivated
+	CreatedAt    time.Time `json:"created_at" example:"2024-12-01T00:00:00Z"`                         // Creation timestamp
+}
+
+func FromApiKeyModelToApiKeyResponse(k *models.ApiKey, now time.Time) *ApiKeyResponse {
+	apiKeyResponse := &ApiKeyResponse{
+		Id:         k.Id,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.GetScopes(),
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		LastUsedIp: k.LastUsedIp,
+		RevokedAt:  k.RevokedAt,
+		Active:     k.IsUsable(now),
+		CreatedAt:  k.CreatedAt,
+	}
+
+	if k.User != nil {
+		apiKeyResponse.UserId = k.User.Id
+	}
+
+	return apiKeyResponse
+}
+
+func FromApiKeyModelListToApiKeyResponseList(keys []*models.ApiKey, now time.Time) []*ApiKeyResponse {
+	var result []*ApiKeyResponse
+
+	for _, val := range keys {
+		result = append(result, FromApiKeyModelToApiKeyResponse(val, now))
+	}
+
+	return result
+}
+
+func FromUserModelToServiceAccountResponse(u *models.User) *ServiceAccountResponse {
+	serviceAccountResponse := &ServiceAccountResponse{
+		Id:        u.Id,
+		Name:      u.Name,
+		Email:     u.Email,
+		Role:      u.Role,
+		Active:    u.IsActive(),
+		CreatedAt: u.CreatedAt,
+	}
+
+	if u.Department != nil {
+		serviceAccountResponse.DepartmentId = u.Department.Id
+	}
+
+	return serviceAccountResponse
+}
+
+func FromUserModelListToServiceAccountResponseList(users []*models.User) []*ServiceAccountResponse {
+	var result []*ServiceAccountResponse
+
+	for _, val := range users {
+		result = append(result, FromUserModelToServiceAccountResponse(val))
+	}
+
+	return result
+}
@@ -0,0 +1,46 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/snykk/beego-presence-api/models"
+)
+
+// AuditLogResponse represents the record of a change to a user, department, schedule or presence
+// @Description AuditLogResponse represents the record of a change to a user, department, schedule or presence
+type AuditLogResponse struct {
+	Id         int             `json:"id" example:"1"`
+	ActorId    int             `json:"actor_id" example:"1"`                      // User who made the change
+	Action     string          `json:"action" example:"update"`                   // create, update, delete or restore
+	EntityType string          `json:"entity_type" example:"presence"`            // user, department, schedule or presence
+	EntityId   int             `json:"entity_id" example:"42"`                    // Id of the changed entity
+	Changes    json.RawMessage `json:"changes" swaggertype:"object" example:"{}"` // Changed fields with their values before and after the change
+	Ip         string          `json:"ip" example:"203.0.113.7"`                  // IP address of the actor
+	CreatedAt  time.Time       `json:"created_at" example:"2024-12-02T08:00:00Z"` // Time of the change
+}
+
+func FromAuditLogModelToAuditLogResponse(a *models.AuditLog) *AuditLogResponse {
+	changes := json.RawMessage(a.Changes)
+	if !json.Valid(changes) {
+		changes = json.RawMessage("{}")
+	}
+	return &AuditLogResponse{
+		Id:         a.Id,
+		ActorId:    a.ActorId,
+		Action:     a.Action,
+		EntityType: a.EntityType,
+		EntityId:   a.EntityId,
+		Changes:    changes,
+		Ip:         a.Ip,
+		CreatedAt:  a.CreatedAt,
+	}
+}
+
+func FromAuditLogModelListToAuditLogResponseList(entries []*models.AuditLog) []*AuditLogResponse {
+	result := make([]*AuditLogResponse, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, FromAuditLogModelToAuditLogResponse(entry))
+	}
+	return result
+}
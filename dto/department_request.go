@@ -1,20 +1,36 @@
 package dto
 
-import "github.com/snykk/beego-presence-api/models"
+import (
+	"strings"
+
+	"github.com/snykk/beego-presence-api/models"
+)
 
 // DepartmentRequest represents the structure of a department request
 // @Description DepartmentRequest represents the structure of a department request
 type DepartmentRequest struct {
-	Name string `json:"name" validate:"required" example:"Human Resources"` // Department name
+	Name                string   `json:"name" validate:"required" example:"Human Resources"`                         // Department name
+	AllowedEmailDomains []string `json:"allowed_email_domains" validate:"omitempty,dive,fqdn" example:"example.com"` // Email domains allowed to self-register, empty allows any
 }
 
 func (d *DepartmentRequest) ToDepartmentModel() *models.Department {
 	return &models.Department{
-		Name: d.Name,
+		Name:                d.Name,
+		AllowedEmailDomains: d.joinAllowedEmailDomains(),
 	}
 }
 
 func (d *DepartmentRequest) ToDepartmentModelWithValue(md *models.Department) *models.Department {
 	md.Name = d.Name
+	md.AllowedEmailDomains = d.joinAllowedEmailDomains()
 	return md
 }
+
+// joinAllowedEmailDomains normalizes the domains into the comma separated form stored on the department
+func (d *DepartmentRequest) joinAllowedEmailDomains() string {
+	domains := make([]string, 0, len(d.AllowedEmailDomains))
+	for _, domain := range d.AllowedEmailDomains {
+		domains = append(domains, strings.ToLower(strings.TrimSpace(domain)))
+	}
+	return strings.Join(domains, ",")
+}
@@ -9,20 +9,24 @@ import (
 // DepartmentResponse represents the structure of a department response
 // @Description DepartmentResponse represents the structure of a department response
 type DepartmentResponse struct {
-	Id        int                 `json:"id" example:"1"`                            // Department ID
-	Name      string              `json:"name" example:"Human Resources"`            // Department name
-	Users     []*UserResponse     `json:"users,omitempty"`                           // List of users in the department
-	Schedules []*ScheduleResponse `json:"schedules,omitempty"`                       // List of schedules for the department
-	CreatedAt time.Time           `json:"created_at" example:"2023-01-01T00:00:00Z"` // Creation timestamp
-	UpdatedAt time.Time           `json:"updated_at" example:"2023-01-02T00:00:00Z"` // Last update timestamp
+	Id                  int                 `json:"id" example:"1"`                                      // Department ID
+	Name                string              `json:"name" example:"Human Resources"`                      // Department name
+	AllowedEmailDomains []string            `json:"allowed_email_domains" example:"example.com"`         // Email domains allowed to self-register, empty allows any
+	Users               []*UserResponse     `json:"users,omitempty"`                                     // List of users in the department
+	Schedules           []*ScheduleResponse `json:"schedules,omitempty"`                                 // List of schedules for the department
+	CreatedAt           time.Time           `json:"created_at" example:"2023-01-01T00:00:00Z"`           // Creation timestamp
+	UpdatedAt           time.Time           `json:"updated_at" example:"2023-01-02T00:00:00Z"`           // Last update timestamp
+	DeletedAt           *time.Time          `json:"deleted_at,omitempty" example:"2023-01-03T00:00:00Z"` // Deletion timestamp, only set on deleted departments
 }
 
 func FromDepartmentModelToDepartmentResponse(d *models.Department, isIncludeUserList, isIncludeScheduleList bool) *DepartmentResponse {
 	departmentResponse := &DepartmentResponse{
-		Id:        d.Id,
-		Name:      d.Name,
-		CreatedAt: d.CreatedAt,
-		UpdatedAt: d.UpdatedAt,
+		Id:                  d.Id,
+		Name:                d.Name,
+		AllowedEmailDomains: d.GetAllowedEmailDomains(),
+		CreatedAt:           d.CreatedAt,
+		UpdatedAt:           d.UpdatedAt,
+		DeletedAt:           d.DeletedAt,
 	}
 
 	if isIncludeUserList {
@@ -0,0 +1,21 @@
+package dto
+
+import "github.com/snykk/beego-presence-api/models"
+
+// DependentsResponse represents the rows depending on a deleted department or schedule
+// @Description DependentsResponse represents the rows depending on a deleted department or schedule
+type DependentsResponse struct {
+	Users        int64 `json:"users" example:"12"`                  // Assigned users, they have to be reassigned before the deletion
+	Schedules    int64 `json:"schedules,omitempty" example:"2"`     // Schedules of the department, reassigned or deleted with it
+	Presences    int64 `json:"presences" example:"340"`             // Presences of the assigned users, kept as history
+	ReassignedTo int   `json:"reassigned_to,omitempty" example:"3"` // Department or schedule the users were moved to
+}
+
+func FromDependentsModelToDependentsResponse(d models.Dependents, reassignedTo int) *DependentsResponse {
+	return &DependentsResponse{
+		Users:        d.Users,
+		Schedules:    d.Schedules,
+		Presences:    d.Presences,
+		ReassignedTo: reassignedTo,
+	}
+}
@@ -0,0 +1,44 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/snykk/beego-presence-api/models"
+)
+
+// LoginAttemptResponse represents the audit record of a login attempt
+// @Description LoginAttemptResponse represents the audit record of a login attempt
+type LoginAttemptResponse struct {
+	Id        int       `json:"id" example:"1"`
+	UserId    *int      `json:"user_id,omitempty" example:"2"` // Empty for unknown emails and deleted users
+	Email     string    `json:"email" example:"employee1@example.com"`
+	Ip        string    `json:"ip" example:"203.0.113.7"`
+	UserAgent string    `json:"user_agent" example:"Mozilla/5.0"`
+	Success   bool      `json:"success" example:"false"`
+	Reason    string    `json:"reason" example:"invalid_password"`
+	CreatedAt time.Time `json:"created_at" example:"2024-12-02T08:00:00Z"`
+}
+
+func FromLoginAttemptModelToLoginAttemptResponse(a *models.LoginAttempt) *LoginAttemptResponse {
+	response := &LoginAttemptResponse{
+		Id:        a.Id,
+		Email:     a.Email,
+		Ip:        a.Ip,
+		UserAgent: a.UserAgent,
+		Success:   a.Success,
+		Reason:    a.Reason,
+		CreatedAt: a.CreatedAt,
+	}
+	if a.User != nil {
+		response.UserId = &a.User.Id
+	}
+	return response
+}
+
+func FromLoginAttemptModelListToLoginAttemptResponseList(attempts []*models.LoginAttempt) []*LoginAttemptResponse {
+	result := make([]*LoginAttemptResponse, 0, len(attempts))
+	for _, attempt := range attempts {
+		result = append(result, FromLoginAttemptModelToLoginAttemptResponse(attempt))
+	}
+	return result
+}
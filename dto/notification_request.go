@@ -0,0 +1,34 @@
+package dto
+
+import "github.com/snykk/beego-presence-api/constants"
+
+// NotificationPreferencesRequest represents the notification preferences to change, omitted types are left unchanged
+// @Description NotificationPreferencesRequest represents the notification preferences to change, omitted types are left unchanged
+type NotificationPreferencesRequest struct {
+	Welcome         *bool `json:"welcome" example:"true"`           // Welcome email after registration
+	LateDigest      *bool `json:"late_digest" example:"true"`       // Daily digest of late arrivals (managers)
+	MissingCheckout *bool `json:"missing_checkout" example:"false"` // Reminder for a check-in without check-out
+	ShiftReminder   *bool `json:"shift_reminder" example:"true"`    // Reminder before the shift starts
+	MissedCheckIn   *bool `json:"missed_checkin" example:"true"`    // Nudge when there is no check-in by the late threshold
+}
+
+// ToPreferenceMap maps the given preferences by notification type
+func (r *NotificationPreferencesRequest) ToPreferenceMap() map[string]bool {
+	preferences := make(map[string]bool)
+	if r.Welcome != nil {
+		preferences[constants.NotificationTypeWelcome] = *r.Welcome
+	}
+	if r.LateDigest != nil {
+		preferences[constants.NotificationTypeLateDigest] = *r.LateDigest
+	}
+	if r.MissingCheckout != nil {
+		preferences[constants.NotificationTypeMissingCheckout] = *r.MissingCheckout
+	}
+	if r.ShiftReminder != nil {
+		preferences[constants.NotificationTypeShiftReminder] = *r.ShiftReminder
+	}
+	if r.MissedCheckIn != nil {
+		preferences[constants.NotificationTypeMissedCheckIn] = *r.MissedCheckIn
+	}
+	return preferences
+}
@@ -0,0 +1,79 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/models"
+)
+
+// NotificationPreferencesResponse represents the notification preferences of a user
+// @Description NotificationPreferencesResponse represents the notification preferences of a user
+type NotificationPreferencesResponse struct {
+	Welcome         bool `json:"welcome" example:"true"`
+	LateDigest      bool `json:"late_digest" example:"true"`
+	MissingCheckout bool `json:"missing_checkout" example:"false"`
+	ShiftReminder   bool `json:"shift_reminder" example:"true"`
+	MissedCheckIn   bool `json:"missed_checkin" example:"true"`
+}
+
+// NotificationLogResponse represents an attempt to send a notification
+// @Description NotificationLogResponse represents an attempt to send a notification
+type NotificationLogResponse struct {
+	Id        int       `json:"id" example:"1"`
+	UserId    *int      `json:"user_id,omitempty" example:"2"` // Empty once the user was deleted
+	Type      string    `json:"type" example:"missing_checkout"`
+	Reference string    `json:"reference" example:"missing_checkout:2:2024-12-02"`
+	Channel   string    `json:"channel" example:"email"`
+	Recipient string    `json:"recipient" example:"employee1@example.com"`
+	Subject   string    `json:"subject" example:"Missing check-out on 2024-12-02"`
+	Status    string    `json:"status" example:"sent"`
+	Error     string    `json:"error,omitempty" example:"dial tcp: connection refused"`
+	CreatedAt time.Time `json:"created_at" example:"2024-12-02T20:00:00Z"`
+}
+
+// FromNotificationPreferenceModelListToNotificationPreferencesResponse applies the stored preferences over the defaults, every notification is enabled by default
+func FromNotificationPreferenceModelListToNotificationPreferencesResponse(preferences []*models.NotificationPreference) *NotificationPreferencesResponse {
+	response := &NotificationPreferencesResponse{Welcome: true, LateDigest: true, MissingCheckout: true, ShiftReminder: true, MissedCheckIn: true}
+	for _, preference := range preferences {
+		switch preference.Type {
+		case constants.NotificationTypeWelcome:
+			response.Welcome = preference.Enabled
+		case constants.NotificationTypeLateDigest:
+			response.LateDigest = preference.Enabled
+		case constants.NotificationTypeMissingCheckout:
+			response.MissingCheckout = preference.Enabled
+		case constants.NotificationTypeShiftReminder:
+			response.ShiftReminder = preference.Enabled
+		case constants.NotificationTypeMissedCheckIn:
+			response.MissedCheckIn = preference.Enabled
+		}
+	}
+	return response
+}
+
+func FromNotificationLogModelToNotificationLogResponse(l *models.NotificationLog) *NotificationLogResponse {
+	response := &NotificationLogResponse{
+		Id:        l.Id,
+		Type:      l.Type,
+		Reference: l.Reference,
+		Channel:   l.Channel,
+		Recipient: l.Recipient,
+		Subject:   l.Subject,
+		Status:    l.Status,
+		Error:     l.Error,
+		CreatedAt: l.CreatedAt,
+	}
+	if l.User != nil {
+		response.UserId = &l.User.Id
+	}
+	return response
+}
+
+func FromNotificationLogModelListToNotificationLogResponseList(entries []*models.NotificationLog) []*NotificationLogResponse {
+	result := make([]*NotificationLogResponse, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, FromNotificationLogModelToNotificationLogResponse(entry))
+	}
+	return result
+}
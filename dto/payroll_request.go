@@ -0,0 +1,102 @@
+package dto
+
+import (
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/models"
+)
+
+// PayrollFieldRequest represents the mapping of a single payroll file column
+// @Description PayrollFieldRequest represents the mapping of a single payroll file column
+type PayrollFieldRequest struct {
+	Code     string `json:"code" validate:"required,max=50" example:"LATEDED"`               // Column code expected by the payroll system
+	Source   string `json:"source" validate:"required,max=30" example:"late_deduction"`      // Attendance value of the column
+	Bucket   string `json:"bucket" validate:"max=20" example:"L15"`                          // Lateness bucket code for bucket_count and bucket_deduction
+	Value    string `json:"value" validate:"max=100" example:""`                             // Fixed value for the literal source
+	Unit     string `json:"unit" validate:"omitempty,oneof=minutes hours" example:"minutes"` // Unit of duration sources, defaults to minutes
+	Decimals int    `json:"decimals" validate:"min=0,max=6" example:"0"`                     // Number of decimals of numeric values
+	Width    int    `json:"width" validate:"min=0,max=255" example:"12"`                     // Column width, required for fixed-width templates
+	Align    string `json:"align" validate:"omitempty,oneof=left right" example:"right"`     // Alignment in fixed-width files, defaults to right for numbers and left for text
+	Pad      string `json:"pad" validate:"max=1" example:"0"`                                // Padding character in fixed-width files, defaults to a space
+}
+
+// PayrollLateBucketRequest represents a deduction rule for late check-ins within a range of late minutes
+// @Description PayrollLateBucketRequest represents a deduction rule for late check-ins within a range of late minutes
+type PayrollLateBucketRequest struct {
+	Code       string  `json:"code" validate:"required,max=20" example:"L15"` // Bucket code referenced by fields
+	MinMinutes int     `json:"min_minutes" validate:"min=1" example:"1"`      // Inclusive lower bound of late minutes
+	MaxMinutes int     `json:"max_minutes" validate:"min=0" example:"15"`     // Inclusive upper bound of late minutes, 0 means unbounded
+	Amount     float64 `json:"amount" validate:"min=0" example:"25000"`       // Deduction per late day, or per late minute when per_minute is set
+	PerMinute  bool    `json:"per_minute" example:"false"`                    // Charge the amount per late minute
+}
+
+// PayrollTemplateRequest represents the structure of a payroll template create or update request
+// @Description PayrollTemplateRequest represents the structure of a payroll template create or update request
+type PayrollTemplateRequest struct {
+	Name            string                      `json:"name" validate:"required,max=100" example:"Acme Payroll"`                    // Name of the template
+	Format          string                      `json:"format" validate:"required,oneof=csv fixed" example:"csv"`                   // File format: csv or fixed (fixed-width)
+	Delimiter       string                      `json:"delimiter" validate:"max=1" example:";"`                                     // Field delimiter of CSV files, defaults to a comma
+	IncludeHeader   *bool                       `json:"include_header" example:"true"`                                              // Write a header row with the column codes, defaults to true
+	RoundingMode    string                      `json:"rounding_mode" validate:"omitempty,oneof=none up down nearest" example:"up"` // Rounding of daily durations, defaults to none
+	RoundingMinutes int                         `json:"rounding_minutes" validate:"min=0,max=60" example:"5"`                       // Rounding unit in minutes, defaults to 1
+	Fields          []*PayrollFieldRequest      `json:"fields" validate:"required,min=1,dive"`                                      // Columns of the file in order
+	LateBuckets     []*PayrollLateBucketRequest `json:"late_buckets" validate:"dive"`                                               // Deduction rules per lateness bucket
+}
+
+func (p PayrollTemplateRequest) ToPayrollTemplateModel() *models.PayrollTemplate {
+	template := &models.PayrollTemplate{
+		Name:            p.Name,
+		Format:          p.Format,
+		Delimiter:       p.Delimiter,
+		IncludeHeader:   p.IncludeHeader == nil || *p.IncludeHeader,
+		RoundingMode:    p.RoundingMode,
+		RoundingMinutes: p.RoundingMinutes,
+	}
+
+	if template.Delimiter == "" {
+		template.Delimiter = ","
+	}
+	if template.RoundingMode == "" {
+		template.RoundingMode = constants.PayrollRoundingNone
+	}
+	if template.RoundingMinutes == 0 {
+		template.RoundingMinutes = 1
+	}
+
+	fields := make([]models.PayrollField, 0, len(p.Fields))
+	for _, f := range p.Fields {
+		fields = append(fields, models.PayrollField{
+			Code:     f.Code,
+			Source:   f.Source,
+			Bucket:   f.Bucket,
+			Value:    f.Value,
+			Unit:     f.Unit,
+			Decimals: f.Decimals,
+			Width:    f.Width,
+			Align:    f.Align,
+			Pad:      f.Pad,
+		})
+	}
+	template.SetFields(fields)
+
+	buckets := make([]models.PayrollLateBucket, 0, len(p.LateBuckets))
+	for _, b := range p.LateBuckets {
+		buckets = append(buckets, models.PayrollLateBucket{
+			Code:       b.Code,
+			MinMinutes: b.MinMinutes,
+			MaxMinutes: b.MaxMinutes,
+			Amount:     b.Amount,
+			PerMinute:  b.PerMinute,
+		})
+	}
+	template.SetLateBuckets(buckets)
+
+	return template
+}
+
+// PayrollExportRequest represents the structure of a payroll export request
+// @Description PayrollExportRequest represents the structure of a payroll export request
+type PayrollExportRequest struct {
+	TemplateId   int    `json:"template_id" validate:"required,min=1" example:"1"`             // Payroll template to generate the file with
+	DepartmentId int    `json:"department_id" validate:"required,min=1" example:"1"`           // Exported department
+	Period       string `json:"period" validate:"required,datetime=2006-01" example:"2024-12"` // Exported month (YYYY-MM)
+}
@@ -0,0 +1,100 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/snykk/beego-presence-api/models"
+)
+
+// PayrollTemplateResponse represents the structure of a payroll template response
+// @Description PayrollTemplateResponse represents the structure of a payroll template response
+type PayrollTemplateResponse struct {
+	Id              int                        `json:"id" example:"1"`                            // Template ID
+	Name            string                     `json:"name" example:"Acme Payroll"`               // Name of the template
+	Format          string                     `json:"format" example:"csv"`                      // File format: csv or fixed
+	Delimiter       string                     `json:"delimiter" example:";"`                     // Field delimiter of CSV files
+	IncludeHeader   bool                       `json:"include_header" example:"true"`             // Whether a header row is written
+	RoundingMode    string                     `json:"rounding_mode" example:"up"`                // Rounding of daily durations
+	RoundingMinutes int                        `json:"rounding_minutes" example:"5"`              // Rounding unit in minutes
+	Fields          []models.PayrollField      `json:"fields"`                                    // Columns of the file in order
+	LateBuckets     []models.PayrollLateBucket `json:"late_buckets"`                              // Deduction rules per lateness bucket
+	CreatedAt       time.Time                  `json:"created_at" example:"2024-12-01T00:00:00Z"` // Creation timestamp
+	UpdatedAt       time.Time                  `json:"updated_at" example:"2024-12-01T00:00:00Z"` // Last update timestamp
+}
+
+// PayrollExportResponse represents the structure of a payroll export response
+// @Description PayrollExportResponse represents the structure of a payroll export response
+type PayrollExportResponse struct {
+	Id           int       `json:"id" example:"1"`                            // Export ID
+	TemplateId   *int      `json:"template_id" example:"1"`                   // Template the file was generated with, null once the template is deleted
+	DepartmentId int       `json:"department_id" example:"1"`                 // Exported department
+	Department   string    `json:"department" example:"Engineering"`          // Name of the exported department
+	Period       string    `json:"period" example:"2024-12"`                  // Exported month (YYYY-MM)
+	RecordCount  int       `json:"record_count" example:"15"`                 // Number of employees in the file
+	Filename     string    `json:"filename" example:"payroll-1-2024-12.csv"`  // Name of the generated file
+	CreatedAt    time.Time `json:"created_at" example:"2025-01-02T08:00:00Z"` // Export timestamp
+}
+
+func FromPayrollTemplateModelToPayrollTemplateResponse(t *models.PayrollTemplate) *PayrollTemplateResponse {
+	// Templates are validated before they are stored, decoding errors leave the lists empty
+	fields, _ := t.GetFields()
+	buckets, _ := t.GetLateBuckets()
+	if buckets == nil {
+		buckets = []models.PayrollLateBucket{}
+	}
+
+	return &PayrollTemplateResponse{
+		Id:              t.Id,
+		Name:            t.Name,
+		Format:          t.Format,
+		Delimiter:       t.Delimiter,
+		IncludeHeader:   t.IncludeHeader,
+		RoundingMode:    t.RoundingMode,
+		RoundingMinutes: t.RoundingMinutes,
+		Fields:          fields,
+		LateBuckets:     buckets,
+		CreatedAt:       t.CreatedAt,
+		UpdatedAt:       t.UpdatedAt,
+	}
+}
+
+func FromPayrollTemplateModelListToPayrollTemplateResponseList(templates []*models.PayrollTemplate) []*PayrollTemplateResponse {
+	var result []*PayrollTemplateResponse
+
+	for _, val := range templates {
+		result = append(result, FromPayrollTemplateModelToPayrollTemplateResponse(val))
+	}
+
+	return result
+}
+
+func FromPayrollExportModelToPayrollExportResponse(e *models.PayrollExport) *PayrollExportResponse {
+	response := &PayrollExportResponse{
+		Id:          e.Id,
+		Period:      e.Period,
+		RecordCount: e.RecordCount,
+		Filename:    e.Filename,
+		CreatedAt:   e.CreatedAt,
+	}
+
+	if e.Template != nil && e.Template.Id != 0 {
+		response.TemplateId = &e.Template.Id
+	}
+
+	if e.Department != nil {
+		response.DepartmentId = e.Department.Id
+		response.Department = e.Department.Name
+	}
+
+	return response
+}
+
+func FromPayrollExportModelListToPayrollExportResponseList(exports []*models.PayrollExport) []*PayrollExportResponse {
+	var result []*PayrollExportResponse
+
+	for _, val := range exports {
+		result = append(result, FromPayrollExportModelToPayrollExportResponse(val))
+	}
+
+	return result
+}
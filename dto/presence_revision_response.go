@@ -0,0 +1,82 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/snykk/beego-presence-api/models"
+)
+
+// PresenceRevisionResponse represents a snapshot of a presence recorded with a change
+// @Description PresenceRevisionResponse represents a snapshot of a presence recorded with a change
+type PresenceRevisionResponse struct {
+	Revision          int       `json:"revision" example:"2"`                                                                     // Starts at 1 and increases by 1 with every change
+	Action            string    `json:"action" example:"updated"`                                                                 // created, updated, deleted, baseline or out_of_band
+	UserId            int       `json:"user_id" example:"2"`                                                                      // User of the presence
+	ScheduleId        int       `json:"schedule_id" example:"1"`                                                                  // Schedule of the presence
+	Type              string    `json:"type" example:"in"`                                                                        // Type of the presence
+	Status            string    `json:"status" example:"ontime"`                                                                  // Status of the presence
+	PresenceCreatedAt time.Time `json:"presence_created_at" example:"2024-12-02T08:20:00Z"`                                       // Time of the check-in or check-out
+	ActorId           int       `json:"actor_id" example:"1"`                                                                     // User who made the change, 0 for changes outside the API
+	PreviousHash      string    `json:"previous_hash" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"` // Hash of the previous revision
+	Hash              string    `json:"hash" example:"2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"`          // Hash of the revision and the previous hash
+	CreatedAt         time.Time `json:"created_at" example:"2024-12-02T09:00:00Z"`                                                // Time of the change
+}
+
+// PresenceProblemResponse represents a problem found by the verification of the presence history
+// @Description PresenceProblemResponse represents a problem found by the verification of the presence history
+type PresenceProblemResponse struct {
+	PresenceId int    `json:"presence_id" example:"42"`
+	Revision   int    `json:"revision,omitempty" example:"2"`     // Revision the problem was found at
+	Problem    string `json:"problem" example:"presence_changed"` // revision_hash_mismatch, revision_chain_broken, presence_changed, presence_deleted, presence_untracked or out_of_band_change
+}
+
+// PresenceHistoryResponse represents the edit history of a presence
+// @Description PresenceHistoryResponse represents the edit history of a presence
+type PresenceHistoryResponse struct {
+	PresenceId int                         `json:"presence_id" example:"42"`
+	Intact     bool                        `json:"intact" example:"true"` // No problems were found in the history and the presence matches its last revision
+	Problems   []*PresenceProblemResponse  `json:"problems"`
+	Revisions  []*PresenceRevisionResponse `json:"revisions"` // Oldest revision first
+}
+
+// PresenceVerificationResponse represents the result of verifying the history of every presence
+// @Description PresenceVerificationResponse represents the result of verifying the history of every presence
+type PresenceVerificationResponse struct {
+	Presences int                        `json:"presences" example:"1200"` // Verified presences, including deleted ones
+	Revisions int                        `json:"revisions" example:"1350"` // Verified revisions
+	Intact    bool                       `json:"intact" example:"true"`    // No problems were found
+	Problems  []*PresenceProblemResponse `json:"problems"`
+}
+
+func FromPresenceRevisionModelToPresenceRevisionResponse(r *models.PresenceRevision) *PresenceRevisionResponse {
+	return &PresenceRevisionResponse{
+		Revision:          r.Revision,
+		Action:            r.Action,
+		UserId:            r.UserId,
+		ScheduleId:        r.ScheduleId,
+		Type:              r.Type,
+		Status:            r.Status,
+		PresenceCreatedAt: r.PresenceCreatedAt,
+		ActorId:           r.ActorId,
+		PreviousHash:      r.PreviousHash,
+		Hash:              r.Hash,
+		CreatedAt:         r.CreatedAt,
+	}
+}
+
+func FromPresenceRevisionModelListToPresenceRevisionResponseList(revisions []*models.PresenceRevision) []*PresenceRevisionResponse {
+	result := make([]*PresenceRevisionResponse, 0, len(revisions))
+	for _, revision := range revisions {
+		result = append(result, FromPresenceRevisionModelToPresenceRevisionResponse(revision))
+	}
+	return result
+}
+
+func FromPresenceRevisionModelListToPresenceHistoryResponse(presenceId int, revisions []*models.PresenceRevision, problems []*PresenceProblemResponse) *PresenceHistoryResponse {
+	return &PresenceHistoryResponse{
+		PresenceId: presenceId,
+		Intact:     len(problems) == 0,
+		Problems:   problems,
+		Revisions:  FromPresenceRevisionModelListToPresenceRevisionResponseList(revisions),
+	}
+}
@@ -0,0 +1,117 @@
+package dto
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/snykk/beego-presence-api/models"
+)
+
+// AttendanceDayResponse represents the attendance of a user on a single day
+// @Description AttendanceDayResponse represents the attendance of a user on a single day
+type AttendanceDayResponse struct {
+	Date              string  `json:"date" example:"2024-12-02"`                                   // Day of the month (YYYY-MM-DD)
+	ScheduledIn       *string `json:"scheduled_in,omitempty" example:"2024-12-02T09:00:00+07:00"`  // Scheduled start of the shift
+	ScheduledOut      *string `json:"scheduled_out,omitempty" example:"2024-12-02T17:00:00+07:00"` // Scheduled end of the shift
+	CheckIn           *string `json:"check_in,omitempty" example:"2024-12-02T09:20:00+07:00"`      // Time of the "in" presence
+	CheckOut          *string `json:"check_out,omitempty" example:"2024-12-02T17:05:00+07:00"`     // Time of the "out" presence
+	Status            string  `json:"status" example:"late"`                                       // present, late, absent, pending or the day marker
+	Marker            string  `json:"marker,omitempty" example:"holiday"`                          // weekend, holiday or leave
+	MarkerDescription string  `json:"marker_description,omitempty" example:"Christmas Day"`        // Holiday name or leave reason
+	WorkedMinutes     int     `json:"worked_minutes" example:"465"`                                // Minutes between check-in and check-out
+	LateMinutes       int     `json:"late_minutes" example:"20"`                                   // Minutes between scheduled start and a late check-in
+}
+
+// AttendanceTotalsResponse represents the monthly attendance totals of a user
+// @Description AttendanceTotalsResponse represents the monthly attendance totals of a user
+type AttendanceTotalsResponse struct {
+	WorkingDays        int `json:"working_days" example:"21"`           // Days that are neither weekend, holiday nor leave
+	DaysPresent        int `json:"days_present" example:"19"`           // Days with a check-in (on time or late)
+	LateCount          int `json:"late_count" example:"3"`              // Days with a late check-in
+	AbsentCount        int `json:"absent_count" example:"1"`            // Elapsed working days without a check-in
+	LeaveCount         int `json:"leave_count" example:"1"`             // Days covered by a leave
+	TotalLateMinutes   int `json:"total_late_minutes" example:"55"`     // Sum of late minutes
+	TotalWorkedMinutes int `json:"total_worked_minutes" example:"8820"` // Sum of worked minutes
+}
+
+// MonthlyAttendanceResponse represents the monthly attendance report of a user
+// @Description MonthlyAttendanceResponse represents the monthly attendance report of a user
+type MonthlyAttendanceResponse struct {
+	User   *UserResponse            `json:"user"`                    // User the report belongs to
+	Month  string                   `json:"month" example:"2024-12"` // Reported month (YYYY-MM)
+	Days   []*AttendanceDayResponse `json:"days"`                    // One row per day of the month
+	Totals AttendanceTotalsResponse `json:"totals"`                  // Monthly totals
+}
+
+// DepartmentDayResponse represents the aggregated attendance of a department on a single day
+// @Description DepartmentDayResponse represents the aggregated attendance of a department on a single day
+type DepartmentDayResponse struct {
+	Date         string `json:"date" example:"2024-12-02"`     // Day (YYYY-MM-DD)
+	IsWorkingDay bool   `json:"is_working_day" example:"true"` // False on weekends and holidays
+	Present      int    `json:"present" example:"12"`          // Users who checked in (on time or late)
+	Late         int    `json:"late" example:"2"`              // Users who checked in late
+	Absent       int    `json:"absent" example:"1"`            // Users without check-in or leave on an elapsed working day
+	OnLeave      int    `json:"on_leave" example:"1"`          // Users on leave
+}
+
+// LateArrivalResponse represents the lateness of a single user over a date range
+// @Description LateArrivalResponse represents the lateness of a single user over a date range
+type LateArrivalResponse struct {
+	UserId      int    `json:"user_id" example:"2"`       // User ID
+	Name        string `json:"name" example:"Employee1"`  // Name of the user
+	LateCount   int    `json:"late_count" example:"4"`    // Number of late check-ins
+	LateMinutes int    `json:"late_minutes" example:"95"` // Sum of minutes between scheduled start and check-in
+}
+
+// DepartmentDashboardResponse represents the attendance dashboard of a department over a date range
+// @Description DepartmentDashboardResponse represents the attendance dashboard of a department over a date range
+type DepartmentDashboardResponse struct {
+	Department      *DepartmentResponse      `json:"department"`                                    // Reported department
+	From            string                   `json:"from" example:"2024-12-01"`                     // First reported day (YYYY-MM-DD)
+	To              string                   `json:"to" example:"2024-12-31"`                       // Last reported day (YYYY-MM-DD)
+	Headcount       int                      `json:"headcount" example:"15"`                        // Users assigned to the department
+	Days            []*DepartmentDayResponse `json:"days"`                                          // One row per day of the range
+	AverageCheckIn  *string                  `json:"average_check_in,omitempty" example:"09:04:12"` // Average check-in clock time
+	PunctualityRate float64                  `json:"punctuality_rate" example:"87.5"`               // Percentage of check-ins that were on time
+	TopLateArrivals []*LateArrivalResponse   `json:"top_late_arrivals"`                             // Users with the most late check-ins
+}
+
+func FromDepartmentDashboardModelsToDashboardResponse(md *models.Department, from, to string, headcount int64, days []*models.DepartmentDailyAttendance, stats *models.DepartmentCheckInStats, lateArrivals []*models.DepartmentLateArrival) *DepartmentDashboardResponse {
+	dashboard := &DepartmentDashboardResponse{
+		Department:      FromDepartmentModelToDepartmentResponse(md, false, false),
+		From:            from,
+		To:              to,
+		Headcount:       int(headcount),
+		Days:            []*DepartmentDayResponse{},
+		TopLateArrivals: []*LateArrivalResponse{},
+	}
+
+	for _, day := range days {
+		dashboard.Days = append(dashboard.Days, &DepartmentDayResponse{
+			Date:         day.Day,
+			IsWorkingDay: !day.IsWeekend && !day.IsHoliday,
+			Present:      day.Present,
+			Late:         day.Late,
+			Absent:       day.Absent,
+			OnLeave:      day.OnLeave,
+		})
+	}
+
+	if stats.CheckIns > 0 {
+		seconds := int(stats.AverageCheckInSeconds)
+		averageCheckIn := fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds%3600/60, seconds%60)
+		dashboard.AverageCheckIn = &averageCheckIn
+		dashboard.PunctualityRate = math.Round(float64(stats.OnTime)/float64(stats.CheckIns)*10000) / 100
+	}
+
+	for _, arrival := range lateArrivals {
+		dashboard.TopLateArrivals = append(dashboard.TopLateArrivals, &LateArrivalResponse{
+			UserId:      arrival.UserId,
+			Name:        arrival.Name,
+			LateCount:   arrival.LateCount,
+			LateMinutes: arrival.LateMinutes,
+		})
+	}
+
+	return dashboard
+}
@@ -11,14 +11,15 @@ import (
 type ScheduleResponse struct {
 	Id           int                 `json:"id" example:"1"` // Unique identifier of the schedule
 	Name         string              `json:"name" example:"Morning Shift"`
-	DepartmentId *int                `json:"department_id,omitempty" example:"1"`       // ForeignKey to Department
-	Department   *DepartmentResponse `json:"department,omitempty"`                      // Department of the schedule
-	InTime       string              `json:"in_time" example:"08:00:00"`                // Time when the schedule starts
-	OutTime      string              `json:"out_time" example:"16:00:00"`               // Time when the schedule ends
-	Presences    []*PresenceResponse `json:"presences,omitempty"`                       // Reverse relationship with Presence
-	Users        []*UserResponse     `json:"users,omitempty"`                           // Reverse relationship with User
-	CreatedAt    time.Time           `json:"created_at" example:"2021-01-01T00:00:00Z"` // Time when the schedule was created
-	UpdatedAt    time.Time           `json:"updated_at" example:"2021-01-01T00:00:00Z"` // Time when the schedule was updated
+	DepartmentId *int                `json:"department_id,omitempty" example:"1"`                 // ForeignKey to Department
+	Department   *DepartmentResponse `json:"department,omitempty"`                                // Department of the schedule
+	InTime       string              `json:"in_time" example:"08:00:00"`                          // Time when the schedule starts
+	OutTime      string              `json:"out_time" example:"16:00:00"`                         // Time when the schedule ends
+	Presences    []*PresenceResponse `json:"presences,omitempty"`                                 // Reverse relationship with Presence
+	Users        []*UserResponse     `json:"users,omitempty"`                                     // Reverse relationship with User
+	CreatedAt    time.Time           `json:"created_at" example:"2021-01-01T00:00:00Z"`           // Time when the schedule was created
+	UpdatedAt    time.Time           `json:"updated_at" example:"2021-01-01T00:00:00Z"`           // Time when the schedule was updated
+	DeletedAt    *time.Time          `json:"deleted_at,omitempty" example:"2021-01-02T00:00:00Z"` // Time when the schedule was deleted, only set on deleted schedules
 }
 
 func FromScheduleModelToScheduleResponse(s *models.Schedule, isIncludeDepartment, isIncludePresenceList, isIncludeUserList bool) *ScheduleResponse {
@@ -30,6 +31,7 @@ func FromScheduleModelToScheduleResponse(s *models.Schedule, isIncludeDepartment
 		OutTime:      s.OutTime,
 		CreatedAt:    s.CreatedAt,
 		UpdatedAt:    s.UpdatedAt,
+		DeletedAt:    s.DeletedAt,
 	}
 
 	if isIncludeDepartment {
@@ -0,0 +1,60 @@
+package dto
+
+// ScimUserRequest represents a SCIM user created or replaced by the identity platform
+// @Description ScimUserRequest represents a SCIM user created or replaced by the identity platform
+type ScimUserRequest struct {
+	Schemas     []string            `json:"schemas"`
+	ExternalId  string              `json:"externalId" example:"00u1a2b3c4"`                               // Id of the user at the identity platform
+	UserName    string              `json:"userName" validate:"required,email" example:"jane@example.com"` // Email the user logs in with
+	Name        *ScimName           `json:"name"`
+	DisplayName string              `json:"displayName" example:"Jane Doe"`
+	Emails      []ScimMultiValue    `json:"emails"`
+	Active      *bool               `json:"active" example:"true"` // Inactive users are deactivated, their presence history is kept
+	Password    string              `json:"password" validate:"omitempty,securepwd"`
+	Roles       []ScimMultiValue    `json:"roles"` // EMPLOYEE, MANAGER or ADMIN
+	Enterprise  *ScimEnterpriseUser `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"`
+}
+
+// ScimName represents the name of a SCIM user
+type ScimName struct {
+	Formatted  string `json:"formatted,omitempty" example:"Jane Doe"`
+	GivenName  string `json:"givenName,omitempty" example:"Jane"`
+	FamilyName string `json:"familyName,omitempty" example:"Doe"`
+}
+
+// ScimEnterpriseUser represents the enterprise extension of a SCIM user
+type ScimEnterpriseUser struct {
+	Department string `json:"department,omitempty" example:"Engineering"` // Name of the department of the user
+}
+
+// ScimMultiValue represents a value of a multi-valued SCIM attribute, e.g. an email or a group member
+type ScimMultiValue struct {
+	Value   string `json:"value" example:"1"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// ScimGroupRequest represents a SCIM group created or replaced by the identity platform, groups are departments
+// @Description ScimGroupRequest represents a SCIM group created or replaced by the identity platform, groups are departments
+type ScimGroupRequest struct {
+	Schemas     []string         `json:"schemas"`
+	ExternalId  string           `json:"externalId" example:"00g1a2b3c4"`
+	DisplayName string           `json:"displayName" validate:"required" example:"Engineering"` // Name of the department
+	Members     []ScimMultiValue `json:"members"`                                               // Users of the department, by id
+}
+
+// ScimPatchRequest represents a SCIM PATCH request
+// @Description ScimPatchRequest represents a SCIM PATCH request
+type ScimPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []ScimPatchOperation `json:"Operations" validate:"required,min=1,dive"`
+}
+
+// ScimPatchOperation represents an operation of a SCIM PATCH request
+type ScimPatchOperation struct {
+	Op    string      `json:"op" validate:"required" example:"replace"` // add, replace or remove, case insensitive
+	Path  string      `json:"path" example:"active"`                    // Attribute to change, the value holds the attributes if empty
+	Value interface{} `json:"value"`
+}
@@ -0,0 +1,143 @@
+package dto
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/models"
+)
+
+// ScimMeta represents the metadata of a SCIM resource
+type ScimMeta struct {
+	ResourceType string    `json:"resourceType" example:"User"`
+	Created      time.Time `json:"created" example:"2024-12-01T00:00:00Z"`
+	LastModified time.Time `json:"lastModified" example:"2024-12-01T00:00:00Z"`
+	Location     string    `json:"location" example:"http://localhost:8080/scim/v2/Users/1"`
+}
+
+// ScimUserResponse represents a user as SCIM resource
+// @Description ScimUserResponse represents a user as SCIM resource
+type ScimUserResponse struct {
+	Schemas     []string            `json:"schemas"`
+	Id          string              `json:"id" example:"1"`
+	ExternalId  string              `json:"externalId,omitempty" example:"00u1a2b3c4"`
+	UserName    string              `json:"userName" example:"jane@example.com"`
+	Name        ScimName            `json:"name"`
+	DisplayName string              `json:"displayName" example:"Jane Doe"`
+	Emails      []ScimMultiValue    `json:"emails"`
+	Active      bool                `json:"active" example:"true"`
+	Roles       []ScimMultiValue    `json:"roles"`
+	Groups      []ScimMultiValue    `json:"groups"` // The department of the user, read-only
+	Enterprise  *ScimEnterpriseUser `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User,omitempty"`
+	Meta        ScimMeta            `json:"meta"`
+}
+
+// FromUserModelToScimUserResponse converts a user with its department into a SCIM resource, baseUrl is the URL of the SCIM API
+func FromUserModelToScimUserResponse(u *models.User, baseUrl string) *ScimUserResponse {
+	response := &ScimUserResponse{
+		Schemas:     []string{constants.ScimSchemaUser, constants.ScimSchemaEnterpriseUser},
+		Id:          strconv.Itoa(u.Id),
+		ExternalId:  u.ScimExternalId,
+		UserName:    u.Email,
+		Name:        ScimName{Formatted: u.Name},
+		DisplayName: u.Name,
+		Emails:      []ScimMultiValue{{Value: u.Email, Type: "work", Primary: true}},
+		Active:      u.IsActive(),
+		Roles:       []ScimMultiValue{{Value: u.Role, Primary: true}},
+		Groups:      []ScimMultiValue{},
+		Meta: ScimMeta{
+			ResourceType: constants.ScimResourceTypeUser,
+			Created:      u.CreatedAt,
+			LastModified: u.UpdatedAt,
+			Location:     baseUrl + "/Users/" + strconv.Itoa(u.Id),
+		},
+	}
+
+	if u.Department != nil {
+		response.Groups = append(response.Groups, ScimMultiValue{
+			Value:   strconv.Itoa(u.Department.Id),
+			Display: u.Department.Name,
+			Ref:     baseUrl + "/Groups/" + strconv.Itoa(u.Department.Id),
+		})
+		response.Enterprise = &ScimEnterpriseUser{Department: u.Department.Name}
+	}
+
+	return response
+}
+
+// ToScimUserRequest converts the response into the request replacing the user with its current state, e.g. to apply a PATCH to it
+func (r *ScimUserResponse) ToScimUserRequest() *ScimUserRequest {
+	active := r.Active
+	request := &ScimUserRequest{
+		Schemas:     r.Schemas,
+		ExternalId:  r.ExternalId,
+		UserName:    r.UserName,
+		Name:        &ScimName{Formatted: r.Name.Formatted},
+		DisplayName: r.DisplayName,
+		Emails:      r.Emails,
+		Active:      &active,
+		Roles:       r.Roles,
+	}
+	if r.Enterprise != nil {
+		request.Enterprise = &ScimEnterpriseUser{Department: r.Enterprise.Department}
+	}
+	return request
+}
+
+// ScimGroupResponse represents a department as SCIM group
+// @Description ScimGroupResponse represents a department as SCIM group
+type ScimGroupResponse struct {
+	Schemas     []string         `json:"schemas"`
+	Id          string           `json:"id" example:"1"`
+	ExternalId  string           `json:"externalId,omitempty" example:"00g1a2b3c4"`
+	DisplayName string           `json:"displayName" example:"Engineering"`
+	Members     []ScimMultiValue `json:"members"`
+	Meta        ScimMeta         `json:"meta"`
+}
+
+// FromDepartmentModelToScimGroupResponse converts a department with its users into a SCIM group, baseUrl is the URL of the SCIM API
+func FromDepartmentModelToScimGroupResponse(d *models.Department, baseUrl string) *ScimGroupResponse {
+	response := &ScimGroupResponse{
+		Schemas:     []string{constants.ScimSchemaGroup},
+		Id:          strconv.Itoa(d.Id),
+		ExternalId:  d.ScimExternalId,
+		DisplayName: d.Name,
+		Members:     make([]ScimMultiValue, 0, len(d.Users)),
+		Meta: ScimMeta{
+			ResourceType: constants.ScimResourceTypeGroup,
+			Created:      d.CreatedAt,
+			LastModified: d.UpdatedAt,
+			Location:     baseUrl + "/Groups/" + strconv.Itoa(d.Id),
+		},
+	}
+
+	for _, user := range d.Users {
+		response.Members = append(response.Members, ScimMultiValue{
+			Value:   strconv.Itoa(user.Id),
+			Display: user.Name,
+			Ref:     baseUrl + "/Users/" + strconv.Itoa(user.Id),
+		})
+	}
+
+	return response
+}
+
+// ScimListResponse represents a page of SCIM resources
+// @Description ScimListResponse represents a page of SCIM resources
+type ScimListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int64       `json:"totalResults" example:"1"`
+	StartIndex   int         `json:"startIndex" example:"1"`
+	ItemsPerPage int         `json:"itemsPerPage" example:"1"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// ScimErrorResponse represents a SCIM error
+// @Description ScimErrorResponse represents a SCIM error
+type ScimErrorResponse struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status" example:"400"`
+	ScimType string   `json:"scimType,omitempty" example:"invalidFilter"`
+	Detail   string   `json:"detail,omitempty"`
+}
@@ -0,0 +1,21 @@
+package dto
+
+// TwoFactorCodeRequest represents the structure of a request confirmed with a code of the authenticator app
+// @Description TwoFactorCodeRequest represents the structure of a request confirmed with a code of the authenticator app
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required,numeric,len=6" example:"123456"` // Current code of the authenticator app
+}
+
+// TwoFactorVerifyRequest represents the structure of the second login step
+// @Description TwoFactorVerifyRequest represents the structure of the second login step
+type TwoFactorVerifyRequest struct {
+	Token string `json:"token" validate:"required" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."` // Pre-auth token returned by the login
+	Code  string `json:"code" validate:"required,max=16" example:"123456"`                            // Code of the authenticator app or a recovery code
+}
+
+// TwoFactorDisableRequest represents the structure of a request disabling two-factor authentication
+// @Description TwoFactorDisableRequest represents the structure of a request disabling two-factor authentication
+type TwoFactorDisableRequest struct {
+	Password string `json:"password" validate:"required" example:"Mys3cur3P@5s"` // Current password of the user
+	Code     string `json:"code" validate:"required,max=16" example:"123456"`    // Code of the authenticator app or a recovery code
+}
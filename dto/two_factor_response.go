@@ -0,0 +1,23 @@
+package dto
+
+// TwoFactorStatusResponse represents the structure of the two-factor authentication status of a user
+// @Description TwoFactorStatusResponse represents the structure of the two-factor authentication status of a user
+type TwoFactorStatusResponse struct {
+	Enabled           bool  `json:"enabled" example:"true"`           // Whether logins require a second factor
+	Required          bool  `json:"required" example:"true"`          // Whether the policy requires two-factor authentication for the role of the user
+	RecoveryCodesLeft int64 `json:"recovery_codes_left" example:"10"` // Unused recovery codes
+}
+
+// TwoFactorEnrollmentResponse represents the structure of a started two-factor enrollment
+// @Description TwoFactorEnrollmentResponse represents the structure of a started two-factor enrollment
+type TwoFactorEnrollmentResponse struct {
+	Secret     string `json:"secret" example:"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`                                                     // Shared secret for manual entry
+	OtpauthUri string `json:"otpauth_uri" example:"otpauth://totp/Beego%20Presence%20API:admin@example.com?secret=JBSWY3DPEHPK3PXP"` // URI to show as QR code
+}
+
+// TwoFactorRecoveryCodesResponse represents the structure of newly issued recovery codes
+// @Description TwoFactorRecoveryCodesResponse represents the structure of newly issued recovery codes
+type TwoFactorRecoveryCodesResponse struct {
+	Token         string   `json:"token,omitempty" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."` // Token with full access, issued on activation
+	RecoveryCodes []string `json:"recovery_codes" example:"3f2a1-9c4d7"`                              // Single-use codes, shown only once
+}
@@ -45,3 +45,59 @@ func (u UserRequest) ToUserModel(mu *models.User, md *models.Department) *models
 	mu.Department = md
 	return mu
 }
+
+// CreateUserRequest represents the structure of a request of an admin creating a user
+// @Description CreateUserRequest represents the structure of a request of an admin creating a user
+type CreateUserRequest struct {
+	Name         string `json:"name" validate:"required,min=3,max=50" example:"Najib Fikri"`              // Name of the user
+	Email        string `json:"email" validate:"required,email" example:"najibfikri@gmail.com"`           // Email of the user
+	Password     string `json:"password" validate:"required,securepwd" example:"Mys3cur3P@5s"`            // Initial password, the user changes it at the first login
+	Role         string `json:"role" validate:"required,oneof=EMPLOYEE MANAGER ADMIN" example:"EMPLOYEE"` // Role of the user
+	DepartmentId int    `json:"department_id" validate:"required,min=1" example:"1"`                      // ForeignKey to Department
+	ScheduleId   int    `json:"schedule_id" validate:"omitempty,min=1" example:"1"`                       // ForeignKey to Schedule of the department, optional
+}
+
+func (r CreateUserRequest) ToUserModel(md *models.Department, ms *models.Schedule) *models.User {
+	return &models.User{
+		Name:               r.Name,
+		Email:              r.Email,
+		Password:           r.Password,
+		Role:               r.Role,
+		Department:         md,
+		Schedule:           ms,
+		MustChangePassword: true, // the initial password is known to the admin
+		EmailVerified:      true, // the address was provided by the admin
+	}
+}
+
+// ChangeRoleRequest represents the structure of a request promoting or demoting a user
+// @Description ChangeRoleRequest represents the structure of a request promoting or demoting a user
+type ChangeRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=EMPLOYEE MANAGER ADMIN" example:"MANAGER"` // New role of the user
+}
+
+// ChangePasswordRequest represents the structure of a request changing the password of the authenticated user
+// @Description ChangePasswordRequest represents the structure of a request changing the password of the authenticated user
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required" example:"Mys3cur3P@5s"`        // Current password of the user
+	NewPassword     string `json:"new_password" validate:"required,securepwd" example:"N3wS3cur3P@5s"` // New password of the user
+}
+
+// ForgotPasswordRequest represents the structure of a password reset request
+// @Description ForgotPasswordRequest represents the structure of a password reset request
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email" example:"najibfikri@gmail.com"` // Email of the account
+}
+
+// ResendVerificationRequest represents the structure of a request for a new email verification link
+// @Description ResendVerificationRequest represents the structure of a request for a new email verification link
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email" example:"najibfikri@gmail.com"` // Email of the account
+}
+
+// ResetPasswordRequest represents the structure of a request setting a new password with a reset token
+// @Description ResetPasswordRequest represents the structure of a request setting a new password with a reset token
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required,len=64" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"` // Token from the reset email
+	Password string `json:"password" validate:"required,securepwd" example:"N3wS3cur3P@5s"`                                              // New password of the user
+}
@@ -0,0 +1,39 @@
+package dto
+
+import (
+	"strings"
+
+	"github.com/snykk/beego-presence-api/models"
+)
+
+// WebhookRequest represents the structure of a webhook create or update request
+// @Description WebhookRequest represents the structure of a webhook create or update request
+type WebhookRequest struct {
+	Url         string   `json:"url" validate:"required,url,startswith=http,max=500" example:"https://hris.example.com/hooks/presence"`                                                                                        // URL the events are posted to
+	Events      []string `json:"events" validate:"required,min=1,dive,oneof=* user.created user.updated user.deleted user.logged_in presence.created presence.late presence.updated presence.deleted" example:"presence.late"` // Subscribed events, * subscribes to every event
+	Secret      string   `json:"secret" validate:"omitempty,min=16,max=100" example:"4f1c2e9a7b8d6e5f4f1c2e9a7b8d6e5f"`                                                                                                        // Key of the HMAC signature, generated when empty on create
+	Description string   `json:"description" validate:"max=255" example:"Late check-ins to the team chat"`                                                                                                                     // Description of the subscriber
+	Active      *bool    `json:"active" example:"true"`                                                                                                                                                                        // Whether events are delivered, defaults to true
+}
+
+func (w WebhookRequest) ToWebhookModel() *models.Webhook {
+	return &models.Webhook{
+		Url:         w.Url,
+		Events:      strings.Join(w.Events, ","),
+		Secret:      w.Secret,
+		Description: w.Description,
+		Active:      w.Active == nil || *w.Active,
+	}
+}
+
+func (w WebhookRequest) ToWebhookModelWithValue(existing *models.Webhook) *models.Webhook {
+	webhook := w.ToWebhookModel()
+	webhook.Id = existing.Id
+	webhook.CreatedAt = existing.CreatedAt
+
+	// Keep the current secret unless a new one is provided
+	if webhook.Secret == "" {
+		webhook.Secret = existing.Secret
+	}
+	return webhook
+}
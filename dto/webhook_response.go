@@ -0,0 +1,99 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/models"
+)
+
+// WebhookResponse represents the structure of a webhook response
+// @Description WebhookResponse represents the structure of a webhook response
+type WebhookResponse struct {
+	Id          int       `json:"id" example:"1"`                                              // Webhook ID
+	Url         string    `json:"url" example:"https://hris.example.com/hooks/presence"`       // URL the events are posted to
+	Events      []string  `json:"events" example:"presence.late"`                              // Subscribed events
+	Secret      string    `json:"secret,omitempty" example:"4f1c2e9a7b8d6e5f4f1c2e9a7b8d6e5f"` // Signing secret, only returned when the webhook is created
+	Description string    `json:"description" example:"Late check-ins to the team chat"`       // Description of the subscriber
+	Active      bool      `json:"active" example:"true"`                                       // Whether events are delivered
+	CreatedAt   time.Time `json:"created_at" example:"2024-12-01T00:00:00Z"`                   // Creation timestamp
+	UpdatedAt   time.Time `json:"updated_at" example:"2024-12-01T00:00:00Z"`                   // Last update timestamp
+}
+
+// WebhookDeliveryResponse represents a queued or attempted delivery of an event to a webhook
+// @Description WebhookDeliveryResponse represents a queued or attempted delivery of an event to a webhook
+type WebhookDeliveryResponse struct {
+	Id             int             `json:"id" example:"10"`                                          // Delivery ID
+	WebhookId      int             `json:"webhook_id" example:"1"`                                   // Webhook the event is delivered to
+	Event          string          `json:"event" example:"presence.late"`                            // Delivered event
+	Status         string          `json:"status" example:"pending"`                                 // pending, delivered or failed
+	Attempts       int             `json:"attempts" example:"2"`                                     // Number of attempts made
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty" example:"2024-12-02T09:31:00Z"` // Time of the next attempt of a pending delivery
+	LastStatusCode int             `json:"last_status_code,omitempty" example:"503"`                 // HTTP status of the last attempt
+	LastError      string          `json:"last_error,omitempty" example:"unexpected status 503: "`   // Error of the last attempt
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty" example:"2024-12-02T09:32:00Z"`    // Time of the successful attempt
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`                             // Body posted to the webhook
+	CreatedAt      time.Time       `json:"created_at" example:"2024-12-02T09:30:00Z"`                // Time the event was queued
+}
+
+// FromWebhookModelToWebhookResponse converts a webhook, secret is the plaintext signing secret and only passed when the
+// webhook is created, the stored one is encrypted
+func FromWebhookModelToWebhookResponse(w *models.Webhook, secret string) *WebhookResponse {
+	webhookResponse := &WebhookResponse{
+		Id:          w.Id,
+		Url:         w.Url,
+		Events:      w.GetEvents(),
+		Description: w.Description,
+		Active:      w.Active,
+		CreatedAt:   w.CreatedAt,
+		UpdatedAt:   w.UpdatedAt,
+		Secret:      secret,
+	}
+
+	return webhookResponse
+}
+
+func FromWebhookModelListToWebhookResponseList(webhooks []*models.Webhook) []*WebhookResponse {
+	var result []*WebhookResponse
+
+	for _, val := range webhooks {
+		result = append(result, FromWebhookModelToWebhookResponse(val, ""))
+	}
+
+	return result
+}
+
+func FromWebhookDeliveryModelToWebhookDeliveryResponse(d *models.WebhookDelivery) *WebhookDeliveryResponse {
+	deliveryResponse := &WebhookDeliveryResponse{
+		Id:             d.Id,
+		Event:          d.Event,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+		DeliveredAt:    d.DeliveredAt,
+		Payload:        json.RawMessage(d.Payload),
+		CreatedAt:      d.CreatedAt,
+	}
+
+	if d.Webhook != nil {
+		deliveryResponse.WebhookId = d.Webhook.Id
+	}
+
+	if d.Status == constants.WebhookDeliveryStatusPending {
+		deliveryResponse.NextAttemptAt = &d.NextAttemptAt
+	}
+
+	return deliveryResponse
+}
+
+func FromWebhookDeliveryModelListToWebhookDeliveryResponseList(deliveries []*models.WebhookDelivery) []*WebhookDeliveryResponse {
+	var result []*WebhookDeliveryResponse
+
+	for _, val := range deliveries {
+		result = append(result, FromWebhookDeliveryModelToWebhookDeliveryResponse(val))
+	}
+
+	return result
+}
@@ -0,0 +1,156 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+var (
+	// ErrApiKeyInvalid is returned for unknown, revoked and expired keys and keys of deactivated accounts
+	ErrApiKeyInvalid = errors.New("invalid, revoked or expired API key")
+	// ErrApiKeyLimit is returned when a user already has the maximum number of usable keys
+	ErrApiKeyLimit = fmt.Errorf("at most %d usable API keys per account", constants.ApiKeyMaxPerUser)
+)
+
+// IsApiKey reports whether a bearer token is an API key rather than a JWT
+func IsApiKey(token string) bool {
+	return strings.HasPrefix(token, constants.ApiKeyPrefix)
+}
+
+// GenerateApiKey creates a new API key for the user, the key itself is only returned here and stored hashed
+func GenerateApiKey(user *models.User, name string, scopes []string, ttlDays int, now time.Time) (*models.ApiKey, string, error) {
+	usable, err := models.CountUsableApiKeys(user.Id, now)
+	if err != nil {
+		return nil, "", err
+	}
+	if usable >= constants.ApiKeyMaxPerUser {
+		return nil, "", ErrApiKeyLimit
+	}
+
+	secret, err := GenerateRandomHex(constants.ApiKeyBytes)
+	if err != nil {
+		return nil, "", err
+	}
+	plain := constants.ApiKeyPrefix + secret
+
+	if ttlDays == 0 {
+		ttlDays = constants.ApiKeyDefaultTTLDays
+	}
+	key := &models.ApiKey{
+		User:      user,
+		Name:      name,
+		Prefix:    plain[:constants.ApiKeyDisplayLength],
+		KeyHash:   HashToken(plain),
+		Scopes:    strings.Join(scopes, ","),
+		ExpiresAt: now.AddDate(0, 0, ttlDays),
+	}
+	if err := models.CreateApiKey(key); err != nil {
+		return nil, "", err
+	}
+	return key, plain, nil
+}
+
+// AuthenticateApiKey returns the key and its account for a presented key and records its use
+func AuthenticateApiKey(plain, ip string, now time.Time) (*models.ApiKey, *models.User, error) {
+	key, err := models.GetApiKeyByHash(HashToken(plain))
+	if err == orm.ErrNoRows {
+		return nil, nil, ErrApiKeyInvalid
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !key.IsUsable(now) {
+		return nil, nil, ErrApiKeyInvalid
+	}
+
+	user, err := models.GetUserAccountById(key.User.Id)
+	if err == orm.ErrNoRows {
+		return nil, nil, ErrApiKeyInvalid
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !user.IsActive() {
+		return nil, nil, ErrApiKeyInvalid
+	}
+
+	// The last use is informational, requests are not rejected when it can't be recorded
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= constants.ApiKeyLastUsedInterval || key.LastUsedIp != ip {
+		if err := models.TouchApiKey(key, now, ip); err != nil {
+			log.Printf("Failed to record the use of API key %d: %v", key.Id, err)
+		}
+	}
+	return key, user, nil
+}
+
+// ApiKeyScopeForRequest returns the scope an API key needs for a request, named after the first path segment
+// below /api/v1 and read for GET requests. Endpoints managing credentials, e.g. API keys, passwords and two-factor
+// authentication, can't be used with API keys.
+func ApiKeyScopeForRequest(path, method string) (string, bool) {
+	if strings.HasSuffix(path, constants.ChangePasswordPath) || strings.Contains(path, constants.TwoFactorPath) {
+		return "", false
+	}
+
+	resource := strings.TrimPrefix(path, "/api/v1/")
+	if i := strings.Index(resource, "/"); i >= 0 {
+		resource = resource[:i]
+	}
+	access := constants.ApiKeyScopeWrite
+	if method == "GET" || method == "HEAD" {
+		access = constants.ApiKeyScopeRead
+	}
+
+	scope := resource + ":" + access
+	for _, known := range constants.ApiKeyScopes {
+		if known == scope {
+			return scope, true
+		}
+	}
+	return "", false
+}
+
+// CreateServiceAccount creates the account of an integration. Service accounts can't log in and authenticate with
+// API keys only, their email is generated. The creation is recorded in the audit log as made by the actor.
+func CreateServiceAccount(name, role string, department *models.Department, actor models.AuditActor) (*models.User, error) {
+	suffix, err := GenerateRandomHex(4)
+	if err != nil {
+		return nil, err
+	}
+	randomPassword, err := GenerateRandomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := HashPassword(randomPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Name:           name,
+		Email:          fmt.Sprintf("service-account-%s@%s", suffix, constants.ServiceAccountEmailDomain),
+		Password:       hashedPassword,
+		Role:           role,
+		Department:     department,
+		EmailVerified:  true,
+		ServiceAccount: true,
+	}
+	userCreated := NewDomainEvent(constants.EventUserCreated, func() interface{} {
+		return dto.FromUserModelToUserResponse(user, false, false, false)
+	})
+	audit := NewAuditEntry(actor, constants.AuditActionCreate, constants.AuditEntityUser, func() (int, interface{}, interface{}) {
+		return user.Id, nil, dto.FromUserModelToServiceAccountResponse(user)
+	})
+	if err := models.CreateUser(user, userCreated, audit); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,232 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/models"
+)
+
+// PresenceLocation returns the timezone presences are recorded and reported in
+func PresenceLocation() *time.Location {
+	location, err := time.LoadLocation(constants.PresenceTimezone)
+	if err != nil {
+		return time.Local
+	}
+	return location
+}
+
+// ParseReportMonth parses a YYYY-MM month into the first instant of that month in the presence timezone.
+// An empty value resolves to the current month.
+func ParseReportMonth(month string, now time.Time) (time.Time, error) {
+	location := PresenceLocation()
+	if month == "" {
+		now = now.In(location)
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, location), nil
+	}
+	return time.ParseInLocation(constants.ReportMonthLayout, month, location)
+}
+
+// ParseReportDateRange parses an inclusive YYYY-MM-DD date range in the presence timezone.
+// Empty values default to the first day of the current month and today.
+func ParseReportDateRange(from, to string, now time.Time) (time.Time, time.Time, error) {
+	location := PresenceLocation()
+	now = now.In(location)
+
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, location)
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+
+	var err error
+	if from != "" {
+		if start, err = time.ParseInLocation(constants.ReportDateLayout, from, location); err != nil {
+			return start, end, fmt.Errorf("invalid from date: %s", from)
+		}
+	}
+	if to != "" {
+		if end, err = time.ParseInLocation(constants.ReportDateLayout, to, location); err != nil {
+			return start, end, fmt.Errorf("invalid to date: %s", to)
+		}
+	}
+
+	if end.Before(start) {
+		return start, end, errors.New("to date must not be before from date")
+	}
+	if end.Sub(start) > constants.ReportMaxRangeDays*24*time.Hour {
+		return start, end, fmt.Errorf("date range must not exceed %d days", constants.ReportMaxRangeDays)
+	}
+
+	return start, end, nil
+}
+
+// LoadMonthlyAttendance fetches the presences, holidays and leaves of a user for the month starting at monthStart
+// and computes the monthly attendance report from them.
+func LoadMonthlyAttendance(user *models.User, monthStart time.Time, now time.Time) (*dto.MonthlyAttendanceResponse, error) {
+	monthEnd := monthStart.AddDate(0, 1, 0)
+	lastDay := monthEnd.AddDate(0, 0, -1)
+
+	// Fetch presences of the month plus the next day for overnight check-outs
+	presences, err := models.GetPresencesByUserIdBetween(user.Id, monthStart, monthEnd.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch presences: %w", err)
+	}
+
+	holidays, err := models.GetHolidaysBetween(monthStart, lastDay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch holidays: %w", err)
+	}
+
+	leaves, err := models.GetLeavesByUserIdBetween(user.Id, monthStart, lastDay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch leaves: %w", err)
+	}
+
+	return BuildMonthlyAttendance(user, monthStart, presences, holidays, leaves, now), nil
+}
+
+// BuildMonthlyAttendance computes the per-day attendance rows and the totals of a user for the month starting at monthStart.
+// Presences should cover the month plus the first day of the next month so overnight check-outs are matched.
+func BuildMonthlyAttendance(user *models.User, monthStart time.Time, presences []*models.Presence, holidays []*models.Holiday, leaves []*models.Leave, now time.Time) *dto.MonthlyAttendanceResponse {
+	location := monthStart.Location()
+	now = now.In(location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+
+	// Index check-ins and check-outs by their calendar day
+	checkIns := map[string]*models.Presence{}
+	checkOuts := map[string]*models.Presence{}
+	for _, presence := range presences {
+		key := presence.CreatedAt.In(location).Format(constants.ReportDateLayout)
+		switch presence.Type {
+		case constants.PresenceTypeIn:
+			if _, exists := checkIns[key]; !exists {
+				checkIns[key] = presence
+			}
+		case constants.PresenceTypeOut:
+			if _, exists := checkOuts[key]; !exists {
+				checkOuts[key] = presence
+			}
+		}
+	}
+
+	holidayNames := map[string]string{}
+	for _, holiday := range holidays {
+		holidayNames[holiday.Date.Format(constants.ReportDateLayout)] = holiday.Name
+	}
+
+	report := &dto.MonthlyAttendanceResponse{
+		User:  dto.FromUserModelToUserResponse(user, false, false, false),
+		Month: monthStart.Format(constants.ReportMonthLayout),
+	}
+
+	for day := monthStart; day.Month() == monthStart.Month(); day = day.AddDate(0, 0, 1) {
+		key := day.Format(constants.ReportDateLayout)
+		row := &dto.AttendanceDayResponse{Date: key}
+
+		// Mark days that are not expected to be worked
+		if name, isHoliday := holidayNames[key]; isHoliday {
+			row.Marker, row.MarkerDescription = constants.ReportDayMarkerHoliday, name
+		} else if leave := findLeave(leaves, key); leave != nil {
+			row.Marker, row.MarkerDescription = constants.ReportDayMarkerLeave, leave.Reason
+		} else if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			row.Marker = constants.ReportDayMarkerWeekend
+		}
+
+		checkIn := checkIns[key]
+
+		// Prefer the schedule the user checked in against, fall back to the assigned schedule
+		schedule := user.Schedule
+		if checkIn != nil && checkIn.Schedule != nil && checkIn.Schedule.InTime != "" {
+			schedule = checkIn.Schedule
+		}
+
+		var scheduledIn time.Time
+		overnight := false
+		if schedule != nil {
+			in, inErr := ParseScheduleTime(day, schedule.InTime)
+			out, outErr := ParseScheduleTime(day, schedule.OutTime)
+			if inErr == nil && outErr == nil {
+				if !out.After(in) {
+					// The shift ends on the next day
+					out = out.AddDate(0, 0, 1)
+					overnight = true
+				}
+				scheduledIn = in
+				row.ScheduledIn = formatReportTime(in)
+				row.ScheduledOut = formatReportTime(out)
+			}
+		}
+
+		// Overnight shifts are checked out on the following calendar day
+		checkOut := checkOuts[key]
+		if overnight {
+			checkOut = checkOuts[day.AddDate(0, 0, 1).Format(constants.ReportDateLayout)]
+		}
+
+		switch {
+		case checkIn != nil:
+			row.CheckIn = formatReportTime(checkIn.CreatedAt.In(location))
+			row.Status = constants.ReportDayStatusPresent
+			if checkIn.Status == constants.PresenceStatusLate {
+				row.Status = constants.ReportDayStatusLate
+				if !scheduledIn.IsZero() && checkIn.CreatedAt.After(scheduledIn) {
+					row.LateMinutes = int(checkIn.CreatedAt.Sub(scheduledIn).Minutes())
+				}
+			}
+			if checkOut != nil && checkOut.CreatedAt.After(checkIn.CreatedAt) {
+				row.CheckOut = formatReportTime(checkOut.CreatedAt.In(location))
+				row.WorkedMinutes = int(checkOut.CreatedAt.Sub(checkIn.CreatedAt).Minutes())
+			}
+		case row.Marker != "":
+			row.Status = row.Marker
+		case !day.Before(today):
+			row.Status = constants.ReportDayStatusPending
+		default:
+			row.Status = constants.ReportDayStatusAbsent
+		}
+
+		accumulateAttendanceTotals(&report.Totals, row)
+		report.Days = append(report.Days, row)
+	}
+
+	return report
+}
+
+// accumulateAttendanceTotals adds a single day row to the monthly totals
+func accumulateAttendanceTotals(totals *dto.AttendanceTotalsResponse, row *dto.AttendanceDayResponse) {
+	if row.Marker == "" {
+		totals.WorkingDays++
+	}
+	if row.Marker == constants.ReportDayMarkerLeave {
+		totals.LeaveCount++
+	}
+
+	switch row.Status {
+	case constants.ReportDayStatusPresent:
+		totals.DaysPresent++
+	case constants.ReportDayStatusLate:
+		totals.DaysPresent++
+		totals.LateCount++
+	case constants.ReportDayStatusAbsent:
+		totals.AbsentCount++
+	}
+
+	totals.TotalLateMinutes += row.LateMinutes
+	totals.TotalWorkedMinutes += row.WorkedMinutes
+}
+
+// findLeave returns the leave covering the given day (YYYY-MM-DD), if any
+func findLeave(leaves []*models.Leave, day string) *models.Leave {
+	for _, leave := range leaves {
+		if leave.StartDate.Format(constants.ReportDateLayout) <= day && day <= leave.EndDate.Format(constants.ReportDateLayout) {
+			return leave
+		}
+	}
+	return nil
+}
+
+func formatReportTime(t time.Time) *string {
+	formatted := t.Format(time.RFC3339)
+	return &formatted
+}
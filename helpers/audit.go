@@ -0,0 +1,113 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/models"
+)
+
+// auditIgnoredFields change with every update and aren't recorded in the audit log
+var auditIgnoredFields = map[string]bool{"updated_at": true}
+
+// AuditChange is the value of a field before and after a change
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditDiff compares the JSON representations of an entity before and after a change and returns the changed fields.
+// The before value is nil for creations and the after value for deletions.
+func AuditDiff(before, after interface{}) (map[string]AuditChange, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]AuditChange{}
+	for field, value := range beforeFields {
+		if !reflect.DeepEqual(value, afterFields[field]) {
+			changes[field] = AuditChange{Before: value, After: afterFields[field]}
+		}
+	}
+	for field, value := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			changes[field] = AuditChange{After: value}
+		}
+	}
+	return changes, nil
+}
+
+// auditFields decodes the JSON representation of an entity into its fields
+func auditFields(entity interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if entity == nil || reflect.ValueOf(entity).Kind() == reflect.Ptr && reflect.ValueOf(entity).IsNil() {
+		return fields, nil
+	}
+
+	body, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	for field := range auditIgnoredFields {
+		delete(fields, field)
+	}
+	return fields, nil
+}
+
+// NewAuditEntry records a change in the audit log, in the same transaction as the change. Once the change is saved,
+// change returns the id of the entity and the entity as its response before and after the change, so secrets never
+// reach the log. The change fails when it can't be recorded.
+func NewAuditEntry(actor models.AuditActor, action, entityType string, change func() (int, interface{}, interface{})) models.AuditEntry {
+	return func() (*models.AuditLog, error) {
+		entityId, before, after := change()
+		changes, err := AuditDiff(before, after)
+		if err != nil {
+			return nil, err
+		}
+		body, err := json.Marshal(changes)
+		if err != nil {
+			return nil, err
+		}
+		return &models.AuditLog{ActorId: actor.Id, Action: action, EntityType: entityType, EntityId: entityId, Changes: string(body), Ip: actor.Ip}, nil
+	}
+}
+
+// ParseAuditDateRange parses an inclusive YYYY-MM-DD date range in the presence timezone into the start of the first
+// day and the start of the day after the last day. Empty values leave that side open.
+func ParseAuditDateRange(from, to string) (*time.Time, *time.Time, error) {
+	location := PresenceLocation()
+
+	var start, end *time.Time
+	if from != "" {
+		date, err := time.ParseInLocation(constants.ReportDateLayout, from, location)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid from date: %s", from)
+		}
+		start = &date
+	}
+	if to != "" {
+		date, err := time.ParseInLocation(constants.ReportDateLayout, to, location)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid to date: %s", to)
+		}
+		date = date.AddDate(0, 0, 1)
+		end = &date
+	}
+
+	if start != nil && end != nil && !end.After(*start) {
+		return nil, nil, errors.New("to date must not be before from date")
+	}
+	return start, end, nil
+}
@@ -3,6 +3,8 @@ package helpers
 import (
 	"log"
 
+	"github.com/snykk/beego-presence-api/models"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -20,3 +22,21 @@ func HashPassword(password string) (string, error) {
 func ComparePassword(hashedPassword, password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
+
+// IsPasswordReused reports whether the password matches the current password of the user or one of the previous ones
+func IsPasswordReused(user *models.User, password string) (bool, error) {
+	if ComparePassword(user.Password, password) == nil {
+		return true, nil
+	}
+
+	history, err := models.GetPasswordHistory(user.Id)
+	if err != nil {
+		return false, err
+	}
+	for _, previous := range history {
+		if ComparePassword(previous.PasswordHash, password) == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
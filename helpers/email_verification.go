@@ -0,0 +1,153 @@
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/server/web"
+)
+
+// ErrEmailVerificationTokenInvalid is returned for verification tokens that are malformed, tampered with or expired
+var ErrEmailVerificationTokenInvalid = errors.New("invalid or expired verification token")
+
+var emailVerificationLimiter = NewRateLimiter(constants.EmailVerificationRateLimit, constants.EmailVerificationRateWindow)
+
+// emailVerificationSecret returns the key the verification links are signed with
+func emailVerificationSecret() []byte {
+	return configuredSecret("email_verification_secret")
+}
+
+// SignEmailVerificationToken creates a token confirming the email of a user until expiresAt.
+// The token carries the email, so it stops working once the user changes the address.
+func SignEmailVerificationToken(userId int, email string, expiresAt time.Time) string {
+	payload := fmt.Sprintf("%d|%s|%d", userId, strings.ToLower(email), expiresAt.Unix())
+	mac := hmac.New(sha256.New, emailVerificationSecret())
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ParseEmailVerificationToken checks the signature and the expiry of a token and returns the user id and email it confirms
+func ParseEmailVerificationToken(token string, now time.Time) (int, string, error) {
+	encodedPayload, encodedSignature, found := strings.Cut(token, ".")
+	if !found {
+		return 0, "", ErrEmailVerificationTokenInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return 0, "", ErrEmailVerificationTokenInvalid
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return 0, "", ErrEmailVerificationTokenInvalid
+	}
+
+	mac := hmac.New(sha256.New, emailVerificationSecret())
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return 0, "", ErrEmailVerificationTokenInvalid
+	}
+
+	fields := strings.Split(string(payload), "|")
+	if len(fields) != 3 {
+		return 0, "", ErrEmailVerificationTokenInvalid
+	}
+	userId, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, "", ErrEmailVerificationTokenInvalid
+	}
+	expiresAt, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil || !now.Before(time.Unix(expiresAt, 0)) {
+		return 0, "", ErrEmailVerificationTokenInvalid
+	}
+	return userId, fields[1], nil
+}
+
+// VerifyEmail marks the email of the user confirmed by a token as verified and records constants.EventUserVerified
+func VerifyEmail(token string, now time.Time) (*models.User, error) {
+	userId, email, err := ParseEmailVerificationToken(token, now)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := models.GetUserAccountById(userId)
+	if err == orm.ErrNoRows {
+		return nil, ErrEmailVerificationTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(user.Email, email) {
+		return nil, ErrEmailVerificationTokenInvalid
+	}
+	if user.EmailVerified {
+		return user, nil
+	}
+	userVerified := NewDomainEvent(constants.EventUserVerified, func() interface{} {
+		return map[string]interface{}{"id": user.Id, "email": user.Email}
+	})
+	return user, models.MarkUserEmailVerified(user, userVerified)
+}
+
+// AllowEmailVerificationRequest reports whether another verification email may be requested for the email
+func AllowEmailVerificationRequest(email string, now time.Time) bool {
+	return emailVerificationLimiter.Allow(strings.ToLower(strings.TrimSpace(email)), now)
+}
+
+// BuildEmailVerificationUrl appends the token to the verification endpoint configured with email_verification_url
+func BuildEmailVerificationUrl(token string) (string, error) {
+	verificationUrl, err := url.Parse(web.AppConfig.DefaultString("email_verification_url", "http://localhost:8080/api/v1/auth/verify-email"))
+	if err != nil {
+		return "", err
+	}
+	query := verificationUrl.Query()
+	query.Set("token", token)
+	verificationUrl.RawQuery = query.Encode()
+	return verificationUrl.String(), nil
+}
+
+// SendEmailVerification emails a verification link to a user who didn't verify the email yet
+func SendEmailVerification(user *models.User) error {
+	if user.EmailVerified {
+		return nil
+	}
+
+	expiresAt := time.Now().Add(constants.EmailVerificationTokenTTL)
+	verificationUrl, err := BuildEmailVerificationUrl(SignEmailVerificationToken(user.Id, user.Email, expiresAt))
+	if err != nil {
+		return fmt.Errorf("invalid email_verification_url: %w", err)
+	}
+	data := map[string]interface{}{
+		"VerificationUrl": verificationUrl,
+		"ExpiresHours":    int(constants.EmailVerificationTokenTTL.Hours()),
+	}
+	// Every link is a new notification, so resending isn't suppressed as a duplicate
+	reference := fmt.Sprintf("%s:%d:%d", constants.NotificationTypeEmailVerification, user.Id, expiresAt.UnixNano())
+	return SendNotification(user, constants.NotificationTypeEmailVerification, reference, data)
+}
+
+// ResendEmailVerification emails a new verification link if the email belongs to an unverified user, and does nothing otherwise.
+// It is meant to run in the background, so the response time doesn't reveal whether the account exists.
+func ResendEmailVerification(email string) {
+	user, err := models.GetUserByEmail(email)
+	if err != nil {
+		if err != orm.ErrNoRows {
+			log.Printf("Failed to fetch user for email verification: %v", err)
+		}
+		return
+	}
+	if err := SendEmailVerification(&user); err != nil {
+		log.Printf("Failed to send email verification to user %d: %v", user.Id, err)
+	}
+}
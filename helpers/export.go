@@ -0,0 +1,219 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/snykk/beego-presence-api/constants"
+)
+
+// ExportColumn describes a column that can be selected in a file export
+type ExportColumn struct {
+	Key    string // Identifier used in the columns query parameter
+	Header string // Title written in the header row
+}
+
+// ExportLayouts holds the date layouts used for a locale in file exports
+type ExportLayouts struct {
+	Date     string
+	DateTime string
+}
+
+var exportLocaleLayouts = map[string]ExportLayouts{
+	constants.ExportDefaultLocale: {Date: "2006-01-02", DateTime: "2006-01-02 15:04:05"},
+	"en-us":                       {Date: "01/02/2006", DateTime: "01/02/2006 03:04:05 PM"},
+	"en-gb":                       {Date: "02/01/2006", DateTime: "02/01/2006 15:04:05"},
+	"id-id":                       {Date: "02/01/2006", DateTime: "02/01/2006 15.04.05"},
+	"de-de":                       {Date: "02.01.2006", DateTime: "02.01.2006 15:04:05"},
+	"fr-fr":                       {Date: "02/01/2006", DateTime: "02/01/2006 15:04:05"},
+}
+
+// TableWriter writes rows of a tabular export
+type TableWriter interface {
+	WriteRow(values []string) error
+	Flush() error
+	Close() error
+}
+
+// NegotiateExportFormat resolves the export format from the format query parameter, falling back to the Accept header.
+// An empty result means the regular JSON response is expected.
+func NegotiateExportFormat(format, accept string) (string, error) {
+	if format != "" {
+		switch strings.ToLower(format) {
+		case constants.ExportFormatCSV, constants.ExportFormatXLSX:
+			return strings.ToLower(format), nil
+		case "json":
+			return "", nil
+		default:
+			return "", fmt.Errorf("unsupported export format: %s", format)
+		}
+	}
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case constants.ExportMimeCSV:
+			return constants.ExportFormatCSV, nil
+		case constants.ExportMimeXLSX:
+			return constants.ExportFormatXLSX, nil
+		}
+	}
+
+	return "", nil
+}
+
+// SelectExportColumns returns the requested columns (comma separated keys) in the requested order.
+// An empty selection returns all available columns.
+func SelectExportColumns(available []ExportColumn, requested string) ([]ExportColumn, error) {
+	if strings.TrimSpace(requested) == "" {
+		return available, nil
+	}
+
+	byKey := map[string]ExportColumn{}
+	for _, column := range available {
+		byKey[column.Key] = column
+	}
+
+	var selected []ExportColumn
+	for _, key := range strings.Split(requested, ",") {
+		column, exists := byKey[strings.TrimSpace(key)]
+		if !exists {
+			return nil, fmt.Errorf("unknown export column: %s", strings.TrimSpace(key))
+		}
+		selected = append(selected, column)
+	}
+
+	return selected, nil
+}
+
+// GetExportLayouts returns the date layouts of a locale (e.g. en-US, id-ID), falling back to ISO 8601
+func GetExportLayouts(locale string) ExportLayouts {
+	if layouts, exists := exportLocaleLayouts[strings.ToLower(strings.ReplaceAll(locale, "_", "-"))]; exists {
+		return layouts
+	}
+	return exportLocaleLayouts[constants.ExportDefaultLocale]
+}
+
+// StartExport returns a TableWriter with the header row already written. The download headers are only sent once the
+// file is started, so on an error nothing is committed and the caller can still respond with the error.
+func StartExport(w http.ResponseWriter, format, filename string, columns []ExportColumn) (TableWriter, error) {
+	response := &exportResponse{w: w}
+	var (
+		writer                 TableWriter
+		contentType, extension string
+		err                    error
+	)
+
+	switch format {
+	case constants.ExportFormatXLSX:
+		contentType, extension = constants.ExportMimeXLSX, "xlsx"
+		writer, err = NewXLSXWriter(response, filename)
+	default:
+		contentType, extension = constants.ExportMimeCSV+"; charset=utf-8", "csv"
+		writer = &csvTableWriter{csv: csv.NewWriter(response)}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Header
+	}
+	if err := writer.WriteRow(header); err != nil {
+		return nil, err
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, filename, extension))
+	if err := response.commit(); err != nil {
+		return nil, err
+	}
+	return &responseTableWriter{TableWriter: writer, w: w}, nil
+}
+
+// exportResponse holds back the start of an export until it's committed, then writes through to the response
+type exportResponse struct {
+	w         http.ResponseWriter
+	pending   bytes.Buffer
+	committed bool
+}
+
+func (e *exportResponse) Write(p []byte) (int, error) {
+	if !e.committed {
+		return e.pending.Write(p)
+	}
+	return e.w.Write(p)
+}
+
+// commit sends the status and what was written so far
+func (e *exportResponse) commit() error {
+	e.w.WriteHeader(http.StatusOK)
+	e.committed = true
+	_, err := e.w.Write(e.pending.Bytes())
+	e.pending.Reset()
+	return err
+}
+
+// ExportRowValues picks the values of the selected columns from a row keyed by column key
+func ExportRowValues(columns []ExportColumn, row map[string]string) []string {
+	values := make([]string, len(columns))
+	for i, column := range columns {
+		values[i] = row[column.Key]
+	}
+	return values
+}
+
+// csvTableWriter writes rows as CSV, neutralizing values that spreadsheet applications would evaluate as formulas.
+// Values starting with =, +, -, @, a tab or a carriage return are prefixed with a quote, including negative numbers.
+type csvTableWriter struct {
+	csv *csv.Writer
+}
+
+func (c *csvTableWriter) WriteRow(values []string) error {
+	sanitized := make([]string, len(values))
+	for i, value := range values {
+		sanitized[i] = neutralizeFormula(value)
+	}
+	return c.csv.Write(sanitized)
+}
+
+// neutralizeFormula prefixes a value spreadsheet applications would evaluate as a formula with a quote
+func neutralizeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func (c *csvTableWriter) Flush() error {
+	c.csv.Flush()
+	return c.csv.Error()
+}
+
+func (c *csvTableWriter) Close() error {
+	return c.Flush()
+}
+
+// responseTableWriter pushes flushed rows through to the client so exports are streamed
+type responseTableWriter struct {
+	TableWriter
+	w http.ResponseWriter
+}
+
+func (r *responseTableWriter) Flush() error {
+	if err := r.TableWriter.Flush(); err != nil {
+		return err
+	}
+	if flusher, ok := r.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package helpers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/models"
+)
+
+// PresenceExportColumns lists the columns available when exporting presences
+var PresenceExportColumns = []ExportColumn{
+	{Key: "id", Header: "ID"},
+	{Key: "user_id", Header: "User ID"},
+	{Key: "user_name", Header: "User Name"},
+	{Key: "user_email", Header: "User Email"},
+	{Key: "schedule_id", Header: "Schedule ID"},
+	{Key: "schedule_name", Header: "Schedule Name"},
+	{Key: "type", Header: "Type"},
+	{Key: "status", Header: "Status"},
+	{Key: "date", Header: "Date"},
+	{Key: "created_at", Header: "Recorded At"},
+	{Key: "updated_at", Header: "Updated At"},
+}
+
+// AttendanceDayExportColumns lists the columns available when exporting a monthly attendance report
+var AttendanceDayExportColumns = []ExportColumn{
+	{Key: "date", Header: "Date"},
+	{Key: "scheduled_in", Header: "Scheduled In"},
+	{Key: "scheduled_out", Header: "Scheduled Out"},
+	{Key: "check_in", Header: "Check In"},
+	{Key: "check_out", Header: "Check Out"},
+	{Key: "status", Header: "Status"},
+	{Key: "marker", Header: "Marker"},
+	{Key: "marker_description", Header: "Marker Description"},
+	{Key: "worked_minutes", Header: "Worked Minutes"},
+	{Key: "late_minutes", Header: "Late Minutes"},
+}
+
+// DepartmentDayExportColumns lists the columns available when exporting a department dashboard
+var DepartmentDayExportColumns = []ExportColumn{
+	{Key: "date", Header: "Date"},
+	{Key: "is_working_day", Header: "Working Day"},
+	{Key: "present", Header: "Present"},
+	{Key: "late", Header: "Late"},
+	{Key: "absent", Header: "Absent"},
+	{Key: "on_leave", Header: "On Leave"},
+}
+
+// PresenceExportRow converts a presence into an export row keyed by column key
+func PresenceExportRow(p *models.Presence, layouts ExportLayouts) map[string]string {
+	location := PresenceLocation()
+	row := map[string]string{
+		"id":         strconv.Itoa(p.Id),
+		"type":       p.Type,
+		"status":     p.Status,
+		"date":       p.CreatedAt.In(location).Format(layouts.Date),
+		"created_at": p.CreatedAt.In(location).Format(layouts.DateTime),
+		"updated_at": p.UpdatedAt.In(location).Format(layouts.DateTime),
+	}
+
+	if p.User != nil {
+		row["user_id"] = strconv.Itoa(p.User.Id)
+		row["user_name"] = p.User.Name
+		row["user_email"] = p.User.Email
+	}
+
+	if p.Schedule != nil {
+		row["schedule_id"] = strconv.Itoa(p.Schedule.Id)
+		row["schedule_name"] = p.Schedule.Name
+	}
+
+	return row
+}
+
+// AttendanceDayExportRow converts a monthly report day into an export row keyed by column key
+func AttendanceDayExportRow(d *dto.AttendanceDayResponse, layouts ExportLayouts) map[string]string {
+	return map[string]string{
+		"date":               formatExportDate(d.Date, layouts),
+		"scheduled_in":       formatExportDateTime(d.ScheduledIn, layouts),
+		"scheduled_out":      formatExportDateTime(d.ScheduledOut, layouts),
+		"check_in":           formatExportDateTime(d.CheckIn, layouts),
+		"check_out":          formatExportDateTime(d.CheckOut, layouts),
+		"status":             d.Status,
+		"marker":             d.Marker,
+		"marker_description": d.MarkerDescription,
+		"worked_minutes":     strconv.Itoa(d.WorkedMinutes),
+		"late_minutes":       strconv.Itoa(d.LateMinutes),
+	}
+}
+
+// DepartmentDayExportRow converts a department dashboard day into an export row keyed by column key
+func DepartmentDayExportRow(d *dto.DepartmentDayResponse, layouts ExportLayouts) map[string]string {
+	return map[string]string{
+		"date":           formatExportDate(d.Date, layouts),
+		"is_working_day": strconv.FormatBool(d.IsWorkingDay),
+		"present":        strconv.Itoa(d.Present),
+		"late":           strconv.Itoa(d.Late),
+		"absent":         strconv.Itoa(d.Absent),
+		"on_leave":       strconv.Itoa(d.OnLeave),
+	}
+}
+
+// formatExportDate re-formats a YYYY-MM-DD report date with the locale date layout
+func formatExportDate(value string, layouts ExportLayouts) string {
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return value
+	}
+	return date.Format(layouts.Date)
+}
+
+// formatExportDateTime re-formats an RFC 3339 report time with the locale date-time layout
+func formatExportDateTime(value *string, layouts ExportLayouts) string {
+	if value == nil {
+		return ""
+	}
+	t, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return *value
+	}
+	return t.Format(layouts.DateTime)
+}
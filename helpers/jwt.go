@@ -5,18 +5,22 @@ import (
 	"log"
 	"time"
 
+	"github.com/snykk/beego-presence-api/constants"
+
 	"github.com/dgrijalva/jwt-go"
 )
 
 var jwtIssuer = "beego-presence-api"
 var jwtSecret = []byte("my-secret-tralalala")
 
-// GenerateJWT generates a JWT token for a user with the given role
-func GenerateJWT(userId int, email, role string) (string, error) {
+// GenerateJWT generates a JWT token for a user with the given role.
+// The token version of the user is embedded, raising it invalidates the tokens issued before.
+func GenerateJWT(userId int, email, role string, tokenVersion int) (string, error) {
 	claims := jwt.MapClaims{
 		"sub":   userId,
 		"email": email,
 		"role":  role,
+		"ver":   tokenVersion,
 		"exp":   time.Now().Add(time.Hour * 24).Unix(),
 		"iat":   time.Now().Unix(),
 		"iss":   jwtIssuer,
@@ -32,6 +36,30 @@ func GenerateJWT(userId int, email, role string) (string, error) {
 	return signedToken, nil
 }
 
+// GeneratePreAuthJWT generates a short-lived token for a user who passed the password step of the login.
+// It only allows completing the login with the second factor or enrolling in two-factor authentication.
+func GeneratePreAuthJWT(userId int, email, role string, tokenVersion int) (string, error) {
+	claims := jwt.MapClaims{
+		"sub":   userId,
+		"email": email,
+		"role":  role,
+		"ver":   tokenVersion,
+		"scope": constants.TokenScopePreAuth,
+		"exp":   time.Now().Add(constants.PreAuthTokenTTL).Unix(),
+		"iat":   time.Now().Unix(),
+		"iss":   jwtIssuer,
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	signedToken, err := token.SignedString(jwtSecret)
+	if err != nil {
+		log.Println("Error signing token:", err)
+		return "", err
+	}
+	return signedToken, nil
+}
+
 // ParseJWT parses the JWT token and returns the claims
 func ParseJWT(tokenString string) (claims jwt.MapClaims, err error) {
 	if token, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
@@ -58,3 +86,18 @@ func GetUseridFromMapClaims(claims jwt.MapClaims) (int, error) {
 
 	return 0, errors.New("userId not found in claims")
 }
+
+// GetTokenVersionFromMapClaims returns the token version of the claims, tokens issued without one have version 0
+func GetTokenVersionFromMapClaims(claims jwt.MapClaims) int {
+	if version, ok := claims["ver"].(float64); ok {
+		return int(version)
+	}
+
+	return 0
+}
+
+// GetScopeFromMapClaims returns the scope of the claims, empty for tokens with full access
+func GetScopeFromMapClaims(claims jwt.MapClaims) string {
+	scope, _ := claims["scope"].(string)
+	return scope
+}
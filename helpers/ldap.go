@@ -0,0 +1,522 @@
+package helpers
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/server/web"
+	"github.com/beego/beego/v2/task"
+	"github.com/go-ldap/ldap/v3"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrUnknownAccount is returned when neither the database nor the directory knows the email
+	ErrUnknownAccount = errors.New("unknown account")
+	// ErrInvalidCredentials is returned when the password doesn't match
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrLDAPAccountLinked is returned when the account with the email is synced from another directory entry
+	ErrLDAPAccountLinked = errors.New("the account is linked to another directory entry")
+	// ErrLDAPNoDepartment is returned when no department can be mapped for a new user
+	ErrLDAPNoDepartment = errors.New("no department is mapped for the directory entry")
+)
+
+// LDAPConfig holds the connection to the directory and how its entries map to users
+type LDAPConfig struct {
+	Url          string // e.g. ldap://localhost:389 or ldaps://ldap.example.com
+	StartTLS     bool
+	BindDn       string // Service account that searches the directory, anonymous if empty
+	BindPassword string
+	BaseDn       string
+	UserFilter   string // Filter matching the entries that are users, e.g. (objectClass=person)
+
+	UsernameAttribute string
+	EmailAttribute    string
+	NameAttribute     string
+	GroupAttribute    string // Attribute listing the DNs of the groups of an entry
+
+	GroupDepartments  map[string]string // Lowercased group DN to department name
+	GroupRoles        map[string]string // Lowercased group DN to role
+	DefaultDepartment string            // Department of new users without a mapped group
+}
+
+// DirectoryUser is a user entry of the directory
+type DirectoryUser struct {
+	Dn       string
+	Username string
+	Email    string
+	Name     string
+	Groups   []string
+}
+
+// LDAPDirectory authenticates and lists the users of an LDAP directory. Every operation uses its own connection.
+type LDAPDirectory struct {
+	config LDAPConfig
+}
+
+// NewLDAPDirectory creates a directory client for the given configuration
+func NewLDAPDirectory(config LDAPConfig) *LDAPDirectory {
+	if config.UserFilter == "" {
+		config.UserFilter = constants.LDAPDefaultUserFilter
+	}
+	if config.UsernameAttribute == "" {
+		config.UsernameAttribute = constants.LDAPDefaultUsernameAttribute
+	}
+	if config.EmailAttribute == "" {
+		config.EmailAttribute = constants.LDAPDefaultEmailAttribute
+	}
+	if config.NameAttribute == "" {
+		config.NameAttribute = constants.LDAPDefaultNameAttribute
+	}
+	if config.GroupAttribute == "" {
+		config.GroupAttribute = constants.LDAPDefaultGroupAttribute
+	}
+	return &LDAPDirectory{config: config}
+}
+
+var (
+	ldapDirectory     *LDAPDirectory
+	ldapDirectoryOnce sync.Once
+)
+
+// GetLDAPDirectory returns the directory configured with the ldap_* keys,
+// or nil if auth_backend isn't ldap
+func GetLDAPDirectory() *LDAPDirectory {
+	ldapDirectoryOnce.Do(func() {
+		if web.AppConfig.DefaultString("auth_backend", constants.AuthBackendLocal) != constants.AuthBackendLDAP {
+			return
+		}
+		ldapDirectory = NewLDAPDirectory(LDAPConfig{
+			Url:               web.AppConfig.DefaultString("ldap_url", "ldap://localhost:389"),
+			StartTLS:          web.AppConfig.DefaultBool("ldap_start_tls", false),
+			BindDn:            web.AppConfig.DefaultString("ldap_bind_dn", ""),
+			BindPassword:      web.AppConfig.DefaultString("ldap_bind_password", ""),
+			BaseDn:            web.AppConfig.DefaultString("ldap_base_dn", ""),
+			UserFilter:        web.AppConfig.DefaultString("ldap_user_filter", constants.LDAPDefaultUserFilter),
+			UsernameAttribute: web.AppConfig.DefaultString("ldap_username_attribute", constants.LDAPDefaultUsernameAttribute),
+			EmailAttribute:    web.AppConfig.DefaultString("ldap_email_attribute", constants.LDAPDefaultEmailAttribute),
+			NameAttribute:     web.AppConfig.DefaultString("ldap_name_attribute", constants.LDAPDefaultNameAttribute),
+			GroupAttribute:    web.AppConfig.DefaultString("ldap_group_attribute", constants.LDAPDefaultGroupAttribute),
+			GroupDepartments:  ParseLDAPGroupMapping(web.AppConfig.DefaultString("ldap_group_departments", "")),
+			GroupRoles:        ParseLDAPGroupMapping(web.AppConfig.DefaultString("ldap_group_roles", "")),
+			DefaultDepartment: web.AppConfig.DefaultString("ldap_default_department", ""),
+		})
+	})
+	return ldapDirectory
+}
+
+// ParseLDAPGroupMapping parses semicolon separated "group DN:value" pairs,
+// e.g. "cn=admins,ou=groups,dc=example,dc=org:ADMIN;cn=hr,ou=groups,dc=example,dc=org:MANAGER"
+func ParseLDAPGroupMapping(value string) map[string]string {
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(value, ";") {
+		separator := strings.LastIndex(pair, ":")
+		if separator <= 0 {
+			continue
+		}
+		group := normalizeLDAPDn(pair[:separator])
+		if target := strings.TrimSpace(pair[separator+1:]); group != "" && target != "" {
+			mapping[group] = target
+		}
+	}
+	return mapping
+}
+
+// normalizeLDAPDn lowercases a DN and removes the spaces around its components so DNs can be compared
+func normalizeLDAPDn(dn string) string {
+	components := strings.Split(strings.ToLower(strings.TrimSpace(dn)), ",")
+	for i := range components {
+		components[i] = strings.TrimSpace(components[i])
+	}
+	return strings.Join(components, ",")
+}
+
+// connect opens a connection bound as the service account
+func (d *LDAPDirectory) connect() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(d.config.Url, ldap.DialWithDialer(&net.Dialer{Timeout: constants.LDAPRequestTimeout}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the directory: %w", err)
+	}
+	conn.SetTimeout(constants.LDAPRequestTimeout)
+
+	if d.config.StartTLS {
+		host, _, _ := net.SplitHostPort(strings.TrimPrefix(d.config.Url, "ldap://"))
+		if err := conn.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to start TLS with the directory: %w", err)
+		}
+	}
+
+	if d.config.BindDn != "" {
+		if err := conn.Bind(d.config.BindDn, d.config.BindPassword); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to bind the service account: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// attributes lists the attributes read of a user entry
+func (d *LDAPDirectory) attributes() []string {
+	return []string{d.config.UsernameAttribute, d.config.EmailAttribute, d.config.NameAttribute, d.config.GroupAttribute}
+}
+
+// toDirectoryUser reads a user entry, entries without username or email are skipped
+func (d *LDAPDirectory) toDirectoryUser(entry *ldap.Entry) (*DirectoryUser, bool) {
+	user := &DirectoryUser{
+		Dn:       entry.DN,
+		Username: entry.GetAttributeValue(d.config.UsernameAttribute),
+		Email:    strings.ToLower(entry.GetAttributeValue(d.config.EmailAttribute)),
+		Name:     entry.GetAttributeValue(d.config.NameAttribute),
+		Groups:   entry.GetAttributeValues(d.config.GroupAttribute),
+	}
+	if user.Username == "" || user.Email == "" {
+		return nil, false
+	}
+	return user, true
+}
+
+// findUser searches the user entry with the email on an open connection
+func (d *LDAPDirectory) findUser(conn *ldap.Conn, email string) (*DirectoryUser, error) {
+	filter := fmt.Sprintf("(&%s(%s=%s))", d.config.UserFilter, d.config.EmailAttribute, ldap.EscapeFilter(email))
+	request := ldap.NewSearchRequest(d.config.BaseDn, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(constants.LDAPRequestTimeout.Seconds()), false, filter, d.attributes(), nil)
+	result, err := conn.Search(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search the directory: %w", err)
+	}
+
+	// An ambiguous email can't identify the account
+	if len(result.Entries) != 1 {
+		return nil, ErrUnknownAccount
+	}
+	user, ok := d.toDirectoryUser(result.Entries[0])
+	if !ok {
+		return nil, ErrUnknownAccount
+	}
+	return user, nil
+}
+
+// FindUserByEmail returns the user entry with the email
+func (d *LDAPDirectory) FindUserByEmail(email string) (*DirectoryUser, error) {
+	conn, err := d.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return d.findUser(conn, email)
+}
+
+// Authenticate checks the password of the user with the email by binding as the user entry
+func (d *LDAPDirectory) Authenticate(email, password string) (*DirectoryUser, error) {
+	// An empty password would be an unauthenticated bind, which directories accept
+	if password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := d.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	user, err := d.findUser(conn, email)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Bind(user.Dn, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("failed to bind the user: %w", err)
+	}
+	return user, nil
+}
+
+// ListUsers returns every user entry of the directory
+func (d *LDAPDirectory) ListUsers() ([]*DirectoryUser, error) {
+	conn, err := d.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	request := ldap.NewSearchRequest(d.config.BaseDn, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, d.config.UserFilter, d.attributes(), nil)
+	result, err := conn.SearchWithPaging(request, constants.LDAPPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the directory: %w", err)
+	}
+
+	users := make([]*DirectoryUser, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		if user, ok := d.toDirectoryUser(entry); ok {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+// MapRole returns the highest role mapped for the groups of the user, employees by default
+func (d *LDAPDirectory) MapRole(user *DirectoryUser) string {
+	rank := map[string]int{constants.RoleEmployee: 0, constants.RoleManager: 1, constants.RoleAdmin: 2}
+	role := constants.RoleEmployee
+	for _, group := range user.Groups {
+		mapped, ok := d.config.GroupRoles[normalizeLDAPDn(group)]
+		if ok && rank[strings.ToUpper(mapped)] > rank[role] {
+			role = strings.ToUpper(mapped)
+		}
+	}
+	return role
+}
+
+// MapDepartment returns the name of the department mapped for the first matching group of the user,
+// or the default department
+func (d *LDAPDirectory) MapDepartment(user *DirectoryUser) string {
+	for _, group := range user.Groups {
+		if department, ok := d.config.GroupDepartments[normalizeLDAPDn(group)]; ok {
+			return department
+		}
+	}
+	return d.config.DefaultDepartment
+}
+
+// AuthenticatePassword checks the password of the account with the email against the auth_backend. With the ldap backend
+// the directory checks the password and the account is synced from the directory entry. Accounts that aren't managed by
+// the directory, e.g. the seeded admins, keep logging in with their local password. user is nil for unknown emails.
+func AuthenticatePassword(user *models.User, email, password string) (*models.User, error) {
+	directory := GetLDAPDirectory()
+	if directory == nil || (user != nil && user.LdapUsername == "") {
+		if user == nil {
+			return nil, ErrUnknownAccount
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+			return nil, ErrInvalidCredentials
+		}
+		return user, nil
+	}
+
+	entry, err := directory.Authenticate(email, password)
+	if err != nil {
+		return nil, err
+	}
+	return SyncDirectoryUser(directory, entry)
+}
+
+// SyncDirectoryUser creates or updates the user of a directory entry and returns it. Entries are matched by username,
+// then linked to the account with the same email. The role and the department follow the groups of the entry.
+func SyncDirectoryUser(directory *LDAPDirectory, entry *DirectoryUser) (*models.User, error) {
+	user, _, err := syncDirectoryUser(directory, entry)
+	return user, err
+}
+
+// ldapSyncChange tells how the sync of an entry changed its user
+type ldapSyncChange int
+
+const (
+	ldapSyncUnchanged ldapSyncChange = iota
+	ldapSyncCreated
+	ldapSyncUpdated
+)
+
+// syncDirectoryUser syncs an entry and reports how its user changed
+func syncDirectoryUser(directory *LDAPDirectory, entry *DirectoryUser) (*models.User, ldapSyncChange, error) {
+	user, err := models.GetUserByLdapUsername(entry.Username)
+	if err == orm.ErrNoRows {
+		existing, err := models.GetUserByEmail(entry.Email)
+		if err == nil {
+			if existing.LdapUsername != "" {
+				return nil, ldapSyncUnchanged, ErrLDAPAccountLinked
+			}
+			user = &existing
+		} else if err != orm.ErrNoRows {
+			return nil, ldapSyncUnchanged, err
+		}
+	} else if err != nil {
+		return nil, ldapSyncUnchanged, err
+	}
+
+	department, err := mapLDAPDepartment(directory.MapDepartment(entry))
+	if err != nil {
+		return nil, ldapSyncUnchanged, err
+	}
+
+	if user == nil {
+		if department == nil {
+			return nil, ldapSyncUnchanged, ErrLDAPNoDepartment
+		}
+
+		// The directory checks the passwords, the local one is never used
+		randomPassword, err := GenerateRandomHex(32)
+		if err != nil {
+			return nil, ldapSyncUnchanged, err
+		}
+		hashedPassword, err := HashPassword(randomPassword)
+		if err != nil {
+			return nil, ldapSyncUnchanged, err
+		}
+
+		user = &models.User{
+			Name:          entry.Name,
+			Email:         entry.Email,
+			Password:      hashedPassword,
+			Role:          directory.MapRole(entry),
+			Department:    department,
+			EmailVerified: true,
+			LdapUsername:  entry.Username,
+		}
+		if user.Name == "" {
+			user.Name = entry.Username
+		}
+		userCreated := NewDomainEvent(constants.EventUserCreated, func() interface{} {
+			return dto.FromUserModelToUserResponse(user, false, false, false)
+		})
+		audit := NewAuditEntry(models.AuditActor{}, constants.AuditActionCreate, constants.AuditEntityUser, func() (int, interface{}, interface{}) {
+			return user.Id, nil, dto.FromUserModelToUserResponse(user, false, false, false)
+		})
+		if err := models.CreateUser(user, userCreated, audit); err != nil {
+			return nil, ldapSyncUnchanged, fmt.Errorf("failed to create user %s: %w", entry.Username, err)
+		}
+		return user, ldapSyncCreated, nil
+	}
+
+	// Users keep their department when none of their groups is mapped
+	role := directory.MapRole(entry)
+	if department == nil {
+		department = user.Department
+	}
+	changed := user.LdapUsername != entry.Username || user.Email != entry.Email || user.Role != role ||
+		user.Department == nil || user.Department.Id != department.Id || !user.EmailVerified || !user.IsActive() ||
+		(entry.Name != "" && user.Name != entry.Name)
+	if !changed {
+		return user, ldapSyncUnchanged, nil
+	}
+
+	// A new role is only granted with a new token
+	before := dto.FromUserModelToUserResponse(user, false, false, false)
+	if user.Role != role {
+		user.TokenVersion++
+	}
+	user.LdapUsername = entry.Username
+	user.Email = entry.Email
+	user.Role = role
+	user.Department = department
+	user.EmailVerified = true
+	user.DeactivatedAt = nil
+	if entry.Name != "" {
+		user.Name = entry.Name
+	}
+	userUpdated := NewDomainEvent(constants.EventUserUpdated, func() interface{} {
+		return dto.FromUserModelToUserResponse(user, false, false, false)
+	})
+	audit := NewAuditEntry(models.AuditActor{}, constants.AuditActionUpdate, constants.AuditEntityUser, func() (int, interface{}, interface{}) {
+		return user.Id, before, dto.FromUserModelToUserResponse(user, false, false, false)
+	})
+	if err := models.UpdateProvisionedUser(user, userUpdated, audit); err != nil {
+		return nil, ldapSyncUnchanged, fmt.Errorf("failed to update user %s: %w", entry.Username, err)
+	}
+	return user, ldapSyncUpdated, nil
+}
+
+// mapLDAPDepartment finds the department with the name, nil if the name is empty
+func mapLDAPDepartment(name string) (*models.Department, error) {
+	if name == "" {
+		return nil, nil
+	}
+	department, err := models.GetDepartmentByName(name)
+	if err == orm.ErrNoRows {
+		return nil, fmt.Errorf("department %q of the directory mapping doesn't exist: %w", name, ErrLDAPNoDepartment)
+	}
+	return department, err
+}
+
+// LDAPSyncResult counts the changes of a directory sync
+type LDAPSyncResult struct {
+	Created     int
+	Updated     int
+	Deactivated int
+	Failed      int
+}
+
+// SyncLDAPUsers syncs every user entry of the directory and deactivates the synced users that were removed from it.
+// Entries that fail to sync are counted and reported in the error, the other entries are still synced. The changes
+// are recorded in the audit log without an actor.
+func SyncLDAPUsers(directory *LDAPDirectory, now time.Time) (LDAPSyncResult, error) {
+	var result LDAPSyncResult
+	entries, err := directory.ListUsers()
+	if err != nil {
+		return result, err
+	}
+
+	// An empty listing is rather a wrong base DN or filter than a directory without users
+	if len(entries) == 0 {
+		return result, errors.New("the directory listed no users, nobody is deactivated")
+	}
+
+	var errs []error
+	listed := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		listed[entry.Username] = true
+		_, change, err := syncDirectoryUser(directory, entry)
+		switch {
+		case err != nil:
+			result.Failed++
+			errs = append(errs, fmt.Errorf("%s: %w", entry.Username, err))
+		case change == ldapSyncCreated:
+			result.Created++
+		case change == ldapSyncUpdated:
+			result.Updated++
+		}
+	}
+
+	users, err := models.GetLdapUsers()
+	if err != nil {
+		return result, err
+	}
+	for _, user := range users {
+		if listed[user.LdapUsername] || !user.IsActive() {
+			continue
+		}
+		before := dto.FromUserModelToUserResponse(user, false, false, false)
+		userUpdated := NewDomainEvent(constants.EventUserUpdated, func() interface{} {
+			return dto.FromUserModelToUserResponse(user, false, false, false)
+		})
+		audit := NewAuditEntry(models.AuditActor{}, constants.AuditActionUpdate, constants.AuditEntityUser, func() (int, interface{}, interface{}) {
+			return user.Id, before, dto.FromUserModelToUserResponse(user, false, false, false)
+		})
+		if err := models.DeactivateUser(user, now, userUpdated, audit); err != nil {
+			result.Failed++
+			errs = append(errs, fmt.Errorf("%s: %w", user.LdapUsername, err))
+			continue
+		}
+		result.Deactivated++
+	}
+	return result, errors.Join(errs...)
+}
+
+// StartLDAPSync schedules the directory sync with the cron spec ldap_sync_spec if auth_backend is ldap.
+// It has to be called before the tasks are started.
+func StartLDAPSync() {
+	directory := GetLDAPDirectory()
+	if directory == nil {
+		return
+	}
+
+	task.AddTask(constants.LDAPSyncTaskName, task.NewTask(constants.LDAPSyncTaskName,
+		web.AppConfig.DefaultString("ldap_sync_spec", constants.LDAPDefaultSyncSpec),
+		func(ctx context.Context) error {
+			result, err := SyncLDAPUsers(directory, time.Now())
+			log.Printf("LDAP sync: %d created, %d updated, %d deactivated, %d failed", result.Created, result.Updated, result.Deactivated, result.Failed)
+			return err
+		}))
+}
@@ -0,0 +1,70 @@
+package helpers
+
+import (
+	"log"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/server/web"
+)
+
+// LoginPolicy holds the thresholds of the brute-force protection
+type LoginPolicy struct {
+	MaxFailures   int           // Failed passwords in a row that lock the account
+	Lockout       time.Duration // Time the account stays locked
+	IpMaxFailures int           // Failed logins from an IP within IpWindow that block it
+	IpWindow      time.Duration
+	DelayBase     time.Duration // Wait after the first failed password, doubled with every further failure
+	DelayMax      time.Duration
+}
+
+// GetLoginPolicy reads the thresholds from login_max_failures, login_lockout_minutes, login_ip_max_failures and login_ip_window_minutes
+func GetLoginPolicy() LoginPolicy {
+	return LoginPolicy{
+		MaxFailures:   web.AppConfig.DefaultInt("login_max_failures", constants.LoginDefaultMaxFailures),
+		Lockout:       time.Duration(web.AppConfig.DefaultInt("login_lockout_minutes", int(constants.LoginDefaultLockout/time.Minute))) * time.Minute,
+		IpMaxFailures: web.AppConfig.DefaultInt("login_ip_max_failures", constants.LoginDefaultIpMaxFailures),
+		IpWindow:      time.Duration(web.AppConfig.DefaultInt("login_ip_window_minutes", int(constants.LoginDefaultIpWindow/time.Minute))) * time.Minute,
+		DelayBase:     constants.LoginDelayBase,
+		DelayMax:      constants.LoginDelayMax,
+	}
+}
+
+// AccountRetryAfter returns how long the account has to wait before the next login attempt and why,
+// or zero if the password may be checked now.
+func (p LoginPolicy) AccountRetryAfter(user *models.User, now time.Time) (time.Duration, string) {
+	if user.LockedUntil != nil && now.Before(*user.LockedUntil) {
+		return user.LockedUntil.Sub(now), constants.LoginReasonLocked
+	}
+	if user.FailedLoginAttempts > 0 && user.LastFailedLoginAt != nil {
+		next := user.LastFailedLoginAt.Add(ExponentialBackoff(user.FailedLoginAttempts, p.DelayBase, p.DelayMax))
+		if now.Before(next) {
+			return next.Sub(now), constants.LoginReasonThrottled
+		}
+	}
+	return 0, ""
+}
+
+// IsIpBlocked reports whether too many logins failed from an IP within the window.
+// Only wrong passwords and unknown emails count, attempts rejected while blocked don't extend the block.
+func (p LoginPolicy) IsIpBlocked(ip string, now time.Time) (bool, error) {
+	failures, err := models.CountFailedLoginsByIp(ip, now.Add(-p.IpWindow), []string{constants.LoginReasonInvalidPassword, constants.LoginReasonUnknownEmail})
+	if err != nil {
+		return false, err
+	}
+	return failures >= int64(p.IpMaxFailures), nil
+}
+
+// RecordLoginAttempt writes the audit record of a login attempt, user is nil for unknown emails.
+// Failing to record doesn't fail the login, the error is logged.
+func RecordLoginAttempt(user *models.User, email, ip, userAgent string, success bool, reason string) {
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	attempt := &models.LoginAttempt{User: user, Email: email, Ip: ip, UserAgent: userAgent, Success: success, Reason: reason}
+	if err := models.CreateLoginAttempt(attempt); err != nil {
+		log.Printf("Failed to record login attempt of %s from %s: %v", email, ip, err)
+	}
+}
@@ -0,0 +1,172 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+
+	"github.com/beego/beego/v2/server/web"
+)
+
+// MailMessage is an email with a plain text and an HTML version of its body
+type MailMessage struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Bytes encodes the message as multipart/alternative MIME message
+func (m *MailMessage) Bytes(from string, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	body := multipart.NewWriter(&buf)
+
+	header := textproto.MIMEHeader{}
+	header.Set("From", from)
+	header.Set("To", m.To)
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header.Set("Date", date.Format(time.RFC1123Z))
+	header.Set("MIME-Version", "1.0")
+	header.Set("Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", body.Boundary()))
+
+	var message bytes.Buffer
+	for _, key := range []string{"From", "To", "Subject", "Date", "MIME-Version", "Content-Type"} {
+		fmt.Fprintf(&message, "%s: %s\r\n", key, header.Get(key))
+	}
+	message.WriteString("\r\n")
+
+	// Clients display the last alternative they support, so the HTML version comes last
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		writer, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoder := quotedprintable.NewWriter(writer)
+		if _, err := encoder.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+
+	message.Write(buf.Bytes())
+	return message.Bytes(), nil
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(message *MailMessage) error
+}
+
+// SMTPMailer sends emails through an SMTP server, upgrading the connection with STARTTLS when the server offers it
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string // Authentication is skipped without a username
+	Password string
+	From     string
+}
+
+// Send implements Mailer
+func (s *SMTPMailer) Send(message *MailMessage) error {
+	sender, err := mail.ParseAddress(s.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+
+	body, err := message.Bytes(s.From, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	return smtp.SendMail(s.Host+":"+strconv.Itoa(s.Port), auth, sender.Address, []string{message.To}, body)
+}
+
+// LogMailer writes the emails as .eml files into a directory instead of sending them, for development
+type LogMailer struct {
+	Dir  string
+	From string
+}
+
+// Send implements Mailer
+func (l *LogMailer) Send(message *MailMessage) error {
+	body, err := message.Bytes(l.From, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(l.Dir, 0o755); err != nil {
+		return err
+	}
+	suffix, err := GenerateRandomHex(4)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(l.Dir, fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405"), suffix))
+	if err := os.WriteFile(path, body, 0o644); err != nil {
+		return err
+	}
+
+	log.Printf("Mail %q to %s written to %s", message.Subject, message.To, path)
+	return nil
+}
+
+var (
+	mailer     Mailer
+	mailerOnce sync.Once
+)
+
+// GetMailer returns the mailer selected by mail_driver in the application configuration
+func GetMailer() Mailer {
+	mailerOnce.Do(func() {
+		if mailer != nil {
+			return
+		}
+		from := web.AppConfig.DefaultString("mail_from", "Beego Presence API <no-reply@example.com>")
+		if web.AppConfig.DefaultString("mail_driver", constants.MailDriverLog) == constants.MailDriverSMTP {
+			mailer = &SMTPMailer{
+				Host:     web.AppConfig.DefaultString("smtp_host", "localhost"),
+				Port:     web.AppConfig.DefaultInt("smtp_port", 587),
+				Username: web.AppConfig.DefaultString("smtp_username", ""),
+				Password: web.AppConfig.DefaultString("smtp_password", ""),
+				From:     from,
+			}
+			return
+		}
+		mailer = &LogMailer{Dir: web.AppConfig.DefaultString("mail_log_dir", "logs/mail"), From: from}
+	})
+	return mailer
+}
+
+// SetMailer replaces the configured mailer, e.g. to capture the emails in tests
+func SetMailer(m Mailer) {
+	mailerOnce.Do(func() {})
+	mailer = m
+}
@@ -0,0 +1,233 @@
+package helpers
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/server/web"
+	"github.com/beego/beego/v2/task"
+)
+
+// The templates of a notification type are <type>.txt, which also defines the "<type>.subject" template, and <type>.html
+//
+//go:embed templates/mail
+var notificationTemplateFS embed.FS
+
+var (
+	notificationTextTemplates = texttemplate.Must(texttemplate.ParseFS(notificationTemplateFS, "templates/mail/*.txt"))
+	notificationHTMLTemplates = htmltemplate.Must(htmltemplate.ParseFS(notificationTemplateFS, "templates/mail/*.html"))
+)
+
+// LateArrival is a line of the late arrival digest
+type LateArrival struct {
+	Name        string
+	CheckIn     string
+	LateMinutes int
+}
+
+// RenderNotification renders the subject and the bodies of a notification, the recipient is left empty
+func RenderNotification(notificationType string, data map[string]interface{}) (*MailMessage, error) {
+	var subject, text, html bytes.Buffer
+	if err := notificationTextTemplates.ExecuteTemplate(&subject, notificationType+".subject", data); err != nil {
+		return nil, err
+	}
+	if err := notificationTextTemplates.ExecuteTemplate(&text, notificationType+".txt", data); err != nil {
+		return nil, err
+	}
+	if err := notificationHTMLTemplates.ExecuteTemplate(&html, notificationType+".html", data); err != nil {
+		return nil, err
+	}
+	return &MailMessage{Subject: strings.TrimSpace(subject.String()), Text: text.String(), HTML: html.String()}, nil
+}
+
+// SendNotification delivers a notification to a user through the channels of its type, unless the user opted out of
+// the type or the notification with the same reference was already sent through the channel. Every attempt is recorded
+// in the notification log.
+func SendNotification(user *models.User, notificationType, reference string, data map[string]interface{}) error {
+	// Only the types listed in constants.NotificationTypes can be opted out of
+	if contains(constants.NotificationTypes, notificationType) {
+		enabled, err := models.IsNotificationEnabled(user.Id, notificationType)
+		if err != nil || !enabled {
+			return err
+		}
+	}
+
+	var message *MailMessage
+	var errs []error
+	for _, channel := range GetNotificationChannels(notificationType) {
+		if models.IsNotificationSent(reference, channel.Name()) {
+			continue
+		}
+
+		// Render once, and only when there is something left to send
+		if message == nil {
+			data["User"] = user
+			data["Company"] = GetTimesheetCompany().Name
+			var err error
+			if message, err = RenderNotification(notificationType, data); err != nil {
+				return fmt.Errorf("failed to render %s notification: %w", notificationType, err)
+			}
+		}
+
+		entry := &models.NotificationLog{
+			User:      user,
+			Type:      notificationType,
+			Reference: reference,
+			Channel:   channel.Name(),
+			Recipient: user.Email,
+			Subject:   message.Subject,
+			Status:    constants.NotificationStatusSent,
+		}
+		if err := channel.Deliver(user, message); err != nil {
+			entry.Status = constants.NotificationStatusFailed
+			entry.Error = err.Error()
+			errs = append(errs, fmt.Errorf("%s: %w", channel.Name(), err))
+		}
+		if err := models.CreateNotificationLog(entry); err != nil {
+			log.Printf("Failed to log %s notification to %s: %v", notificationType, user.Email, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SendWelcomeNotification is the event handler welcoming the users who registered themselves, once they verified the
+// email. Accounts created by admins or provisioned from a directory start verified, so they aren't welcomed. A user
+// verifying a changed email isn't welcomed again, the notification is sent once per user.
+func SendWelcomeNotification(envelope *EventEnvelope) error {
+	var data struct {
+		Id int `json:"id"`
+	}
+	if err := json.Unmarshal(envelope.Data, &data); err != nil {
+		return err
+	}
+
+	user, err := models.GetUserById(data.Id, false)
+	if err == orm.ErrNoRows {
+		// Deleted before the event was handled
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.ServiceAccount {
+		return nil
+	}
+
+	return SendNotification(user, constants.NotificationTypeWelcome, fmt.Sprintf("%s:%d", constants.NotificationTypeWelcome, user.Id), map[string]interface{}{})
+}
+
+// SendLateArrivalDigests emails the late arrivals of the day to the managers of each department
+func SendLateArrivalDigests(day time.Time) error {
+	dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, PresenceLocation())
+	presences, err := models.GetPresencesBetween(dayStart, dayStart.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+
+	arrivals := make(map[int][]LateArrival)
+	for _, presence := range presences {
+		if presence.Type != constants.PresenceTypeIn || presence.Status != constants.PresenceStatusLate || presence.User.Department == nil {
+			continue
+		}
+		scheduledIn, err := ParseScheduleTime(dayStart, presence.Schedule.InTime)
+		if err != nil {
+			return err
+		}
+		checkIn := presence.CreatedAt.In(PresenceLocation())
+		departmentId := presence.User.Department.Id
+		arrivals[departmentId] = append(arrivals[departmentId], LateArrival{
+			Name:        presence.User.Name,
+			CheckIn:     checkIn.Format("15:04"),
+			LateMinutes: int(checkIn.Sub(scheduledIn).Minutes()),
+		})
+	}
+
+	managers, err := models.GetUsersByRole(constants.RoleManager)
+	if err != nil {
+		return err
+	}
+
+	date := dayStart.Format(constants.ReportDateLayout)
+	var errs []error
+	for _, manager := range managers {
+		departmentArrivals := arrivals[manager.Department.Id]
+		if len(departmentArrivals) == 0 {
+			continue
+		}
+		reference := fmt.Sprintf("%s:%d:%s", constants.NotificationTypeLateDigest, manager.Id, date)
+		data := map[string]interface{}{"Date": date, "Arrivals": departmentArrivals}
+		if err := SendNotification(manager, constants.NotificationTypeLateDigest, reference, data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SendMissingCheckoutReminders reminds the users who checked in on the day but didn't check out
+func SendMissingCheckoutReminders(day time.Time) error {
+	dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, PresenceLocation())
+	presences, err := models.GetPresencesBetween(dayStart, dayStart.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+
+	checkIns := make(map[int]*models.Presence)
+	checkedOut := make(map[int]bool)
+	for _, presence := range presences {
+		switch presence.Type {
+		case constants.PresenceTypeIn:
+			checkIns[presence.User.Id] = presence
+		case constants.PresenceTypeOut:
+			checkedOut[presence.User.Id] = true
+		}
+	}
+
+	date := dayStart.Format(constants.ReportDateLayout)
+	var errs []error
+	for userId, checkIn := range checkIns {
+		if checkedOut[userId] {
+			continue
+		}
+		reference := fmt.Sprintf("%s:%d:%s", constants.NotificationTypeMissingCheckout, userId, date)
+		data := map[string]interface{}{"Date": date, "CheckIn": checkIn.CreatedAt.In(PresenceLocation()).Format("15:04")}
+		if err := SendNotification(checkIn.User, constants.NotificationTypeMissingCheckout, reference, data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// StartNotifications welcomes self-registered users and schedules the daily digests, the reminders and the shift notifications.
+// The schedules are cron specs with seconds, evaluated in the time zone of the server.
+func StartNotifications() {
+	eventHandlers.Handle(constants.EventUserVerified, "welcome", SendWelcomeNotification)
+
+	task.AddTask(constants.NotificationTypeLateDigest, task.NewTask(constants.NotificationTypeLateDigest,
+		web.AppConfig.DefaultString("notification_late_digest_spec", "0 0 10 * * 1-5"),
+		func(ctx context.Context) error {
+			return SendLateArrivalDigests(time.Now().In(PresenceLocation()))
+		}))
+	task.AddTask(constants.NotificationTypeMissingCheckout, task.NewTask(constants.NotificationTypeMissingCheckout,
+		web.AppConfig.DefaultString("notification_missing_checkout_spec", "0 0 20 * * *"),
+		func(ctx context.Context) error {
+			return SendMissingCheckoutReminders(time.Now().In(PresenceLocation()))
+		}))
+	task.AddTask("shift_notifications", task.NewTask("shift_notifications", constants.ShiftSchedulerSpec,
+		func(ctx context.Context) error {
+			return SendShiftNotifications(time.Now())
+		}))
+	task.StartTask()
+}
@@ -0,0 +1,168 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/server/web"
+)
+
+// NotificationChannel delivers a rendered notification to a user
+type NotificationChannel interface {
+	Name() string // Stable name, recorded in the notification log
+	Deliver(user *models.User, message *MailMessage) error
+}
+
+// EmailChannel emails the notification through the configured mailer
+type EmailChannel struct{}
+
+// Name implements NotificationChannel
+func (EmailChannel) Name() string {
+	return constants.NotificationChannelEmail
+}
+
+// Deliver implements NotificationChannel
+func (EmailChannel) Deliver(user *models.User, message *MailMessage) error {
+	email := *message
+	email.To = user.Email
+	return GetMailer().Send(&email)
+}
+
+// ChatWebhookChannel posts the text version of the notification to a chat webhook accepting {"text": ...}
+type ChatWebhookChannel struct {
+	Url    string
+	client *http.Client
+}
+
+// NewChatWebhookChannel creates a channel posting to the given webhook URL
+func NewChatWebhookChannel(url string) *ChatWebhookChannel {
+	return &ChatWebhookChannel{Url: url, client: &http.Client{Timeout: constants.WebhookRequestTimeout}}
+}
+
+// Name implements NotificationChannel
+func (c *ChatWebhookChannel) Name() string {
+	return constants.NotificationChannelChat
+}
+
+// Deliver implements NotificationChannel
+func (c *ChatWebhookChannel) Deliver(user *models.User, message *MailMessage) error {
+	body, err := json.Marshal(map[string]string{
+		"text": fmt.Sprintf("*%s* (%s)\n%s", message.Subject, user.Name, strings.TrimSpace(message.Text)),
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Post(c.Url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, excerpt)
+	}
+	return nil
+}
+
+// PushNotification is a notification for the mobile devices of a user
+type PushNotification struct {
+	UserId    int       `json:"user_id"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LocalPushChannel stands in for a push gateway by appending the notifications as JSON lines to a file
+type LocalPushChannel struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// Name implements NotificationChannel
+func (p *LocalPushChannel) Name() string {
+	return constants.NotificationChannelPush
+}
+
+// Deliver implements NotificationChannel
+func (p *LocalPushChannel) Deliver(user *models.User, message *MailMessage) error {
+	line, err := json.Marshal(PushNotification{UserId: user.Id, Title: message.Subject, Body: strings.TrimSpace(message.Text), CreatedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(p.Path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(p.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	log.Printf("Push notification %q for user %d written to %s", message.Subject, user.Id, p.Path)
+	return nil
+}
+
+var (
+	notificationChannels     map[string][]NotificationChannel
+	notificationChannelsOnce sync.Once
+)
+
+// GetNotificationChannels returns the channels a type of notification is delivered through.
+// Shift reminders and missed check-in nudges use the channels listed in reminder_channels, other notifications are emailed.
+func GetNotificationChannels(notificationType string) []NotificationChannel {
+	notificationChannelsOnce.Do(func() {
+		if notificationChannels != nil {
+			return
+		}
+		reminderChannels := make([]NotificationChannel, 0)
+		for _, name := range strings.Split(web.AppConfig.DefaultString("reminder_channels", constants.NotificationChannelEmail), ",") {
+			switch strings.TrimSpace(name) {
+			case constants.NotificationChannelEmail:
+				reminderChannels = append(reminderChannels, EmailChannel{})
+			case constants.NotificationChannelChat:
+				reminderChannels = append(reminderChannels, NewChatWebhookChannel(web.AppConfig.DefaultString("chat_webhook_url", "")))
+			case constants.NotificationChannelPush:
+				reminderChannels = append(reminderChannels, &LocalPushChannel{Path: web.AppConfig.DefaultString("push_log_file", "logs/push.log")})
+			case "":
+			default:
+				log.Printf("Ignoring unknown notification channel %q", name)
+			}
+		}
+		notificationChannels = map[string][]NotificationChannel{
+			constants.NotificationTypeShiftReminder: reminderChannels,
+			constants.NotificationTypeMissedCheckIn: reminderChannels,
+		}
+	})
+
+	if channels, ok := notificationChannels[notificationType]; ok {
+		return channels
+	}
+	return []NotificationChannel{EmailChannel{}}
+}
+
+// SetNotificationChannels replaces the configured channels of notification types, e.g. to capture notifications in tests
+func SetNotificationChannels(channels map[string][]NotificationChannel) {
+	notificationChannelsOnce.Do(func() {})
+	notificationChannels = channels
+}
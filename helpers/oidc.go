@@ -0,0 +1,338 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+
+	"github.com/beego/beego/v2/server/web"
+	"github.com/dgrijalva/jwt-go"
+)
+
+// OIDCConfig holds the client registration at the identity provider
+type OIDCConfig struct {
+	Issuer          string
+	ClientId        string
+	ClientSecret    string
+	RedirectUrl     string // Callback of this API registered at the provider
+	Scopes          string
+	DepartmentClaim string // ID token claim holding the name of the department of new users
+}
+
+// OIDCClaims are the claims of a validated ID token used to sign the user in
+type OIDCClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Department    string
+}
+
+// oidcDiscovery is the part of the provider metadata the login flow needs
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+// OIDCClient runs the authorization code flow with PKCE against an OpenID Connect provider.
+// The provider metadata and its signing keys are fetched on first use and the keys are refreshed
+// when a token is signed with an unknown key id, e.g. after the provider rotated its keys.
+type OIDCClient struct {
+	config OIDCConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]*rsa.PublicKey
+}
+
+// NewOIDCClient creates a client for the given registration
+func NewOIDCClient(config OIDCConfig) *OIDCClient {
+	if config.Scopes == "" {
+		config.Scopes = constants.OIDCDefaultScopes
+	}
+	if config.DepartmentClaim == "" {
+		config.DepartmentClaim = constants.OIDCDefaultDepartmentClaim
+	}
+	return &OIDCClient{config: config, client: &http.Client{Timeout: constants.OIDCRequestTimeout}}
+}
+
+var (
+	oidcClient     *OIDCClient
+	oidcClientOnce sync.Once
+)
+
+// GetOIDCClient returns the client configured with oidc_issuer, oidc_client_id, oidc_client_secret and oidc_redirect_url,
+// or nil if single sign-on isn't configured
+func GetOIDCClient() *OIDCClient {
+	oidcClientOnce.Do(func() {
+		issuer := web.AppConfig.DefaultString("oidc_issuer", "")
+		if issuer == "" {
+			return
+		}
+		oidcClient = NewOIDCClient(OIDCConfig{
+			Issuer:          issuer,
+			ClientId:        web.AppConfig.DefaultString("oidc_client_id", ""),
+			ClientSecret:    web.AppConfig.DefaultString("oidc_client_secret", ""),
+			RedirectUrl:     web.AppConfig.DefaultString("oidc_redirect_url", "http://localhost:8080/api/v1/auth/oidc/callback"),
+			Scopes:          web.AppConfig.DefaultString("oidc_scopes", constants.OIDCDefaultScopes),
+			DepartmentClaim: web.AppConfig.DefaultString("oidc_department_claim", constants.OIDCDefaultDepartmentClaim),
+		})
+	})
+	return oidcClient
+}
+
+// GeneratePKCE returns a new code verifier and its S256 code challenge
+func GeneratePKCE() (string, string, error) {
+	random := make([]byte, constants.OIDCCodeVerifierBytes)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", err
+	}
+	verifier := base64.RawURLEncoding.EncodeToString(random)
+	challenge := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(challenge[:]), nil
+}
+
+// AuthorizationUrl returns the URL of the provider the user is redirected to for signing in
+func (c *OIDCClient) AuthorizationUrl(state, nonce, codeChallenge string) (string, error) {
+	discovery, err := c.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+
+	authorizationUrl, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	query := authorizationUrl.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", c.config.ClientId)
+	query.Set("redirect_uri", c.config.RedirectUrl)
+	query.Set("scope", c.config.Scopes)
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	authorizationUrl.RawQuery = query.Encode()
+	return authorizationUrl.String(), nil
+}
+
+// Exchange redeems an authorization code at the token endpoint and returns the ID token
+func (c *OIDCClient) Exchange(code, codeVerifier string) (string, error) {
+	discovery, err := c.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.config.RedirectUrl)
+	form.Set("code_verifier", codeVerifier)
+	req, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(c.config.ClientId), url.QueryEscape(c.config.ClientSecret))
+
+	var tokens struct {
+		IdToken string `json:"id_token"`
+	}
+	if err := c.doJSON(req, &tokens); err != nil {
+		return "", fmt.Errorf("token exchange failed: %w", err)
+	}
+	if tokens.IdToken == "" {
+		return "", errors.New("token response doesn't contain an ID token")
+	}
+	return tokens.IdToken, nil
+}
+
+// VerifyIDToken validates the signature of an ID token against the keys of the provider as well as
+// its issuer, audience, lifetime and nonce, and returns the claims identifying the user
+func (c *OIDCClient) VerifyIDToken(rawToken, nonce string, now time.Time) (*OIDCClaims, error) {
+	discovery, err := c.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	// The lifetime is checked below with a tolerance for the clock of the provider
+	parser := &jwt.Parser{ValidMethods: []string{jwt.SigningMethodRS256.Alg()}, SkipClaimsValidation: true}
+	claims := jwt.MapClaims{}
+	if _, err := parser.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return c.getKey(kid)
+	}); err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+
+	if issuer, _ := claims["iss"].(string); issuer != discovery.Issuer {
+		return nil, fmt.Errorf("invalid ID token: unexpected issuer %q", issuer)
+	}
+	if !containsAudience(claims["aud"], c.config.ClientId) {
+		return nil, errors.New("invalid ID token: not issued for this client")
+	}
+	if expiresAt, ok := claims["exp"].(float64); !ok || now.Add(-constants.OIDCClockSkew).After(time.Unix(int64(expiresAt), 0)) {
+		return nil, errors.New("invalid ID token: expired")
+	}
+	if issuedAt, ok := claims["iat"].(float64); ok && now.Add(constants.OIDCClockSkew).Before(time.Unix(int64(issuedAt), 0)) {
+		return nil, errors.New("invalid ID token: issued in the future")
+	}
+	if tokenNonce, _ := claims["nonce"].(string); nonce == "" || tokenNonce != nonce {
+		return nil, errors.New("invalid ID token: nonce mismatch")
+	}
+
+	result := &OIDCClaims{}
+	result.Subject, _ = claims["sub"].(string)
+	result.Email, _ = claims["email"].(string)
+	result.Name, _ = claims["name"].(string)
+	result.Department, _ = claims[c.config.DepartmentClaim].(string)
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		result.EmailVerified = verified
+	case string:
+		// Some providers send the flag as string
+		result.EmailVerified = verified == "true"
+	}
+	if result.Subject == "" {
+		return nil, errors.New("invalid ID token: missing subject")
+	}
+	return result, nil
+}
+
+// containsAudience reports whether the aud claim, a string or a list of strings, contains the client id
+func containsAudience(audience interface{}, clientId string) bool {
+	switch aud := audience.(type) {
+	case string:
+		return aud == clientId
+	case []interface{}:
+		for _, item := range aud {
+			if item == clientId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getDiscovery fetches the provider metadata once
+func (c *OIDCClient) getDiscovery() (*oidcDiscovery, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.discovery != nil {
+		return c.discovery, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(c.config.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	discovery := &oidcDiscovery{}
+	if err := c.doJSON(req, discovery); err != nil {
+		return nil, fmt.Errorf("provider discovery failed: %w", err)
+	}
+	if discovery.Issuer != c.config.Issuer {
+		return nil, fmt.Errorf("provider discovery failed: issuer %q doesn't match %q", discovery.Issuer, c.config.Issuer)
+	}
+	c.discovery = discovery
+	return discovery, nil
+}
+
+// getKey returns the RSA signing key with the given key id, refreshing the key set once if it's unknown
+func (c *OIDCClient) getKey(kid string) (*rsa.PublicKey, error) {
+	c.mu.Lock()
+	key, ok := c.keys[kid]
+	c.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	if err := c.refreshKeys(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key, ok := c.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// refreshKeys fetches the RSA signing keys of the provider
+func (c *OIDCClient) refreshKeys() error {
+	discovery, err := c.getDiscovery()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, discovery.JwksUri, nil)
+	if err != nil {
+		return err
+	}
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := c.doJSON(req, &jwks); err != nil {
+		return fmt.Errorf("fetching the provider keys failed: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		modulus, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			continue
+		}
+		exponent, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(new(big.Int).SetBytes(exponent).Int64())}
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.mu.Unlock()
+	return nil
+}
+
+// doJSON sends a request and decodes the JSON response, any status other than 2xx is an error
+func (c *OIDCClient) doJSON(req *http.Request, target interface{}) error {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, excerpt)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(target)
+}
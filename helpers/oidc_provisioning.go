@@ -0,0 +1,152 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/server/web"
+)
+
+var (
+	// ErrOIDCEmailNotVerified is returned when the provider didn't verify the email, which is needed to link or create an account
+	ErrOIDCEmailNotVerified = errors.New("the identity provider didn't verify the email address")
+	// ErrOIDCAccountLinked is returned when the account with the email is linked to another identity
+	ErrOIDCAccountLinked = errors.New("the account is linked to another identity")
+	// ErrOIDCNoDepartment is returned when no department can be mapped for a new user
+	ErrOIDCNoDepartment = errors.New("no department is mapped for the account")
+)
+
+// ProvisionOIDCUser returns the user signing in with an identity of the provider. Identities are matched by subject first,
+// then linked to an existing account with the same verified email. Unknown users are created just in time as employees,
+// in the department named by the department claim or in oidc_default_department.
+func ProvisionOIDCUser(claims *OIDCClaims) (*models.User, error) {
+	user, err := models.GetUserByOidcSubject(claims.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if err != orm.ErrNoRows {
+		return nil, err
+	}
+
+	// Without a verified email the identity can't be matched to an account, nor be trusted for a new one
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, ErrOIDCEmailNotVerified
+	}
+
+	existing, err := models.GetUserByEmail(claims.Email)
+	if err == nil {
+		if existing.OidcSubject != "" {
+			return nil, ErrOIDCAccountLinked
+		}
+		if err := models.LinkUserOidcSubject(&existing, claims.Subject); err != nil {
+			return nil, err
+		}
+		return &existing, nil
+	}
+	if err != orm.ErrNoRows {
+		return nil, err
+	}
+
+	department, err := mapOIDCDepartment(claims.Department)
+	if err != nil {
+		return nil, err
+	}
+
+	// The account has no usable password, signing in locally needs a password reset
+	randomPassword, err := GenerateRandomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := HashPassword(randomPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = claims.Email
+	}
+	user = &models.User{
+		Name:          name,
+		Email:         claims.Email,
+		Password:      hashedPassword,
+		Role:          constants.RoleEmployee,
+		Department:    department,
+		EmailVerified: true,
+		OidcSubject:   claims.Subject,
+	}
+	userCreated := NewDomainEvent(constants.EventUserCreated, func() interface{} {
+		return dto.FromUserModelToUserResponse(user, false, false, false)
+	})
+	if err := models.CreateUser(user, userCreated); err != nil {
+		return nil, fmt.Errorf("failed to provision user: %w", err)
+	}
+	return user, nil
+}
+
+// mapOIDCDepartment finds the department named by the claim, falling back to oidc_default_department
+func mapOIDCDepartment(name string) (*models.Department, error) {
+	for _, candidate := range []string{name, web.AppConfig.DefaultString("oidc_default_department", "")} {
+		if candidate == "" {
+			continue
+		}
+		department, err := models.GetDepartmentByName(candidate)
+		if err == nil {
+			return department, nil
+		}
+		if err != orm.ErrNoRows {
+			return nil, err
+		}
+	}
+	return nil, ErrOIDCNoDepartment
+}
+
+// StartOIDCLogin stores the secrets of a new login and returns the URL of the provider to redirect the user to
+func StartOIDCLogin(client *OIDCClient, now time.Time) (string, error) {
+	state, err := GenerateRandomHex(constants.OIDCStateBytes)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := GenerateRandomHex(constants.OIDCStateBytes)
+	if err != nil {
+		return "", err
+	}
+	verifier, challenge, err := GeneratePKCE()
+	if err != nil {
+		return "", err
+	}
+
+	authorizationUrl, err := client.AuthorizationUrl(state, nonce, challenge)
+	if err != nil {
+		return "", err
+	}
+	loginState := &models.OidcLoginState{State: state, Nonce: nonce, CodeVerifier: verifier, ExpiresAt: now.Add(constants.OIDCStateTTL)}
+	if err := models.CreateOidcLoginState(loginState); err != nil {
+		return "", err
+	}
+	return authorizationUrl, nil
+}
+
+// CompleteOIDCLogin redeems the code of the callback for the login started with the state and returns the signed in user
+func CompleteOIDCLogin(client *OIDCClient, state, code string, now time.Time) (*models.User, error) {
+	loginState, err := models.ConsumeOidcLoginState(state, now)
+	if err != nil {
+		return nil, err
+	}
+
+	idToken, err := client.Exchange(code, loginState.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := client.VerifyIDToken(idToken, loginState.Nonce, now)
+	if err != nil {
+		return nil, err
+	}
+	return ProvisionOIDCUser(claims)
+}
@@ -0,0 +1,390 @@
+package helpers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/server/web"
+)
+
+// EventEnvelope is the representation of a domain event handed to the sinks
+type EventEnvelope struct {
+	Id        int             `json:"id"` // Identifies the event, identical across redeliveries
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// NewEventEnvelope wraps a recorded outbox event
+func NewEventEnvelope(event *models.OutboxEvent) *EventEnvelope {
+	return &EventEnvelope{Id: event.Id, Event: event.Event, CreatedAt: event.CreatedAt, Data: json.RawMessage(event.Payload)}
+}
+
+// NewDomainEvent records an event whose JSON data is built by data once the change is saved
+func NewDomainEvent(event string, data func() interface{}) models.DomainEvent {
+	return func() (*models.OutboxEvent, error) {
+		payload, err := json.Marshal(data())
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode event %s: %w", event, err)
+		}
+		return &models.OutboxEvent{Event: event, Payload: string(payload)}, nil
+	}
+}
+
+// EventSink publishes the events of the outbox. Events are delivered at least once,
+// so sinks and their consumers must tolerate receiving the same event id again.
+type EventSink interface {
+	Name() string // Stable name, the delivery to every sink is tracked by name
+	Publish(envelope *EventEnvelope) error
+}
+
+// EventSinkGroup is a sink made of parts whose deliveries are tracked one by one, so the parts that failed are
+// retried without handing the event to the others again
+type EventSinkGroup interface {
+	EventSink
+	SinksFor(event string) []EventSink
+}
+
+// EventHandler processes an event in-process
+type EventHandler func(envelope *EventEnvelope) error
+
+// namedEventHandler is a registered handler, tracked as the sink "handlers:<name>"
+type namedEventHandler struct {
+	name    string
+	handler EventHandler
+}
+
+// Name implements EventSink
+func (h *namedEventHandler) Name() string {
+	return constants.OutboxSinkHandlers + ":" + h.name
+}
+
+// Publish implements EventSink
+func (h *namedEventHandler) Publish(envelope *EventEnvelope) error {
+	return h.handler(envelope)
+}
+
+// HandlerSink dispatches the events to in-process handlers.
+// Every handler is tracked on its own, a failing handler doesn't make the others run again on the next attempt.
+type HandlerSink struct {
+	mu       sync.RWMutex
+	handlers map[string][]*namedEventHandler
+}
+
+// NewHandlerSink creates a sink without handlers
+func NewHandlerSink() *HandlerSink {
+	return &HandlerSink{handlers: make(map[string][]*namedEventHandler)}
+}
+
+// Handle registers a handler for an event, constants.EventAll registers it for every event.
+// The name identifies the handler in the delivery tracking of the events, so it must be unique and stable.
+func (s *HandlerSink) Handle(event, name string, handler EventHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[event] = append(s.handlers[event], &namedEventHandler{name: name, handler: handler})
+}
+
+// Name implements EventSink
+func (s *HandlerSink) Name() string {
+	return constants.OutboxSinkHandlers
+}
+
+// SinksFor implements EventSinkGroup
+func (s *HandlerSink) SinksFor(event string) []EventSink {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sinks := make([]EventSink, 0, len(s.handlers[event])+len(s.handlers[constants.EventAll]))
+	for _, handler := range s.handlers[event] {
+		sinks = append(sinks, handler)
+	}
+	for _, handler := range s.handlers[constants.EventAll] {
+		sinks = append(sinks, handler)
+	}
+	return sinks
+}
+
+// Publish implements EventSink, every handler of the event runs
+func (s *HandlerSink) Publish(envelope *EventEnvelope) error {
+	var errs []error
+	for _, handler := range s.SinksFor(envelope.Event) {
+		if err := handler.Publish(envelope); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// HTTPSink posts every event as JSON to a URL, any status other than 2xx is an error
+type HTTPSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPSink creates a sink posting to the given URL
+func NewHTTPSink(url string) *HTTPSink {
+	return &HTTPSink{url: url, client: &http.Client{Timeout: constants.OutboxRequestTimeout}}
+}
+
+// Name implements EventSink
+func (s *HTTPSink) Name() string {
+	return constants.OutboxSinkHTTP
+}
+
+// Publish implements EventSink
+func (s *HTTPSink) Publish(envelope *EventEnvelope) error {
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(constants.OutboxEventHeader, envelope.Event)
+	req.Header.Set(constants.OutboxEventIdHeader, strconv.Itoa(envelope.Id))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, excerpt)
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return nil
+}
+
+// NATSSink publishes every event to a NATS compatible server under "<subject>.<event>".
+// It speaks the plain text client protocol and waits for the PONG following every PUB,
+// so an event only counts as delivered once the server processed it.
+type NATSSink struct {
+	addr    string
+	subject string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewNATSSink creates a sink for a server address such as nats://localhost:4222
+func NewNATSSink(serverUrl, subject string) (*NATSSink, error) {
+	parsed, err := url.Parse(serverUrl)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("invalid NATS server url %q", serverUrl)
+	}
+	return &NATSSink{addr: parsed.Host, subject: subject}, nil
+}
+
+// Name implements EventSink
+func (s *NATSSink) Name() string {
+	return constants.OutboxSinkNATS
+}
+
+// Publish implements EventSink
+func (s *NATSSink) Publish(envelope *EventEnvelope) error {
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.publish(s.subject+"."+envelope.Event, body); err != nil {
+		// Reconnect on the next attempt
+		if s.conn != nil {
+			s.conn.Close()
+			s.conn = nil
+		}
+		return err
+	}
+	return nil
+}
+
+// publish sends a message and waits for the server to acknowledge the following PING, the caller holds the lock
+func (s *NATSSink) publish(subject string, body []byte) error {
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return err
+		}
+	}
+
+	s.conn.SetDeadline(time.Now().Add(constants.OutboxRequestTimeout))
+	if _, err := fmt.Fprintf(s.conn, "PUB %s %d\r\n%s\r\nPING\r\n", subject, len(body), body); err != nil {
+		return err
+	}
+
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := io.WriteString(s.conn, "PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+// connect opens the connection and completes the handshake, the caller holds the lock
+func (s *NATSSink) connect() error {
+	conn, err := net.DialTimeout("tcp", s.addr, constants.OutboxNATSDialTimeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(constants.OutboxRequestTimeout))
+
+	// The server greets with its INFO line before accepting the CONNECT
+	reader := bufio.NewReader(conn)
+	info, err := reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if !strings.HasPrefix(info, "INFO") {
+		conn.Close()
+		return fmt.Errorf("nats: unexpected greeting %q", strings.TrimSpace(info))
+	}
+	if _, err := io.WriteString(conn, `CONNECT {"verbose":false,"pedantic":false,"name":"beego-presence-api"}`+"\r\n"); err != nil {
+		conn.Close()
+		return err
+	}
+
+	s.conn = conn
+	s.reader = reader
+	return nil
+}
+
+var eventHandlers = NewHandlerSink()
+
+// GetEventHandlers returns the sink of the in-process handlers, e.g. to register a handler at startup
+func GetEventHandlers() *HandlerSink {
+	return eventHandlers
+}
+
+// ExponentialBackoff returns the delay before the next attempt once the given number of attempts failed
+func ExponentialBackoff(attempts int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	return delay
+}
+
+// StartOutboxDispatcher publishes the recorded events in the background to the in-process handlers
+// and to the HTTP and NATS sinks configured with outbox_http_url and outbox_nats_url.
+func StartOutboxDispatcher() {
+	sinks := []EventSink{eventHandlers}
+	if httpUrl := web.AppConfig.DefaultString("outbox_http_url", ""); httpUrl != "" {
+		sinks = append(sinks, NewHTTPSink(httpUrl))
+	}
+	if natsUrl := web.AppConfig.DefaultString("outbox_nats_url", ""); natsUrl != "" {
+		sink, err := NewNATSSink(natsUrl, web.AppConfig.DefaultString("outbox_nats_subject", constants.OutboxDefaultSubject))
+		if err != nil {
+			log.Fatalf("Invalid outbox NATS configuration: %v", err)
+		}
+		sinks = append(sinks, sink)
+	}
+
+	go func() {
+		ticker := time.NewTicker(constants.OutboxPollInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			DispatchDueOutboxEvents(sinks)
+		}
+	}()
+}
+
+// DispatchDueOutboxEvents publishes the due events to the sinks in the order they were recorded
+func DispatchDueOutboxEvents(sinks []EventSink) {
+	events, err := models.ClaimDueOutboxEvents(time.Now(), constants.OutboxLease, constants.OutboxBatchSize)
+	if err != nil {
+		log.Printf("Failed to claim outbox events: %v", err)
+		return
+	}
+
+	for _, event := range events {
+		PublishOutboxEvent(sinks, event)
+		if err := models.UpdateOutboxEvent(event); err != nil {
+			log.Printf("Failed to record outbox event %d: %v", event.Id, err)
+		}
+	}
+}
+
+// PublishOutboxEvent hands an event to the sinks that didn't receive it yet and schedules a retry if any failed.
+// The parts of a sink group, e.g. the in-process handlers, are tracked as sinks of their own. The event is given up
+// once it failed constants.OutboxMaxAttempts times.
+func PublishOutboxEvent(sinks []EventSink, event *models.OutboxEvent) {
+	envelope := NewEventEnvelope(event)
+
+	targets := make([]EventSink, 0, len(sinks))
+	for _, sink := range sinks {
+		if group, ok := sink.(EventSinkGroup); ok {
+			targets = append(targets, group.SinksFor(event.Event)...)
+			continue
+		}
+		targets = append(targets, sink)
+	}
+
+	var failures []string
+	for _, sink := range targets {
+		if event.IsDeliveredTo(sink.Name()) {
+			continue
+		}
+		if err := sink.Publish(envelope); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", sink.Name(), err))
+			continue
+		}
+		event.MarkDeliveredTo(sink.Name())
+	}
+
+	now := time.Now()
+	if len(failures) == 0 {
+		event.Status = constants.OutboxStatusPublished
+		event.LastError = ""
+		event.PublishedAt = &now
+		return
+	}
+	event.LastError = strings.Join(failures, "; ")
+	if event.Attempts >= constants.OutboxMaxAttempts {
+		event.Status = constants.OutboxStatusDead
+		log.Printf("Gave up on outbox event %d after %d attempts: %s", event.Id, event.Attempts, event.LastError)
+		return
+	}
+	event.NextAttemptAt = now.Add(ExponentialBackoff(event.Attempts, constants.OutboxBaseBackoff, constants.OutboxMaxBackoff))
+	log.Printf("Failed to publish outbox event %d (attempt %d): %s", event.Id, event.Attempts, event.LastError)
+}
@@ -0,0 +1,84 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/server/web"
+)
+
+var passwordResetLimiter = NewRateLimiter(constants.PasswordResetRateLimit, constants.PasswordResetRateWindow)
+
+// HashToken returns the hex encoded SHA-256 of a token, tokens are only stored hashed
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// AllowPasswordResetRequest reports whether another reset may be requested for the email.
+// Registered and unknown emails are limited alike, so the limit doesn't reveal which accounts exist.
+func AllowPasswordResetRequest(email string, now time.Time) bool {
+	return passwordResetLimiter.Allow(strings.ToLower(strings.TrimSpace(email)), now)
+}
+
+// BuildPasswordResetUrl appends the token to the reset page configured with password_reset_url
+func BuildPasswordResetUrl(token string) (string, error) {
+	resetUrl, err := url.Parse(web.AppConfig.DefaultString("password_reset_url", "http://localhost:8080/reset-password"))
+	if err != nil {
+		return "", err
+	}
+	query := resetUrl.Query()
+	query.Set("token", token)
+	resetUrl.RawQuery = query.Encode()
+	return resetUrl.String(), nil
+}
+
+// SendPasswordResetToken emails a new reset token if the email belongs to a user, and does nothing otherwise.
+// It is meant to run in the background, so the response time doesn't reveal whether the account exists.
+func SendPasswordResetToken(email string) {
+	user, err := models.GetUserByEmail(email)
+	if err != nil {
+		if err != orm.ErrNoRows {
+			log.Printf("Failed to fetch user for password reset: %v", err)
+		}
+		return
+	}
+
+	token, err := GenerateRandomHex(constants.PasswordResetTokenBytes)
+	if err != nil {
+		log.Printf("Failed to generate password reset token: %v", err)
+		return
+	}
+	resetToken := &models.PasswordResetToken{
+		User:      &user,
+		TokenHash: HashToken(token),
+		ExpiresAt: time.Now().Add(constants.PasswordResetTokenTTL),
+	}
+	if err := models.CreatePasswordResetToken(resetToken); err != nil {
+		log.Printf("Failed to store password reset token of user %d: %v", user.Id, err)
+		return
+	}
+
+	resetUrl, err := BuildPasswordResetUrl(token)
+	if err != nil {
+		log.Printf("Invalid password_reset_url: %v", err)
+		return
+	}
+	data := map[string]interface{}{
+		"ResetUrl":       resetUrl,
+		"ExpiresMinutes": int(constants.PasswordResetTokenTTL.Minutes()),
+	}
+	reference := fmt.Sprintf("%s:%d", constants.NotificationTypePasswordReset, resetToken.Id)
+	if err := SendNotification(&user, constants.NotificationTypePasswordReset, reference, data); err != nil {
+		log.Printf("Failed to send password reset to user %d: %v", user.Id, err)
+	}
+}
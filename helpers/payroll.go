@@ -0,0 +1,386 @@
+package helpers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/models"
+)
+
+// payrollNumericSources lists the sources holding numbers, every other source holds text
+var payrollNumericSources = map[string]bool{
+	constants.PayrollSourceEmployeeId:      true,
+	constants.PayrollSourceDepartmentId:    true,
+	constants.PayrollSourceWorkingDays:     true,
+	constants.PayrollSourceDaysPresent:     true,
+	constants.PayrollSourceAbsentDays:      true,
+	constants.PayrollSourceLeaveDays:       true,
+	constants.PayrollSourceLateCount:       true,
+	constants.PayrollSourceLateMinutes:     true,
+	constants.PayrollSourceWorkedMinutes:   true,
+	constants.PayrollSourceOvertimeMinutes: true,
+	constants.PayrollSourceLateDeduction:   true,
+	constants.PayrollSourceBucketCount:     true,
+	constants.PayrollSourceBucketDeduction: true,
+}
+
+// payrollDurationSources lists the sources expressed in minutes that can be converted to hours
+var payrollDurationSources = map[string]bool{
+	constants.PayrollSourceLateMinutes:     true,
+	constants.PayrollSourceWorkedMinutes:   true,
+	constants.PayrollSourceOvertimeMinutes: true,
+}
+
+// PayrollRecord holds the payroll values of a user for a period
+type PayrollRecord struct {
+	User             *models.User
+	Period           string
+	Totals           dto.AttendanceTotalsResponse
+	LateMinutes      int // Rounded daily late minutes, summed
+	WorkedMinutes    int // Rounded daily worked minutes, summed
+	OvertimeMinutes  int // Rounded daily overtime minutes, summed
+	LateDeduction    float64
+	BucketCounts     map[string]int
+	BucketDeductions map[string]float64
+}
+
+// ValidatePayrollTemplate checks the consistency of a template that the request validation cannot express
+func ValidatePayrollTemplate(template *models.PayrollTemplate) error {
+	fields, err := template.GetFields()
+	if err != nil {
+		return fmt.Errorf("invalid fields: %w", err)
+	}
+	buckets, err := template.GetLateBuckets()
+	if err != nil {
+		return fmt.Errorf("invalid late buckets: %w", err)
+	}
+
+	if template.Format != constants.PayrollFormatCSV && template.Format != constants.PayrollFormatFixedWidth {
+		return fmt.Errorf("unsupported format: %s", template.Format)
+	}
+	if len(fields) == 0 {
+		return errors.New("at least one field is required")
+	}
+	if template.Format == constants.PayrollFormatCSV && strings.ContainsAny(template.Delimiter, "\"\r\n") {
+		return errors.New("delimiter must not be a quote or a line break")
+	}
+
+	// Bucket ranges must not overlap, otherwise a late day would be deducted twice
+	bucketCodes := map[string]bool{}
+	sorted := append([]models.PayrollLateBucket(nil), buckets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].MinMinutes < sorted[j].MinMinutes })
+	for i, bucket := range sorted {
+		if bucketCodes[bucket.Code] {
+			return fmt.Errorf("duplicate late bucket code: %s", bucket.Code)
+		}
+		bucketCodes[bucket.Code] = true
+
+		if bucket.MaxMinutes != 0 && bucket.MaxMinutes < bucket.MinMinutes {
+			return fmt.Errorf("late bucket %s: max_minutes must not be lower than min_minutes", bucket.Code)
+		}
+		if i > 0 {
+			previous := sorted[i-1]
+			if previous.MaxMinutes == 0 || previous.MaxMinutes >= bucket.MinMinutes {
+				return fmt.Errorf("late buckets %s and %s overlap", previous.Code, bucket.Code)
+			}
+		}
+	}
+
+	for _, field := range fields {
+		switch field.Source {
+		case constants.PayrollSourceBucketCount, constants.PayrollSourceBucketDeduction:
+			if !bucketCodes[field.Bucket] {
+				return fmt.Errorf("field %s: unknown late bucket %q", field.Code, field.Bucket)
+			}
+		case constants.PayrollSourceLiteral, constants.PayrollSourceEmployeeName, constants.PayrollSourceEmployeeEmail, constants.PayrollSourcePeriod:
+		default:
+			if !payrollNumericSources[field.Source] {
+				return fmt.Errorf("field %s: unknown source %q", field.Code, field.Source)
+			}
+		}
+
+		if field.Unit == constants.PayrollUnitHours && !payrollDurationSources[field.Source] {
+			return fmt.Errorf("field %s: the hours unit only applies to duration sources", field.Code)
+		}
+		if template.Format == constants.PayrollFormatFixedWidth && field.Width <= 0 {
+			return fmt.Errorf("field %s: width is required for fixed-width templates", field.Code)
+		}
+		if utf8.RuneCountInString(field.Pad) > 1 {
+			return fmt.Errorf("field %s: pad must be a single character", field.Code)
+		}
+	}
+
+	return nil
+}
+
+// BuildPayrollRecord derives the payroll values of a user from the monthly attendance report,
+// rounding the daily durations and applying the lateness bucket deductions of the template
+func BuildPayrollRecord(user *models.User, report *dto.MonthlyAttendanceResponse, template *models.PayrollTemplate) (*PayrollRecord, error) {
+	buckets, err := template.GetLateBuckets()
+	if err != nil {
+		return nil, err
+	}
+
+	record := &PayrollRecord{
+		User:             user,
+		Period:           report.Month,
+		Totals:           report.Totals,
+		BucketCounts:     map[string]int{},
+		BucketDeductions: map[string]float64{},
+	}
+
+	for _, day := range report.Days {
+		// Lateness is only deducted on working days, work on other days is paid as overtime
+		late := 0
+		if day.Marker == "" {
+			late = roundPayrollMinutes(day.LateMinutes, template.RoundingMode, template.RoundingMinutes)
+		}
+		worked := roundPayrollMinutes(day.WorkedMinutes, template.RoundingMode, template.RoundingMinutes)
+		overtime := roundPayrollMinutes(payrollOvertimeMinutes(day), template.RoundingMode, template.RoundingMinutes)
+
+		record.LateMinutes += late
+		record.WorkedMinutes += worked
+		record.OvertimeMinutes += overtime
+
+		if late <= 0 {
+			continue
+		}
+		for _, bucket := range buckets {
+			if late < bucket.MinMinutes || (bucket.MaxMinutes != 0 && late > bucket.MaxMinutes) {
+				continue
+			}
+			deduction := bucket.Amount
+			if bucket.PerMinute {
+				deduction = bucket.Amount * float64(late)
+			}
+			record.BucketCounts[bucket.Code]++
+			record.BucketDeductions[bucket.Code] += deduction
+			record.LateDeduction += deduction
+			break
+		}
+	}
+
+	return record, nil
+}
+
+// WritePayrollFile writes the payroll records as a CSV or fixed-width file laid out by the template.
+// In CSV files the names and emails, which users choose themselves, are neutralized like in the exports so that
+// spreadsheet applications don't evaluate them as formulas. The numbers are left as they are for the payroll systems.
+func WritePayrollFile(w io.Writer, template *models.PayrollTemplate, records []*PayrollRecord) error {
+	fields, err := template.GetFields()
+	if err != nil {
+		return err
+	}
+
+	var writeRow func(values []string, isHeader bool) error
+	var flush func() error
+
+	if template.Format == constants.PayrollFormatFixedWidth {
+		writeRow = func(values []string, isHeader bool) error {
+			var line strings.Builder
+			for i, field := range fields {
+				cell, err := padPayrollValue(field, values[i], isHeader)
+				if err != nil {
+					return err
+				}
+				line.WriteString(cell)
+			}
+			line.WriteString("\n")
+			_, err := io.WriteString(w, line.String())
+			return err
+		}
+		flush = func() error { return nil }
+	} else {
+		writer := csv.NewWriter(w)
+		if template.Delimiter != "" {
+			writer.Comma, _ = utf8.DecodeRuneInString(template.Delimiter)
+		}
+		writeRow = func(values []string, isHeader bool) error {
+			if !isHeader {
+				for i, field := range fields {
+					if field.Source == constants.PayrollSourceEmployeeName || field.Source == constants.PayrollSourceEmployeeEmail {
+						values[i] = neutralizeFormula(values[i])
+					}
+				}
+			}
+			return writer.Write(values)
+		}
+		flush = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	}
+
+	if template.IncludeHeader {
+		header := make([]string, len(fields))
+		for i, field := range fields {
+			header[i] = field.Code
+		}
+		if err := writeRow(header, true); err != nil {
+			return err
+		}
+	}
+
+	for _, record := range records {
+		values := make([]string, len(fields))
+		for i, field := range fields {
+			values[i] = payrollFieldValue(field, record)
+		}
+		if err := writeRow(values, false); err != nil {
+			return fmt.Errorf("employee %d: %w", record.User.Id, err)
+		}
+	}
+
+	return flush()
+}
+
+// PayrollFilename returns the name of the payroll file of a department and period
+func PayrollFilename(template *models.PayrollTemplate, departmentId int, period string) string {
+	extension := "csv"
+	if template.Format == constants.PayrollFormatFixedWidth {
+		extension = "txt"
+	}
+	return fmt.Sprintf("payroll-%d-%s.%s", departmentId, period, extension)
+}
+
+// payrollOvertimeMinutes returns the minutes worked beyond the scheduled shift.
+// Work on weekends, holidays and leave days counts as overtime entirely.
+func payrollOvertimeMinutes(day *dto.AttendanceDayResponse) int {
+	if day.WorkedMinutes <= 0 {
+		return 0
+	}
+	if day.Marker != "" {
+		return day.WorkedMinutes
+	}
+	if day.ScheduledIn == nil || day.ScheduledOut == nil {
+		return 0
+	}
+
+	scheduledIn, inErr := time.Parse(time.RFC3339, *day.ScheduledIn)
+	scheduledOut, outErr := time.Parse(time.RFC3339, *day.ScheduledOut)
+	if inErr != nil || outErr != nil {
+		return 0
+	}
+
+	overtime := day.WorkedMinutes - int(scheduledOut.Sub(scheduledIn).Minutes())
+	if overtime < 0 {
+		return 0
+	}
+	return overtime
+}
+
+// roundPayrollMinutes rounds minutes to a multiple of unit
+func roundPayrollMinutes(minutes int, mode string, unit int) int {
+	if unit <= 1 || minutes == 0 {
+		return minutes
+	}
+
+	switch mode {
+	case constants.PayrollRoundingUp:
+		return (minutes + unit - 1) / unit * unit
+	case constants.PayrollRoundingDown:
+		return minutes / unit * unit
+	case constants.PayrollRoundingNearest:
+		return (minutes + unit/2) / unit * unit
+	default:
+		return minutes
+	}
+}
+
+// payrollFieldValue formats the value of a field for a record
+func payrollFieldValue(field models.PayrollField, record *PayrollRecord) string {
+	var number float64
+
+	switch field.Source {
+	case constants.PayrollSourceLiteral:
+		return field.Value
+	case constants.PayrollSourceEmployeeName:
+		return record.User.Name
+	case constants.PayrollSourceEmployeeEmail:
+		return record.User.Email
+	case constants.PayrollSourcePeriod:
+		return record.Period
+	case constants.PayrollSourceEmployeeId:
+		number = float64(record.User.Id)
+	case constants.PayrollSourceDepartmentId:
+		if record.User.Department != nil {
+			number = float64(record.User.Department.Id)
+		}
+	case constants.PayrollSourceWorkingDays:
+		number = float64(record.Totals.WorkingDays)
+	case constants.PayrollSourceDaysPresent:
+		number = float64(record.Totals.DaysPresent)
+	case constants.PayrollSourceAbsentDays:
+		number = float64(record.Totals.AbsentCount)
+	case constants.PayrollSourceLeaveDays:
+		number = float64(record.Totals.LeaveCount)
+	case constants.PayrollSourceLateCount:
+		number = float64(record.Totals.LateCount)
+	case constants.PayrollSourceLateMinutes:
+		number = float64(record.LateMinutes)
+	case constants.PayrollSourceWorkedMinutes:
+		number = float64(record.WorkedMinutes)
+	case constants.PayrollSourceOvertimeMinutes:
+		number = float64(record.OvertimeMinutes)
+	case constants.PayrollSourceLateDeduction:
+		number = record.LateDeduction
+	case constants.PayrollSourceBucketCount:
+		number = float64(record.BucketCounts[field.Bucket])
+	case constants.PayrollSourceBucketDeduction:
+		number = record.BucketDeductions[field.Bucket]
+	}
+
+	if field.Unit == constants.PayrollUnitHours {
+		number /= 60
+	}
+
+	// Round half away from zero at the requested precision before formatting
+	scale := math.Pow(10, float64(field.Decimals))
+	return strconv.FormatFloat(math.Round(number*scale)/scale, 'f', field.Decimals, 64)
+}
+
+// padPayrollValue pads a value to the width of a fixed-width field.
+// Text and header codes are truncated to the width, numbers that do not fit are rejected since truncating them would change the amount.
+func padPayrollValue(field models.PayrollField, value string, isHeader bool) (string, error) {
+	numeric := payrollNumericSources[field.Source] && !isHeader
+
+	if length := utf8.RuneCountInString(value); length > field.Width {
+		if numeric {
+			return "", fmt.Errorf("value %s of field %s exceeds width %d", value, field.Code, field.Width)
+		}
+		value = string([]rune(value)[:field.Width])
+	}
+
+	pad := field.Pad
+	if pad == "" {
+		pad = " "
+	}
+	padding := strings.Repeat(pad, field.Width-utf8.RuneCountInString(value))
+
+	align := field.Align
+	if align == "" {
+		align = constants.PayrollAlignLeft
+		if numeric {
+			align = constants.PayrollAlignRight
+		}
+	}
+
+	if align == constants.PayrollAlignRight {
+		// Keep the sign in front of zero padding
+		if numeric && pad == "0" && strings.HasPrefix(value, "-") {
+			return "-" + padding + value[1:], nil
+		}
+		return padding + value, nil
+	}
+	return value + padding, nil
+}
@@ -23,32 +23,12 @@ func DeterminePresenceStatus(presenceType, scheduleInTime, scheduleOutTime strin
 		return "", fmt.Errorf("invalid presence type: %s", presenceType)
 	}
 
-	// Parse schedule time
-	timeParts := strings.Split(scheduleTime, ":")
-	if len(timeParts) != 3 {
-		return "", fmt.Errorf("invalid schedule time format: %s", scheduleTime)
-	}
-
-	hour, err := strconv.Atoi(timeParts[0])
-	if err != nil {
-		return "", fmt.Errorf("invalid hour in schedule time: %s", err)
-	}
-	minute, err := strconv.Atoi(timeParts[1])
-	if err != nil {
-		return "", fmt.Errorf("invalid minute in schedule time: %s", err)
-	}
-	second, err := strconv.Atoi(timeParts[2])
+	// Parse schedule time in the same timezone as currentTime
+	scheduleTimeParsed, err := ParseScheduleTime(currentTime, scheduleTime)
 	if err != nil {
-		return "", fmt.Errorf("invalid second in schedule time: %s", err)
+		return "", err
 	}
 
-	// Ensure the schedule time is in the same timezone as currentTime
-	location := currentTime.Location()
-	scheduleTimeParsed := time.Date(
-		currentTime.Year(), currentTime.Month(), currentTime.Day(),
-		hour, minute, second, 0, location,
-	)
-
 	// Add late threshold only for "in" type
 	var thresholdTime time.Time
 	if presenceType == constants.PresenceTypeIn {
@@ -66,3 +46,26 @@ func DeterminePresenceStatus(presenceType, scheduleInTime, scheduleOutTime strin
 
 	return constants.PresenceStatusOnTime, nil
 }
+
+// ParseScheduleTime combines the date of day with a schedule clock time (HH:MM:SS) in the location of day.
+func ParseScheduleTime(day time.Time, scheduleTime string) (time.Time, error) {
+	timeParts := strings.Split(scheduleTime, ":")
+	if len(timeParts) != 3 {
+		return time.Time{}, fmt.Errorf("invalid schedule time format: %s", scheduleTime)
+	}
+
+	hour, err := strconv.Atoi(timeParts[0])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid hour in schedule time: %s", err)
+	}
+	minute, err := strconv.Atoi(timeParts[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid minute in schedule time: %s", err)
+	}
+	second, err := strconv.Atoi(timeParts[2])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid second in schedule time: %s", err)
+	}
+
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, second, 0, day.Location()), nil
+}
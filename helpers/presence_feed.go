@@ -0,0 +1,151 @@
+package helpers
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/models"
+)
+
+// PresenceFeedEvent is a change of a presence pushed to the live feed
+type PresenceFeedEvent struct {
+	Id           int64
+	Event        string
+	UserId       int
+	DepartmentId int
+	Data         []byte // JSON encoded presence
+}
+
+// PresenceFeedFilter restricts the events a subscriber receives, zero values match everything
+type PresenceFeedFilter struct {
+	UserId       int
+	DepartmentId int
+}
+
+// Matches reports whether the event passes the filter
+func (f PresenceFeedFilter) Matches(event *PresenceFeedEvent) bool {
+	if f.UserId != 0 && event.UserId != f.UserId {
+		return false
+	}
+	if f.DepartmentId != 0 && event.DepartmentId != f.DepartmentId {
+		return false
+	}
+	return true
+}
+
+// PresenceFeedSubscription receives the events of the feed until it is closed.
+// The channel is closed when the subscription ends, including when the client falls too far behind.
+type PresenceFeedSubscription struct {
+	Events <-chan *PresenceFeedEvent
+	events chan *PresenceFeedEvent
+	filter PresenceFeedFilter
+	feed   *PresenceFeed
+}
+
+// Close ends the subscription
+func (s *PresenceFeedSubscription) Close() {
+	s.feed.unsubscribe(s)
+}
+
+// PresenceFeed fans out presence changes to the connected clients and keeps the most recent ones to resume streams
+type PresenceFeed struct {
+	mu          sync.Mutex
+	lastId      int64
+	history     []*PresenceFeedEvent
+	subscribers map[*PresenceFeedSubscription]struct{}
+}
+
+// NewPresenceFeed creates an empty feed. Event ids start at the current time in milliseconds,
+// so ids handed out before a restart stay lower than the new ones.
+func NewPresenceFeed() *PresenceFeed {
+	return &PresenceFeed{
+		lastId:      time.Now().UnixMilli(),
+		subscribers: make(map[*PresenceFeedSubscription]struct{}),
+	}
+}
+
+var presenceFeed = NewPresenceFeed()
+
+// GetPresenceFeed returns the feed of the application
+func GetPresenceFeed() *PresenceFeed {
+	return presenceFeed
+}
+
+// PublishPresence pushes a created or updated presence to the clients of the application feed
+func PublishPresence(event string, presence *models.Presence) {
+	data, err := json.Marshal(dto.FromPresenceModelToPresenceResponse(presence, true, false))
+	if err != nil {
+		log.Printf("Failed to encode presence %d for the live feed: %v", presence.Id, err)
+		return
+	}
+
+	feedEvent := &PresenceFeedEvent{Event: event, UserId: presence.User.Id, Data: data}
+	if presence.User.Department != nil {
+		feedEvent.DepartmentId = presence.User.Department.Id
+	}
+	presenceFeed.Publish(feedEvent)
+}
+
+// Publish assigns the next id to the event and delivers it to the matching subscribers
+func (f *PresenceFeed) Publish(event *PresenceFeedEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lastId++
+	event.Id = f.lastId
+
+	f.history = append(f.history, event)
+	if len(f.history) > constants.PresenceFeedHistorySize {
+		f.history = f.history[len(f.history)-constants.PresenceFeedHistorySize:]
+	}
+
+	for subscription := range f.subscribers {
+		if !subscription.filter.Matches(event) {
+			continue
+		}
+		select {
+		case subscription.events <- event:
+		default:
+			// The client doesn't keep up, it resumes from its last event after reconnecting
+			f.remove(subscription)
+		}
+	}
+}
+
+// Subscribe registers a subscriber and returns the missed events following lastEventId, if any
+func (f *PresenceFeed) Subscribe(filter PresenceFeedFilter, lastEventId int64) (*PresenceFeedSubscription, []*PresenceFeedEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var missed []*PresenceFeedEvent
+	if lastEventId > 0 {
+		for _, event := range f.history {
+			if event.Id > lastEventId && filter.Matches(event) {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	events := make(chan *PresenceFeedEvent, constants.PresenceFeedSubscriberBuffer)
+	subscription := &PresenceFeedSubscription{Events: events, events: events, filter: filter, feed: f}
+	f.subscribers[subscription] = struct{}{}
+	return subscription, missed
+}
+
+func (f *PresenceFeed) unsubscribe(subscription *PresenceFeedSubscription) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.remove(subscription)
+}
+
+// remove drops a subscriber and closes its channel, the caller holds the lock
+func (f *PresenceFeed) remove(subscription *PresenceFeedSubscription) {
+	if _, ok := f.subscribers[subscription]; ok {
+		delete(f.subscribers, subscription)
+		close(subscription.events)
+	}
+}
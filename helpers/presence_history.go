@@ -0,0 +1,139 @@
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/models"
+)
+
+// presenceRevisionSecret returns the key the presence revisions are hashed with
+func presenceRevisionSecret() []byte {
+	return configuredSecret("presence_revision_secret")
+}
+
+// SignPresenceRevision computes the hash of the content of a presence revision. The hash is keyed, so the chain
+// can't be recomputed with access to the database alone.
+func SignPresenceRevision(content string) string {
+	mac := hmac.New(sha256.New, presenceRevisionSecret())
+	mac.Write([]byte(content))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyPresenceHistory checks the hash chain of the revisions of a presence, oldest first, and compares the
+// presence with its last revision. The presence is nil once it's deleted.
+func VerifyPresenceHistory(presenceId int, presence *models.Presence, revisions []*models.PresenceRevision) []*dto.PresenceProblemResponse {
+	problems := []*dto.PresenceProblemResponse{}
+	report := func(revision int, problem string) {
+		problems = append(problems, &dto.PresenceProblemResponse{PresenceId: presenceId, Revision: revision, Problem: problem})
+	}
+
+	if len(revisions) == 0 {
+		if presence != nil {
+			report(0, constants.PresenceProblemUntracked)
+		}
+		return problems
+	}
+
+	previousHash := ""
+	for i, revision := range revisions {
+		if revision.Revision != i+1 || revision.PreviousHash != previousHash {
+			report(revision.Revision, constants.PresenceProblemRevisionChain)
+		}
+		if !hmac.Equal([]byte(SignPresenceRevision(revision.Content())), []byte(revision.Hash)) {
+			report(revision.Revision, constants.PresenceProblemRevisionHash)
+		}
+		if revision.Action == constants.PresenceRevisionOutOfBand {
+			report(revision.Revision, constants.PresenceProblemOutOfBand)
+		}
+		previousHash = revision.Hash
+	}
+
+	last := revisions[len(revisions)-1]
+	if presence == nil {
+		if last.Action != constants.PresenceRevisionDeleted {
+			report(last.Revision, constants.PresenceProblemDeleted)
+		}
+	} else if last.Action == constants.PresenceRevisionDeleted || !last.Matches(presence) {
+		report(last.Revision, constants.PresenceProblemChanged)
+	}
+	return problems
+}
+
+// VerifyPresences verifies the history of every presence, including the deleted ones, to find changes made to the
+// presences or their revisions outside the API
+func VerifyPresences() (*dto.PresenceVerificationResponse, error) {
+	result := &dto.PresenceVerificationResponse{Problems: []*dto.PresenceProblemResponse{}}
+
+	revisionedIds, err := models.GetRevisionedPresenceIds()
+	if err != nil {
+		return nil, err
+	}
+	deletedIds := make(map[int]bool, len(revisionedIds))
+	for _, id := range revisionedIds {
+		deletedIds[id] = true
+	}
+
+	err = models.ForEachPresenceBatch(0, constants.PresenceVerifyBatchSize, func(batch []*models.Presence) error {
+		ids := make([]int, 0, len(batch))
+		for _, presence := range batch {
+			ids = append(ids, presence.Id)
+			delete(deletedIds, presence.Id)
+		}
+		revisions, err := models.GetPresenceRevisionsByPresenceIds(ids)
+		if err != nil {
+			return err
+		}
+
+		history := groupPresenceRevisions(revisions)
+		for _, presence := range batch {
+			result.Problems = append(result.Problems, VerifyPresenceHistory(presence.Id, presence, history[presence.Id])...)
+		}
+		result.Presences += len(batch)
+		result.Revisions += len(revisions)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The remaining histories belong to presences that no longer exist
+	ids := make([]int, 0, len(deletedIds))
+	for id := range deletedIds {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for start := 0; start < len(ids); start += constants.PresenceVerifyBatchSize {
+		end := start + constants.PresenceVerifyBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		revisions, err := models.GetPresenceRevisionsByPresenceIds(ids[start:end])
+		if err != nil {
+			return nil, err
+		}
+
+		history := groupPresenceRevisions(revisions)
+		for _, id := range ids[start:end] {
+			result.Problems = append(result.Problems, VerifyPresenceHistory(id, nil, history[id])...)
+		}
+		result.Presences += end - start
+		result.Revisions += len(revisions)
+	}
+
+	result.Intact = len(result.Problems) == 0
+	return result, nil
+}
+
+// groupPresenceRevisions groups revisions by presence, keeping their order
+func groupPresenceRevisions(revisions []*models.PresenceRevision) map[int][]*models.PresenceRevision {
+	history := make(map[int][]*models.PresenceRevision)
+	for _, revision := range revisions {
+		history[revision.PresenceId] = append(history[revision.PresenceId], revision)
+	}
+	return history
+}
@@ -0,0 +1,52 @@
+package helpers
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter allows a number of events per key within a sliding window.
+// The counts are kept in memory, so every instance of the API limits on its own.
+type RateLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	hits      map[string][]time.Time
+	lastSweep time.Time
+}
+
+// NewRateLimiter creates a limiter allowing limit events per key within window
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{limit: limit, window: window, hits: make(map[string][]time.Time)}
+}
+
+// Allow records an event for the key and reports whether it is within the limit
+func (r *RateLimiter) Allow(key string, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cutoff := now.Add(-r.window)
+
+	// Forget the keys without recent events once per window
+	if now.Sub(r.lastSweep) >= r.window {
+		for k, hits := range r.hits {
+			if len(hits) == 0 || !hits[len(hits)-1].After(cutoff) {
+				delete(r.hits, k)
+			}
+		}
+		r.lastSweep = now
+	}
+
+	recent := r.hits[key][:0]
+	for _, hit := range r.hits[key] {
+		if hit.After(cutoff) {
+			recent = append(recent, hit)
+		}
+	}
+	if len(recent) >= r.limit {
+		r.hits[key] = recent
+		return false
+	}
+	r.hits[key] = append(recent, now)
+	return true
+}
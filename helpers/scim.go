@@ -0,0 +1,794 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/server/web"
+)
+
+// ScimError is an error reported to the SCIM client with its status and error type
+type ScimError struct {
+	Status   int
+	ScimType string
+	Detail   string
+}
+
+func (e *ScimError) Error() string {
+	return e.Detail
+}
+
+// NewScimError creates a SCIM error
+func NewScimError(status int, scimType, detail string, args ...interface{}) *ScimError {
+	return &ScimError{Status: status, ScimType: scimType, Detail: fmt.Sprintf(detail, args...)}
+}
+
+// ScimUserFilterAttributes are the user attributes SCIM filters can compare
+var ScimUserFilterAttributes = map[string]ScimFilterAttribute{
+	"id":                    {Field: "Id", Kind: ScimAttributeInteger},
+	"externalid":            {Field: "ScimExternalId", Kind: ScimAttributeString},
+	"username":              {Field: "Email", Kind: ScimAttributeString},
+	"emails":                {Field: "Email", Kind: ScimAttributeString},
+	"emails.value":          {Field: "Email", Kind: ScimAttributeString},
+	"displayname":           {Field: "Name", Kind: ScimAttributeString},
+	"name.formatted":        {Field: "Name", Kind: ScimAttributeString},
+	"active":                {Field: "DeactivatedAt__isnull", Kind: ScimAttributeBoolean},
+	"roles":                 {Field: "Role", Kind: ScimAttributeString},
+	"roles.value":           {Field: "Role", Kind: ScimAttributeString},
+	"groups":                {Field: "Department__Id", Kind: ScimAttributeInteger},
+	"groups.value":          {Field: "Department__Id", Kind: ScimAttributeInteger},
+	"enterprise:department": {Field: "Department__Name", Kind: ScimAttributeString},
+	"meta.created":          {Field: "CreatedAt", Kind: ScimAttributeDateTime},
+	"meta.lastmodified":     {Field: "UpdatedAt", Kind: ScimAttributeDateTime},
+}
+
+// ScimGroupFilterAttributes are the group attributes SCIM filters can compare
+var ScimGroupFilterAttributes = map[string]ScimFilterAttribute{
+	"id":                {Field: "Id", Kind: ScimAttributeInteger},
+	"externalid":        {Field: "ScimExternalId", Kind: ScimAttributeString},
+	"displayname":       {Field: "Name", Kind: ScimAttributeString},
+	"members":           {Field: "Users__Id", Kind: ScimAttributeInteger},
+	"members.value":     {Field: "Users__Id", Kind: ScimAttributeInteger},
+	"meta.created":      {Field: "CreatedAt", Kind: ScimAttributeDateTime},
+	"meta.lastmodified": {Field: "UpdatedAt", Kind: ScimAttributeDateTime},
+}
+
+// ScimResponse writes a SCIM resource
+func ScimResponse(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", constants.ScimContentType)
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
+
+// ScimErrorResponse writes a SCIM error, errors other than ScimError are internal server errors
+func ScimErrorResponse(w http.ResponseWriter, err error) {
+	scimErr, ok := err.(*ScimError)
+	if !ok {
+		scimErr = NewScimError(http.StatusInternalServerError, "", "%s", err.Error())
+	}
+	ScimResponse(w, scimErr.Status, dto.ScimErrorResponse{
+		Schemas:  []string{constants.ScimSchemaError},
+		Status:   strconv.Itoa(scimErr.Status),
+		ScimType: scimErr.ScimType,
+		Detail:   scimErr.Detail,
+	})
+}
+
+// IsValidScimToken reports whether the bearer token is the scim_token of the identity platform. SCIM is disabled while
+// scim_token is empty.
+func IsValidScimToken(token string) bool {
+	expected := web.AppConfig.DefaultString("scim_token", "")
+	if expected == "" || token == "" {
+		return false
+	}
+	// Comparing digests takes the same time for tokens of any length
+	expectedSum, tokenSum := sha256.Sum256([]byte(expected)), sha256.Sum256([]byte(token))
+	return subtle.ConstantTimeCompare(expectedSum[:], tokenSum[:]) == 1
+}
+
+// ScimBaseUrl returns the URL of the SCIM API the request was sent to, resources link to it in their meta.location
+func ScimBaseUrl(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if forwarded := r.Header.Get("X-Forwarded-Proto"); forwarded != "" {
+		scheme = forwarded
+	}
+	return scheme + "://" + r.Host + constants.ScimPath
+}
+
+// ParseScimPagination reads the 1-based startIndex and the count of a list request
+func ParseScimPagination(startIndex, count string) (int, int, error) {
+	start, size := 1, constants.ScimDefaultCount
+	if startIndex != "" {
+		value, err := strconv.Atoi(startIndex)
+		if err != nil {
+			return 0, 0, NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidValue, "startIndex must be a number")
+		}
+		// Values below 1 are interpreted as 1
+		if value > 1 {
+			start = value
+		}
+	}
+	if count != "" {
+		value, err := strconv.Atoi(count)
+		if err != nil {
+			return 0, 0, NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidValue, "count must be a number")
+		}
+		size = max(0, min(value, constants.ScimMaxCount))
+	}
+	return start, size, nil
+}
+
+// ParseScimFilterCondition parses the filter of a list request into an ORM condition on the given attributes
+func ParseScimFilterCondition(filter string, attributes map[string]ScimFilterAttribute) (*orm.Condition, error) {
+	if filter == "" {
+		return orm.NewCondition(), nil
+	}
+	parsed, err := ParseScimFilter(filter)
+	if err != nil {
+		return nil, NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidFilter, "%s", err.Error())
+	}
+	cond, err := parsed.ToCondition(attributes)
+	if err != nil {
+		return nil, NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidFilter, "%s", err.Error())
+	}
+	return cond, nil
+}
+
+// scimDefaultDepartment returns the department configured with scim_default_department, nil if none is configured
+func scimDefaultDepartment() (*models.Department, error) {
+	name := web.AppConfig.DefaultString("scim_default_department", "")
+	if name == "" {
+		return nil, nil
+	}
+	department, err := models.GetDepartmentByName(name)
+	if err == orm.ErrNoRows {
+		return nil, fmt.Errorf("scim_default_department %q doesn't exist", name)
+	}
+	return department, err
+}
+
+// scimUserName returns the name of the user from the display name or the name parts
+func scimUserName(req *dto.ScimUserRequest) string {
+	if req.DisplayName != "" {
+		return req.DisplayName
+	}
+	if req.Name != nil {
+		if req.Name.Formatted != "" {
+			return req.Name.Formatted
+		}
+		if name := strings.TrimSpace(req.Name.GivenName + " " + req.Name.FamilyName); name != "" {
+			return name
+		}
+	}
+	return req.UserName
+}
+
+// scimUserRole returns the primary role of the user, employees by default
+func scimUserRole(req *dto.ScimUserRequest) (string, error) {
+	role := constants.RoleEmployee
+	for i, value := range req.Roles {
+		if i == 0 || value.Primary {
+			role = strings.ToUpper(value.Value)
+		}
+	}
+	switch role {
+	case constants.RoleEmployee, constants.RoleManager, constants.RoleAdmin:
+		return role, nil
+	}
+	return "", NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidValue, "unknown role %q", role)
+}
+
+// SaveScimUser creates the user of a SCIM request, or replaces the attributes of the given user with the request.
+// New users get the department of the enterprise extension or scim_default_department, existing users keep
+// their department unless the request names another one. Inactive users are deactivated. The change is recorded in
+// the audit log as made by the actor.
+func SaveScimUser(user *models.User, req *dto.ScimUserRequest, actor models.AuditActor, now time.Time) (*models.User, error) {
+	if _, err := ValidatePayloads(*req); err != nil {
+		return nil, NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidValue, "%s", err.Error())
+	}
+	email := strings.ToLower(req.UserName)
+
+	role, err := scimUserRole(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// The email is the login of the user and has to stay unique
+	if existing, err := models.GetUserByEmail(email); err == nil && (user == nil || existing.Id != user.Id) {
+		return nil, NewScimError(http.StatusConflict, constants.ScimErrorUniqueness, "userName %q is already taken", req.UserName)
+	} else if err != nil && err != orm.ErrNoRows {
+		return nil, err
+	} else if err == orm.ErrNoRows {
+		// Deleted users keep their email until they are purged
+		if registered, err := models.IsEmailRegistered(email); err != nil {
+			return nil, err
+		} else if registered {
+			return nil, NewScimError(http.StatusConflict, constants.ScimErrorUniqueness, "userName %q belongs to a deleted user", req.UserName)
+		}
+	}
+
+	var department *models.Department
+	if req.Enterprise != nil && req.Enterprise.Department != "" {
+		department, err = models.GetDepartmentByName(req.Enterprise.Department)
+		if err == orm.ErrNoRows {
+			return nil, NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidValue, "department %q doesn't exist", req.Enterprise.Department)
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else if user != nil {
+		department = user.Department
+	} else {
+		if department, err = scimDefaultDepartment(); err != nil {
+			return nil, err
+		}
+		if department == nil {
+			return nil, NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidValue, "the enterprise department is required, no scim_default_department is configured")
+		}
+	}
+
+	password := req.Password
+	if password == "" && user == nil {
+		// The user signs in with single sign-on or resets the password
+		if password, err = GenerateRandomHex(32); err != nil {
+			return nil, err
+		}
+	}
+	hashedPassword := ""
+	if password != "" {
+		if hashedPassword, err = HashPassword(password); err != nil {
+			return nil, err
+		}
+	}
+
+	active := req.Active == nil || *req.Active
+	if user == nil {
+		user = &models.User{
+			Name:           scimUserName(req),
+			Email:          email,
+			Password:       hashedPassword,
+			Role:           role,
+			Department:     department,
+			EmailVerified:  true,
+			ScimExternalId: req.ExternalId,
+		}
+		if !active {
+			user.DeactivatedAt = &now
+		}
+		userCreated := NewDomainEvent(constants.EventUserCreated, func() interface{} {
+			return dto.FromUserModelToUserResponse(user, false, false, false)
+		})
+		audit := NewAuditEntry(actor, constants.AuditActionCreate, constants.AuditEntityUser, func() (int, interface{}, interface{}) {
+			return user.Id, nil, dto.FromUserModelToUserResponse(user, false, false, false)
+		})
+		if err := models.CreateUser(user, userCreated, audit); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	before := dto.FromUserModelToUserResponse(user, false, false, false)
+	// Tokens of users who lose access or change role are revoked
+	if user.Role != role || (user.IsActive() && !active) {
+		user.TokenVersion++
+	}
+	if !active && user.IsActive() {
+		user.DeactivatedAt = &now
+	} else if active {
+		user.DeactivatedAt = nil
+	}
+	if hashedPassword != "" {
+		user.Password = hashedPassword
+		user.PasswordChangedAt = &now
+	}
+	user.Name = scimUserName(req)
+	user.Email = email
+	user.Role = role
+	user.Department = department
+	user.ScimExternalId = req.ExternalId
+	userUpdated := NewDomainEvent(constants.EventUserUpdated, func() interface{} {
+		return dto.FromUserModelToUserResponse(user, false, false, false)
+	})
+	audit := NewAuditEntry(actor, constants.AuditActionUpdate, constants.AuditEntityUser, func() (int, interface{}, interface{}) {
+		return user.Id, before, dto.FromUserModelToUserResponse(user, false, false, false)
+	})
+	if err := models.UpdateProvisionedUser(user, userUpdated, audit); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// scimValueString reads a string value of a PATCH operation
+func scimValueString(path string, value interface{}) (string, error) {
+	text, ok := value.(string)
+	if !ok {
+		return "", NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidValue, "%s must be a string", path)
+	}
+	return text, nil
+}
+
+// scimValueBool reads a boolean value of a PATCH operation, some platforms send booleans as strings
+func scimValueBool(path string, value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		if boolean, err := strconv.ParseBool(v); err == nil {
+			return boolean, nil
+		}
+	}
+	return false, NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidValue, "%s must be a boolean", path)
+}
+
+// scimValueMultiValues reads the values of a multi-valued attribute, a single value or a plain string is accepted too
+func scimValueMultiValues(path string, value interface{}) ([]dto.ScimMultiValue, error) {
+	if text, ok := value.(string); ok {
+		return []dto.ScimMultiValue{{Value: text}}, nil
+	}
+	if object, ok := value.(map[string]interface{}); ok {
+		value = []interface{}{object}
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidValue, "%s has an invalid value", path)
+	}
+	var values []dto.ScimMultiValue
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidValue, "%s has an invalid value", path)
+	}
+	return values, nil
+}
+
+// scimMultiValuedPath matches paths into multi-valued attributes like emails[type eq "work"].value
+var scimMultiValuedPath = regexp.MustCompile(`^(?i)(emails|roles)(\[[^\]]*\])?(\.value)?$`)
+
+// applyScimUserAttribute sets or, with a nil value, removes an attribute of the user request
+func applyScimUserAttribute(req *dto.ScimUserRequest, path string, value interface{}) error {
+	name := scimAttributeName(path)
+	if match := scimMultiValuedPath.FindStringSubmatch(path); match != nil {
+		name = strings.ToLower(match[1])
+		// Values of a single entry are sent without the entry
+		if match[3] != "" || match[2] != "" {
+			if text, ok := value.(string); ok {
+				value = []interface{}{map[string]interface{}{"value": text, "primary": true}}
+			}
+		}
+	}
+
+	if req.Name == nil {
+		req.Name = &dto.ScimName{}
+	}
+	var err error
+	switch name {
+	case "username":
+		if value == nil {
+			return NewScimError(http.StatusBadRequest, constants.ScimErrorMutability, "userName is required")
+		}
+		req.UserName, err = scimValueString(path, value)
+	case "externalid":
+		req.ExternalId = ""
+		if value != nil {
+			req.ExternalId, err = scimValueString(path, value)
+		}
+	case "displayname":
+		req.DisplayName = ""
+		if value != nil {
+			req.DisplayName, err = scimValueString(path, value)
+		}
+	case "name":
+		// The display name is derived from the name parts again
+		req.DisplayName, req.Name = "", &dto.ScimName{}
+		if value != nil {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidValue, "name must be an object")
+			}
+			for attribute, attributeValue := range object {
+				if err := applyScimUserAttribute(req, "name."+attribute, attributeValue); err != nil {
+					return err
+				}
+			}
+		}
+	case "name.formatted", "name.givenname", "name.familyname":
+		text := ""
+		if value != nil {
+			if text, err = scimValueString(path, value); err != nil {
+				return err
+			}
+		}
+		req.DisplayName = ""
+		switch name {
+		case "name.formatted":
+			req.Name.Formatted = text
+		case "name.givenname":
+			req.Name.Formatted, req.Name.GivenName = "", text
+		default:
+			req.Name.Formatted, req.Name.FamilyName = "", text
+		}
+	case "active":
+		if value == nil {
+			return NewScimError(http.StatusBadRequest, constants.ScimErrorMutability, "active can't be removed")
+		}
+		active, err := scimValueBool(path, value)
+		if err != nil {
+			return err
+		}
+		req.Active = &active
+	case "password":
+		if value == nil {
+			return NewScimError(http.StatusBadRequest, constants.ScimErrorMutability, "password can't be removed")
+		}
+		req.Password, err = scimValueString(path, value)
+	case "emails":
+		// The email is the userName, the primary email replaces it
+		if value == nil {
+			return NewScimError(http.StatusBadRequest, constants.ScimErrorMutability, "emails can't be removed")
+		}
+		emails, err := scimValueMultiValues(path, value)
+		if err != nil {
+			return err
+		}
+		for i, email := range emails {
+			if i == 0 || email.Primary {
+				req.UserName = email.Value
+			}
+		}
+		req.Emails = emails
+	case "roles":
+		req.Roles = nil
+		if value != nil {
+			req.Roles, err = scimValueMultiValues(path, value)
+		}
+	case "enterprise:department":
+		// Without a department the user keeps the current one
+		req.Enterprise = &dto.ScimEnterpriseUser{}
+		if value != nil {
+			req.Enterprise.Department, err = scimValueString(path, value)
+		}
+	case strings.ToLower(constants.ScimSchemaEnterpriseUser):
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidValue, "%s must be an object", path)
+		}
+		for attribute, attributeValue := range object {
+			if err := applyScimUserAttribute(req, constants.ScimSchemaEnterpriseUser+":"+attribute, attributeValue); err != nil {
+				return err
+			}
+		}
+	case "schemas", "id", "meta", "groups":
+		// Read-only attributes sent back by platforms replacing the whole resource are ignored
+	default:
+		return NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidPath, "attribute %q is not supported", path)
+	}
+	return err
+}
+
+// ApplyScimUserPatch applies the operations of a PATCH request to the request replacing the user
+func ApplyScimUserPatch(req *dto.ScimUserRequest, operations []dto.ScimPatchOperation) error {
+	for _, operation := range operations {
+		switch strings.ToLower(operation.Op) {
+		case constants.ScimPatchOpAdd, constants.ScimPatchOpReplace:
+			if operation.Path != "" {
+				if err := applyScimUserAttribute(req, operation.Path, operation.Value); err != nil {
+					return err
+				}
+				continue
+			}
+
+			// Without path the value holds the attributes to change
+			attributes, ok := operation.Value.(map[string]interface{})
+			if !ok {
+				return NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidValue, "the value of an operation without path must be an object")
+			}
+			for attribute, value := range attributes {
+				if err := applyScimUserAttribute(req, attribute, value); err != nil {
+					return err
+				}
+			}
+		case constants.ScimPatchOpRemove:
+			if operation.Path == "" {
+				return NewScimError(http.StatusBadRequest, constants.ScimErrorNoTarget, "remove operations need a path")
+			}
+			if err := applyScimUserAttribute(req, operation.Path, nil); err != nil {
+				return err
+			}
+		default:
+			return NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidSyntax, "unknown operation %q", operation.Op)
+		}
+	}
+	return nil
+}
+
+// scimMemberIds reads the user ids of group members
+func scimMemberIds(members []dto.ScimMultiValue) ([]int, error) {
+	ids := make([]int, 0, len(members))
+	for _, member := range members {
+		id, err := strconv.Atoi(member.Value)
+		if err != nil {
+			return nil, NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidValue, "member %q is not a user id", member.Value)
+		}
+		ids = append(ids, id)
+	}
+	return uniqueIds(ids), nil
+}
+
+// updateScimGroupMembers moves the added users into the department and the removed members into scim_default_department
+func updateScimGroupMembers(department *models.Department, add, remove []int, actor models.AuditActor) error {
+	if len(add) == 0 && len(remove) == 0 {
+		return nil
+	}
+
+	var fallback *models.Department
+	if len(remove) > 0 {
+		var err error
+		if fallback, err = scimDefaultDepartment(); err != nil {
+			return err
+		}
+		if fallback == nil {
+			return NewScimError(http.StatusBadRequest, constants.ScimErrorMutability, "users always belong to a department, members can only be removed with a scim_default_department")
+		}
+		if fallback.Id == department.Id {
+			return NewScimError(http.StatusBadRequest, constants.ScimErrorMutability, "members can't be removed from the default department, add them to another group instead")
+		}
+	}
+
+	events := make([]models.ChangeRecord, 0, len(add)+len(remove))
+	for _, moved := range []struct {
+		ids        []int
+		department *models.Department
+	}{{add, department}, {remove, fallback}} {
+		for _, id := range moved.ids {
+			userUpdated := NewDomainEvent(constants.EventUserUpdated, func() interface{} {
+				return map[string]interface{}{"id": id, "department_id": moved.department.Id}
+			})
+			events = append(events, userUpdated)
+		}
+	}
+
+	if err := models.UpdateDepartmentMembers(department, add, remove, fallback, actor, events...); err != nil {
+		if err == models.ErrUnknownMembers {
+			return NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidValue, "%s", err.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+// SaveScimGroup creates the department of a SCIM group, or renames the given department, and sets its members.
+// The changes are recorded in the audit log as made by the actor.
+func SaveScimGroup(department *models.Department, req *dto.ScimGroupRequest, actor models.AuditActor) (*models.Department, error) {
+	if _, err := ValidatePayloads(*req); err != nil {
+		return nil, NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidValue, "%s", err.Error())
+	}
+	members, err := scimMemberIds(req.Members)
+	if err != nil {
+		return nil, err
+	}
+
+	// Departments are matched by name, e.g. by the enterprise department of users
+	if existing, err := models.GetDepartmentByName(req.DisplayName); err == nil && (department == nil || existing.Id != department.Id) {
+		return nil, NewScimError(http.StatusConflict, constants.ScimErrorUniqueness, "displayName %q is already taken", req.DisplayName)
+	} else if err != nil && err != orm.ErrNoRows {
+		return nil, err
+	}
+
+	if department == nil {
+		department = &models.Department{Name: req.DisplayName, ScimExternalId: req.ExternalId}
+		audit := NewAuditEntry(actor, constants.AuditActionCreate, constants.AuditEntityDepartment, func() (int, interface{}, interface{}) {
+			return department.Id, nil, dto.FromDepartmentModelToDepartmentResponse(department, false, false)
+		})
+		if err := models.CreateDepartment(department, audit); err != nil {
+			return nil, err
+		}
+		return department, updateScimGroupMembers(department, members, nil, actor)
+	}
+
+	if err := renameScimGroup(department, req, actor); err != nil {
+		return nil, err
+	}
+
+	// The members of the request replace the current ones
+	current, err := models.GetDepartmentMembers(department.Id)
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[int]bool, len(members))
+	for _, id := range members {
+		keep[id] = true
+	}
+	var add, remove []int
+	for _, user := range current {
+		if keep[user.Id] {
+			delete(keep, user.Id)
+		} else {
+			remove = append(remove, user.Id)
+		}
+	}
+	for _, id := range members {
+		if keep[id] {
+			add = append(add, id)
+		}
+	}
+	return department, updateScimGroupMembers(department, add, remove, actor)
+}
+
+// renameScimGroup saves the name and external id of the request to the department, when they changed
+func renameScimGroup(department *models.Department, req *dto.ScimGroupRequest, actor models.AuditActor) error {
+	if department.Name == req.DisplayName && department.ScimExternalId == req.ExternalId {
+		return nil
+	}
+	before := dto.FromDepartmentModelToDepartmentResponse(department, false, false)
+	department.Name = req.DisplayName
+	department.ScimExternalId = req.ExternalId
+	audit := NewAuditEntry(actor, constants.AuditActionUpdate, constants.AuditEntityDepartment, func() (int, interface{}, interface{}) {
+		return department.Id, before, dto.FromDepartmentModelToDepartmentResponse(department, false, false)
+	})
+	return models.UpdateDepartment(department, audit)
+}
+
+// scimMemberFilterPath matches paths selecting a member like members[value eq "2"]
+var scimMemberFilterPath = regexp.MustCompile(`^(?i)members\[value eq "([^"]*)"\]$`)
+
+// ApplyScimGroupPatch applies the operations of a PATCH request to the department, members are added and removed
+// without replacing the other members. The changes are recorded in the audit log as made by the actor.
+func ApplyScimGroupPatch(department *models.Department, operations []dto.ScimPatchOperation, actor models.AuditActor) error {
+	req := &dto.ScimGroupRequest{DisplayName: department.Name, ExternalId: department.ScimExternalId}
+	renamed := false
+	var add, remove []int
+
+	// flush saves the changes collected so far, users added again in the same request stay members
+	flush := func() error {
+		if renamed {
+			if existing, err := models.GetDepartmentByName(req.DisplayName); err == nil && existing.Id != department.Id {
+				return NewScimError(http.StatusConflict, constants.ScimErrorUniqueness, "displayName %q is already taken", req.DisplayName)
+			} else if err != nil && err != orm.ErrNoRows {
+				return err
+			}
+			if err := renameScimGroup(department, req, actor); err != nil {
+				return err
+			}
+		}
+
+		added := make(map[int]bool, len(add))
+		for _, id := range add {
+			added[id] = true
+		}
+		removed := make([]int, 0, len(remove))
+		for _, id := range remove {
+			if !added[id] {
+				removed = append(removed, id)
+			}
+		}
+		err := updateScimGroupMembers(department, uniqueIds(add), uniqueIds(removed), actor)
+		add, remove, renamed = nil, nil, false
+		return err
+	}
+
+	for _, operation := range operations {
+		op := strings.ToLower(operation.Op)
+		if op != constants.ScimPatchOpAdd && op != constants.ScimPatchOpReplace && op != constants.ScimPatchOpRemove {
+			return NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidSyntax, "unknown operation %q", operation.Op)
+		}
+
+		attributes := map[string]interface{}{operation.Path: operation.Value}
+		if operation.Path == "" {
+			if op == constants.ScimPatchOpRemove {
+				return NewScimError(http.StatusBadRequest, constants.ScimErrorNoTarget, "remove operations need a path")
+			}
+			object, ok := operation.Value.(map[string]interface{})
+			if !ok {
+				return NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidValue, "the value of an operation without path must be an object")
+			}
+			attributes = object
+		}
+
+		for path, value := range attributes {
+			if match := scimMemberFilterPath.FindStringSubmatch(path); match != nil {
+				if op != constants.ScimPatchOpRemove {
+					return NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidPath, "members can only be added with the members path")
+				}
+				ids, err := scimMemberIds([]dto.ScimMultiValue{{Value: match[1]}})
+				if err != nil {
+					return err
+				}
+				remove = append(remove, ids...)
+				continue
+			}
+
+			switch scimAttributeName(path) {
+			case "displayname":
+				if op == constants.ScimPatchOpRemove {
+					return NewScimError(http.StatusBadRequest, constants.ScimErrorMutability, "displayName is required")
+				}
+				name, err := scimValueString(path, value)
+				if err != nil {
+					return err
+				}
+				req.DisplayName, renamed = name, true
+			case "externalid":
+				req.ExternalId, renamed = "", true
+				if op != constants.ScimPatchOpRemove {
+					externalId, err := scimValueString(path, value)
+					if err != nil {
+						return err
+					}
+					req.ExternalId = externalId
+				}
+			case "members":
+				var members []dto.ScimMultiValue
+				if value != nil {
+					var err error
+					if members, err = scimValueMultiValues(path, value); err != nil {
+						return err
+					}
+				}
+				ids, err := scimMemberIds(members)
+				if err != nil {
+					return err
+				}
+
+				switch op {
+				case constants.ScimPatchOpAdd:
+					add = append(add, ids...)
+				case constants.ScimPatchOpRemove:
+					if value != nil {
+						remove = append(remove, ids...)
+						continue
+					}
+					// Removing the attribute removes every member
+					current, err := models.GetDepartmentMembers(department.Id)
+					if err != nil {
+						return err
+					}
+					for _, user := range current {
+						remove = append(remove, user.Id)
+					}
+				default:
+					// Replacing the members goes through the same code path as replacing the group
+					if err := flush(); err != nil {
+						return err
+					}
+					req.Members = members
+					if _, err := SaveScimGroup(department, req, actor); err != nil {
+						return err
+					}
+				}
+			case "schemas", "id", "meta":
+				// Read-only attributes are ignored
+			default:
+				return NewScimError(http.StatusBadRequest, constants.ScimErrorInvalidPath, "attribute %q is not supported", path)
+			}
+		}
+	}
+
+	return flush()
+}
+
+// uniqueIds removes repeated ids keeping the order
+func uniqueIds(ids []int) []int {
+	unique := make([]int, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
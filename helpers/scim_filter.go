@@ -0,0 +1,357 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// ScimFilter is a parsed SCIM filter expression (RFC 7644 section 3.4.2.2). Logical filters (and, or, not) hold
+// their operands in Filters, attribute filters compare Attribute with Value.
+type ScimFilter struct {
+	Operator  string // and, or, not, eq, ne, co, sw, ew, pr, gt, ge, lt, le
+	Attribute string
+	Value     interface{} // string, bool, float64 or nil
+	Filters   []*ScimFilter
+}
+
+// Kinds of the attributes a filter can compare
+const (
+	ScimAttributeString = iota
+	ScimAttributeInteger
+	ScimAttributeBoolean
+	ScimAttributeDateTime
+)
+
+// ScimFilterAttribute maps a SCIM attribute to the ORM field it is filtered on
+type ScimFilterAttribute struct {
+	Field string
+	Kind  int
+}
+
+var scimComparisonOperators = map[string]bool{"eq": true, "ne": true, "co": true, "sw": true, "ew": true, "pr": true, "gt": true, "ge": true, "lt": true, "le": true}
+
+// scimFilterToken is a token of a filter expression, quoted strings keep their quotes
+type scimFilterToken struct {
+	text   string
+	quoted bool
+}
+
+// tokenizeScimFilter splits a filter expression into words, parentheses and quoted strings
+func tokenizeScimFilter(filter string) ([]scimFilterToken, error) {
+	var tokens []scimFilterToken
+	for i := 0; i < len(filter); {
+		switch c := filter[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, scimFilterToken{text: string(c)})
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(filter) && filter[end] != '"' {
+				if filter[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(filter) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, scimFilterToken{text: filter[i : end+1], quoted: true})
+			i = end + 1
+		case c == '[':
+			return nil, fmt.Errorf("value path filters are not supported")
+		default:
+			end := i
+			for end < len(filter) && !strings.ContainsRune(" \t()\"[", rune(filter[end])) {
+				end++
+			}
+			tokens = append(tokens, scimFilterToken{text: filter[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+// scimFilterParser is a recursive descent parser of filter expressions, "and" binds stronger than "or"
+type scimFilterParser struct {
+	tokens []scimFilterToken
+	pos    int
+}
+
+// ParseScimFilter parses a SCIM filter expression, e.g. userName eq "jane@example.com" and active eq true
+func ParseScimFilter(filter string) (*ScimFilter, error) {
+	tokens, err := tokenizeScimFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty filter")
+	}
+
+	parser := &scimFilterParser{tokens: tokens}
+	parsed, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(parser.tokens) {
+		return nil, fmt.Errorf("unexpected %q", parser.tokens[parser.pos].text)
+	}
+	return parsed, nil
+}
+
+func (p *scimFilterParser) next() (scimFilterToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return scimFilterToken{}, false
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	return token, true
+}
+
+func (p *scimFilterParser) peekKeyword(keyword string) bool {
+	return p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && strings.EqualFold(p.tokens[p.pos].text, keyword)
+}
+
+func (p *scimFilterParser) parseOr() (*ScimFilter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &ScimFilter{Operator: "or", Filters: []*ScimFilter{left, right}}
+	}
+	return left, nil
+}
+
+func (p *scimFilterParser) parseAnd() (*ScimFilter, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and") {
+		p.pos++
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = &ScimFilter{Operator: "and", Filters: []*ScimFilter{left, right}}
+	}
+	return left, nil
+}
+
+func (p *scimFilterParser) parseFactor() (*ScimFilter, error) {
+	negate := false
+	if p.peekKeyword("not") {
+		p.pos++
+		negate = true
+	}
+
+	if token, ok := p.next(); ok && token.text == "(" && !token.quoted {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing, ok := p.next(); !ok || closing.text != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		if negate {
+			return &ScimFilter{Operator: "not", Filters: []*ScimFilter{inner}}, nil
+		}
+		return inner, nil
+	} else if ok {
+		p.pos--
+	}
+	if negate {
+		return nil, fmt.Errorf("not must be followed by a parenthesized filter")
+	}
+	return p.parseComparison()
+}
+
+func (p *scimFilterParser) parseComparison() (*ScimFilter, error) {
+	attribute, ok := p.next()
+	if !ok || attribute.quoted || attribute.text == "(" || attribute.text == ")" {
+		return nil, fmt.Errorf("attribute expected")
+	}
+	operator, ok := p.next()
+	if !ok || operator.quoted || !scimComparisonOperators[strings.ToLower(operator.text)] {
+		return nil, fmt.Errorf("comparison operator expected after %q", attribute.text)
+	}
+
+	filter := &ScimFilter{Operator: strings.ToLower(operator.text), Attribute: attribute.text}
+	if filter.Operator == "pr" {
+		return filter, nil
+	}
+
+	value, ok := p.next()
+	if !ok || value.text == "(" || value.text == ")" {
+		return nil, fmt.Errorf("value expected after %q %s", attribute.text, filter.Operator)
+	}
+	if value.quoted {
+		var text string
+		if err := json.Unmarshal([]byte(value.text), &text); err != nil {
+			return nil, fmt.Errorf("invalid string %s", value.text)
+		}
+		filter.Value = text
+		return filter, nil
+	}
+	switch strings.ToLower(value.text) {
+	case "true":
+		filter.Value = true
+	case "false":
+		filter.Value = false
+	case "null":
+		filter.Value = nil
+	default:
+		number, err := strconv.ParseFloat(value.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q", value.text)
+		}
+		filter.Value = number
+	}
+	return filter, nil
+}
+
+// scimAttributeName strips the schema URN from a fully qualified attribute and lowercases it, attribute names are case insensitive
+func scimAttributeName(attribute string) string {
+	for _, schema := range []string{constants.ScimSchemaEnterpriseUser, constants.ScimSchemaUser, constants.ScimSchemaGroup} {
+		if len(attribute) > len(schema) && strings.EqualFold(attribute[:len(schema)+1], schema+":") {
+			attribute = attribute[len(schema)+1:]
+			if schema == constants.ScimSchemaEnterpriseUser {
+				attribute = "enterprise:" + attribute
+			}
+			break
+		}
+	}
+	return strings.ToLower(attribute)
+}
+
+// ToCondition translates the filter into an ORM condition on the fields of the given attributes,
+// which are keyed by their lowercased name
+func (f *ScimFilter) ToCondition(attributes map[string]ScimFilterAttribute) (*orm.Condition, error) {
+	cond := orm.NewCondition()
+	switch f.Operator {
+	case "and", "or":
+		left, err := f.Filters[0].ToCondition(attributes)
+		if err != nil {
+			return nil, err
+		}
+		right, err := f.Filters[1].ToCondition(attributes)
+		if err != nil {
+			return nil, err
+		}
+		if f.Operator == "and" {
+			return cond.AndCond(left).AndCond(right), nil
+		}
+		return cond.AndCond(left).OrCond(right), nil
+	case "not":
+		inner, err := f.Filters[0].ToCondition(attributes)
+		if err != nil {
+			return nil, err
+		}
+		return cond.AndNotCond(inner), nil
+	}
+
+	attribute, ok := attributes[scimAttributeName(f.Attribute)]
+	if !ok {
+		return nil, fmt.Errorf("attribute %q can't be filtered", f.Attribute)
+	}
+
+	if f.Operator == "pr" {
+		switch attribute.Kind {
+		case ScimAttributeString:
+			return cond.AndNot(attribute.Field, ""), nil
+		case ScimAttributeBoolean:
+			return nil, fmt.Errorf("%s: boolean values can only be compared with eq and ne", f.Attribute)
+		}
+		return cond.And(attribute.Field+"__isnull", false), nil
+	}
+
+	value, err := scimFilterValue(attribute, f.Operator, f.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Attribute, err)
+	}
+
+	switch f.Operator {
+	case "eq":
+		if attribute.Kind == ScimAttributeString {
+			return cond.And(attribute.Field+"__iexact", value), nil
+		}
+		return cond.And(attribute.Field, value), nil
+	case "ne":
+		if attribute.Kind == ScimAttributeString {
+			return cond.AndNot(attribute.Field+"__iexact", value), nil
+		}
+		return cond.AndNot(attribute.Field, value), nil
+	case "co":
+		return cond.And(attribute.Field+"__icontains", value), nil
+	case "sw":
+		return cond.And(attribute.Field+"__istartswith", value), nil
+	case "ew":
+		return cond.And(attribute.Field+"__iendswith", value), nil
+	case "gt":
+		return cond.And(attribute.Field+"__gt", value), nil
+	case "ge":
+		return cond.And(attribute.Field+"__gte", value), nil
+	case "lt":
+		return cond.And(attribute.Field+"__lt", value), nil
+	default: // le
+		return cond.And(attribute.Field+"__lte", value), nil
+	}
+}
+
+// scimFilterValue converts the compared value to the kind of the attribute
+func scimFilterValue(attribute ScimFilterAttribute, operator string, value interface{}) (interface{}, error) {
+	switch attribute.Kind {
+	case ScimAttributeString:
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("string value expected")
+		}
+		return text, nil
+	case ScimAttributeInteger:
+		var number int
+		var err error
+		switch v := value.(type) {
+		case float64:
+			number = int(v)
+		case string:
+			// Ids are strings in SCIM
+			number, err = strconv.Atoi(v)
+		default:
+			err = fmt.Errorf("integer value expected")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("integer value expected")
+		}
+		return number, nil
+	case ScimAttributeBoolean:
+		boolean, ok := value.(bool)
+		if !ok || (operator != "eq" && operator != "ne") {
+			return nil, fmt.Errorf("boolean values can only be compared with eq and ne")
+		}
+		return boolean, nil
+	default:
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("dateTime value expected")
+		}
+		dateTime, err := time.Parse(time.RFC3339, text)
+		if err != nil {
+			return nil, fmt.Errorf("dateTime value expected")
+		}
+		return dateTime, nil
+	}
+}
@@ -0,0 +1,61 @@
+package helpers
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+// secretBoxKey derives the AES-256 key from secret_encryption_key
+func secretBoxKey() []byte {
+	key := sha256.Sum256(configuredSecret("secret_encryption_key"))
+	return key[:]
+}
+
+// EncryptSecret encrypts a value to be stored, e.g. a TOTP secret, with AES-GCM.
+// The random nonce is prepended and the result is base64 encoded.
+func EncryptSecret(plaintext string) (string, error) {
+	block, err := aes.NewCipher(secretBoxKey())
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(plaintext), nil)), nil
+}
+
+// DecryptSecret reverses EncryptSecret
+func DecryptSecret(ciphertext string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(secretBoxKey())
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("encrypted secret is too short")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,29 @@
+package helpers
+
+import (
+	"fmt"
+
+	"github.com/beego/beego/v2/server/web"
+)
+
+// requiredSecrets are the settings holding the keys the presence history, the stored secrets and the verification
+// links are protected with. They have no default, a key known from the source code wouldn't protect anything.
+var requiredSecrets = []string{"presence_revision_secret", "secret_encryption_key", "email_verification_secret"}
+
+// CheckRequiredSecrets makes sure every required secret is configured, the server refuses to start otherwise
+func CheckRequiredSecrets() error {
+	for _, name := range requiredSecrets {
+		if configuredSecret(name) == nil {
+			return fmt.Errorf("%s must be set in conf/app.conf", name)
+		}
+	}
+	return nil
+}
+
+// configuredSecret returns the value of a secret setting, nil when it isn't set
+func configuredSecret(name string) []byte {
+	if secret := web.AppConfig.DefaultString(name, ""); secret != "" {
+		return []byte(secret)
+	}
+	return nil
+}
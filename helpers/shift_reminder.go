@@ -0,0 +1,164 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/server/web"
+)
+
+// ShiftNotification is a shift reminder or missed check-in nudge due for a user
+type ShiftNotification struct {
+	User   *models.User
+	Type   string
+	InTime time.Time
+}
+
+// DueShiftNotifications determines the notifications due at now: a reminder from reminderMinutes before the shift
+// until it starts, and a nudge from the late threshold until the shift ends when there is no check-in yet.
+// Overnight shifts of the previous day are still nudged after midnight. Nothing is due for shifts on weekends and
+// holidays, and users on leave or already checked in on the day of the shift are skipped.
+func DueShiftNotifications(now time.Time, users []*models.User, presences []*models.Presence, holidays []*models.Holiday, leaves []*models.Leave, reminderMinutes int) []ShiftNotification {
+	location := PresenceLocation()
+	now = now.In(location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+
+	var due []ShiftNotification
+	for _, dayStart := range []time.Time{today.AddDate(0, 0, -1), today} {
+		due = append(due, dueShiftNotificationsOfDay(now, dayStart, users, presences, holidays, leaves, reminderMinutes)...)
+	}
+	return due
+}
+
+// dueShiftNotificationsOfDay determines the notifications due at now for the shifts starting on the day
+func dueShiftNotificationsOfDay(now, dayStart time.Time, users []*models.User, presences []*models.Presence, holidays []*models.Holiday, leaves []*models.Leave, reminderMinutes int) []ShiftNotification {
+	location := PresenceLocation()
+	day := dayStart.Format(constants.ReportDateLayout)
+
+	if dayStart.Weekday() == time.Saturday || dayStart.Weekday() == time.Sunday {
+		return nil
+	}
+	for _, holiday := range holidays {
+		if holiday.Date.Format(constants.ReportDateLayout) == day {
+			return nil
+		}
+	}
+
+	skipped := make(map[int]bool)
+	for _, presence := range presences {
+		if presence.Type == constants.PresenceTypeIn && presence.CreatedAt.In(location).Format(constants.ReportDateLayout) == day {
+			skipped[presence.User.Id] = true
+		}
+	}
+	for _, leave := range leaves {
+		if leave.StartDate.Format(constants.ReportDateLayout) <= day && leave.EndDate.Format(constants.ReportDateLayout) >= day {
+			skipped[leave.User.Id] = true
+		}
+	}
+
+	var due []ShiftNotification
+	for _, user := range users {
+		if user.Schedule == nil || skipped[user.Id] {
+			continue
+		}
+
+		inTime, err := ParseScheduleTime(dayStart, user.Schedule.InTime)
+		if err != nil {
+			continue
+		}
+		outTime, err := ParseScheduleTime(dayStart, user.Schedule.OutTime)
+		if err != nil {
+			continue
+		}
+		if !outTime.After(inTime) {
+			// Overnight shift
+			outTime = outTime.AddDate(0, 0, 1)
+		}
+
+		switch {
+		case !now.Before(inTime.Add(-time.Duration(reminderMinutes)*time.Minute)) && now.Before(inTime):
+			due = append(due, ShiftNotification{User: user, Type: constants.NotificationTypeShiftReminder, InTime: inTime})
+		case !now.Before(inTime.Add(constants.PresenceLateThreshold*time.Minute)) && now.Before(outTime):
+			due = append(due, ShiftNotification{User: user, Type: constants.NotificationTypeMissedCheckIn, InTime: inTime})
+		}
+	}
+	return due
+}
+
+// ShiftNotificationRetryDue reports whether a shift notification that failed the given number of times, the last time at
+// lastFailedAt, is attempted again at now. The retries back off exponentially and stop after
+// constants.ShiftNotificationMaxAttempts failures.
+func ShiftNotificationRetryDue(attempts int, lastFailedAt, now time.Time) bool {
+	if attempts == 0 {
+		return true
+	}
+	if attempts >= constants.ShiftNotificationMaxAttempts {
+		return false
+	}
+	return !now.Before(lastFailedAt.Add(ExponentialBackoff(attempts, constants.ShiftNotificationBaseBackoff, constants.ShiftNotificationMaxBackoff)))
+}
+
+// SendShiftNotifications sends the shift reminders and missed check-in nudges due at now, each at most once per shift.
+// Failed notifications are retried with a backoff instead of on every run of the scheduler. A run is skipped while
+// another instance of the API is sending, the next run sends what is left.
+func SendShiftNotifications(now time.Time) error {
+	_, err := models.RunExclusively(constants.ShiftNotificationLockId, func() error {
+		return sendShiftNotifications(now)
+	})
+	return err
+}
+
+// sendShiftNotifications sends the notifications due at now for the shifts that started yesterday or start today
+func sendShiftNotifications(now time.Time) error {
+	location := PresenceLocation()
+	now = now.In(location)
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+	yesterday := dayStart.AddDate(0, 0, -1)
+
+	users, err := models.GetUsersWithSchedule()
+	if err != nil {
+		return fmt.Errorf("failed to fetch users: %w", err)
+	}
+	presences, err := models.GetPresencesBetween(yesterday, dayStart.AddDate(0, 0, 1))
+	if err != nil {
+		return fmt.Errorf("failed to fetch presences: %w", err)
+	}
+	holidays, err := models.GetHolidaysBetween(yesterday, dayStart)
+	if err != nil {
+		return fmt.Errorf("failed to fetch holidays: %w", err)
+	}
+	leaves, err := models.GetLeavesBetween(yesterday, dayStart)
+	if err != nil {
+		return fmt.Errorf("failed to fetch leaves: %w", err)
+	}
+
+	reminderMinutes := web.AppConfig.DefaultInt("shift_reminder_minutes", constants.ShiftReminderDefaultMinutes)
+
+	var errs []error
+	for _, notification := range DueShiftNotifications(now, users, presences, holidays, leaves, reminderMinutes) {
+		// Shifts are identified by the day they start on
+		date := notification.InTime.Format(constants.ReportDateLayout)
+		reference := fmt.Sprintf("%s:%d:%s", notification.Type, notification.User.Id, date)
+		attempts, lastFailedAt, err := models.GetNotificationFailures(reference)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !ShiftNotificationRetryDue(attempts, lastFailedAt, now) {
+			continue
+		}
+		data := map[string]interface{}{
+			"Date":    date,
+			"InTime":  notification.InTime.Format("15:04"),
+			"Minutes": int(notification.InTime.Sub(now).Round(time.Minute).Minutes()),
+		}
+		if err := SendNotification(notification.User, notification.Type, reference, data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
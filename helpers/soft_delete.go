@@ -0,0 +1,20 @@
+package helpers
+
+import (
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/server/web"
+)
+
+// SoftDeleteRetentionDays returns the days deleted rows are kept before they can be purged
+func SoftDeleteRetentionDays() int {
+	return web.AppConfig.DefaultInt("soft_delete_retention_days", constants.SoftDeleteDefaultRetentionDays)
+}
+
+// PurgeDeleted permanently deletes the rows deleted more than the retention days before now
+func PurgeDeleted(retentionDays int, now time.Time) (models.PurgeResult, error) {
+	return models.PurgeDeleted(now.AddDate(0, 0, -retentionDays))
+}
@@ -0,0 +1,198 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+
+	"github.com/beego/beego/v2/server/web"
+	"github.com/go-pdf/fpdf"
+)
+
+// TimesheetCompany holds the company details printed in the timesheet header
+type TimesheetCompany struct {
+	Name    string
+	Address string
+}
+
+// timesheetColumn describes a column of the daily table
+type timesheetColumn struct {
+	title string
+	width float64
+	value func(day *dto.AttendanceDayResponse) string
+}
+
+var timesheetColumns = []timesheetColumn{
+	{"Date", 24, func(d *dto.AttendanceDayResponse) string { return d.Date }},
+	{"Day", 14, func(d *dto.AttendanceDayResponse) string { return timesheetWeekday(d.Date) }},
+	{"Scheduled", 26, func(d *dto.AttendanceDayResponse) string {
+		if d.ScheduledIn == nil || d.ScheduledOut == nil {
+			return "-"
+		}
+		return timesheetClock(d.ScheduledIn) + " - " + timesheetClock(d.ScheduledOut)
+	}},
+	{"Check In", 20, func(d *dto.AttendanceDayResponse) string { return timesheetClock(d.CheckIn) }},
+	{"Check Out", 20, func(d *dto.AttendanceDayResponse) string { return timesheetClock(d.CheckOut) }},
+	{"Status", 30, func(d *dto.AttendanceDayResponse) string {
+		if d.MarkerDescription != "" {
+			return d.Status + " (" + d.MarkerDescription + ")"
+		}
+		return d.Status
+	}},
+	{"Worked", 18, func(d *dto.AttendanceDayResponse) string { return timesheetDuration(d.WorkedMinutes) }},
+	{"Late (min)", 18, func(d *dto.AttendanceDayResponse) string { return fmt.Sprint(d.LateMinutes) }},
+}
+
+// GetTimesheetCompany reads the company details from the application configuration
+func GetTimesheetCompany() TimesheetCompany {
+	return TimesheetCompany{
+		Name:    web.AppConfig.DefaultString("company_name", "Beego Presence API"),
+		Address: web.AppConfig.DefaultString("company_address", ""),
+	}
+}
+
+// RenderTimesheetPDF renders a monthly attendance report as a signable A4 timesheet
+func RenderTimesheetPDF(w io.Writer, report *dto.MonthlyAttendanceResponse, company TimesheetCompany, generatedAt time.Time) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(fmt.Sprintf("Timesheet %s - %s", report.User.Name, report.Month), true)
+	pdf.SetCreator(company.Name, true)
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 20)
+
+	// The core fonts are cp1252 encoded, translate UTF-8 input
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.CellFormat(0, 10, fmt.Sprintf("Generated %s - Page %d/{nb}", generatedAt.In(PresenceLocation()).Format("2006-01-02 15:04"), pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AliasNbPages("")
+	pdf.AddPage()
+
+	// Company header
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 8, tr(company.Name), "", 1, "L", false, 0, "")
+	if company.Address != "" {
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.MultiCell(0, 5, tr(company.Address), "", "L", false)
+	}
+	pdf.Ln(2)
+	pdf.Line(15, pdf.GetY(), 195, pdf.GetY())
+	pdf.Ln(4)
+
+	// Title and employee details
+	month, _ := time.Parse(constants.ReportMonthLayout, report.Month)
+	pdf.SetFont("Helvetica", "B", 13)
+	pdf.CellFormat(0, 8, "Monthly Timesheet - "+month.Format("January 2006"), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 6, tr(fmt.Sprintf("Employee: %s (%s)", report.User.Name, report.User.Email)), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 6, fmt.Sprintf("Employee ID: %d", report.User.Id), "", 1, "L", false, 0, "")
+	pdf.Ln(3)
+
+	// Daily table
+	renderHeader := func() {
+		pdf.SetFont("Helvetica", "B", 8)
+		pdf.SetFillColor(230, 230, 230)
+		for _, column := range timesheetColumns {
+			pdf.CellFormat(column.width, 6, column.title, "1", 0, "C", true, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	renderHeader()
+	pdf.SetFont("Helvetica", "", 8)
+	for _, day := range report.Days {
+		if pdf.GetY() > 270 {
+			pdf.AddPage()
+			renderHeader()
+			pdf.SetFont("Helvetica", "", 8)
+		}
+		fill := day.Marker != ""
+		pdf.SetFillColor(245, 245, 245)
+		for _, column := range timesheetColumns {
+			pdf.CellFormat(column.width, 5.5, fitTimesheetText(pdf, tr(column.value(day)), column.width-2), "1", 0, "C", fill, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.Ln(4)
+
+	// Totals
+	totals := report.Totals
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(0, 6, "Totals", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	for _, line := range [][2]string{
+		{"Working days", fmt.Sprint(totals.WorkingDays)},
+		{"Days present", fmt.Sprint(totals.DaysPresent)},
+		{"Late arrivals", fmt.Sprint(totals.LateCount)},
+		{"Absences", fmt.Sprint(totals.AbsentCount)},
+		{"Leave days", fmt.Sprint(totals.LeaveCount)},
+		{"Total lateness", fmt.Sprintf("%d min", totals.TotalLateMinutes)},
+		{"Total worked", timesheetDuration(totals.TotalWorkedMinutes)},
+	} {
+		pdf.CellFormat(45, 5, line[0], "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 5, line[1], "", 1, "L", false, 0, "")
+	}
+
+	// Signature lines, kept together on one page
+	if pdf.GetY() > 240 {
+		pdf.AddPage()
+	}
+	pdf.Ln(14)
+	y := pdf.GetY()
+	for _, signature := range []struct {
+		x     float64
+		title string
+	}{{15, "Employee signature"}, {115, "Supervisor signature"}} {
+		pdf.Line(signature.x, y, signature.x+80, y)
+		pdf.SetXY(signature.x, y+1)
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.CellFormat(80, 5, signature.title, "", 2, "L", false, 0, "")
+		pdf.CellFormat(80, 5, "Date: ____________________", "", 0, "L", false, 0, "")
+	}
+
+	return pdf.Output(w)
+}
+
+// timesheetClock extracts the HH:MM part of an RFC 3339 report time
+func timesheetClock(value *string) string {
+	if value == nil {
+		return "-"
+	}
+	t, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return *value
+	}
+	return t.Format("15:04")
+}
+
+// timesheetWeekday returns the short weekday name of a YYYY-MM-DD date
+func timesheetWeekday(date string) string {
+	t, err := time.Parse(constants.ReportDateLayout, date)
+	if err != nil {
+		return ""
+	}
+	return t.Format("Mon")
+}
+
+// timesheetDuration formats minutes as H:MM
+func timesheetDuration(minutes int) string {
+	if minutes == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%02d", minutes/60, minutes%60)
+}
+
+// fitTimesheetText shortens text with an ellipsis until it fits into width with the current font
+func fitTimesheetText(pdf *fpdf.Fpdf, text string, width float64) string {
+	if pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	for len(text) > 0 && pdf.GetStringWidth(text+"...") > width {
+		text = text[:len(text)-1]
+	}
+	return text + "..."
+}
@@ -0,0 +1,82 @@
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret returns a new shared secret, base32 encoded as authenticator apps expect it
+func GenerateTOTPSecret() (string, error) {
+	secret := make([]byte, constants.TOTPSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// TOTPStep returns the time step a moment falls into
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(constants.TOTPPeriod/time.Second)
+}
+
+// TOTPCode computes the code of a time step as described in RFC 6238 with HMAC-SHA1
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimSpace(secret)))
+	if err != nil {
+		return "", fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation, RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulo := uint32(1)
+	for i := 0; i < constants.TOTPDigits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", constants.TOTPDigits, value%modulo), nil
+}
+
+// ValidateTOTP checks a code against the steps around now and returns the matching step
+func ValidateTOTP(secret, code string, now time.Time) (int64, bool) {
+	current := TOTPStep(now)
+	for step := current - constants.TOTPSkew; step <= current+constants.TOTPSkew; step++ {
+		expected, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// TOTPUri returns the otpauth URI authenticator apps enroll from, usually shown as QR code
+func TOTPUri(secret, account string) string {
+	label := url.PathEscape(constants.TOTPIssuer + ":" + account)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", constants.TOTPIssuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(constants.TOTPDigits))
+	query.Set("period", fmt.Sprint(int(constants.TOTPPeriod/time.Second)))
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
@@ -0,0 +1,72 @@
+package helpers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/server/web"
+)
+
+var twoFactorLimiter = NewRateLimiter(constants.TwoFactorRateLimit, constants.TwoFactorRateWindow)
+
+// IsTwoFactorRequired reports whether the policy in two_factor_required_roles requires two-factor authentication for a role
+func IsTwoFactorRequired(role string) bool {
+	for _, required := range strings.Split(web.AppConfig.DefaultString("two_factor_required_roles", constants.RoleAdmin), ",") {
+		if strings.TrimSpace(required) == role {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowTwoFactorAttempt reports whether the user may try another code, limiting guesses of the six digits
+func AllowTwoFactorAttempt(userId int, now time.Time) bool {
+	return twoFactorLimiter.Allow(strconv.Itoa(userId), now)
+}
+
+// GenerateRecoveryCodes returns new recovery codes to show to the user together with the hashes to store
+func GenerateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, constants.RecoveryCodeCount)
+	hashes := make([]string, 0, constants.RecoveryCodeCount)
+	for i := 0; i < constants.RecoveryCodeCount; i++ {
+		code, err := GenerateRandomHex(constants.RecoveryCodeBytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, code[:len(code)/2]+"-"+code[len(code)/2:])
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode hashes a recovery code, ignoring the case and the separator the code is shown with
+func HashRecoveryCode(code string) string {
+	return HashToken(strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", "")))
+}
+
+// VerifyTOTPCode checks a code of the authenticator app against the secret of the user. A code is only accepted once.
+func VerifyTOTPCode(user *models.User, code string, now time.Time) (bool, error) {
+	secret, err := DecryptSecret(user.TwoFactorSecret)
+	if err != nil {
+		return false, err
+	}
+	step, ok := ValidateTOTP(secret, code, now)
+	if !ok {
+		return false, nil
+	}
+	return models.UseTOTPStep(user.Id, step)
+}
+
+// VerifyTwoFactorCode checks the second factor of a user with two-factor authentication enabled,
+// which is either a code of the authenticator app or an unused recovery code.
+func VerifyTwoFactorCode(user *models.User, code string, now time.Time) (bool, error) {
+	code = strings.TrimSpace(code)
+	if len(code) == constants.TOTPDigits {
+		return VerifyTOTPCode(user, code, now)
+	}
+	return models.UseRecoveryCode(user.Id, HashRecoveryCode(code), now)
+}
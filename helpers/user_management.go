@@ -0,0 +1,43 @@
+package helpers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/models"
+)
+
+// ErrLastAdmin is returned when a change would leave no admin able to log in
+var ErrLastAdmin = errors.New("the last active admin can't be demoted or deactivated")
+
+// EnsureAdminRemains checks that another active admin is left when the user loses the admin role or is deactivated
+func EnsureAdminRemains(user *models.User) error {
+	if user.Role != constants.RoleAdmin || !user.IsActive() || user.ServiceAccount {
+		return nil
+	}
+	admins, err := models.CountActiveAdmins()
+	if err != nil {
+		return err
+	}
+	if admins <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// DeactivateAccount deactivates the user and revokes the API keys of the account. The account and its presence
+// history are kept, revoked keys stay revoked when the account is reactivated. The records, e.g. the audit entry,
+// are saved together with the deactivation.
+func DeactivateAccount(user *models.User, now time.Time, records ...models.ChangeRecord) error {
+	if user.IsActive() {
+		userUpdated := NewDomainEvent(constants.EventUserUpdated, func() interface{} {
+			return dto.FromUserModelToUserResponse(user, false, false, false)
+		})
+		if err := models.DeactivateUser(user, now, append(records, userUpdated)...); err != nil {
+			return err
+		}
+	}
+	return models.RevokeUserApiKeys(user.Id, now)
+}
@@ -17,10 +17,11 @@ var mapHelper = map[string]string{
 	"oneof":       "must be one of %s",
 	"contains":    "must contain '%s'",
 	"containsany": "must contain at least one symbol of '%s'",
+	"datetime":    "must match the format %s",
 	"securepwd":   "must contain at least 8 characters, including lowercase, uppercase, a number, and a special character",
 }
 
-var needParam = []string{"min", "max", "len", "oneof", "contains", "containsany"}
+var needParam = []string{"min", "max", "len", "oneof", "contains", "containsany", "datetime"}
 
 // ValidatePayloads validates a payload using go-playground validator
 func ValidatePayloads(payload interface{}) (map[string]string, error) {
@@ -0,0 +1,172 @@
+package helpers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/models"
+)
+
+// QueueWebhookDeliveries is the event handler queueing a delivery of the event to every subscribed webhook.
+// The payload is the event envelope, receivers recognize redelivered events by its id.
+func QueueWebhookDeliveries(envelope *EventEnvelope) error {
+	webhooks, err := models.GetActiveWebhooksByEvent(envelope.Event)
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	deliveries := make([]*models.WebhookDelivery, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		deliveries = append(deliveries, &models.WebhookDelivery{
+			Webhook:       webhook,
+			Event:         envelope.Event,
+			Payload:       string(payload),
+			Status:        constants.WebhookDeliveryStatusPending,
+			NextAttemptAt: now,
+		})
+	}
+	return models.CreateWebhookDeliveries(deliveries)
+}
+
+// SignWebhookPayload computes the signature header value of a payload.
+// Receivers recompute the HMAC-SHA256 of "<timestamp>.<body>" with the shared secret and compare it in constant time.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookBackoff returns the delay before the next attempt once the given number of attempts failed
+func WebhookBackoff(attempts int) time.Duration {
+	return ExponentialBackoff(attempts, constants.WebhookBaseBackoff, constants.WebhookMaxBackoff)
+}
+
+// GenerateRandomHex returns n random bytes encoded as hex, e.g. for secrets and identifiers
+func GenerateRandomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// StartWebhookDispatcher queues the deliveries of the recorded events and delivers them in the background.
+// Several instances of the API can run a dispatcher, claimed deliveries are not picked up twice.
+func StartWebhookDispatcher() {
+	eventHandlers.Handle(constants.EventAll, "webhooks", QueueWebhookDeliveries)
+
+	client := &http.Client{Timeout: constants.WebhookRequestTimeout}
+
+	go func() {
+		ticker := time.NewTicker(constants.WebhookPollInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			dispatchDueWebhookDeliveries(client)
+		}
+	}()
+}
+
+// dispatchDueWebhookDeliveries attempts every due delivery once, concurrently
+func dispatchDueWebhookDeliveries(client *http.Client) {
+	deliveries, err := models.ClaimDueWebhookDeliveries(time.Now(), constants.WebhookDeliveryLease, constants.WebhookBatchSize)
+	if err != nil {
+		log.Printf("Failed to claim webhook deliveries: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery *models.WebhookDelivery) {
+			defer wg.Done()
+			attemptWebhookDelivery(client, delivery)
+		}(delivery)
+	}
+	wg.Wait()
+}
+
+// attemptWebhookDelivery posts a delivery and records the outcome, scheduling a retry on failure
+func attemptWebhookDelivery(client *http.Client, delivery *models.WebhookDelivery) {
+	statusCode, err := postWebhook(client, delivery)
+	now := time.Now()
+
+	delivery.LastStatusCode = statusCode
+	delivery.UpdatedAt = now
+	switch {
+	case err == nil:
+		delivery.Status = constants.WebhookDeliveryStatusDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= constants.WebhookMaxAttempts:
+		delivery.Status = constants.WebhookDeliveryStatusFailed
+		delivery.LastError = err.Error()
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now.Add(WebhookBackoff(delivery.Attempts))
+	}
+
+	if err := models.UpdateWebhookDelivery(delivery); err != nil {
+		log.Printf("Failed to record webhook delivery %d: %v", delivery.Id, err)
+	}
+}
+
+// postWebhook sends the signed payload of a delivery, any status other than 2xx is an error
+func postWebhook(client *http.Client, delivery *models.WebhookDelivery) (int, error) {
+	if delivery.Webhook == nil {
+		return 0, fmt.Errorf("webhook of delivery %d not found", delivery.Id)
+	}
+
+	secret, err := DecryptSecret(delivery.Webhook.Secret)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decrypt the secret of webhook %d: %w", delivery.Webhook.Id, err)
+	}
+
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequest(http.MethodPost, delivery.Webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "beego-presence-api-webhook")
+	req.Header.Set(constants.WebhookEventHeader, delivery.Event)
+	req.Header.Set(constants.WebhookDeliveryHeader, strconv.Itoa(delivery.Id))
+	req.Header.Set(constants.WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(constants.WebhookSignatureHeader, SignWebhookPayload(secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, excerpt)
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	return resp.StatusCode, nil
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"os"
+
 	"github.com/snykk/beego-presence-api/database"
+	"github.com/snykk/beego-presence-api/helpers"
 	_ "github.com/snykk/beego-presence-api/routers"
 
 	beego "github.com/beego/beego/v2/server/web"
@@ -9,6 +12,17 @@ import (
 
 func main() {
 	database.InitDB()
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+	if err := helpers.CheckRequiredSecrets(); err != nil {
+		panic(err)
+	}
+	database.PrepareDB()
+	helpers.StartWebhookDispatcher()
+	helpers.StartLDAPSync()
+	helpers.StartNotifications()
+	helpers.StartOutboxDispatcher()
 	if beego.BConfig.RunMode == "dev" {
 		beego.BConfig.WebConfig.DirectoryIndex = true
 		beego.BConfig.WebConfig.StaticDir["/swagger"] = "swagger"
@@ -2,11 +2,15 @@ package middlewares
 
 import (
 	"errors"
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/snykk/beego-presence-api/constants"
 	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
 
+	"github.com/beego/beego/v2/client/orm"
 	"github.com/beego/beego/v2/server/web"
 	beecontext "github.com/beego/beego/v2/server/web/context"
 )
@@ -21,6 +25,13 @@ func RoleBasedMiddleware() web.FilterFunc {
 
 		// Get the user's token from the Authorization header
 		authHeader := ctx.Input.Header("Authorization")
+
+		// EventSource and WebSocket clients of the live feed can't set headers and pass the token as query parameter
+		if authHeader == "" && strings.HasSuffix(ctx.Request.URL.Path, "/presences/stream") {
+			if token := ctx.Input.Query(constants.PresenceFeedTokenParam); token != "" {
+				authHeader = "Bearer " + token
+			}
+		}
 		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
 			helpers.ErrorResponse(ctx.ResponseWriter, 401, "Unauthorized", errors.New("invalid token format"))
 			return
@@ -29,17 +40,16 @@ func RoleBasedMiddleware() web.FilterFunc {
 		// Extract the token part (remove the "Bearer " prefix)
 		token := strings.TrimPrefix(authHeader, "Bearer ")
 
-		// Validate the token
-		claims, err := helpers.ParseJWT(token)
-		if err != nil {
-			helpers.ErrorResponse(ctx.ResponseWriter, 401, "Unauthorized or expired token", err)
-			return
+		// Bearer tokens are either JWTs issued at login or API keys
+		var userId int
+		var userRole string
+		var ok bool
+		if helpers.IsApiKey(token) {
+			userId, userRole, ok = authenticateApiKey(ctx, token)
+		} else {
+			userId, userRole, ok = authenticateJWT(ctx, token)
 		}
-
-		//  extract the role from claiams
-		userRole, err := helpers.GetRoleFromMapClaims(claims)
-		if err != nil {
-			helpers.ErrorResponse(ctx.ResponseWriter, 500, "Internal server error", err)
+		if !ok {
 			return
 		}
 
@@ -53,19 +63,111 @@ func RoleBasedMiddleware() web.FilterFunc {
 			return
 		}
 
-		//  extract the role from claiams
-		userId, err := helpers.GetUseridFromMapClaims(claims)
-		if err != nil {
-			helpers.ErrorResponse(ctx.ResponseWriter, 500, "Internal server error", err)
-			return
-		}
-
 		// Continue to the next handler
 		ctx.Input.SetData(constants.CtxAuthenticatedUserId, userId)
 		ctx.Input.SetData(constants.CtxAuthenticatedUserRole, userRole)
 	}
 }
 
+// authenticateJWT validates a token issued at login against the account and returns the id and role of the user,
+// the error response is written when the request can't continue
+func authenticateJWT(ctx *beecontext.Context, token string) (int, string, bool) {
+	// Validate the token
+	claims, err := helpers.ParseJWT(token)
+	if err != nil {
+		helpers.ErrorResponse(ctx.ResponseWriter, 401, "Unauthorized or expired token", err)
+		return 0, "", false
+	}
+
+	// Check the token against the account, tokens issued before the last password change are revoked
+	userId, err := helpers.GetUseridFromMapClaims(claims)
+	if err != nil {
+		helpers.ErrorResponse(ctx.ResponseWriter, 500, "Internal server error", err)
+		return 0, "", false
+	}
+	user, err := models.GetUserAccountById(userId)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			helpers.ErrorResponse(ctx.ResponseWriter, 401, "Unauthorized", errors.New("user no longer exists"))
+			return 0, "", false
+		}
+		helpers.ErrorResponse(ctx.ResponseWriter, 500, "Internal server error", err)
+		return 0, "", false
+	}
+	if !user.IsActive() {
+		helpers.ErrorResponse(ctx.ResponseWriter, 401, "Unauthorized", errors.New("the account was deactivated"))
+		return 0, "", false
+	}
+	if helpers.GetTokenVersionFromMapClaims(claims) != user.TokenVersion {
+		helpers.ErrorResponse(ctx.ResponseWriter, 401, "Token has been revoked", errors.New("the password was changed, please log in again"))
+		return 0, "", false
+	}
+
+	isTwoFactorPath := strings.Contains(ctx.Request.URL.Path, constants.TwoFactorPath)
+	if helpers.GetScopeFromMapClaims(claims) == constants.TokenScopePreAuth {
+		// Pre-auth tokens can only set up two-factor authentication, once enabled the login is completed with a code
+		if user.TwoFactorEnabled || !isTwoFactorPath {
+			helpers.ErrorResponse(ctx.ResponseWriter, 403, "Two-factor authentication required", errors.New("complete the login with the two-factor code"))
+			return 0, "", false
+		}
+	} else if !checkAccountPolicies(ctx, user, isTwoFactorPath) {
+		return 0, "", false
+	}
+
+	//  extract the role from claiams
+	userRole, err := helpers.GetRoleFromMapClaims(claims)
+	if err != nil {
+		helpers.ErrorResponse(ctx.ResponseWriter, 500, "Internal server error", err)
+		return 0, "", false
+	}
+	return userId, userRole, true
+}
+
+// authenticateApiKey validates a personal API key or a key of a service account and checks that the key was granted
+// the scope of the request, the role is the current role of the account
+func authenticateApiKey(ctx *beecontext.Context, token string) (int, string, bool) {
+	key, user, err := helpers.AuthenticateApiKey(token, ctx.Input.IP(), time.Now())
+	if err != nil {
+		if err == helpers.ErrApiKeyInvalid {
+			helpers.ErrorResponse(ctx.ResponseWriter, 401, "Unauthorized", err)
+			return 0, "", false
+		}
+		helpers.ErrorResponse(ctx.ResponseWriter, 500, "Internal server error", err)
+		return 0, "", false
+	}
+
+	// Service accounts never log in, the password and two-factor policies only apply to personal keys
+	if !user.ServiceAccount && !checkAccountPolicies(ctx, user, false) {
+		return 0, "", false
+	}
+
+	scope, ok := helpers.ApiKeyScopeForRequest(ctx.Request.URL.Path, ctx.Request.Method)
+	if !ok || !key.HasScope(scope) {
+		helpers.ErrorResponse(ctx.ResponseWriter, 403, "Insufficient scope", fmt.Errorf("the API key wasn't granted access to %s %s", ctx.Request.Method, ctx.Request.URL.Path))
+		return 0, "", false
+	}
+
+	ctx.Input.SetData(constants.CtxAuthenticatedApiKeyId, key.Id)
+	return user.Id, user.Role, true
+}
+
+// checkAccountPolicies enforces the password change and two-factor policies of an account,
+// the two-factor setup endpoints stay available to comply with them
+func checkAccountPolicies(ctx *beecontext.Context, user *models.User, isTwoFactorPath bool) bool {
+	// Users with an initial password can only change it
+	if user.MustChangePassword && !isTwoFactorPath && !strings.HasSuffix(ctx.Request.URL.Path, constants.ChangePasswordPath) {
+		helpers.ErrorResponse(ctx.ResponseWriter, 403, "Password change required", errors.New("change the initial password before using the API"))
+		return false
+	}
+
+	// Roles the policy requires two-factor authentication for can only set it up, e.g. with tokens issued before the policy applied
+	if helpers.IsTwoFactorRequired(user.Role) && !user.TwoFactorEnabled && !isTwoFactorPath {
+		helpers.ErrorResponse(ctx.ResponseWriter, 403, "Two-factor authentication setup required", errors.New("enable two-factor authentication before using the API"))
+		return false
+	}
+	return true
+}
+
 // isRestrictedAccess checks if the access to the endpoint is restricted based on the role and method
 func isRestrictedAccess(url, method, role string) bool {
 	// Check if the URL contains "/departments" or "/schedules" and if the method is POST, PUT, or DELETE
@@ -76,17 +178,62 @@ func isRestrictedAccess(url, method, role string) bool {
 		}
 	}
 
+	// Verifying the presence history is only available to admins
+	if strings.HasSuffix(url, "/presences/verify") {
+		return role != constants.RoleAdmin
+	}
+
 	// Check if the URL contains "/presences"
 	if strings.Contains(url, "/presences") {
 		if method == "POST" {
-			// Only allow users to access the POST method for creating a presence
-			return role != constants.RoleEmployee
+			// Only allow employees and managers to access the POST method for creating a presence
+			return role != constants.RoleEmployee && role != constants.RoleManager
 		} else if method == "PUT" || method == "DELETE" {
 			// Only allow admins to access PUT and DELETE methods for updating or deleting presence
 			return role != constants.RoleAdmin
 		}
 	}
 
+	// Department reports are only available to admins
+	if strings.Contains(url, "/reports/departments") {
+		return role != constants.RoleAdmin
+	}
+
+	// Payroll templates and exports as well as webhooks are only available to admins
+	if strings.Contains(url, "/payroll") || strings.Contains(url, "/webhooks") {
+		return role != constants.RoleAdmin
+	}
+
+	// The notification send log is only available to admins, preferences to every user
+	if strings.Contains(url, "/notifications/logs") {
+		return role != constants.RoleAdmin
+	}
+
+	// Creating users, changing their role and deactivating them is only available to admins
+	if (strings.HasSuffix(strings.TrimSuffix(url, "/"), "/api/v1/users") && method == "POST") || strings.HasSuffix(url, "/role") || strings.HasSuffix(url, "/deactivate") || strings.HasSuffix(url, "/reactivate") {
+		return role != constants.RoleAdmin
+	}
+
+	// Deleted users, departments and schedules are only listed and restored by admins
+	if strings.HasSuffix(url, "/deleted") || strings.HasSuffix(url, "/restore") {
+		return role != constants.RoleAdmin
+	}
+
+	// Service accounts are only managed by admins
+	if strings.Contains(url, "/service-accounts") {
+		return role != constants.RoleAdmin
+	}
+
+	// Login audit records and lifting lockouts are only available to admins
+	if strings.Contains(url, "/login-attempts") || strings.HasSuffix(url, "/unlock") {
+		return role != constants.RoleAdmin
+	}
+
+	// The audit log is only available to admins
+	if strings.Contains(url, "/audit-logs") {
+		return role != constants.RoleAdmin
+	}
+
 	// For GET methods, all users (admin or user) are allowed
 	return false
 }
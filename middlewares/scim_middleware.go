@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/helpers"
+
+	"github.com/beego/beego/v2/server/web"
+	beecontext "github.com/beego/beego/v2/server/web/context"
+)
+
+// ScimAuthMiddleware checks the bearer token of the identity platform on the SCIM endpoints.
+// The token is the scim_token of app.conf, employee JWTs are not accepted.
+func ScimAuthMiddleware() web.FilterFunc {
+	return func(ctx *beecontext.Context) {
+		authHeader := ctx.Input.Header("Authorization")
+		if !strings.HasPrefix(authHeader, "Bearer ") || !helpers.IsValidScimToken(strings.TrimPrefix(authHeader, "Bearer ")) {
+			ctx.Output.Header("WWW-Authenticate", `Bearer realm="`+constants.ScimPath+`"`)
+			helpers.ScimErrorResponse(ctx.ResponseWriter, helpers.NewScimError(http.StatusUnauthorized, "", "invalid or missing SCIM bearer token"))
+			return
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// ApiKey is a personal API key of a user or a key of a service account. Only the SHA-256 of the key is stored.
+type ApiKey struct {
+	Id         int        `orm:"auto"`
+	User       *User      `orm:"rel(fk);on_delete(cascade)"`
+	Name       string     `orm:"size(100)"`
+	Prefix     string     `orm:"size(20)"`        // Leading characters of the key to identify it in listings
+	KeyHash    string     `orm:"size(64);unique"` // SHA-256 of the key, hex encoded
+	Scopes     string     `orm:"size(500)"`       // Comma separated list of the granted scopes
+	ExpiresAt  time.Time  `orm:"type(datetime)"`
+	LastUsedAt *time.Time `orm:"null;type(datetime)"`
+	LastUsedIp string     `orm:"size(45)"`
+	RevokedAt  *time.Time `orm:"null;type(datetime)"`
+	CreatedAt  time.Time  `orm:"auto_now_add;type(datetime)"`
+}
+
+// GetScopes returns the granted scopes of the key
+func (k *ApiKey) GetScopes() []string {
+	if k.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+// HasScope reports whether the key was granted the scope
+func (k *ApiKey) HasScope(scope string) bool {
+	for _, granted := range k.GetScopes() {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUsable reports whether the key is neither revoked nor expired
+func (k *ApiKey) IsUsable(now time.Time) bool {
+	return k.RevokedAt == nil && now.Before(k.ExpiresAt)
+}
+
+// CreateApiKey stores a new key
+func CreateApiKey(key *ApiKey) error {
+	o := orm.NewOrm()
+	_, err := o.Insert(key)
+	return err
+}
+
+// GetApiKeyByHash retrieves the key with the hash
+func GetApiKeyByHash(hash string) (*ApiKey, error) {
+	o := orm.NewOrm()
+	key := &ApiKey{KeyHash: hash}
+	if err := o.Read(key, "KeyHash"); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// GetApiKeyById retrieves a key
+func GetApiKeyById(id int) (*ApiKey, error) {
+	o := orm.NewOrm()
+	key := &ApiKey{Id: id}
+	if err := o.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// GetApiKeysByUserId retrieves the keys of a user, newest first
+func GetApiKeysByUserId(userId int) ([]*ApiKey, error) {
+	o := orm.NewOrm()
+	var keys []*ApiKey
+	_, err := o.QueryTable(new(ApiKey)).Filter("User__Id", userId).OrderBy("-Id").All(&keys)
+	return keys, err
+}
+
+// CountUsableApiKeys counts the keys of a user that are neither revoked nor expired
+func CountUsableApiKeys(userId int, now time.Time) (int64, error) {
+	o := orm.NewOrm()
+	return o.QueryTable(new(ApiKey)).Filter("User__Id", userId).Filter("RevokedAt__isnull", true).Filter("ExpiresAt__gt", now).Count()
+}
+
+// RevokeApiKey revokes a key, revoked keys are kept for the listings
+func RevokeApiKey(key *ApiKey, now time.Time) error {
+	o := orm.NewOrm()
+	key.RevokedAt = &now
+	_, err := o.Update(key, "RevokedAt")
+	return err
+}
+
+// RevokeUserApiKeys revokes every key of a user
+func RevokeUserApiKeys(userId int, now time.Time) error {
+	o := orm.NewOrm()
+	_, err := o.QueryTable(new(ApiKey)).Filter("User__Id", userId).Filter("RevokedAt__isnull", true).Update(orm.Params{"RevokedAt": now})
+	return err
+}
+
+// TouchApiKey records the last use of a key
+func TouchApiKey(key *ApiKey, now time.Time, ip string) error {
+	o := orm.NewOrm()
+	key.LastUsedAt = &now
+	key.LastUsedIp = ip
+	_, err := o.Update(key, "LastUsedAt", "LastUsedIp")
+	return err
+}
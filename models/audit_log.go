@@ -0,0 +1,105 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// AuditLog is the append-only record of a change to a user, department, schedule or presence. The actor is stored
+// by id, so the records outlive purged users.
+type AuditLog struct {
+	Id         int       `orm:"auto"`
+	ActorId    int       `orm:"index"` // User who made the change, 0 for changes without an authenticated user
+	Action     string    `orm:"size(20);index"`
+	EntityType string    `orm:"size(50);index"`
+	EntityId   int       `orm:"index"`
+	Changes    string    `orm:"type(text)"` // JSON encoded changed fields with their values before and after the change
+	Ip         string    `orm:"size(45)"`
+	CreatedAt  time.Time `orm:"auto_now_add;type(datetime);index"`
+}
+
+// AuditLogFilter narrows the audit records, zero values don't filter
+type AuditLogFilter struct {
+	ActorId    int
+	Action     string
+	EntityType string
+	EntityId   int
+	From       *time.Time // Inclusive
+	To         *time.Time // Exclusive
+	Limit      int
+}
+
+// AuditActor is who made a change and from where, the zero value for changes without an authenticated user
+type AuditActor struct {
+	Id int
+	Ip string
+}
+
+// AuditEntry builds the audit record of a change once it is saved, so the record can refer to generated ids.
+// It's inserted in the same transaction as the change, the records are never updated or deleted.
+type AuditEntry func() (*AuditLog, error)
+
+// insert builds the record and adds it to the audit log
+func (build AuditEntry) insert(tx orm.TxOrmer) error {
+	entry, err := build()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Insert(entry)
+	return err
+}
+
+// auditMove is a row moved to another department or schedule along with a change, e.g. the deletion of the one
+// it was assigned to
+type auditMove struct {
+	EntityId int
+	From     int
+	To       int
+}
+
+// insertMoveAudits records the moves of the rows in the audit log, with the changed field like the API names it
+func insertMoveAudits(tx orm.TxOrmer, actor AuditActor, entityType, field string, moves []auditMove) error {
+	for _, move := range moves {
+		changes, err := json.Marshal(map[string]map[string]int{field: {"before": move.From, "after": move.To}})
+		if err != nil {
+			return err
+		}
+		entry := &AuditLog{ActorId: actor.Id, Action: constants.AuditActionUpdate, EntityType: entityType, EntityId: move.EntityId, Changes: string(changes), Ip: actor.Ip}
+		if _, err := tx.Insert(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAuditLogs retrieves the most recent audit records matching the filter
+func GetAuditLogs(filter AuditLogFilter) ([]*AuditLog, error) {
+	o := orm.NewOrm()
+	query := o.QueryTable(new(AuditLog))
+	if filter.ActorId != 0 {
+		query = query.Filter("ActorId", filter.ActorId)
+	}
+	if filter.Action != "" {
+		query = query.Filter("Action", filter.Action)
+	}
+	if filter.EntityType != "" {
+		query = query.Filter("EntityType", filter.EntityType)
+	}
+	if filter.EntityId != 0 {
+		query = query.Filter("EntityId", filter.EntityId)
+	}
+	if filter.From != nil {
+		query = query.Filter("CreatedAt__gte", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Filter("CreatedAt__lt", *filter.To)
+	}
+
+	var entries []*AuditLog
+	_, err := query.OrderBy("-Id").Limit(filter.Limit).All(&entries)
+	return entries, err
+}
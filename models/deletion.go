@@ -0,0 +1,51 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// ErrUsersAssigned is returned when a department or schedule is deleted while users are still assigned to it
+var ErrUsersAssigned = errors.New("users are still assigned, reassign them first")
+
+// Dependents counts the rows depending on a department or schedule. Users have to be reassigned before the
+// deletion, schedules of a department are deleted with it and presences are kept as history.
+type Dependents struct {
+	Users     int64
+	Schedules int64
+	Presences int64
+}
+
+// CountDepartmentDependents counts the users and schedules of a department and the presences of its users
+func CountDepartmentDependents(id int) (Dependents, error) {
+	return countDepartmentDependents(orm.NewOrm(), id)
+}
+
+// CountScheduleDependents counts the users and presences of a schedule
+func CountScheduleDependents(id int) (Dependents, error) {
+	return countScheduleDependents(orm.NewOrm(), id)
+}
+
+func countDepartmentDependents(q orm.QueryExecutor, id int) (Dependents, error) {
+	var dependents Dependents
+	var err error
+	if dependents.Users, err = q.QueryTable(new(User)).Filter("Department__Id", id).Filter("DeletedAt__isnull", true).Count(); err != nil {
+		return dependents, err
+	}
+	if dependents.Schedules, err = q.QueryTable(new(Schedule)).Filter("Department__Id", id).Filter("DeletedAt__isnull", true).Count(); err != nil {
+		return dependents, err
+	}
+	dependents.Presences, err = q.QueryTable(new(Presence)).Filter("User__Department__Id", id).Count()
+	return dependents, err
+}
+
+func countScheduleDependents(q orm.QueryExecutor, id int) (Dependents, error) {
+	var dependents Dependents
+	var err error
+	if dependents.Users, err = q.QueryTable(new(User)).Filter("Schedule__Id", id).Filter("DeletedAt__isnull", true).Count(); err != nil {
+		return dependents, err
+	}
+	dependents.Presences, err = q.QueryTable(new(Presence)).Filter("Schedule__Id", id).Count()
+	return dependents, err
+}
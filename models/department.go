@@ -1,18 +1,52 @@
 package models
 
 import (
+	"strings"
 	"time"
 
+	"github.com/snykk/beego-presence-api/constants"
+
 	"github.com/beego/beego/v2/client/orm"
 )
 
 type Department struct {
-	Id        int         `orm:"auto"`
-	Name      string      `orm:"size(100)"`
-	Users     []*User     `orm:"reverse(many)"` // Reverse relationship with User
-	Schedules []*Schedule `orm:"reverse(many)"` // Reverse relationship with Schedule
-	CreatedAt time.Time   `orm:"auto_now_add;type(datetime)"`
-	UpdatedAt time.Time   `orm:"auto_now;type(datetime)"`
+	Id                  int         `orm:"auto"`
+	Name                string      `orm:"size(100)"`
+	AllowedEmailDomains string      `orm:"size(500)"`                 // Comma separated list of the email domains allowed to self-register, empty allows any
+	ScimExternalId      string      `orm:"size(255)"`                 // Id of the group at the identity platform provisioning it over SCIM
+	DeletedAt           *time.Time  `orm:"null;type(datetime);index"` // Deleted departments are hidden and purged after the retention period
+	Users               []*User     `orm:"reverse(many)"`             // Reverse relationship with User
+	Schedules           []*Schedule `orm:"reverse(many)"`             // Reverse relationship with Schedule
+	CreatedAt           time.Time   `orm:"auto_now_add;type(datetime)"`
+	UpdatedAt           time.Time   `orm:"auto_now;type(datetime)"`
+}
+
+// GetAllowedEmailDomains returns the email domains allowed to self-register into the department
+func (d *Department) GetAllowedEmailDomains() []string {
+	if d.AllowedEmailDomains == "" {
+		return []string{}
+	}
+	return strings.Split(d.AllowedEmailDomains, ",")
+}
+
+// AllowsEmail reports whether the email may self-register into the department
+func (d *Department) AllowsEmail(email string) bool {
+	domains := d.GetAllowedEmailDomains()
+	if len(domains) == 0 {
+		return true
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := email[at+1:]
+	for _, allowed := range domains {
+		if strings.EqualFold(domain, allowed) {
+			return true
+		}
+	}
+	return false
 }
 
 // func init() {
@@ -23,7 +57,7 @@ func GetAllDepartments(isIncludeUserList, isIncludeScheduleList bool) ([]*Depart
 	o := orm.NewOrm()
 	var departments []*Department
 	// Fetch all departments
-	_, err := o.QueryTable(new(Department)).All(&departments)
+	_, err := o.QueryTable(new(Department)).Filter("DeletedAt__isnull", true).All(&departments)
 	if err != nil {
 		return nil, err
 	}
@@ -31,15 +65,13 @@ func GetAllDepartments(isIncludeUserList, isIncludeScheduleList bool) ([]*Depart
 	// Load related Users and Schedules for each department
 	for i := range departments {
 		if isIncludeUserList {
-			_, err = o.LoadRelated(departments[i], "Users")
-			if err != nil {
+			if err := loadDepartmentUsers(o, departments[i]); err != nil {
 				return nil, err
 			}
 		}
 
 		if isIncludeScheduleList {
-			_, err = o.LoadRelated(departments[i], "Schedules")
-			if err != nil {
+			if err := loadDepartmentSchedules(o, departments[i]); err != nil {
 				return nil, err
 			}
 		}
@@ -55,17 +87,18 @@ func GetDepartmentById(id int, isIncludeUserList, isIncludeScheduleList bool) (*
 	if err != nil {
 		return nil, err
 	}
+	if department.DeletedAt != nil {
+		return nil, orm.ErrNoRows
+	}
 
 	if isIncludeUserList {
-		_, err = o.LoadRelated(department, "Users")
-		if err != nil {
+		if err := loadDepartmentUsers(o, department); err != nil {
 			return nil, err
 		}
 	}
 
 	if isIncludeScheduleList {
-		_, err = o.LoadRelated(department, "Schedules")
-		if err != nil {
+		if err := loadDepartmentSchedules(o, department); err != nil {
 			return nil, err
 		}
 	}
@@ -73,20 +106,84 @@ func GetDepartmentById(id int, isIncludeUserList, isIncludeScheduleList bool) (*
 	return department, nil
 }
 
-func CreateDepartment(department *Department) error {
-	o := orm.NewOrm()
-	_, err := o.Insert(department)
+// loadDepartmentUsers loads the users of the department that weren't deleted
+func loadDepartmentUsers(o orm.Ormer, department *Department) error {
+	_, err := o.QueryTable(new(User)).Filter("Department__Id", department.Id).Filter("DeletedAt__isnull", true).OrderBy("Id").All(&department.Users)
 	return err
 }
 
-func UpdateDepartment(department *Department) error {
-	o := orm.NewOrm()
-	_, err := o.Update(department)
+// loadDepartmentSchedules loads the schedules of the department that weren't deleted
+func loadDepartmentSchedules(o orm.Ormer, department *Department) error {
+	_, err := o.QueryTable(new(Schedule)).Filter("Department__Id", department.Id).Filter("DeletedAt__isnull", true).OrderBy("Id").All(&department.Schedules)
 	return err
 }
 
-func DeleteDepartment(id int) (int64, error) {
-	o := orm.NewOrm()
-	affectedRows, err := o.Delete(&Department{Id: id})
-	return affectedRows, err
+func CreateDepartment(department *Department, records ...ChangeRecord) error {
+	_, err := saveWithEvents(func(tx orm.TxOrmer) (int64, error) {
+		return tx.Insert(department)
+	}, records)
+	return err
+}
+
+func UpdateDepartment(department *Department, records ...ChangeRecord) error {
+	_, err := saveWithEvents(func(tx orm.TxOrmer) (int64, error) {
+		return tx.Update(department)
+	}, records)
+	return err
+}
+
+// DeleteDepartment soft-deletes the department together with its schedules, they are kept until they are purged.
+// With reassignTo the users and schedules are moved to that department first, in the same transaction, and the
+// moves are recorded in the audit log as changes by the actor. Departments with users are only deleted with
+// reassignTo, ErrUsersAssigned is returned otherwise.
+func DeleteDepartment(id int, reassignTo *Department, actor AuditActor, records ...ChangeRecord) (int64, error) {
+	return saveWithEvents(func(tx orm.TxOrmer) (int64, error) {
+		now := time.Now()
+		if reassignTo != nil {
+			if err := moveToDepartment(tx, new(User), constants.AuditEntityUser, id, reassignTo.Id, actor, now); err != nil {
+				return 0, err
+			}
+			if err := moveToDepartment(tx, new(Schedule), constants.AuditEntitySchedule, id, reassignTo.Id, actor, now); err != nil {
+				return 0, err
+			}
+		}
+
+		dependents, err := countDepartmentDependents(tx, id)
+		if err != nil {
+			return 0, err
+		}
+		if dependents.Users > 0 {
+			return 0, ErrUsersAssigned
+		}
+
+		if _, err := tx.QueryTable(new(Schedule)).Filter("Department__Id", id).Filter("DeletedAt__isnull", true).Update(orm.Params{"DeletedAt": now, "UpdatedAt": now}); err != nil {
+			return 0, err
+		}
+		return tx.QueryTable(new(Department)).Filter("Id", id).Filter("DeletedAt__isnull", true).Update(orm.Params{"DeletedAt": now, "UpdatedAt": now})
+	}, records)
+}
+
+// moveToDepartment moves the users or schedules that weren't deleted from a department to another and records the moves
+func moveToDepartment(tx orm.TxOrmer, model interface{}, entityType string, from, to int, actor AuditActor, now time.Time) error {
+	var ids orm.ParamsList
+	qs := tx.QueryTable(model).Filter("Department__Id", from).Filter("DeletedAt__isnull", true)
+	if _, err := qs.ForUpdate().ValuesFlat(&ids, "Id"); err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if _, err := qs.Update(orm.Params{"Department": to, "UpdatedAt": now}); err != nil {
+		return err
+	}
+	return insertMoveAudits(tx, actor, entityType, "department_id", movesOf(ids, from, to))
+}
+
+// movesOf lists the moves of the rows with the ids from one department or schedule to another
+func movesOf(ids orm.ParamsList, from, to int) []auditMove {
+	moves := make([]auditMove, 0, len(ids))
+	for _, id := range ids {
+		moves = append(moves, auditMove{EntityId: int(id.(int64)), From: from, To: to})
+	}
+	return moves
 }
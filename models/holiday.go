@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// Holiday represents a company-wide non-working day. The reports only read the holidays, they are maintained in the database.
+type Holiday struct {
+	Id        int       `orm:"auto"`
+	Date      time.Time `orm:"type(date);unique"`
+	Name      string    `orm:"size(100)"`
+	CreatedAt time.Time `orm:"auto_now_add;type(datetime)"`
+	UpdatedAt time.Time `orm:"auto_now;type(datetime)"`
+}
+
+// GetHolidaysBetween retrieves the holidays falling within the given date range (inclusive)
+func GetHolidaysBetween(start, end time.Time) ([]*Holiday, error) {
+	o := orm.NewOrm()
+	var holidays []*Holiday
+	_, err := o.QueryTable(new(Holiday)).
+		Filter("Date__gte", start.Format("2006-01-02")).
+		Filter("Date__lte", end.Format("2006-01-02")).
+		OrderBy("Date").
+		All(&holidays)
+	return holidays, err
+}
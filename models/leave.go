@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// Leave represents an approved absence of a user over a range of days. The reports only read the leaves, they are maintained
+// in the database.
+type Leave struct {
+	Id        int       `orm:"auto"`
+	User      *User     `orm:"rel(fk)"` // ForeignKey to User
+	StartDate time.Time `orm:"type(date)"`
+	EndDate   time.Time `orm:"type(date)"`
+	Reason    string    `orm:"size(255)"`
+	CreatedAt time.Time `orm:"auto_now_add;type(datetime)"`
+	UpdatedAt time.Time `orm:"auto_now;type(datetime)"`
+}
+
+// GetLeavesByUserIdBetween retrieves the leave records of a user overlapping the given date range (inclusive)
+func GetLeavesByUserIdBetween(userId int, start, end time.Time) ([]*Leave, error) {
+	o := orm.NewOrm()
+	var leaves []*Leave
+	_, err := o.QueryTable(new(Leave)).
+		Filter("User__Id", userId).
+		Filter("StartDate__lte", end.Format("2006-01-02")).
+		Filter("EndDate__gte", start.Format("2006-01-02")).
+		All(&leaves)
+	return leaves, err
+}
+
+// GetLeavesBetween retrieves the leave records of all users overlapping the given date range (inclusive)
+func GetLeavesBetween(start, end time.Time) ([]*Leave, error) {
+	o := orm.NewOrm()
+	var leaves []*Leave
+	_, err := o.QueryTable(new(Leave)).
+		Filter("StartDate__lte", end.Format("2006-01-02")).
+		Filter("EndDate__gte", start.Format("2006-01-02")).
+		All(&leaves)
+	return leaves, err
+}
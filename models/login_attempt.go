@@ -0,0 +1,107 @@
+package models
+
+import (
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// LoginAttempt is the audit record of a login attempt
+type LoginAttempt struct {
+	Id        int       `orm:"auto"`
+	User      *User     `orm:"null;rel(fk);on_delete(set_null)"` // Empty for unknown emails
+	Email     string    `orm:"size(100);index"`
+	Ip        string    `orm:"size(45);index"`
+	UserAgent string    `orm:"size(255)"`
+	Success   bool      `orm:"default(false)"`
+	Reason    string    `orm:"size(50)"`
+	CreatedAt time.Time `orm:"auto_now_add;type(datetime);index"`
+}
+
+// CreateLoginAttempt records a login attempt
+func CreateLoginAttempt(attempt *LoginAttempt) error {
+	o := orm.NewOrm()
+	_, err := o.Insert(attempt)
+	return err
+}
+
+// CountFailedLoginsByIp counts the failed logins from an IP since the given time, for the given reasons
+func CountFailedLoginsByIp(ip string, since time.Time, reasons []string) (int64, error) {
+	o := orm.NewOrm()
+	return o.QueryTable(new(LoginAttempt)).
+		Filter("Ip", ip).
+		Filter("Success", false).
+		Filter("Reason__in", reasons).
+		Filter("CreatedAt__gte", since).
+		Count()
+}
+
+// GetLoginAttempts retrieves the most recent login attempts, optionally filtered by user, email, IP and outcome
+func GetLoginAttempts(userId int, email, ip string, success *bool, limit int) ([]*LoginAttempt, error) {
+	o := orm.NewOrm()
+	query := o.QueryTable(new(LoginAttempt))
+	if userId != 0 {
+		query = query.Filter("User__Id", userId)
+	}
+	if email != "" {
+		query = query.Filter("Email__iexact", email)
+	}
+	if ip != "" {
+		query = query.Filter("Ip", ip)
+	}
+	if success != nil {
+		query = query.Filter("Success", *success)
+	}
+
+	var attempts []*LoginAttempt
+	_, err := query.OrderBy("-Id").Limit(limit).All(&attempts)
+	return attempts, err
+}
+
+// RegisterFailedLogin counts a failed password of the user and locks the account for lockout once maxFailures is reached.
+// The counter is incremented in the database, so concurrent guesses can't pass the limit.
+func RegisterFailedLogin(user *User, now time.Time, maxFailures int, lockout time.Duration) error {
+	o := orm.NewOrm()
+	if _, err := o.QueryTable(new(User)).Filter("Id", user.Id).Update(orm.Params{
+		"FailedLoginAttempts": orm.ColValue(orm.ColAdd, 1),
+		"LastFailedLoginAt":   now,
+	}); err != nil {
+		return err
+	}
+	if err := o.Read(user); err != nil {
+		return err
+	}
+	if user.FailedLoginAttempts < maxFailures {
+		return nil
+	}
+
+	// The lock replaces the delays, after it expires the account starts over
+	lockedUntil := now.Add(lockout)
+	user.LockedUntil = &lockedUntil
+	user.FailedLoginAttempts = 0
+	_, err := o.Update(user, "LockedUntil", "FailedLoginAttempts")
+	return err
+}
+
+// ResetFailedLogins clears the failed passwords of the user after a successful login
+func ResetFailedLogins(user *User) error {
+	if user.FailedLoginAttempts == 0 && user.LockedUntil == nil {
+		return nil
+	}
+	o := orm.NewOrm()
+	user.FailedLoginAttempts = 0
+	user.LastFailedLoginAt = nil
+	user.LockedUntil = nil
+	_, err := o.Update(user, "FailedLoginAttempts", "LastFailedLoginAt", "LockedUntil")
+	return err
+}
+
+// UnlockUser lifts the lockout of a user and clears the failed passwords
+func UnlockUser(id int) (int64, error) {
+	o := orm.NewOrm()
+	return o.QueryTable(new(User)).Filter("Id", id).Update(orm.Params{
+		"FailedLoginAttempts": 0,
+		"LastFailedLoginAt":   nil,
+		"LockedUntil":         nil,
+	})
+}
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// NotificationPreference stores whether a user receives a type of notification.
+// Notifications without a preference are enabled.
+type NotificationPreference struct {
+	Id        int       `orm:"auto"`
+	User      *User     `orm:"rel(fk);on_delete(cascade)"`
+	Type      string    `orm:"size(30)"`
+	Enabled   bool      `orm:"default(true)"`
+	UpdatedAt time.Time `orm:"auto_now;type(datetime)"`
+}
+
+// TableUnique allows a single preference per user and notification type
+func (p *NotificationPreference) TableUnique() [][]string {
+	return [][]string{{"User", "Type"}}
+}
+
+// NotificationLog records every attempt to send a notification
+type NotificationLog struct {
+	Id        int       `orm:"auto"`
+	User      *User     `orm:"null;rel(fk);on_delete(set_null)"`
+	Type      string    `orm:"size(30);index"`
+	Reference string    `orm:"size(100);index"` // Identifies the occasion, e.g. welcome:7, so a notification is sent only once per channel
+	Channel   string    `orm:"size(20);default(email)"`
+	Recipient string    `orm:"size(100)"`
+	Subject   string    `orm:"size(255)"`
+	Status    string    `orm:"size(20);index"` // sent or failed
+	Error     string    `orm:"type(text)"`
+	CreatedAt time.Time `orm:"auto_now_add;type(datetime)"`
+}
+
+// GetNotificationPreferences retrieves the stored preferences of a user
+func GetNotificationPreferences(userId int) ([]*NotificationPreference, error) {
+	o := orm.NewOrm()
+	var preferences []*NotificationPreference
+	_, err := o.QueryTable(new(NotificationPreference)).Filter("User__Id", userId).All(&preferences)
+	return preferences, err
+}
+
+// IsNotificationEnabled reports whether a user receives a type of notification
+func IsNotificationEnabled(userId int, notificationType string) (bool, error) {
+	o := orm.NewOrm()
+	preference := &NotificationPreference{}
+	err := o.QueryTable(new(NotificationPreference)).Filter("User__Id", userId).Filter("Type", notificationType).One(preference)
+	if err == orm.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return preference.Enabled, nil
+}
+
+// SaveNotificationPreferences stores the given preferences of a user, other types are left unchanged
+func SaveNotificationPreferences(userId int, enabled map[string]bool) error {
+	return orm.NewOrm().DoTx(func(ctx context.Context, tx orm.TxOrmer) error {
+		for notificationType, isEnabled := range enabled {
+			preference := &NotificationPreference{User: &User{Id: userId}, Type: notificationType}
+			if _, _, err := tx.ReadOrCreate(preference, "User", "Type"); err != nil {
+				return err
+			}
+			preference.Enabled = isEnabled
+			if _, err := tx.Update(preference, "Enabled", "UpdatedAt"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CreateNotificationLog records an attempt to send a notification
+func CreateNotificationLog(entry *NotificationLog) error {
+	o := orm.NewOrm()
+	_, err := o.Insert(entry)
+	return err
+}
+
+// IsNotificationSent reports whether the notification with the reference was already sent successfully through the channel
+func IsNotificationSent(reference, channel string) bool {
+	o := orm.NewOrm()
+	return o.QueryTable(new(NotificationLog)).Filter("Reference", reference).Filter("Channel", channel).Filter("Status", constants.NotificationStatusSent).Exist()
+}
+
+// GetNotificationFailures returns the failed attempts of the notification with the reference on the channel that failed
+// most often, and the time of the last failed attempt, which is zero if there is none
+func GetNotificationFailures(reference string) (int, time.Time, error) {
+	o := orm.NewOrm()
+	var entries []*NotificationLog
+	if _, err := o.QueryTable(new(NotificationLog)).Filter("Reference", reference).Filter("Status", constants.NotificationStatusFailed).All(&entries, "Channel", "CreatedAt"); err != nil {
+		return 0, time.Time{}, err
+	}
+
+	attempts := 0
+	var lastFailedAt time.Time
+	perChannel := make(map[string]int)
+	for _, entry := range entries {
+		perChannel[entry.Channel]++
+		attempts = max(attempts, perChannel[entry.Channel])
+		if entry.CreatedAt.After(lastFailedAt) {
+			lastFailedAt = entry.CreatedAt
+		}
+	}
+	return attempts, lastFailedAt, nil
+}
+
+// RunExclusively runs fn while holding the advisory lock with the id, unless another instance holds it already.
+// The lock is released when fn returns. It reports whether fn ran.
+func RunExclusively(lockId int64, fn func() error) (bool, error) {
+	ran := false
+	err := orm.NewOrm().DoTx(func(ctx context.Context, tx orm.TxOrmer) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", lockId).QueryRow(&locked); err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+		ran = true
+		return fn()
+	})
+	return ran, err
+}
+
+// GetNotificationLogs retrieves the most recent notification log entries, optionally filtered by user, type and status
+func GetNotificationLogs(userId int, notificationType, status string, limit int) ([]*NotificationLog, error) {
+	o := orm.NewOrm()
+	var entries []*NotificationLog
+	qs := o.QueryTable(new(NotificationLog))
+	if userId != 0 {
+		qs = qs.Filter("User__Id", userId)
+	}
+	if notificationType != "" {
+		qs = qs.Filter("Type", notificationType)
+	}
+	if status != "" {
+		qs = qs.Filter("Status", status)
+	}
+	_, err := qs.OrderBy("-Id").Limit(limit).All(&entries)
+	return entries, err
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// ErrOidcStateInvalid is returned for login states that are unknown, expired or already used
+var ErrOidcStateInvalid = errors.New("invalid or expired login state")
+
+// OidcLoginState holds the secrets of a login started at the identity provider until the callback
+type OidcLoginState struct {
+	Id           int       `orm:"auto"`
+	State        string    `orm:"size(64);unique"`
+	Nonce        string    `orm:"size(64)"`
+	CodeVerifier string    `orm:"size(128)"` // PKCE verifier, only its hash is sent to the provider before the code exchange
+	ExpiresAt    time.Time `orm:"type(datetime)"`
+	CreatedAt    time.Time `orm:"auto_now_add;type(datetime)"`
+}
+
+// CreateOidcLoginState stores the state of a started login
+func CreateOidcLoginState(state *OidcLoginState) error {
+	o := orm.NewOrm()
+	_, err := o.Insert(state)
+	return err
+}
+
+// ConsumeOidcLoginState removes and returns an unexpired login state, so every state completes a single login.
+// Expired states are removed along the way.
+func ConsumeOidcLoginState(state string, now time.Time) (*OidcLoginState, error) {
+	loginState := &OidcLoginState{}
+	err := orm.NewOrm().DoTx(func(ctx context.Context, tx orm.TxOrmer) error {
+		if _, err := tx.QueryTable(new(OidcLoginState)).Filter("ExpiresAt__lte", now).Delete(); err != nil {
+			return err
+		}
+
+		err := tx.QueryTable(new(OidcLoginState)).Filter("State", state).ForUpdate().One(loginState)
+		if err == orm.ErrNoRows {
+			return ErrOidcStateInvalid
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.Delete(loginState)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loginState, nil
+}
+
+// GetUserByOidcSubject retrieves the user linked to a subject of the identity provider
+func GetUserByOidcSubject(subject string) (*User, error) {
+	o := orm.NewOrm()
+	user := &User{}
+	if err := o.QueryTable(new(User)).Filter("OidcSubject", subject).Filter("DeletedAt__isnull", true).One(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// LinkUserOidcSubject links an existing user to a subject of the identity provider, which also verifies the email
+func LinkUserOidcSubject(user *User, subject string) error {
+	o := orm.NewOrm()
+	user.OidcSubject = subject
+	user.EmailVerified = true
+	_, err := o.Update(user, "OidcSubject", "EmailVerified", "UpdatedAt")
+	return err
+}
+
+// GetDepartmentByName retrieves a department by its name, ignoring the case
+func GetDepartmentByName(name string) (*Department, error) {
+	o := orm.NewOrm()
+	department := &Department{}
+	if err := o.QueryTable(new(Department)).Filter("Name__iexact", name).Filter("DeletedAt__isnull", true).One(department); err != nil {
+		return nil, err
+	}
+	return department, nil
+}
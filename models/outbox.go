@@ -0,0 +1,141 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// OutboxEvent is a domain event recorded in the same transaction as the change it describes
+type OutboxEvent struct {
+	Id             int        `orm:"auto"`
+	Event          string     `orm:"size(50)"`
+	Payload        string     `orm:"type(text)"`     // JSON encoded data of the event
+	Status         string     `orm:"size(20);index"` // pending, published or dead
+	DeliveredSinks string     `orm:"size(255)"`      // Comma separated sinks that already received the event
+	Attempts       int        `orm:"default(0)"`
+	NextAttemptAt  time.Time  `orm:"type(datetime);index"`
+	LastError      string     `orm:"type(text)"`
+	PublishedAt    *time.Time `orm:"null;type(datetime)"`
+	CreatedAt      time.Time  `orm:"auto_now_add;type(datetime)"`
+}
+
+// DomainEvent builds an outbox event once the change it describes is saved, so the event can refer to generated ids
+type DomainEvent func() (*OutboxEvent, error)
+
+// IsDeliveredTo reports whether the sink already received the event
+func (e *OutboxEvent) IsDeliveredTo(sink string) bool {
+	for _, delivered := range strings.Split(e.DeliveredSinks, ",") {
+		if delivered == sink {
+			return true
+		}
+	}
+	return false
+}
+
+// MarkDeliveredTo records that the sink received the event
+func (e *OutboxEvent) MarkDeliveredTo(sink string) {
+	if e.IsDeliveredTo(sink) {
+		return
+	}
+	if e.DeliveredSinks == "" {
+		e.DeliveredSinks = sink
+		return
+	}
+	e.DeliveredSinks += "," + sink
+}
+
+// ChangeRecord is recorded in the same transaction as the change it describes, once the change is saved:
+// a DomainEvent in the outbox or an AuditEntry in the audit log
+type ChangeRecord interface {
+	insert(tx orm.TxOrmer) error
+}
+
+// insert builds the event and adds it to the outbox
+func (build DomainEvent) insert(tx orm.TxOrmer) error {
+	event, err := build()
+	if err != nil {
+		return err
+	}
+	event.Status = constants.OutboxStatusPending
+	event.NextAttemptAt = time.Now()
+	_, err = tx.Insert(event)
+	return err
+}
+
+// saveWithEvents runs a change and records its events and audit entries in a single transaction.
+// They are only recorded when the change affected at least one row.
+func saveWithEvents(change func(tx orm.TxOrmer) (int64, error), records []ChangeRecord) (int64, error) {
+	var affectedRows int64
+	err := orm.NewOrm().DoTx(func(ctx context.Context, tx orm.TxOrmer) error {
+		var err error
+		affectedRows, err = change(tx)
+		if err != nil || affectedRows == 0 {
+			return err
+		}
+		return insertChangeRecords(tx, records)
+	})
+	return affectedRows, err
+}
+
+// insertChangeRecords builds and inserts the records within the given transaction
+func insertChangeRecords(tx orm.TxOrmer, records []ChangeRecord) error {
+	for _, record := range records {
+		if err := record.insert(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordEvents records events that are not tied to a change of a model
+func RecordEvents(events ...DomainEvent) error {
+	return orm.NewOrm().DoTx(func(ctx context.Context, tx orm.TxOrmer) error {
+		for _, event := range events {
+			if err := event.insert(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ClaimDueOutboxEvents leases the pending events whose next attempt is due and counts the attempt.
+// Rows locked by another dispatcher are skipped, and the lease hides the claimed rows until it has passed,
+// so an event is published again if its dispatcher stops before recording the result.
+func ClaimDueOutboxEvents(now time.Time, lease time.Duration, limit int) ([]*OutboxEvent, error) {
+	o := orm.NewOrm()
+
+	var ids orm.ParamsList
+	_, err := o.Raw(`
+		UPDATE outbox_event
+		SET next_attempt_at = ?, attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM outbox_event
+			WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id`,
+		now.Add(lease), constants.OutboxStatusPending, now, limit,
+	).ValuesFlat(&ids)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	var events []*OutboxEvent
+	_, err = o.QueryTable(new(OutboxEvent)).Filter("Id__in", ids...).OrderBy("Id").All(&events)
+	return events, err
+}
+
+// UpdateOutboxEvent stores the result of a publishing attempt
+func UpdateOutboxEvent(event *OutboxEvent) error {
+	o := orm.NewOrm()
+	_, err := o.Update(event, "Status", "DeliveredSinks", "NextAttemptAt", "LastError", "PublishedAt")
+	return err
+}
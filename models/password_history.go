@@ -0,0 +1,59 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// PasswordHistory keeps the hashes of the previous passwords of a user to prevent their reuse
+type PasswordHistory struct {
+	Id           int       `orm:"auto"`
+	User         *User     `orm:"rel(fk);on_delete(cascade)"`
+	PasswordHash string    `orm:"size(255)"`
+	CreatedAt    time.Time `orm:"auto_now_add;type(datetime)"`
+}
+
+// GetPasswordHistory retrieves the most recent previous password hashes of a user
+func GetPasswordHistory(userId int) ([]*PasswordHistory, error) {
+	o := orm.NewOrm()
+	var history []*PasswordHistory
+	_, err := o.QueryTable(new(PasswordHistory)).Filter("User__Id", userId).OrderBy("-Id").Limit(constants.PasswordHistorySize).All(&history)
+	return history, err
+}
+
+// ChangeUserPassword sets a new password, keeping the previous one in the history
+func ChangeUserPassword(user *User, hashedPassword string, now time.Time) error {
+	return orm.NewOrm().DoTx(func(ctx context.Context, tx orm.TxOrmer) error {
+		return setUserPassword(tx, user, hashedPassword, now)
+	})
+}
+
+// setUserPassword stores a new password within a transaction. The previous password moves to the history, which is
+// trimmed to its configured size, and the token version is raised so the tokens issued before are no longer accepted. A lockout after failed logins is lifted as well.
+func setUserPassword(tx orm.TxOrmer, user *User, hashedPassword string, now time.Time) error {
+	if _, err := tx.Insert(&PasswordHistory{User: user, PasswordHash: user.Password}); err != nil {
+		return err
+	}
+
+	var kept orm.ParamsList
+	if _, err := tx.QueryTable(new(PasswordHistory)).Filter("User__Id", user.Id).OrderBy("-Id").Limit(constants.PasswordHistorySize).ValuesFlat(&kept, "Id"); err != nil {
+		return err
+	}
+	if _, err := tx.QueryTable(new(PasswordHistory)).Filter("User__Id", user.Id).Exclude("Id__in", kept...).Delete(); err != nil {
+		return err
+	}
+
+	user.Password = hashedPassword
+	user.TokenVersion++
+	user.MustChangePassword = false
+	user.PasswordChangedAt = &now
+	user.FailedLoginAttempts = 0
+	user.LastFailedLoginAt = nil
+	user.LockedUntil = nil
+	_, err := tx.Update(user, "Password", "TokenVersion", "MustChangePassword", "PasswordChangedAt", "FailedLoginAttempts", "LastFailedLoginAt", "LockedUntil", "UpdatedAt")
+	return err
+}
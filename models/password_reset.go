@@ -0,0 +1,80 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// ErrPasswordResetTokenInvalid is returned for unknown, used and expired reset tokens alike
+var ErrPasswordResetTokenInvalid = errors.New("invalid or expired password reset token")
+
+// PasswordResetToken is a single-use token to set a new password, only the SHA-256 hash of the token is stored
+type PasswordResetToken struct {
+	Id        int        `orm:"auto"`
+	User      *User      `orm:"rel(fk);on_delete(cascade)"`
+	TokenHash string     `orm:"size(64);unique"`
+	ExpiresAt time.Time  `orm:"type(datetime)"`
+	UsedAt    *time.Time `orm:"null;type(datetime)"`
+	CreatedAt time.Time  `orm:"auto_now_add;type(datetime)"`
+}
+
+// CreatePasswordResetToken inserts a new reset token
+func CreatePasswordResetToken(token *PasswordResetToken) error {
+	o := orm.NewOrm()
+	_, err := o.Insert(token)
+	return err
+}
+
+// GetValidPasswordResetToken retrieves an unused and unexpired reset token
+func GetValidPasswordResetToken(tokenHash string, now time.Time) (*PasswordResetToken, error) {
+	o := orm.NewOrm()
+	token := &PasswordResetToken{}
+	err := o.QueryTable(new(PasswordResetToken)).
+		Filter("TokenHash", tokenHash).
+		Filter("UsedAt__isnull", true).
+		Filter("ExpiresAt__gt", now).
+		One(token)
+	if err == orm.ErrNoRows {
+		return nil, ErrPasswordResetTokenInvalid
+	}
+	return token, err
+}
+
+// ResetPasswordWithToken sets the password of the owner of a valid token and invalidates all outstanding tokens of the user.
+// The token row is locked, so a token can't be used twice by concurrent requests.
+func ResetPasswordWithToken(tokenHash, hashedPassword string, now time.Time) (*User, error) {
+	var user *User
+	err := orm.NewOrm().DoTx(func(ctx context.Context, tx orm.TxOrmer) error {
+		token := &PasswordResetToken{}
+		err := tx.QueryTable(new(PasswordResetToken)).
+			Filter("TokenHash", tokenHash).
+			Filter("UsedAt__isnull", true).
+			Filter("ExpiresAt__gt", now).
+			ForUpdate().
+			One(token)
+		if err == orm.ErrNoRows {
+			return ErrPasswordResetTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		user = &User{Id: token.User.Id}
+		if err := tx.Read(user); err != nil {
+			return err
+		}
+		if err := setUserPassword(tx, user, hashedPassword, now); err != nil {
+			return err
+		}
+
+		_, err = tx.QueryTable(new(PasswordResetToken)).
+			Filter("User__Id", user.Id).
+			Filter("UsedAt__isnull", true).
+			Update(orm.Params{"UsedAt": now})
+		return err
+	})
+	return user, err
+}
@@ -0,0 +1,196 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/lib/pq"
+)
+
+// ErrPayrollPeriodExported is returned when the payroll period of a department has already been exported
+var ErrPayrollPeriodExported = errors.New("payroll period already exported")
+
+// PayrollField maps a column of the payroll file to an attendance value
+type PayrollField struct {
+	Code     string `json:"code"`               // Column code expected by the payroll system, used as header
+	Source   string `json:"source"`             // Attendance value the column is filled with
+	Bucket   string `json:"bucket,omitempty"`   // Lateness bucket code for bucket sources
+	Value    string `json:"value,omitempty"`    // Fixed value for the literal source
+	Unit     string `json:"unit,omitempty"`     // minutes or hours for duration sources
+	Decimals int    `json:"decimals,omitempty"` // Number of decimals of numeric values
+	Width    int    `json:"width,omitempty"`    // Column width in fixed-width files
+	Align    string `json:"align,omitempty"`    // left or right alignment in fixed-width files
+	Pad      string `json:"pad,omitempty"`      // Padding character in fixed-width files
+}
+
+// PayrollLateBucket is a deduction rule applied to late check-ins within a range of late minutes
+type PayrollLateBucket struct {
+	Code       string  `json:"code"`
+	MinMinutes int     `json:"min_minutes"`           // Inclusive lower bound of late minutes
+	MaxMinutes int     `json:"max_minutes,omitempty"` // Inclusive upper bound of late minutes, 0 means unbounded
+	Amount     float64 `json:"amount"`                // Deduction per late day, or per late minute when PerMinute is set
+	PerMinute  bool    `json:"per_minute,omitempty"`
+}
+
+// PayrollTemplate describes the layout of the files ingested by a payroll system
+type PayrollTemplate struct {
+	Id              int       `orm:"auto"`
+	Name            string    `orm:"size(100);unique"`
+	Format          string    `orm:"size(10)"` // csv or fixed
+	Delimiter       string    `orm:"size(1)"`  // Field delimiter of CSV files
+	IncludeHeader   bool      `orm:"default(true)"`
+	RoundingMode    string    `orm:"size(10)"`   // Rounding of the daily durations: none, up, down or nearest
+	RoundingMinutes int       `orm:"default(1)"` // Rounding unit in minutes
+	Fields          string    `orm:"type(text)"` // JSON encoded list of PayrollField
+	LateBuckets     string    `orm:"type(text)"` // JSON encoded list of PayrollLateBucket
+	CreatedAt       time.Time `orm:"auto_now_add;type(datetime)"`
+	UpdatedAt       time.Time `orm:"auto_now;type(datetime)"`
+}
+
+// PayrollExport records a payroll period file generated for a department
+type PayrollExport struct {
+	Id          int              `orm:"auto"`
+	Template    *PayrollTemplate `orm:"null;rel(fk);on_delete(set_null)"` // Template the file was generated with
+	Department  *Department      `orm:"rel(fk)"`
+	Period      string           `orm:"size(7)"` // Exported month (YYYY-MM)
+	RecordCount int
+	Filename    string    `orm:"size(255)"`
+	Content     string    `orm:"type(text)"` // Generated file, kept so it can be downloaded again
+	CreatedAt   time.Time `orm:"auto_now_add;type(datetime)"`
+}
+
+// TableUnique makes sure a period of a department can only be exported once
+func (e *PayrollExport) TableUnique() [][]string {
+	return [][]string{{"Department", "Period"}}
+}
+
+// GetFields decodes the field mapping of the template
+func (t *PayrollTemplate) GetFields() ([]PayrollField, error) {
+	var fields []PayrollField
+	if t.Fields == "" {
+		return fields, nil
+	}
+	err := json.Unmarshal([]byte(t.Fields), &fields)
+	return fields, err
+}
+
+// SetFields encodes the field mapping of the template
+func (t *PayrollTemplate) SetFields(fields []PayrollField) error {
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	t.Fields = string(encoded)
+	return nil
+}
+
+// GetLateBuckets decodes the lateness deduction rules of the template
+func (t *PayrollTemplate) GetLateBuckets() ([]PayrollLateBucket, error) {
+	var buckets []PayrollLateBucket
+	if t.LateBuckets == "" {
+		return buckets, nil
+	}
+	err := json.Unmarshal([]byte(t.LateBuckets), &buckets)
+	return buckets, err
+}
+
+// SetLateBuckets encodes the lateness deduction rules of the template
+func (t *PayrollTemplate) SetLateBuckets(buckets []PayrollLateBucket) error {
+	encoded, err := json.Marshal(buckets)
+	if err != nil {
+		return err
+	}
+	t.LateBuckets = string(encoded)
+	return nil
+}
+
+// GetAllPayrollTemplates retrieves all payroll templates
+func GetAllPayrollTemplates() ([]*PayrollTemplate, error) {
+	o := orm.NewOrm()
+	var templates []*PayrollTemplate
+	_, err := o.QueryTable(new(PayrollTemplate)).OrderBy("Id").All(&templates)
+	return templates, err
+}
+
+// GetPayrollTemplateById retrieves a payroll template by ID
+func GetPayrollTemplateById(id int) (*PayrollTemplate, error) {
+	o := orm.NewOrm()
+	template := &PayrollTemplate{Id: id}
+	err := o.Read(template)
+	if err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// CreatePayrollTemplate inserts a new payroll template
+func CreatePayrollTemplate(template *PayrollTemplate) error {
+	o := orm.NewOrm()
+	_, err := o.Insert(template)
+	return err
+}
+
+// UpdatePayrollTemplate updates an existing payroll template
+func UpdatePayrollTemplate(template *PayrollTemplate) error {
+	o := orm.NewOrm()
+	_, err := o.Update(template)
+	return err
+}
+
+// DeletePayrollTemplate deletes a payroll template by ID
+func DeletePayrollTemplate(id int) (int64, error) {
+	o := orm.NewOrm()
+	affectedRows, err := o.Delete(&PayrollTemplate{Id: id})
+	return affectedRows, err
+}
+
+// GetPayrollExports retrieves the payroll exports without their content, optionally filtered by department (0 means all)
+func GetPayrollExports(departmentId int) ([]*PayrollExport, error) {
+	o := orm.NewOrm()
+	var exports []*PayrollExport
+	qs := o.QueryTable(new(PayrollExport)).RelatedSel("Department")
+	if departmentId != 0 {
+		qs = qs.Filter("Department__Id", departmentId)
+	}
+	_, err := qs.OrderBy("-Period", "Department__Id").All(&exports, "Id", "Template", "Department", "Period", "RecordCount", "Filename", "CreatedAt")
+	return exports, err
+}
+
+// GetPayrollExportById retrieves a payroll export including its content
+func GetPayrollExportById(id int) (*PayrollExport, error) {
+	o := orm.NewOrm()
+	export := &PayrollExport{}
+	err := o.QueryTable(new(PayrollExport)).Filter("Id", id).RelatedSel("Department").One(export)
+	if err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// IsPayrollPeriodExported checks whether the period of a department has already been exported
+func IsPayrollPeriodExported(departmentId int, period string) bool {
+	o := orm.NewOrm()
+	return o.QueryTable(new(PayrollExport)).Filter("Department__Id", departmentId).Filter("Period", period).Exist()
+}
+
+// CreatePayrollExport records a payroll export. It returns ErrPayrollPeriodExported when the period
+// was exported concurrently, relying on the unique constraint of department and period.
+func CreatePayrollExport(export *PayrollExport) error {
+	o := orm.NewOrm()
+	_, err := o.Insert(export)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrPayrollPeriodExported
+	}
+	return err
+}
+
+// DeletePayrollExport deletes a payroll export record so the period can be exported again
+func DeletePayrollExport(id int) (int64, error) {
+	o := orm.NewOrm()
+	affectedRows, err := o.Delete(&PayrollExport{Id: id})
+	return affectedRows, err
+}
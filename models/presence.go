@@ -3,6 +3,8 @@ package models
 import (
 	"time"
 
+	"github.com/snykk/beego-presence-api/constants"
+
 	"github.com/beego/beego/v2/client/orm"
 )
 
@@ -21,6 +23,11 @@ type Presence struct {
 // 	orm.RegisterModel(new(Presence))
 // }
 
+// TableIndex speeds up the presences of a user within a period, e.g. for the reports and the duplicate check-in check
+func (p *Presence) TableIndex() [][]string {
+	return [][]string{{"User", "CreatedAt"}}
+}
+
 // GetAllPresences retrieves all presence records
 func GetAllPresences() ([]*Presence, error) {
 	o := orm.NewOrm()
@@ -62,31 +69,117 @@ func GetPresencesByUserId(userId int) ([]*Presence, error) {
 	return presences, err
 }
 
-// CreatePresence inserts a new presence record
-func CreatePresence(p *Presence) error {
+// GetPresencesByDepartmentId retrieves all presence records of the users of a department
+func GetPresencesByDepartmentId(departmentId int) ([]*Presence, error) {
 	o := orm.NewOrm()
-	_, err := o.Insert(p)
-	return err
+	var presences []*Presence
+	_, err := o.QueryTable(new(Presence)).
+		Filter("User__Department__Id", departmentId).
+		RelatedSel("User", "Schedule").
+		All(&presences)
+	return presences, err
 }
 
-// UpdatePresence updates an existing presence record
-func UpdatePresence(p *Presence) error {
+// GetPresencesByUserIdBetween retrieves the presence records of a user created within [start, end)
+func GetPresencesByUserIdBetween(userId int, start, end time.Time) ([]*Presence, error) {
 	o := orm.NewOrm()
-	_, err := o.Update(p)
-	return err
+	var presences []*Presence
+	_, err := o.QueryTable(new(Presence)).
+		Filter("User__Id", userId).
+		Filter("CreatedAt__gte", start).
+		Filter("CreatedAt__lt", end).
+		RelatedSel("User", "Schedule").
+		OrderBy("CreatedAt").
+		All(&presences)
+	return presences, err
 }
 
-// DeletePresence deletes a presence record by ID
-func DeletePresence(id int) (int64, error) {
+// ForEachPresenceBatch walks presence records ordered by ID in batches of batchSize, calling fn for every batch.
+// A userId of 0 walks the presences of all users. Only one batch is held in memory at a time.
+func ForEachPresenceBatch(userId int, batchSize int, fn func(batch []*Presence) error) error {
 	o := orm.NewOrm()
-	affectedRows, err := o.Delete(&Presence{Id: id})
-	return affectedRows, err
+	lastId := 0
+	for {
+		qs := o.QueryTable(new(Presence)).Filter("Id__gt", lastId)
+		if userId != 0 {
+			qs = qs.Filter("User__Id", userId)
+		}
+
+		var batch []*Presence
+		if _, err := qs.RelatedSel("User", "Schedule").OrderBy("Id").Limit(batchSize).All(&batch); err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+		lastId = batch[len(batch)-1].Id
+	}
+}
+
+// CreatePresence inserts a new presence record together with its first revision, its events and audit entries
+func CreatePresence(p *Presence, sign RevisionSigner, records ...ChangeRecord) error {
+	_, err := saveWithEvents(func(tx orm.TxOrmer) (int64, error) {
+		affectedRows, err := tx.Insert(p)
+		if err != nil {
+			return affectedRows, err
+		}
+
+		// The revision records the creation time as stored by the database
+		stored := &Presence{Id: p.Id}
+		if err := tx.Read(stored); err != nil {
+			return affectedRows, err
+		}
+		p.CreatedAt = stored.CreatedAt
+		return affectedRows, recordPresenceChange(tx, stored, stored, constants.PresenceRevisionCreated, p.User.Id, sign)
+	}, records)
+	return err
+}
+
+// UpdatePresence updates an existing presence record together with its revision, its events and audit entries
+func UpdatePresence(p *Presence, actorId int, sign RevisionSigner, records ...ChangeRecord) error {
+	_, err := saveWithEvents(func(tx orm.TxOrmer) (int64, error) {
+		stored := &Presence{}
+		if err := tx.QueryTable(new(Presence)).Filter("Id", p.Id).ForUpdate().One(stored); err != nil {
+			return 0, err
+		}
+		if err := recordPresenceChange(tx, stored, p, constants.PresenceRevisionUpdated, actorId, sign); err != nil {
+			return 0, err
+		}
+		return tx.Update(p)
+	}, records)
+	return err
+}
+
+// DeletePresence deletes a presence record by ID, its history ends with a deletion revision.
+// The events and audit entries are only recorded when it existed.
+func DeletePresence(id int, actorId int, sign RevisionSigner, records ...ChangeRecord) (int64, error) {
+	return saveWithEvents(func(tx orm.TxOrmer) (int64, error) {
+		stored := &Presence{}
+		err := tx.QueryTable(new(Presence)).Filter("Id", id).ForUpdate().One(stored)
+		if err == orm.ErrNoRows {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if err := recordPresenceChange(tx, stored, stored, constants.PresenceRevisionDeleted, actorId, sign); err != nil {
+			return 0, err
+		}
+		return tx.Delete(stored)
+	}, records)
 }
 
 // CheckPresenceExistsByUserAndType checks if a presence record exists for a given user ID, presence type, and date
 func CheckPresenceExistsByUserAndType(userId int, presenceType string, date time.Time) (bool, error) {
 	// Ensure the date is in the same timezone as the database
-	location, _ := time.LoadLocation("Asia/Jakarta")
+	location, _ := time.LoadLocation(constants.PresenceTimezone)
 	date = date.In(location)
 
 	o := orm.NewOrm()
@@ -101,3 +194,16 @@ func CheckPresenceExistsByUserAndType(userId int, presenceType string, date time
 	}
 	return count > 0, nil
 }
+
+// GetPresencesBetween retrieves the presence records of all users created within [start, end)
+func GetPresencesBetween(start, end time.Time) ([]*Presence, error) {
+	o := orm.NewOrm()
+	var presences []*Presence
+	_, err := o.QueryTable(new(Presence)).
+		Filter("CreatedAt__gte", start).
+		Filter("CreatedAt__lt", end).
+		RelatedSel("User", "Schedule").
+		OrderBy("CreatedAt").
+		All(&presences)
+	return presences, err
+}
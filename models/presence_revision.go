@@ -0,0 +1,154 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// PresenceRevision is an immutable snapshot of a presence recorded with every change. The revisions of a presence
+// form a hash chain, the hash of a revision covers its snapshot and the hash of the previous revision.
+type PresenceRevision struct {
+	Id                int       `orm:"auto"`
+	PresenceId        int       `orm:"index"` // Not a foreign key, the history outlives the presence
+	Revision          int       // Starts at 1 and increases by 1 with every change of the presence
+	Action            string    `orm:"size(20)"`
+	UserId            int       // Snapshot of the presence
+	ScheduleId        int       // Snapshot of the presence
+	Type              string    `orm:"size(10)"` // Snapshot of the presence
+	Status            string    `orm:"size(50)"` // Snapshot of the presence
+	PresenceCreatedAt time.Time `orm:"type(datetime)"`
+	ActorId           int       // User who made the change, 0 for changes outside the API
+	PreviousHash      string    `orm:"size(64)"` // Empty for the first revision
+	Hash              string    `orm:"size(64)"`
+	CreatedAt         time.Time `orm:"type(datetime)"`
+}
+
+// TableUnique makes sure concurrent changes can't record the same revision of a presence
+func (r *PresenceRevision) TableUnique() [][]string {
+	return [][]string{{"PresenceId", "Revision"}}
+}
+
+// RevisionSigner computes the hash of the content of a revision
+type RevisionSigner func(content string) string
+
+// Content is the canonical representation of the revision its hash is computed from. Times are included with
+// seconds precision, databases round the fractions.
+func (r *PresenceRevision) Content() string {
+	return strings.Join([]string{
+		strconv.Itoa(r.PresenceId),
+		strconv.Itoa(r.Revision),
+		r.Action,
+		strconv.Itoa(r.UserId),
+		strconv.Itoa(r.ScheduleId),
+		r.Type,
+		r.Status,
+		r.PresenceCreatedAt.UTC().Format(time.RFC3339),
+		strconv.Itoa(r.ActorId),
+		r.CreatedAt.UTC().Format(time.RFC3339),
+		r.PreviousHash,
+	}, "|")
+}
+
+// Matches reports whether the revision is a snapshot of the presence
+func (r *PresenceRevision) Matches(p *Presence) bool {
+	return r.UserId == p.User.Id &&
+		r.ScheduleId == p.Schedule.Id &&
+		r.Type == p.Type &&
+		r.Status == p.Status &&
+		r.PresenceCreatedAt.Unix() == p.CreatedAt.Unix()
+}
+
+// GetPresenceRevisions retrieves the history of a presence, oldest revision first
+func GetPresenceRevisions(presenceId int) ([]*PresenceRevision, error) {
+	o := orm.NewOrm()
+	var revisions []*PresenceRevision
+	_, err := o.QueryTable(new(PresenceRevision)).Filter("PresenceId", presenceId).OrderBy("Revision").All(&revisions)
+	return revisions, err
+}
+
+// GetPresenceRevisionsByPresenceIds retrieves the history of the presences ordered by presence and revision
+func GetPresenceRevisionsByPresenceIds(presenceIds []int) ([]*PresenceRevision, error) {
+	var revisions []*PresenceRevision
+	if len(presenceIds) == 0 {
+		return revisions, nil
+	}
+	o := orm.NewOrm()
+	_, err := o.QueryTable(new(PresenceRevision)).Filter("PresenceId__in", presenceIds).OrderBy("PresenceId", "Revision").Limit(-1).All(&revisions)
+	return revisions, err
+}
+
+// GetRevisionedPresenceIds retrieves the ids of the presences with a history, including deleted presences
+func GetRevisionedPresenceIds() ([]int, error) {
+	o := orm.NewOrm()
+	var ids orm.ParamsList
+	if _, err := o.QueryTable(new(PresenceRevision)).Distinct().Limit(-1).ValuesFlat(&ids, "PresenceId"); err != nil {
+		return nil, err
+	}
+
+	presenceIds := make([]int, 0, len(ids))
+	for _, id := range ids {
+		presenceId, err := strconv.Atoi(orm.ToStr(id))
+		if err != nil {
+			return nil, err
+		}
+		presenceIds = append(presenceIds, presenceId)
+	}
+	return presenceIds, nil
+}
+
+// recordPresenceChange appends the revision of a change to the history of the presence. The stored presence is
+// compared to the last revision first: a presence without history gets a baseline revision, a presence changed
+// outside the API an out of band revision, so the change doesn't hide it.
+func recordPresenceChange(tx orm.TxOrmer, stored, changed *Presence, action string, actorId int, sign RevisionSigner) error {
+	var previous *PresenceRevision
+	last := &PresenceRevision{}
+	err := tx.QueryTable(new(PresenceRevision)).Filter("PresenceId", stored.Id).OrderBy("-Revision").Limit(1).One(last)
+	if err != nil && err != orm.ErrNoRows {
+		return err
+	}
+	if err == nil {
+		previous = last
+	}
+
+	if previous == nil && action != constants.PresenceRevisionCreated {
+		if previous, err = insertPresenceRevision(tx, nil, stored, constants.PresenceRevisionBaseline, 0, sign); err != nil {
+			return err
+		}
+	} else if previous != nil && !previous.Matches(stored) {
+		if previous, err = insertPresenceRevision(tx, previous, stored, constants.PresenceRevisionOutOfBand, 0, sign); err != nil {
+			return err
+		}
+	}
+
+	_, err = insertPresenceRevision(tx, previous, changed, action, actorId, sign)
+	return err
+}
+
+// insertPresenceRevision records a snapshot of the presence following the previous revision
+func insertPresenceRevision(tx orm.TxOrmer, previous *PresenceRevision, p *Presence, action string, actorId int, sign RevisionSigner) (*PresenceRevision, error) {
+	revision := &PresenceRevision{
+		PresenceId:        p.Id,
+		Revision:          1,
+		Action:            action,
+		UserId:            p.User.Id,
+		ScheduleId:        p.Schedule.Id,
+		Type:              p.Type,
+		Status:            p.Status,
+		PresenceCreatedAt: p.CreatedAt,
+		ActorId:           actorId,
+		CreatedAt:         time.Now().Truncate(time.Second),
+	}
+	if previous != nil {
+		revision.Revision = previous.Revision + 1
+		revision.PreviousHash = previous.Hash
+	}
+	revision.Hash = sign(revision.Content())
+
+	_, err := tx.Insert(revision)
+	return revision, err
+}
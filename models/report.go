@@ -0,0 +1,158 @@
+package models
+
+import (
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// DepartmentDailyAttendance is the aggregated attendance of a department on a single day
+type DepartmentDailyAttendance struct {
+	Day       string
+	IsWeekend bool
+	IsHoliday bool
+	Present   int
+	Late      int
+	OnLeave   int
+	Absent    int
+}
+
+// DepartmentCheckInStats is the aggregated check-in statistics of a department over a date range
+type DepartmentCheckInStats struct {
+	CheckIns              int
+	OnTime                int
+	AverageCheckInSeconds float64
+}
+
+// DepartmentLateArrival is the lateness of a single user of a department over a date range
+type DepartmentLateArrival struct {
+	UserId      int
+	Name        string
+	LateCount   int
+	LateMinutes int
+}
+
+// CountUsersByDepartmentId counts the current staff of a department, deleted and deactivated users and service accounts excluded
+func CountUsersByDepartmentId(departmentId int) (int64, error) {
+	o := orm.NewOrm()
+	return o.QueryTable(new(User)).Filter("Department__Id", departmentId).Filter("DeletedAt__isnull", true).Filter("DeactivatedAt__isnull", true).Filter("ServiceAccount", false).Count()
+}
+
+// GetDepartmentDailyAttendance aggregates per-day presence counts of a department between from and to (inclusive).
+// Absences are only counted on elapsed working days as headcount minus present and on-leave users.
+func GetDepartmentDailyAttendance(departmentId int, from, to time.Time) ([]*DepartmentDailyAttendance, error) {
+	o := orm.NewOrm()
+	var days []*DepartmentDailyAttendance
+	_, err := o.Raw(`
+		WITH days AS (
+			SELECT d::date AS day FROM generate_series(?::date, ?::date, interval '1 day') AS d
+		), checkins AS (
+			SELECT (p.created_at AT TIME ZONE ?)::date AS day, p.user_id, p.status
+			FROM presence p
+			JOIN "user" u ON u.id = p.user_id
+			WHERE u.department_id = ?
+				AND p.type = ?
+				AND p.created_at >= (?::date)::timestamp AT TIME ZONE ?
+				AND p.created_at < (?::date + 1)::timestamp AT TIME ZONE ?
+		), daily AS (
+			SELECT
+				days.day,
+				EXTRACT(ISODOW FROM days.day) IN (6, 7) AS is_weekend,
+				EXISTS (SELECT 1 FROM holiday h WHERE h.date = days.day) AS is_holiday,
+				COUNT(DISTINCT c.user_id) AS present,
+				COUNT(DISTINCT c.user_id) FILTER (WHERE c.status = ?) AS late,
+				(
+					SELECT COUNT(DISTINCT l.user_id)
+					FROM leave l
+					JOIN "user" lu ON lu.id = l.user_id
+					WHERE lu.department_id = ? AND days.day BETWEEN l.start_date AND l.end_date
+				) AS on_leave
+			FROM days
+			LEFT JOIN checkins c ON c.day = days.day
+			GROUP BY days.day
+		)
+		SELECT
+			daily.day::text AS day,
+			daily.is_weekend,
+			daily.is_holiday,
+			daily.present::int AS present,
+			daily.late::int AS late,
+			daily.on_leave::int AS on_leave,
+			CASE
+				WHEN daily.is_weekend OR daily.is_holiday OR daily.day >= (now() AT TIME ZONE ?)::date THEN 0
+				ELSE GREATEST((SELECT COUNT(*) FROM "user" WHERE department_id = ? AND deleted_at IS NULL AND deactivated_at IS NULL AND service_account = false) - daily.present - daily.on_leave, 0)
+			END::int AS absent
+		FROM daily
+		ORDER BY daily.day`,
+		from.Format("2006-01-02"), to.Format("2006-01-02"),
+		constants.PresenceTimezone,
+		departmentId,
+		constants.PresenceTypeIn,
+		from.Format("2006-01-02"), constants.PresenceTimezone,
+		to.Format("2006-01-02"), constants.PresenceTimezone,
+		constants.PresenceStatusLate,
+		departmentId,
+		constants.PresenceTimezone,
+		departmentId,
+	).QueryRows(&days)
+	return days, err
+}
+
+// GetDepartmentCheckInStats aggregates the check-ins of a department between from and to (inclusive)
+func GetDepartmentCheckInStats(departmentId int, from, to time.Time) (*DepartmentCheckInStats, error) {
+	o := orm.NewOrm()
+	stats := &DepartmentCheckInStats{}
+	err := o.Raw(`
+		SELECT
+			COUNT(*)::int AS check_ins,
+			(COUNT(*) FILTER (WHERE p.status = ?))::int AS on_time,
+			COALESCE(AVG(EXTRACT(EPOCH FROM (p.created_at AT TIME ZONE ?)::time)), 0)::float8 AS average_check_in_seconds
+		FROM presence p
+		JOIN "user" u ON u.id = p.user_id
+		WHERE u.department_id = ?
+			AND p.type = ?
+			AND p.created_at >= (?::date)::timestamp AT TIME ZONE ?
+			AND p.created_at < (?::date + 1)::timestamp AT TIME ZONE ?`,
+		constants.PresenceStatusOnTime,
+		constants.PresenceTimezone,
+		departmentId,
+		constants.PresenceTypeIn,
+		from.Format("2006-01-02"), constants.PresenceTimezone,
+		to.Format("2006-01-02"), constants.PresenceTimezone,
+	).QueryRow(stats)
+	return stats, err
+}
+
+// GetDepartmentTopLateArrivals returns the users of a department with the most late check-ins between from and to (inclusive)
+func GetDepartmentTopLateArrivals(departmentId int, from, to time.Time, limit int) ([]*DepartmentLateArrival, error) {
+	o := orm.NewOrm()
+	var arrivals []*DepartmentLateArrival
+	_, err := o.Raw(`
+		SELECT
+			u.id AS user_id,
+			u.name,
+			COUNT(*)::int AS late_count,
+			COALESCE(SUM(GREATEST(EXTRACT(EPOCH FROM ((p.created_at AT TIME ZONE ?)::time - s.in_time::time)) / 60, 0)), 0)::int AS late_minutes
+		FROM presence p
+		JOIN "user" u ON u.id = p.user_id
+		JOIN schedule s ON s.id = p.schedule_id
+		WHERE u.department_id = ?
+			AND p.type = ?
+			AND p.status = ?
+			AND p.created_at >= (?::date)::timestamp AT TIME ZONE ?
+			AND p.created_at < (?::date + 1)::timestamp AT TIME ZONE ?
+		GROUP BY u.id, u.name
+		ORDER BY late_count DESC, late_minutes DESC, u.id
+		LIMIT ?`,
+		constants.PresenceTimezone,
+		departmentId,
+		constants.PresenceTypeIn,
+		constants.PresenceStatusLate,
+		from.Format("2006-01-02"), constants.PresenceTimezone,
+		to.Format("2006-01-02"), constants.PresenceTimezone,
+		limit,
+	).QueryRows(&arrivals)
+	return arrivals, err
+}
@@ -3,6 +3,8 @@ package models
 import (
 	"time"
 
+	"github.com/snykk/beego-presence-api/constants"
+
 	"github.com/beego/beego/v2/client/orm"
 )
 
@@ -12,8 +14,9 @@ type Schedule struct {
 	Department *Department `orm:"rel(fk);column(department_id)"` // ForeignKey to Department
 	InTime     string      `orm:"size(8)"`
 	OutTime    string      `orm:"size(8)"`
-	Presences  []*Presence `orm:"reverse(many)"` // Reverse relationship with Presence
-	Users      []*User     `orm:"reverse(many)"` // Reverse relationship with User
+	DeletedAt  *time.Time  `orm:"null;type(datetime);index"` // Deleted schedules are hidden and purged after the retention period
+	Presences  []*Presence `orm:"reverse(many)"`             // Reverse relationship with Presence
+	Users      []*User     `orm:"reverse(many)"`             // Reverse relationship with User
 	CreatedAt  time.Time   `orm:"auto_now_add;type(datetime)"`
 	UpdatedAt  time.Time   `orm:"auto_now;type(datetime)"`
 }
@@ -25,7 +28,7 @@ type Schedule struct {
 func GetAllSchedules(isIncludePresenceList, isIncludeUserList bool) ([]*Schedule, error) {
 	o := orm.NewOrm()
 	var schedules []*Schedule
-	_, err := o.QueryTable(new(Schedule)).RelatedSel("Department").All(&schedules)
+	_, err := o.QueryTable(new(Schedule)).Filter("DeletedAt__isnull", true).RelatedSel("Department").All(&schedules)
 	if err != nil {
 		return nil, err
 	}
@@ -39,8 +42,7 @@ func GetAllSchedules(isIncludePresenceList, isIncludeUserList bool) ([]*Schedule
 		}
 
 		if isIncludeUserList {
-			_, err := o.LoadRelated(schedules[i], "Users")
-			if err != nil {
+			if err := loadScheduleUsers(o, schedules[i]); err != nil {
 				return nil, err
 			}
 		}
@@ -55,6 +57,9 @@ func GetScheduleById(id int, isIncludePresenceList, isIncludeUserList bool) (*Sc
 	if err != nil {
 		return nil, err
 	}
+	if schedule.DeletedAt != nil {
+		return nil, orm.ErrNoRows
+	}
 
 	_, err = o.LoadRelated(schedule, "Department")
 	if err != nil {
@@ -69,8 +74,7 @@ func GetScheduleById(id int, isIncludePresenceList, isIncludeUserList bool) (*Sc
 	}
 
 	if isIncludeUserList {
-		_, err = o.LoadRelated(schedule, "Presences")
-		if err != nil {
+		if err := loadScheduleUsers(o, schedule); err != nil {
 			return nil, err
 		}
 	}
@@ -78,20 +82,57 @@ func GetScheduleById(id int, isIncludePresenceList, isIncludeUserList bool) (*Sc
 	return schedule, nil
 }
 
-func CreateSchedule(schedule *Schedule) error {
-	o := orm.NewOrm()
-	_, err := o.Insert(schedule)
+func CreateSchedule(schedule *Schedule, records ...ChangeRecord) error {
+	_, err := saveWithEvents(func(tx orm.TxOrmer) (int64, error) {
+		return tx.Insert(schedule)
+	}, records)
 	return err
 }
 
-func UpdateSchedule(schedule *Schedule) error {
-	o := orm.NewOrm()
-	_, err := o.Update(schedule)
+func UpdateSchedule(schedule *Schedule, records ...ChangeRecord) error {
+	_, err := saveWithEvents(func(tx orm.TxOrmer) (int64, error) {
+		return tx.Update(schedule)
+	}, records)
 	return err
 }
 
-func DeleteSchedule(id int) (int64, error) {
-	o := orm.NewOrm()
-	affectedRows, err := o.Delete(&Schedule{Id: id})
-	return affectedRows, err
+// loadScheduleUsers loads the users of the schedule that weren't deleted
+func loadScheduleUsers(o orm.Ormer, schedule *Schedule) error {
+	_, err := o.QueryTable(new(User)).Filter("Schedule__Id", schedule.Id).Filter("DeletedAt__isnull", true).OrderBy("Id").All(&schedule.Users)
+	return err
+}
+
+// DeleteSchedule soft-deletes the schedule, presences keep referencing it until it is purged. With reassignTo the
+// users of the schedule are moved to that schedule first, in the same transaction, and the moves are recorded in the
+// audit log as changes by the actor. Schedules with users are only deleted with reassignTo, ErrUsersAssigned is
+// returned otherwise.
+func DeleteSchedule(id int, reassignTo *Schedule, actor AuditActor, records ...ChangeRecord) (int64, error) {
+	return saveWithEvents(func(tx orm.TxOrmer) (int64, error) {
+		now := time.Now()
+		if reassignTo != nil {
+			var ids orm.ParamsList
+			users := tx.QueryTable(new(User)).Filter("Schedule__Id", id).Filter("DeletedAt__isnull", true)
+			if _, err := users.ForUpdate().ValuesFlat(&ids, "Id"); err != nil {
+				return 0, err
+			}
+			if len(ids) > 0 {
+				if _, err := users.Update(orm.Params{"Schedule": reassignTo.Id, "UpdatedAt": now}); err != nil {
+					return 0, err
+				}
+				if err := insertMoveAudits(tx, actor, constants.AuditEntityUser, "schedule_id", movesOf(ids, id, reassignTo.Id)); err != nil {
+					return 0, err
+				}
+			}
+		}
+
+		dependents, err := countScheduleDependents(tx, id)
+		if err != nil {
+			return 0, err
+		}
+		if dependents.Users > 0 {
+			return 0, ErrUsersAssigned
+		}
+
+		return tx.QueryTable(new(Schedule)).Filter("Id", id).Filter("DeletedAt__isnull", true).Update(orm.Params{"DeletedAt": now, "UpdatedAt": now})
+	}, records)
 }
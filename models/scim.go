@@ -0,0 +1,92 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// ErrUnknownMembers is returned when members of a department change don't exist
+var ErrUnknownMembers = errors.New("some members don't exist")
+
+// QueryScimUsers retrieves a page of the users matching the condition together with their department,
+// and the number of matching users
+func QueryScimUsers(cond *orm.Condition, offset, limit int) ([]*User, int64, error) {
+	o := orm.NewOrm()
+	qs := o.QueryTable(new(User)).SetCond(cond).Filter("DeletedAt__isnull", true)
+	total, err := qs.Count()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var users []*User
+	_, err = qs.RelatedSel("Department").OrderBy("Id").Offset(offset).Limit(limit).All(&users)
+	return users, total, err
+}
+
+// QueryScimDepartments retrieves a page of the departments matching the condition and the number of matching departments
+func QueryScimDepartments(cond *orm.Condition, offset, limit int) ([]*Department, int64, error) {
+	o := orm.NewOrm()
+	qs := o.QueryTable(new(Department)).SetCond(cond).Filter("DeletedAt__isnull", true)
+	total, err := qs.Count()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var departments []*Department
+	_, err = qs.OrderBy("Id").Offset(offset).Limit(limit).All(&departments)
+	return departments, total, err
+}
+
+// UpdateDepartmentMembers moves the users with the ids in add into the department, and the members with the ids
+// in remove into the fallback department, in a single transaction. The moves are recorded in the audit log as
+// changes by the actor.
+func UpdateDepartmentMembers(department *Department, add, remove []int, fallback *Department, actor AuditActor, records ...ChangeRecord) error {
+	_, err := saveWithEvents(func(tx orm.TxOrmer) (int64, error) {
+		var affectedRows int64
+		now := time.Now()
+		if len(add) > 0 {
+			moved, err := moveMembers(tx, tx.QueryTable(new(User)).Filter("Id__in", add).Filter("DeletedAt__isnull", true), department.Id, actor, now)
+			if err != nil {
+				return 0, err
+			}
+			if moved != int64(len(add)) {
+				return 0, ErrUnknownMembers
+			}
+			affectedRows += moved
+		}
+		if len(remove) > 0 {
+			moved, err := moveMembers(tx, tx.QueryTable(new(User)).Filter("Id__in", remove).Filter("Department__Id", department.Id), fallback.Id, actor, now)
+			if err != nil {
+				return 0, err
+			}
+			affectedRows += moved
+		}
+		return affectedRows, nil
+	}, records)
+	return err
+}
+
+// moveMembers moves the users matching the query into the department and records the moves of the users who weren't
+// members yet
+func moveMembers(tx orm.TxOrmer, users orm.QuerySeter, departmentId int, actor AuditActor, now time.Time) (int64, error) {
+	var members []*User
+	if _, err := users.ForUpdate().All(&members, "Id", "Department"); err != nil {
+		return 0, err
+	}
+	moves := make([]auditMove, 0, len(members))
+	for _, member := range members {
+		if member.Department.Id != departmentId {
+			moves = append(moves, auditMove{EntityId: member.Id, From: member.Department.Id, To: departmentId})
+		}
+	}
+
+	moved, err := users.Update(orm.Params{"Department": departmentId, "UpdatedAt": now})
+	if err != nil {
+		return 0, err
+	}
+	return moved, insertMoveAudits(tx, actor, constants.AuditEntityUser, "department_id", moves)
+}
@@ -0,0 +1,225 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// PurgeResult counts the rows removed by PurgeDeleted and the deleted rows kept because they are still referenced
+type PurgeResult struct {
+	Users       int
+	Departments int
+	Schedules   int
+	Skipped     int
+}
+
+// GetDeletedUsers retrieves the deleted users, most recently deleted first
+func GetDeletedUsers() ([]*User, error) {
+	o := orm.NewOrm()
+	var users []*User
+	_, err := o.QueryTable(new(User)).Filter("DeletedAt__isnull", false).RelatedSel("Department").OrderBy("-DeletedAt").All(&users)
+	return users, err
+}
+
+// GetDeletedDepartments retrieves the deleted departments, most recently deleted first
+func GetDeletedDepartments() ([]*Department, error) {
+	o := orm.NewOrm()
+	var departments []*Department
+	_, err := o.QueryTable(new(Department)).Filter("DeletedAt__isnull", false).OrderBy("-DeletedAt").All(&departments)
+	return departments, err
+}
+
+// GetDeletedSchedules retrieves the deleted schedules, most recently deleted first
+func GetDeletedSchedules() ([]*Schedule, error) {
+	o := orm.NewOrm()
+	var schedules []*Schedule
+	_, err := o.QueryTable(new(Schedule)).Filter("DeletedAt__isnull", false).RelatedSel("Department").OrderBy("-DeletedAt").All(&schedules)
+	return schedules, err
+}
+
+// GetDeletedUserById retrieves a deleted user, users that weren't deleted aren't found
+func GetDeletedUserById(id int) (*User, error) {
+	o := orm.NewOrm()
+	user := &User{}
+	if err := o.QueryTable(new(User)).Filter("Id", id).Filter("DeletedAt__isnull", false).One(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetDeletedDepartmentById retrieves a deleted department, departments that weren't deleted aren't found
+func GetDeletedDepartmentById(id int) (*Department, error) {
+	o := orm.NewOrm()
+	department := &Department{}
+	if err := o.QueryTable(new(Department)).Filter("Id", id).Filter("DeletedAt__isnull", false).One(department); err != nil {
+		return nil, err
+	}
+	return department, nil
+}
+
+// GetDeletedScheduleById retrieves a deleted schedule, schedules that weren't deleted aren't found
+func GetDeletedScheduleById(id int) (*Schedule, error) {
+	o := orm.NewOrm()
+	schedule := &Schedule{}
+	if err := o.QueryTable(new(Schedule)).Filter("Id", id).Filter("DeletedAt__isnull", false).One(schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// RestoreUser undoes the deletion of a user
+func RestoreUser(user *User, records ...ChangeRecord) error {
+	user.DeletedAt = nil
+	_, err := saveWithEvents(func(tx orm.TxOrmer) (int64, error) {
+		return tx.Update(user, "DeletedAt", "UpdatedAt")
+	}, records)
+	return err
+}
+
+// RestoreDepartment undoes the deletion of a department
+func RestoreDepartment(department *Department, records ...ChangeRecord) error {
+	department.DeletedAt = nil
+	_, err := saveWithEvents(func(tx orm.TxOrmer) (int64, error) {
+		return tx.Update(department, "DeletedAt", "UpdatedAt")
+	}, records)
+	return err
+}
+
+// RestoreSchedule undoes the deletion of a schedule
+func RestoreSchedule(schedule *Schedule, records ...ChangeRecord) error {
+	schedule.DeletedAt = nil
+	_, err := saveWithEvents(func(tx orm.TxOrmer) (int64, error) {
+		return tx.Update(schedule, "DeletedAt", "UpdatedAt")
+	}, records)
+	return err
+}
+
+// PurgeDeleted permanently deletes the users, schedules and departments deleted before the time. Purged users take
+// their presences with their history, leaves and credentials with them. Schedules and departments still referenced by remaining rows
+// are kept, deleting them would cascade to those rows. Every row is purged in its own transaction, a failure keeps the
+// rows purged before it.
+func PurgeDeleted(before time.Time) (PurgeResult, error) {
+	o := orm.NewOrm()
+	var result PurgeResult
+
+	var users []*User
+	if _, err := o.QueryTable(new(User)).Filter("DeletedAt__lt", before).All(&users, "Id"); err != nil {
+		return result, err
+	}
+	for _, user := range users {
+		purged, _, err := purgeRow(user, user.Id, before, func(tx orm.TxOrmer) (bool, error) {
+			return true, deletePresenceRevisionsOfUser(tx, user.Id)
+		})
+		if err != nil {
+			return result, err
+		}
+		if purged {
+			result.Users++
+		}
+	}
+
+	var schedules []*Schedule
+	if _, err := o.QueryTable(new(Schedule)).Filter("DeletedAt__lt", before).All(&schedules, "Id"); err != nil {
+		return result, err
+	}
+	for _, schedule := range schedules {
+		purged, kept, err := purgeRow(schedule, schedule.Id, before, func(tx orm.TxOrmer) (bool, error) {
+			referenced, err := isReferenced(
+				tx.QueryTable(new(User)).Filter("Schedule__Id", schedule.Id),
+				tx.QueryTable(new(Presence)).Filter("Schedule__Id", schedule.Id),
+			)
+			return !referenced, err
+		})
+		if err != nil {
+			return result, err
+		}
+		if purged {
+			result.Schedules++
+		} else if kept {
+			result.Skipped++
+		}
+	}
+
+	var departments []*Department
+	if _, err := o.QueryTable(new(Department)).Filter("DeletedAt__lt", before).All(&departments, "Id"); err != nil {
+		return result, err
+	}
+	for _, department := range departments {
+		purged, kept, err := purgeRow(department, department.Id, before, func(tx orm.TxOrmer) (bool, error) {
+			referenced, err := isReferenced(
+				tx.QueryTable(new(User)).Filter("Department__Id", department.Id),
+				tx.QueryTable(new(Schedule)).Filter("Department__Id", department.Id),
+				tx.QueryTable(new(PayrollExport)).Filter("Department__Id", department.Id),
+			)
+			return !referenced, err
+		})
+		if err != nil {
+			return result, err
+		}
+		if purged {
+			result.Departments++
+		} else if kept {
+			result.Skipped++
+		}
+	}
+
+	return result, nil
+}
+
+// purgeRow deletes a row within a transaction, provided it is still deleted before the time as it may have been
+// restored in the meantime. prepare runs in the same transaction first and keeps the row by returning false.
+func purgeRow(row interface{}, id int, before time.Time, prepare func(tx orm.TxOrmer) (bool, error)) (purged bool, kept bool, err error) {
+	err = orm.NewOrm().DoTx(func(ctx context.Context, tx orm.TxOrmer) error {
+		err := tx.QueryTable(row).Filter("Id", id).Filter("DeletedAt__lt", before).ForUpdate().One(row, "Id")
+		if err == orm.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		ok, err := prepare(tx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			kept = true
+			return nil
+		}
+		if _, err := tx.Delete(row); err != nil {
+			return err
+		}
+		purged = true
+		return nil
+	})
+	return purged, kept, err
+}
+
+// isReferenced reports whether any of the queries matches a row
+func isReferenced(queries ...orm.QuerySeter) (bool, error) {
+	for _, qs := range queries {
+		count, err := qs.Count()
+		if err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// deletePresenceRevisionsOfUser deletes the history of the presences of a user, purging the user deletes the presences
+func deletePresenceRevisionsOfUser(o orm.QueryExecutor, userId int) error {
+	var presenceIds orm.ParamsList
+	if _, err := o.QueryTable(new(Presence)).Filter("User__Id", userId).Limit(-1).ValuesFlat(&presenceIds, "Id"); err != nil {
+		return err
+	}
+	if len(presenceIds) == 0 {
+		return nil
+	}
+	_, err := o.QueryTable(new(PresenceRevision)).Filter("PresenceId__in", presenceIds...).Delete()
+	return err
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// RecoveryCode is a single-use code to log in without the authenticator app
+type RecoveryCode struct {
+	Id        int        `orm:"auto"`
+	User      *User      `orm:"rel(fk);on_delete(cascade)"`
+	CodeHash  string     `orm:"size(64)"` // SHA-256 of the code, the code itself is only shown once
+	UsedAt    *time.Time `orm:"null;type(datetime)"`
+	CreatedAt time.Time  `orm:"auto_now_add;type(datetime)"`
+}
+
+// SetTwoFactorSecret stores the encrypted secret of a pending enrollment, two-factor authentication stays disabled until activated
+func SetTwoFactorSecret(user *User, encryptedSecret string) error {
+	o := orm.NewOrm()
+	user.TwoFactorSecret = encryptedSecret
+	user.TwoFactorEnabled = false
+	user.TwoFactorLastStep = 0
+	_, err := o.Update(user, "TwoFactorSecret", "TwoFactorEnabled", "TwoFactorLastStep", "UpdatedAt")
+	return err
+}
+
+// EnableTwoFactor activates the enrolled secret and replaces the recovery codes of the user
+func EnableTwoFactor(user *User, step int64, codeHashes []string) error {
+	return orm.NewOrm().DoTx(func(ctx context.Context, tx orm.TxOrmer) error {
+		user.TwoFactorEnabled = true
+		user.TwoFactorLastStep = step
+		if _, err := tx.Update(user, "TwoFactorEnabled", "TwoFactorLastStep", "UpdatedAt"); err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(tx, user, codeHashes)
+	})
+}
+
+// DisableTwoFactor removes the secret and the recovery codes of the user
+func DisableTwoFactor(user *User) error {
+	return orm.NewOrm().DoTx(func(ctx context.Context, tx orm.TxOrmer) error {
+		user.TwoFactorSecret = ""
+		user.TwoFactorEnabled = false
+		user.TwoFactorLastStep = 0
+		if _, err := tx.Update(user, "TwoFactorSecret", "TwoFactorEnabled", "TwoFactorLastStep", "UpdatedAt"); err != nil {
+			return err
+		}
+		_, err := tx.QueryTable(new(RecoveryCode)).Filter("User__Id", user.Id).Delete()
+		return err
+	})
+}
+
+// ReplaceRecoveryCodes invalidates the recovery codes of the user and stores new ones
+func ReplaceRecoveryCodes(user *User, codeHashes []string) error {
+	return orm.NewOrm().DoTx(func(ctx context.Context, tx orm.TxOrmer) error {
+		return replaceRecoveryCodes(tx, user, codeHashes)
+	})
+}
+
+func replaceRecoveryCodes(tx orm.TxOrmer, user *User, codeHashes []string) error {
+	if _, err := tx.QueryTable(new(RecoveryCode)).Filter("User__Id", user.Id).Delete(); err != nil {
+		return err
+	}
+	codes := make([]*RecoveryCode, 0, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes = append(codes, &RecoveryCode{User: user, CodeHash: hash})
+	}
+	_, err := tx.InsertMulti(len(codes), codes)
+	return err
+}
+
+// UseRecoveryCode marks an unused recovery code of the user as used and reports whether there was one
+func UseRecoveryCode(userId int, codeHash string, now time.Time) (bool, error) {
+	o := orm.NewOrm()
+	affected, err := o.QueryTable(new(RecoveryCode)).
+		Filter("User__Id", userId).
+		Filter("CodeHash", codeHash).
+		Filter("UsedAt__isnull", true).
+		Update(orm.Params{"UsedAt": now})
+	return affected > 0, err
+}
+
+// CountUnusedRecoveryCodes returns the number of recovery codes the user has left
+func CountUnusedRecoveryCodes(userId int) (int64, error) {
+	o := orm.NewOrm()
+	return o.QueryTable(new(RecoveryCode)).Filter("User__Id", userId).Filter("UsedAt__isnull", true).Count()
+}
+
+// UseTOTPStep records the time step of an accepted code and reports false if the step or a later one was already used
+func UseTOTPStep(userId int, step int64) (bool, error) {
+	o := orm.NewOrm()
+	affected, err := o.QueryTable(new(User)).
+		Filter("Id", userId).
+		Filter("TwoFactorLastStep__lt", step).
+		Update(orm.Params{"TwoFactorLastStep": step})
+	return affected > 0, err
+}
@@ -3,20 +3,38 @@ package models
 import (
 	"time"
 
+	"github.com/snykk/beego-presence-api/constants"
+
 	"github.com/beego/beego/v2/client/orm"
 )
 
 type User struct {
-	Id         int         `orm:"auto"`
-	Name       string      `orm:"size(100)"`
-	Email      string      `orm:"size(100);unique"`
-	Password   string      `orm:"size(255)"`
-	Role       string      `orm:"size(10)"`
-	Department *Department `orm:"rel(fk);column(department_id)"`    // ForeignKey to Department
-	Presences  []*Presence `orm:"reverse(many)"`                    // Reverse relationship with Presence
-	Schedule   *Schedule   `orm:"null;rel(fk);column(schedule_id)"` // ForeignKey to Schedule
-	CreatedAt  time.Time   `orm:"auto_now_add;type(datetime)"`
-	UpdatedAt  time.Time   `orm:"auto_now;type(datetime)"`
+	Id                  int         `orm:"auto"`
+	Name                string      `orm:"size(100)"`
+	Email               string      `orm:"size(100);unique"`
+	Password            string      `orm:"size(255)"`
+	Role                string      `orm:"size(10)"`
+	Department          *Department `orm:"rel(fk);column(department_id)"`    // ForeignKey to Department
+	Presences           []*Presence `orm:"reverse(many)"`                    // Reverse relationship with Presence
+	Schedule            *Schedule   `orm:"null;rel(fk);column(schedule_id)"` // ForeignKey to Schedule
+	TokenVersion        int         `orm:"default(0)"`                       // Raised to invalidate the issued tokens, e.g. on a password change
+	MustChangePassword  bool        `orm:"default(false)"`                   // Set for accounts with an initial password
+	PasswordChangedAt   *time.Time  `orm:"null;type(datetime)"`
+	EmailVerified       bool        `orm:"default(true)"` // Self-registered users can't log in until they verify their email
+	TwoFactorSecret     string      `orm:"size(255)"`     // Encrypted TOTP secret, set on enrollment and kept pending until activated
+	TwoFactorEnabled    bool        `orm:"default(false)"`
+	TwoFactorLastStep   int64       `orm:"default(0)"` // Last accepted TOTP time step, codes can't be replayed
+	FailedLoginAttempts int         `orm:"default(0)"` // Failed passwords in a row, each one lengthens the wait before the next attempt
+	LastFailedLoginAt   *time.Time  `orm:"null;type(datetime)"`
+	LockedUntil         *time.Time  `orm:"null;type(datetime)"`       // Logins are rejected until then after too many failed passwords
+	OidcSubject         string      `orm:"size(255);index"`           // Subject at the identity provider for single sign-on, empty for local accounts
+	LdapUsername        string      `orm:"size(100);index"`           // Username in the directory, the account is managed by the LDAP sync while set
+	DeactivatedAt       *time.Time  `orm:"null;type(datetime)"`       // Deactivated accounts can't log in, their presence history is kept
+	ScimExternalId      string      `orm:"size(255);index"`           // Id of the user at the identity platform provisioning it over SCIM
+	ServiceAccount      bool        `orm:"default(false)"`            // Service accounts of integrations authenticate with API keys only
+	DeletedAt           *time.Time  `orm:"null;type(datetime);index"` // Deleted users are hidden and purged after the retention period
+	CreatedAt           time.Time   `orm:"auto_now_add;type(datetime)"`
+	UpdatedAt           time.Time   `orm:"auto_now;type(datetime)"`
 }
 
 // func init() {
@@ -26,7 +44,7 @@ type User struct {
 func GetAllUsers(isIncludePresenceList bool) ([]*User, error) {
 	o := orm.NewOrm()
 	var users []*User
-	_, err := o.QueryTable(new(User)).RelatedSel("Department", "Schedule").All(&users)
+	_, err := o.QueryTable(new(User)).Filter("DeletedAt__isnull", true).RelatedSel("Department", "Schedule").All(&users)
 	if err != nil {
 		return nil, err
 	}
@@ -43,13 +61,144 @@ func GetAllUsers(isIncludePresenceList bool) ([]*User, error) {
 	return users, nil
 }
 
+// GetUsersByDepartmentId retrieves the current staff of a department together with their schedule, deactivated users and
+// service accounts excluded
+func GetUsersByDepartmentId(departmentId int) ([]*User, error) {
+	o := orm.NewOrm()
+	var users []*User
+	_, err := o.QueryTable(new(User)).Filter("Department__Id", departmentId).Filter("DeletedAt__isnull", true).Filter("DeactivatedAt__isnull", true).Filter("ServiceAccount", false).RelatedSel("Department", "Schedule").OrderBy("Id").All(&users)
+	return users, err
+}
+
+// GetDepartmentMembers retrieves every account assigned to a department, e.g. the members of the SCIM group
+func GetDepartmentMembers(departmentId int) ([]*User, error) {
+	o := orm.NewOrm()
+	var users []*User
+	_, err := o.QueryTable(new(User)).Filter("Department__Id", departmentId).Filter("DeletedAt__isnull", true).RelatedSel("Department", "Schedule").OrderBy("Id").All(&users)
+	return users, err
+}
+
+// GetUsersByRole retrieves the current users with the given role, deactivated users and service accounts excluded
+func GetUsersByRole(role string) ([]*User, error) {
+	o := orm.NewOrm()
+	var users []*User
+	_, err := o.QueryTable(new(User)).Filter("Role", role).Filter("DeletedAt__isnull", true).Filter("DeactivatedAt__isnull", true).Filter("ServiceAccount", false).RelatedSel("Department").OrderBy("Id").All(&users)
+	return users, err
+}
+
+// GetUsersWithSchedule retrieves the current users assigned to a schedule, deactivated users and service accounts excluded
+func GetUsersWithSchedule() ([]*User, error) {
+	o := orm.NewOrm()
+	var users []*User
+	_, err := o.QueryTable(new(User)).Filter("Schedule__isnull", false).Filter("DeletedAt__isnull", true).Filter("DeactivatedAt__isnull", true).Filter("ServiceAccount", false).RelatedSel("Schedule").OrderBy("Id").All(&users)
+	return users, err
+}
+
+// GetServiceAccounts retrieves the service accounts together with their department
+func GetServiceAccounts() ([]*User, error) {
+	o := orm.NewOrm()
+	var users []*User
+	_, err := o.QueryTable(new(User)).Filter("ServiceAccount", true).Filter("DeletedAt__isnull", true).RelatedSel("Department").OrderBy("Id").All(&users)
+	return users, err
+}
+
+// IsActive reports whether the account wasn't deactivated
+func (u *User) IsActive() bool {
+	return u.DeactivatedAt == nil
+}
+
+// GetUserAccountById retrieves a user without relations, e.g. to check the credentials of a session
+func GetUserAccountById(id int) (*User, error) {
+	o := orm.NewOrm()
+	user := &User{Id: id}
+	if err := o.Read(user); err != nil {
+		return nil, err
+	}
+	if user.DeletedAt != nil {
+		return nil, orm.ErrNoRows
+	}
+	return user, nil
+}
+
+// MarkUserEmailVerified records that the user confirmed the email address
+func MarkUserEmailVerified(user *User, records ...ChangeRecord) error {
+	user.EmailVerified = true
+	_, err := saveWithEvents(func(tx orm.TxOrmer) (int64, error) {
+		return tx.Update(user, "EmailVerified", "UpdatedAt")
+	}, records)
+	return err
+}
+
+// GetUserByLdapUsername retrieves the user synced from the directory entry with the username
+func GetUserByLdapUsername(username string) (*User, error) {
+	o := orm.NewOrm()
+	user := &User{}
+	if err := o.QueryTable(new(User)).Filter("LdapUsername", username).Filter("DeletedAt__isnull", true).One(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetLdapUsers retrieves the users managed by the directory sync
+func GetLdapUsers() ([]*User, error) {
+	o := orm.NewOrm()
+	var users []*User
+	_, err := o.QueryTable(new(User)).Exclude("LdapUsername", "").Filter("DeletedAt__isnull", true).OrderBy("Id").All(&users)
+	return users, err
+}
+
+// DeactivateUser blocks the logins of the user and revokes the issued tokens
+func DeactivateUser(user *User, now time.Time, records ...ChangeRecord) error {
+	user.DeactivatedAt = &now
+	user.TokenVersion++
+	_, err := saveWithEvents(func(tx orm.TxOrmer) (int64, error) {
+		return tx.Update(user, "DeactivatedAt", "TokenVersion", "UpdatedAt")
+	}, records)
+	return err
+}
+
+// ReactivateUser lets a deactivated user log in again
+func ReactivateUser(user *User, records ...ChangeRecord) error {
+	user.DeactivatedAt = nil
+	_, err := saveWithEvents(func(tx orm.TxOrmer) (int64, error) {
+		return tx.Update(user, "DeactivatedAt", "UpdatedAt")
+	}, records)
+	return err
+}
+
+// ChangeUserRole changes the role of the user and revokes the issued tokens, which carry the previous role
+func ChangeUserRole(user *User, role string, records ...ChangeRecord) error {
+	user.Role = role
+	user.TokenVersion++
+	_, err := saveWithEvents(func(tx orm.TxOrmer) (int64, error) {
+		return tx.Update(user, "Role", "TokenVersion", "UpdatedAt")
+	}, records)
+	return err
+}
+
+// CountActiveAdmins counts the admins that can log in, service accounts excluded
+func CountActiveAdmins() (int64, error) {
+	o := orm.NewOrm()
+	return o.QueryTable(new(User)).Filter("Role", constants.RoleAdmin).Filter("DeactivatedAt__isnull", true).Filter("ServiceAccount", false).Filter("DeletedAt__isnull", true).Count()
+}
+
 func GetUserByEmail(email string) (User, error) {
 	o := orm.NewOrm()
 	user := User{Email: email}
 	err := o.Read(&user, "Email")
+	if err == nil && user.DeletedAt != nil {
+		return User{}, orm.ErrNoRows
+	}
 	return user, err
 }
 
+// IsEmailRegistered reports whether a user, including a deleted one, has the email, emails stay unique until purged
+func IsEmailRegistered(email string) (bool, error) {
+	o := orm.NewOrm()
+	count, err := o.QueryTable(new(User)).Filter("Email", email).Count()
+	return count > 0, err
+}
+
 func GetUserById(id int, isIncludePresenceList bool) (*User, error) {
 	o := orm.NewOrm()
 	user := &User{Id: id}
@@ -57,6 +206,9 @@ func GetUserById(id int, isIncludePresenceList bool) (*User, error) {
 	if err != nil {
 		return nil, err
 	}
+	if user.DeletedAt != nil {
+		return nil, orm.ErrNoRows
+	}
 
 	_, err = o.LoadRelated(user, "Department")
 	if err != nil {
@@ -78,20 +230,38 @@ func GetUserById(id int, isIncludePresenceList bool) (*User, error) {
 	return user, nil
 }
 
-func CreateUser(user *User) error {
-	o := orm.NewOrm()
-	_, err := o.Insert(user)
+func CreateUser(user *User, records ...ChangeRecord) error {
+	_, err := saveWithEvents(func(tx orm.TxOrmer) (int64, error) {
+		return tx.Insert(user)
+	}, records)
 	return err
 }
 
-func UpdateUser(user *User) error {
-	o := orm.NewOrm()
-	_, err := o.Update(user)
+// UpdateUser saves the profile of a user, the columns managed by other flows, e.g. the credentials, are left untouched
+func UpdateUser(user *User, records ...ChangeRecord) error {
+	_, err := saveWithEvents(func(tx orm.TxOrmer) (int64, error) {
+		return tx.Update(user, "Name", "Email", "Department", "EmailVerified", "UpdatedAt")
+	}, records)
 	return err
 }
 
-func DeleteUser(id int) (int64, error) {
-	o := orm.NewOrm()
-	affectedRows, err := o.Delete(&User{Id: id})
-	return affectedRows, err
+// UpdateProvisionedUser saves a user synced from the directory or provisioned over SCIM, with the columns the sync manages
+func UpdateProvisionedUser(user *User, records ...ChangeRecord) error {
+	_, err := saveWithEvents(func(tx orm.TxOrmer) (int64, error) {
+		return tx.Update(user, "Name", "Email", "Role", "Department", "EmailVerified", "Password", "PasswordChangedAt", "TokenVersion",
+			"DeactivatedAt", "ScimExternalId", "LdapUsername", "UpdatedAt")
+	}, records)
+	return err
+}
+
+// DeleteUser soft-deletes the user, the presence history is kept until the user is purged
+func DeleteUser(id int, records ...ChangeRecord) (int64, error) {
+	return saveWithEvents(func(tx orm.TxOrmer) (int64, error) {
+		now := time.Now()
+		return tx.QueryTable(new(User)).Filter("Id", id).Filter("DeletedAt__isnull", true).Update(orm.Params{
+			"DeletedAt":    now,
+			"TokenVersion": orm.ColValue(orm.ColAdd, 1),
+			"UpdatedAt":    now,
+		})
+	}, records)
 }
@@ -0,0 +1,185 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// Webhook represents a subscription of an external URL to application events
+type Webhook struct {
+	Id          int       `orm:"auto"`
+	Url         string    `orm:"size(500)"`
+	Events      string    `orm:"size(500)"` // Comma separated list of subscribed events
+	Secret      string    `orm:"size(255)"` // Encrypted key of the HMAC signature of the payloads
+	Description string    `orm:"size(255)"`
+	Active      bool      `orm:"default(true)"`
+	CreatedAt   time.Time `orm:"auto_now_add;type(datetime)"`
+	UpdatedAt   time.Time `orm:"auto_now;type(datetime)"`
+}
+
+// WebhookDelivery is a queued or attempted delivery of an event to a webhook
+type WebhookDelivery struct {
+	Id             int        `orm:"auto"`
+	Webhook        *Webhook   `orm:"rel(fk);on_delete(cascade)"`
+	Event          string     `orm:"size(50)"`
+	Payload        string     `orm:"type(text)"`
+	Status         string     `orm:"size(20);index"` // pending, delivered or failed
+	Attempts       int        `orm:"default(0)"`
+	NextAttemptAt  time.Time  `orm:"type(datetime);index"`
+	LastStatusCode int        `orm:"default(0)"`
+	LastError      string     `orm:"type(text)"`
+	DeliveredAt    *time.Time `orm:"null;type(datetime)"`
+	CreatedAt      time.Time  `orm:"auto_now_add;type(datetime)"`
+	UpdatedAt      time.Time  `orm:"auto_now;type(datetime)"`
+}
+
+// GetEvents returns the subscribed events of the webhook
+func (w *Webhook) GetEvents() []string {
+	if w.Events == "" {
+		return []string{}
+	}
+	return strings.Split(w.Events, ",")
+}
+
+// IsSubscribed reports whether the webhook receives the given event
+func (w *Webhook) IsSubscribed(event string) bool {
+	for _, subscribed := range w.GetEvents() {
+		if subscribed == event || subscribed == constants.WebhookEventAll {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAllWebhooks retrieves all webhooks
+func GetAllWebhooks() ([]*Webhook, error) {
+	o := orm.NewOrm()
+	var webhooks []*Webhook
+	_, err := o.QueryTable(new(Webhook)).OrderBy("Id").All(&webhooks)
+	return webhooks, err
+}
+
+// GetActiveWebhooksByEvent retrieves the active webhooks subscribed to an event
+func GetActiveWebhooksByEvent(event string) ([]*Webhook, error) {
+	o := orm.NewOrm()
+	var webhooks []*Webhook
+	_, err := o.QueryTable(new(Webhook)).Filter("Active", true).All(&webhooks)
+	if err != nil {
+		return nil, err
+	}
+
+	subscribed := make([]*Webhook, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		if webhook.IsSubscribed(event) {
+			subscribed = append(subscribed, webhook)
+		}
+	}
+	return subscribed, nil
+}
+
+// GetWebhookById retrieves a webhook by ID
+func GetWebhookById(id int) (*Webhook, error) {
+	o := orm.NewOrm()
+	webhook := &Webhook{Id: id}
+	err := o.Read(webhook)
+	if err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// CreateWebhook inserts a new webhook
+func CreateWebhook(webhook *Webhook) error {
+	o := orm.NewOrm()
+	_, err := o.Insert(webhook)
+	return err
+}
+
+// UpdateWebhook updates an existing webhook
+func UpdateWebhook(webhook *Webhook) error {
+	o := orm.NewOrm()
+	_, err := o.Update(webhook)
+	return err
+}
+
+// DeleteWebhook deletes a webhook together with its deliveries
+func DeleteWebhook(id int) (int64, error) {
+	o := orm.NewOrm()
+	affectedRows, err := o.Delete(&Webhook{Id: id})
+	return affectedRows, err
+}
+
+// CreateWebhookDeliveries queues deliveries in a single statement
+func CreateWebhookDeliveries(deliveries []*WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	o := orm.NewOrm()
+	_, err := o.InsertMulti(len(deliveries), deliveries)
+	return err
+}
+
+// GetWebhookDeliveries retrieves the most recent deliveries of a webhook, optionally filtered by status
+func GetWebhookDeliveries(webhookId int, status string, limit int) ([]*WebhookDelivery, error) {
+	o := orm.NewOrm()
+	var deliveries []*WebhookDelivery
+	qs := o.QueryTable(new(WebhookDelivery)).Filter("Webhook__Id", webhookId)
+	if status != "" {
+		qs = qs.Filter("Status", status)
+	}
+	_, err := qs.OrderBy("-Id").Limit(limit).All(&deliveries)
+	return deliveries, err
+}
+
+// GetWebhookDeliveryById retrieves a delivery by ID
+func GetWebhookDeliveryById(id int) (*WebhookDelivery, error) {
+	o := orm.NewOrm()
+	delivery := &WebhookDelivery{Id: id}
+	err := o.Read(delivery)
+	if err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// ClaimDueWebhookDeliveries leases the pending deliveries whose next attempt is due and counts the attempt.
+// Deliveries of inactive webhooks are held back until the webhook is activated again. Rows locked by another
+// dispatcher are skipped, and the lease hides the claimed rows until it has passed, so a delivery is retried
+// if its dispatcher stops before recording the result.
+func ClaimDueWebhookDeliveries(now time.Time, lease time.Duration, limit int) ([]*WebhookDelivery, error) {
+	o := orm.NewOrm()
+
+	var ids orm.ParamsList
+	_, err := o.Raw(`
+		UPDATE webhook_delivery
+		SET next_attempt_at = ?, attempts = attempts + 1, updated_at = ?
+		WHERE id IN (
+			SELECT d.id FROM webhook_delivery d
+			JOIN webhook w ON w.id = d.webhook_id
+			WHERE w.active AND d.status = ? AND d.next_attempt_at <= ?
+			ORDER BY d.next_attempt_at
+			LIMIT ?
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		RETURNING id`,
+		now.Add(lease), now, constants.WebhookDeliveryStatusPending, now, limit,
+	).ValuesFlat(&ids)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	var deliveries []*WebhookDelivery
+	_, err = o.QueryTable(new(WebhookDelivery)).Filter("Id__in", ids...).RelatedSel("Webhook").All(&deliveries)
+	return deliveries, err
+}
+
+// UpdateWebhookDelivery stores the result of a delivery attempt
+func UpdateWebhookDelivery(delivery *WebhookDelivery) error {
+	o := orm.NewOrm()
+	_, err := o.Update(delivery, "Status", "Attempts", "NextAttemptAt", "LastStatusCode", "LastError", "DeliveredAt", "UpdatedAt")
+	return err
+}
@@ -30,6 +30,13 @@ func init() {
 			// Create routes for the UserController in auth endpoint
 			beego.NSRouter("/regis", &controllers.AuthController{}, "post:Register"),
 			beego.NSRouter("/login", &controllers.AuthController{}, "post:Login"),
+			beego.NSRouter("/forgot-password", &controllers.AuthController{}, "post:ForgotPassword"),
+			beego.NSRouter("/reset-password", &controllers.AuthController{}, "post:ResetPassword"),
+			beego.NSRouter("/verify-email", &controllers.AuthController{}, "get:VerifyEmail"),
+			beego.NSRouter("/resend-verification", &controllers.AuthController{}, "post:ResendVerification"),
+			beego.NSRouter("/2fa/verify", &controllers.AuthController{}, "post:VerifyTwoFactor"),
+			beego.NSRouter("/oidc/login", &controllers.AuthController{}, "get:OidcLogin"),
+			beego.NSRouter("/oidc/callback", &controllers.AuthController{}, "get:OidcCallback"),
 
 			// To generate the swagger documentation for the UserController in auth endpoint
 			beego.NSInclude(
@@ -38,8 +45,26 @@ func init() {
 		),
 		beego.NSNamespace("/users",
 			// Create routes for the UserController in users endpoint
-			beego.NSRouter("", &controllers.UserController{}, "get:GetAll"),
+			beego.NSRouter("", &controllers.UserController{}, "get:GetAll;post:Create"),
+			beego.NSRouter("/me/password", &controllers.UserController{}, "put:ChangePassword"),
+			beego.NSNamespace("/me/2fa",
+				// Create routes for the TwoFactorController
+				beego.NSRouter("", &controllers.TwoFactorController{}, "get:GetStatus;post:Enroll;delete:Disable"),
+				beego.NSRouter("/activate", &controllers.TwoFactorController{}, "post:Activate"),
+				beego.NSRouter("/recovery-codes", &controllers.TwoFactorController{}, "post:RegenerateRecoveryCodes"),
+
+				// To generate the swagger documentation for the TwoFactorController
+				beego.NSInclude(
+					&controllers.TwoFactorController{},
+				),
+			),
 			beego.NSRouter("/:id", &controllers.UserController{}, "get:GetById;put:Update;delete:Delete"),
+			beego.NSRouter("/:id/unlock", &controllers.UserController{}, "post:Unlock"),
+			beego.NSRouter("/:id/role", &controllers.UserController{}, "put:ChangeRole"),
+			beego.NSRouter("/:id/deactivate", &controllers.UserController{}, "post:Deactivate"),
+			beego.NSRouter("/:id/reactivate", &controllers.UserController{}, "post:Reactivate"),
+			beego.NSRouter("/deleted", &controllers.UserController{}, "get:GetDeleted"),
+			beego.NSRouter("/:id/restore", &controllers.UserController{}, "post:Restore"),
 
 			// To generate the swagger documentation for the UserController in users endpoint
 			beego.NSInclude(
@@ -50,6 +75,8 @@ func init() {
 			// Create routes for the DepartmentController
 			beego.NSRouter("", &controllers.DepartmentController{}, "get:GetAll;post:Create"),
 			beego.NSRouter("/:id", &controllers.DepartmentController{}, "get:GetById;put:Update;delete:Delete"),
+			beego.NSRouter("/deleted", &controllers.DepartmentController{}, "get:GetDeleted"),
+			beego.NSRouter("/:id/restore", &controllers.DepartmentController{}, "post:Restore"),
 
 			// To generate the swagger documentation for the DepartmentController
 			beego.NSInclude(
@@ -60,6 +87,8 @@ func init() {
 			// Create routes for the ScheduleController
 			beego.NSRouter("", &controllers.ScheduleController{}, "get:GetAll;post:Create"),
 			beego.NSRouter("/:id", &controllers.ScheduleController{}, "get:GetById;put:Update;delete:Delete"),
+			beego.NSRouter("/deleted", &controllers.ScheduleController{}, "get:GetDeleted"),
+			beego.NSRouter("/:id/restore", &controllers.ScheduleController{}, "post:Restore"),
 
 			// To generate the swagger documentation for the ScheduleController
 			beego.NSInclude(
@@ -69,6 +98,9 @@ func init() {
 		beego.NSNamespace("/presences",
 			// Create routes for the PresenceController
 			beego.NSRouter("", &controllers.PresenceController{}, "get:GetAll;post:Create"),
+			beego.NSRouter("/stream", &controllers.PresenceController{}, "get:Stream"),
+			beego.NSRouter("/verify", &controllers.PresenceController{}, "get:Verify"),
+			beego.NSRouter("/:id/history", &controllers.PresenceController{}, "get:History"),
 			beego.NSRouter("/:id", &controllers.PresenceController{}, "get:GetById;put:Update;delete:Delete"),
 
 			// To generate the swagger documentation for the PresenceController
@@ -76,8 +108,109 @@ func init() {
 				&controllers.PresenceController{},
 			),
 		),
+		beego.NSNamespace("/reports",
+			// Create routes for the ReportController
+			beego.NSRouter("/users/:id/monthly", &controllers.ReportController{}, "get:GetUserMonthly"),
+			beego.NSRouter("/users/:id/timesheet", &controllers.ReportController{}, "get:GetUserTimesheet"),
+			beego.NSRouter("/departments/:id/dashboard", &controllers.ReportController{}, "get:GetDepartmentDashboard"),
+
+			// To generate the swagger documentation for the ReportController
+			beego.NSInclude(
+				&controllers.ReportController{},
+			),
+		),
+		beego.NSNamespace("/payroll",
+			// Create routes for the PayrollController
+			beego.NSRouter("/templates", &controllers.PayrollController{}, "get:GetAllTemplates;post:CreateTemplate"),
+			beego.NSRouter("/templates/:id", &controllers.PayrollController{}, "get:GetTemplateById;put:UpdateTemplate;delete:DeleteTemplate"),
+			beego.NSRouter("/exports", &controllers.PayrollController{}, "get:GetAllExports;post:CreateExport"),
+			beego.NSRouter("/exports/:id", &controllers.PayrollController{}, "delete:DeleteExport"),
+			beego.NSRouter("/exports/:id/file", &controllers.PayrollController{}, "get:DownloadExport"),
+
+			// To generate the swagger documentation for the PayrollController
+			beego.NSInclude(
+				&controllers.PayrollController{},
+			),
+		),
+		beego.NSNamespace("/notifications",
+			// Create routes for the NotificationController
+			beego.NSRouter("/preferences", &controllers.NotificationController{}, "get:GetPreferences;put:UpdatePreferences"),
+			beego.NSRouter("/logs", &controllers.NotificationController{}, "get:GetLogs"),
+
+			// To generate the swagger documentation for the NotificationController
+			beego.NSInclude(
+				&controllers.NotificationController{},
+			),
+		),
+		beego.NSNamespace("/login-attempts",
+			// Create routes for the LoginAttemptController
+			beego.NSRouter("", &controllers.LoginAttemptController{}, "get:GetAll"),
+
+			// To generate the swagger documentation for the LoginAttemptController
+			beego.NSInclude(
+				&controllers.LoginAttemptController{},
+			),
+		),
+		beego.NSNamespace("/audit-logs",
+			// Create routes for the AuditLogController
+			beego.NSRouter("", &controllers.AuditLogController{}, "get:GetAll"),
+
+			// To generate the swagger documentation for the AuditLogController
+			beego.NSInclude(
+				&controllers.AuditLogController{},
+			),
+		),
+		beego.NSNamespace("/webhooks",
+			// Create routes for the WebhookController
+			beego.NSRouter("", &controllers.WebhookController{}, "get:GetAll;post:Create"),
+			beego.NSRouter("/:id", &controllers.WebhookController{}, "get:GetById;put:Update;delete:Delete"),
+			beego.NSRouter("/:id/deliveries", &controllers.WebhookController{}, "get:GetDeliveries"),
+			beego.NSRouter("/deliveries/:id/retry", &controllers.WebhookController{}, "post:RetryDelivery"),
+
+			// To generate the swagger documentation for the WebhookController
+			beego.NSInclude(
+				&controllers.WebhookController{},
+			),
+		),
+		beego.NSNamespace("/api-keys",
+			// Create routes for the ApiKeyController
+			beego.NSRouter("", &controllers.ApiKeyController{}, "get:GetAll;post:Create"),
+			beego.NSRouter("/:id", &controllers.ApiKeyController{}, "delete:Revoke"),
+
+			// To generate the swagger documentation for the ApiKeyController
+			beego.NSInclude(
+				&controllers.ApiKeyController{},
+			),
+		),
+		beego.NSNamespace("/service-accounts",
+			// Create routes for the ServiceAccountController
+			beego.NSRouter("", &controllers.ServiceAccountController{}, "get:GetAll;post:Create"),
+			beego.NSRouter("/:id", &controllers.ServiceAccountController{}, "delete:Delete"),
+			beego.NSRouter("/:id/api-keys", &controllers.ServiceAccountController{}, "get:GetApiKeys;post:CreateApiKey"),
+
+			// To generate the swagger documentation for the ServiceAccountController
+			beego.NSInclude(
+				&controllers.ServiceAccountController{},
+			),
+		),
+	)
+
+	// SCIM provisioning authenticates the identity platform with its own bearer token instead of employee JWTs
+	scim := beego.NewNamespace("/scim/v2",
+		beego.NSBefore(middlewares.ScimAuthMiddleware()),
+		// Create routes for the ScimController
+		beego.NSRouter("/ServiceProviderConfig", &controllers.ScimController{}, "get:GetServiceProviderConfig"),
+		beego.NSRouter("/Users", &controllers.ScimController{}, "get:GetUsers;post:CreateUser"),
+		beego.NSRouter("/Users/:id", &controllers.ScimController{}, "get:GetUser;put:ReplaceUser;patch:PatchUser;delete:DeleteUser"),
+		beego.NSRouter("/Groups", &controllers.ScimController{}, "get:GetGroups;post:CreateGroup"),
+		beego.NSRouter("/Groups/:id", &controllers.ScimController{}, "get:GetGroup;put:ReplaceGroup;patch:PatchGroup;delete:DeleteGroup"),
+
+		// To generate the swagger documentation for the ScimController
+		beego.NSInclude(
+			&controllers.ScimController{},
+		),
 	)
 
-	// Register namespace
-	beego.AddNamespace(ns)
+	// Register namespaces
+	beego.AddNamespace(ns, scim)
 }
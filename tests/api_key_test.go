@@ -0,0 +1,80 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestApiKeys checks the scopes required by requests, the validity of keys and the validation of key requests
+func TestApiKeys(t *testing.T) {
+	Convey("Subject: API keys\n", t, func() {
+		Convey("API keys are told apart from JWTs by their prefix", func() {
+			token, _ := helpers.GenerateJWT(1, "employee1@example.com", constants.RoleEmployee, 0)
+			So(helpers.IsApiKey(token), ShouldBeFalse)
+			So(helpers.IsApiKey(constants.ApiKeyPrefix+"3f9a1c2e"), ShouldBeTrue)
+		})
+
+		Convey("Requests need the read or write scope of their resource", func() {
+			scope, ok := helpers.ApiKeyScopeForRequest("/api/v1/presences", "GET")
+			So(ok, ShouldBeTrue)
+			So(scope, ShouldEqual, "presences:read")
+
+			scope, ok = helpers.ApiKeyScopeForRequest("/api/v1/presences/12", "PUT")
+			So(ok, ShouldBeTrue)
+			So(scope, ShouldEqual, "presences:write")
+
+			scope, ok = helpers.ApiKeyScopeForRequest("/api/v1/reports/users/3/monthly", "GET")
+			So(ok, ShouldBeTrue)
+			So(scope, ShouldEqual, "reports:read")
+		})
+
+		Convey("Credentials can't be managed with API keys", func() {
+			for _, request := range [][2]string{
+				{"/api/v1/api-keys", "POST"},
+				{"/api/v1/service-accounts", "GET"},
+				{"/api/v1/users/me/password", "PUT"},
+				{"/api/v1/users/me/2fa", "GET"},
+				{"/api/v1/reports/users/3/monthly", "POST"},
+			} {
+				_, ok := helpers.ApiKeyScopeForRequest(request[0], request[1])
+				So(ok, ShouldBeFalse)
+			}
+		})
+
+		Convey("Revoked and expired keys are unusable", func() {
+			now := time.Now()
+			key := &models.ApiKey{Scopes: "presences:read,reports:read", ExpiresAt: now.Add(time.Hour)}
+			So(key.IsUsable(now), ShouldBeTrue)
+			So(key.HasScope("reports:read"), ShouldBeTrue)
+			So(key.HasScope("presences:write"), ShouldBeFalse)
+			So(key.IsUsable(now.Add(2*time.Hour)), ShouldBeFalse)
+
+			key.RevokedAt = &now
+			So(key.IsUsable(now), ShouldBeFalse)
+		})
+
+		Convey("Every known scope can be granted, others can't", func() {
+			req := dto.ApiKeyRequest{Name: "Payroll export", Scopes: constants.ApiKeyScopes}
+			_, err := helpers.ValidatePayloads(req)
+			So(err, ShouldBeNil)
+
+			req.Scopes = []string{"presences:read", "api-keys:write"}
+			errorsMap, err := helpers.ValidatePayloads(req)
+			So(err, ShouldNotBeNil)
+			So(errorsMap, ShouldNotBeEmpty)
+
+			req.Scopes = []string{"presences:read"}
+			req.ExpiresInDays = constants.ApiKeyMaxTTLDays + 1
+			errorsMap, err = helpers.ValidatePayloads(req)
+			So(err, ShouldNotBeNil)
+			So(errorsMap, ShouldContainKey, "expiresindays")
+		})
+	})
+}
@@ -0,0 +1,61 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestBuildMonthlyAttendance checks the per-day rows and totals of a monthly report
+func TestBuildMonthlyAttendance(t *testing.T) {
+	location := helpers.PresenceLocation()
+	schedule := &models.Schedule{Id: 1, InTime: "09:00:00", OutTime: "17:00:00"}
+	user := &models.User{Id: 2, Name: "Employee1", Department: &models.Department{Id: 1}, Schedule: schedule}
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, time.December, day, hour, minute, 0, 0, location)
+	}
+
+	presences := []*models.Presence{
+		// Monday 2nd: on time, full day
+		{User: user, Schedule: schedule, Type: constants.PresenceTypeIn, Status: constants.PresenceStatusOnTime, CreatedAt: at(2, 8, 55)},
+		{User: user, Schedule: schedule, Type: constants.PresenceTypeOut, Status: constants.PresenceStatusOnTime, CreatedAt: at(2, 17, 0)},
+		// Tuesday 3rd: late by 30 minutes, no check-out
+		{User: user, Schedule: schedule, Type: constants.PresenceTypeIn, Status: constants.PresenceStatusLate, CreatedAt: at(3, 9, 30)},
+	}
+	holidays := []*models.Holiday{{Date: time.Date(2024, time.December, 25, 0, 0, 0, 0, time.Local), Name: "Christmas Day"}}
+	leaves := []*models.Leave{{User: user, StartDate: time.Date(2024, time.December, 4, 0, 0, 0, 0, time.Local), EndDate: time.Date(2024, time.December, 5, 0, 0, 0, 0, time.Local), Reason: "Annual leave"}}
+
+	monthStart, _ := helpers.ParseReportMonth("2024-12", time.Now())
+	report := helpers.BuildMonthlyAttendance(user, monthStart, presences, holidays, leaves, time.Date(2025, time.January, 2, 8, 0, 0, 0, location))
+
+	Convey("Subject: Monthly attendance report\n", t, func() {
+		Convey("Every day of the month has a row", func() {
+			So(len(report.Days), ShouldEqual, 31)
+		})
+		Convey("Worked and late minutes are computed from the presences", func() {
+			So(report.Days[1].Status, ShouldEqual, constants.ReportDayStatusPresent)
+			So(report.Days[1].WorkedMinutes, ShouldEqual, 485)
+			So(report.Days[2].Status, ShouldEqual, constants.ReportDayStatusLate)
+			So(report.Days[2].LateMinutes, ShouldEqual, 30)
+		})
+		Convey("Leaves, holidays and weekends are marked instead of absent", func() {
+			So(report.Days[3].Marker, ShouldEqual, constants.ReportDayMarkerLeave)
+			So(report.Days[24].Marker, ShouldEqual, constants.ReportDayMarkerHoliday)
+			So(report.Days[0].Marker, ShouldEqual, constants.ReportDayMarkerWeekend)
+		})
+		Convey("Totals summarize the month", func() {
+			So(report.Totals.DaysPresent, ShouldEqual, 2)
+			So(report.Totals.LateCount, ShouldEqual, 1)
+			So(report.Totals.LeaveCount, ShouldEqual, 2)
+			So(report.Totals.TotalLateMinutes, ShouldEqual, 30)
+			// 22 weekdays minus Christmas and two days of leave, minus the two worked days
+			So(report.Totals.WorkingDays, ShouldEqual, 19)
+			So(report.Totals.AbsentCount, ShouldEqual, 17)
+		})
+	})
+}
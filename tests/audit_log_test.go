@@ -0,0 +1,129 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestAuditLog checks the changes recorded in the audit log and its filters
+func TestAuditLog(t *testing.T) {
+	Convey("Subject: Audit log\n", t, func() {
+		user := &models.User{Id: 2}
+		schedule := &models.Schedule{Id: 1}
+		presence := &models.Presence{Id: 42, User: user, Schedule: schedule, Type: "in", Status: "late", UpdatedAt: time.Now()}
+
+		Convey("Updates record the changed fields only", func() {
+			before := dto.FromPresenceModelToPresenceResponse(presence, false, false)
+			presence.Status = "ontime"
+			presence.UpdatedAt = presence.UpdatedAt.Add(time.Minute)
+			after := dto.FromPresenceModelToPresenceResponse(presence, false, false)
+
+			changes, err := helpers.AuditDiff(before, after)
+			So(err, ShouldBeNil)
+			So(changes, ShouldHaveLength, 1)
+			So(changes["status"], ShouldResemble, helpers.AuditChange{Before: "late", After: "ontime"})
+		})
+
+		Convey("Creations and deletions record every field", func() {
+			var deleted *dto.PresenceResponse
+			changes, err := helpers.AuditDiff(deleted, dto.FromPresenceModelToPresenceResponse(presence, false, false))
+			So(err, ShouldBeNil)
+			So(changes["status"], ShouldResemble, helpers.AuditChange{After: "late"})
+
+			changes, err = helpers.AuditDiff(dto.FromPresenceModelToPresenceResponse(presence, false, false), nil)
+			So(err, ShouldBeNil)
+			So(changes["status"], ShouldResemble, helpers.AuditChange{Before: "late"})
+		})
+
+		Convey("The date range includes the last day", func() {
+			from, to, err := helpers.ParseAuditDateRange("2024-12-01", "2024-12-01")
+			So(err, ShouldBeNil)
+			So(to.Sub(*from), ShouldEqual, 24*time.Hour)
+
+			from, to, err = helpers.ParseAuditDateRange("", "")
+			So(err, ShouldBeNil)
+			So(from, ShouldBeNil)
+			So(to, ShouldBeNil)
+
+			_, _, err = helpers.ParseAuditDateRange("2024-12-02", "2024-12-01")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("The changes are returned as JSON", func() {
+			entry := &models.AuditLog{Id: 1, ActorId: 1, Action: "update", EntityType: "presence", EntityId: 42, Changes: `{"status":{"before":"late","after":"ontime"}}`}
+			body, _ := json.Marshal(dto.FromAuditLogModelToAuditLogResponse(entry))
+			So(string(body), ShouldContainSubstring, `"changes":{"status":{"before":"late","after":"ontime"}}`)
+		})
+
+		Convey("API keys read the audit log with their own scope", func() {
+			scope, ok := helpers.ApiKeyScopeForRequest("/api/v1/audit-logs", "GET")
+			So(ok, ShouldBeTrue)
+			So(scope, ShouldEqual, "audit-logs:read")
+		})
+	})
+}
+
+// TestAuditLogTransactions checks against the test database that the audit records are saved with their change
+func TestAuditLogTransactions(t *testing.T) {
+	requireTestDatabase(t)
+	resetTestDatabase(t)
+
+	engineering := seedDepartment(t, "Engineering")
+	sales := seedDepartment(t, "Sales")
+	shift := seedSchedule(t, engineering, "09:00:00", "17:00:00")
+	alice := seedUser(t, "Alice", engineering, shift)
+	actor := models.AuditActor{Id: 1, Ip: "10.0.0.1"}
+
+	Convey("Subject: Audit log transactions\n", t, func() {
+		Convey("A change isn't saved when its audit record fails", func() {
+			failing := models.AuditEntry(func() (*models.AuditLog, error) {
+				return nil, errors.New("audit log unavailable")
+			})
+			err := models.CreateDepartment(&models.Department{Name: "Support"}, failing)
+			So(err, ShouldNotBeNil)
+
+			_, err = models.GetDepartmentByName("Support")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("SCIM provisioning is recorded", func() {
+			active := true
+			req := &dto.ScimUserRequest{
+				UserName:   "jane@example.com",
+				Name:       &dto.ScimName{Formatted: "Jane Doe"},
+				Active:     &active,
+				Roles:      []dto.ScimMultiValue{{Value: constants.RoleEmployee, Primary: true}},
+				Enterprise: &dto.ScimEnterpriseUser{Department: "Engineering"},
+			}
+			user, err := helpers.SaveScimUser(nil, req, actor, time.Now())
+			So(err, ShouldBeNil)
+
+			entries, err := models.GetAuditLogs(models.AuditLogFilter{Action: constants.AuditActionCreate, EntityType: constants.AuditEntityUser, EntityId: user.Id})
+			So(err, ShouldBeNil)
+			So(entries, ShouldHaveLength, 1)
+			So(entries[0].ActorId, ShouldEqual, actor.Id)
+			So(entries[0].Ip, ShouldEqual, actor.Ip)
+			So(entries[0].Changes, ShouldContainSubstring, `"email":{"before":null,"after":"jane@example.com"}`)
+		})
+
+		Convey("Users moved by the deletion of their department are recorded", func() {
+			_, err := models.DeleteDepartment(engineering.Id, sales, actor)
+			So(err, ShouldBeNil)
+
+			entries, err := models.GetAuditLogs(models.AuditLogFilter{EntityType: constants.AuditEntityUser, EntityId: alice.Id})
+			So(err, ShouldBeNil)
+			So(entries, ShouldNotBeEmpty)
+			So(entries[0].Action, ShouldEqual, constants.AuditActionUpdate)
+			So(entries[0].Changes, ShouldContainSubstring, `"department_id":{"before":`)
+		})
+	})
+}
@@ -0,0 +1,46 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestChangePassword checks the token versions revoking old sessions and the validation of the password change
+func TestChangePassword(t *testing.T) {
+	Convey("Subject: Change password\n", t, func() {
+		Convey("Tokens carry the token version of the user", func() {
+			token, err := helpers.GenerateJWT(1, "employee1@example.com", "EMPLOYEE", 3)
+			So(err, ShouldBeNil)
+
+			claims, err := helpers.ParseJWT(token)
+			So(err, ShouldBeNil)
+			So(helpers.GetTokenVersionFromMapClaims(claims), ShouldEqual, 3)
+		})
+		Convey("Tokens without a version have version 0", func() {
+			So(helpers.GetTokenVersionFromMapClaims(map[string]interface{}{"sub": 1}), ShouldEqual, 0)
+		})
+		Convey("The current password can't be reused", func() {
+			hashedPassword, err := helpers.HashPassword("Mys3cur3P@5s")
+			So(err, ShouldBeNil)
+
+			reused, err := helpers.IsPasswordReused(&models.User{Id: 1, Password: hashedPassword}, "Mys3cur3P@5s")
+			So(err, ShouldBeNil)
+			So(reused, ShouldBeTrue)
+		})
+		Convey("The new password must be secure", func() {
+			req := dto.ChangePasswordRequest{CurrentPassword: "1234", NewPassword: "password"}
+			errorsMap, err := helpers.ValidatePayloads(req)
+			So(err, ShouldNotBeNil)
+			So(errorsMap, ShouldContainKey, "newpassword")
+
+			req.NewPassword = "N3wS3cur3P@5s"
+			_, err = helpers.ValidatePayloads(req)
+			So(err, ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,148 @@
+package test
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/database"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// The database tests run against the PostgreSQL database of the DSN in TEST_DATABASE_DSN, e.g.
+// "user=postgres password=postgres dbname=presence_test host=localhost sslmode=disable". They empty its tables and
+// roll its migrations back and forth, so it must be a database of its own. They are skipped while it isn't set.
+const testDatabaseEnv = "TEST_DATABASE_DSN"
+
+var testDatabase struct {
+	once sync.Once
+	err  error
+}
+
+// requireTestDatabase connects to the test database and applies the migrations, or skips the test without one
+func requireTestDatabase(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv(testDatabaseEnv)
+	if dsn == "" {
+		t.Skipf("%s isn't set", testDatabaseEnv)
+	}
+
+	testDatabase.once.Do(func() {
+		if testDatabase.err = database.Connect(dsn); testDatabase.err != nil {
+			return
+		}
+		_, testDatabase.err = database.Migrate()
+	})
+	if testDatabase.err != nil {
+		t.Fatalf("Failed to prepare the test database: %v", testDatabase.err)
+	}
+}
+
+// resetTestDatabase empties the tables of the schema, the applied migrations are kept
+func resetTestDatabase(t *testing.T) {
+	t.Helper()
+	o := orm.NewOrm()
+	var tables orm.ParamsList
+	if _, err := o.Raw(`SELECT quote_ident(tablename) AS tablename FROM pg_tables WHERE schemaname = current_schema() AND tablename <> 'schema_migrations'`).ValuesFlat(&tables); err != nil {
+		t.Fatalf("Failed to list the tables of the test database: %v", err)
+	}
+	if len(tables) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(tables))
+	for _, table := range tables {
+		names = append(names, table.(string))
+	}
+	if _, err := o.Raw("TRUNCATE " + strings.Join(names, ", ") + " RESTART IDENTITY CASCADE").Exec(); err != nil {
+		t.Fatalf("Failed to empty the test database: %v", err)
+	}
+}
+
+// seedDepartment inserts a department
+func seedDepartment(t *testing.T, name string) *models.Department {
+	t.Helper()
+	department := &models.Department{Name: name}
+	if _, err := orm.NewOrm().Insert(department); err != nil {
+		t.Fatalf("Failed to seed department %s: %v", name, err)
+	}
+	return department
+}
+
+// seedSchedule inserts a schedule of the department
+func seedSchedule(t *testing.T, department *models.Department, inTime, outTime string) *models.Schedule {
+	t.Helper()
+	schedule := &models.Schedule{Name: inTime + "-" + outTime, Department: department, InTime: inTime, OutTime: outTime}
+	if _, err := orm.NewOrm().Insert(schedule); err != nil {
+		t.Fatalf("Failed to seed schedule %s: %v", schedule.Name, err)
+	}
+	return schedule
+}
+
+// seedUser inserts an employee of the department, schedule may be nil
+func seedUser(t *testing.T, name string, department *models.Department, schedule *models.Schedule) *models.User {
+	t.Helper()
+	user := &models.User{
+		Name:          name,
+		Email:         strings.ToLower(name) + "@example.com",
+		Role:          constants.RoleEmployee,
+		Department:    department,
+		Schedule:      schedule,
+		EmailVerified: true,
+	}
+	if _, err := orm.NewOrm().Insert(user); err != nil {
+		t.Fatalf("Failed to seed user %s: %v", name, err)
+	}
+	return user
+}
+
+// seedServiceAccount inserts a service account of the department, assigned to the schedule like integrations may be
+func seedServiceAccount(t *testing.T, name string, department *models.Department, schedule *models.Schedule) *models.User {
+	t.Helper()
+	account := &models.User{
+		Name:           name,
+		Email:          strings.ToLower(name) + "@service.example.com",
+		Role:           constants.RoleEmployee,
+		Department:     department,
+		Schedule:       schedule,
+		EmailVerified:  true,
+		ServiceAccount: true,
+	}
+	if _, err := orm.NewOrm().Insert(account); err != nil {
+		t.Fatalf("Failed to seed service account %s: %v", name, err)
+	}
+	return account
+}
+
+// seedPresence inserts a presence at the given time, which the ORM would otherwise set to the current time. The time is
+// passed with its offset, so it doesn't depend on the time zone of the database session.
+func seedPresence(t *testing.T, user *models.User, presenceType, status string, at time.Time) {
+	t.Helper()
+	_, err := orm.NewOrm().Raw(`INSERT INTO "presence" ("user_id", "schedule_id", "type", "status", "created_at", "updated_at") VALUES (?, ?, ?, ?, ?, ?)`,
+		user.Id, user.Schedule.Id, presenceType, status, at.Format(time.RFC3339), at.Format(time.RFC3339)).Exec()
+	if err != nil {
+		t.Fatalf("Failed to seed presence of %s: %v", user.Name, err)
+	}
+}
+
+// seedAdmin inserts an admin of the department, with two-factor authentication enabled as the default policy requires
+func seedAdmin(t *testing.T, name string, department *models.Department) *models.User {
+	t.Helper()
+	admin := &models.User{
+		Name:             name,
+		Email:            strings.ToLower(name) + "@example.com",
+		Role:             constants.RoleAdmin,
+		Department:       department,
+		EmailVerified:    true,
+		TwoFactorEnabled: true,
+	}
+	if _, err := orm.NewOrm().Insert(admin); err != nil {
+		t.Fatalf("Failed to seed admin %s: %v", name, err)
+	}
+	return admin
+}
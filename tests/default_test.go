@@ -18,6 +18,11 @@ func init() {
 	_, file, _, _ := runtime.Caller(0)
 	apppath, _ := filepath.Abs(filepath.Dir(filepath.Join(file, ".."+string(filepath.Separator))))
 	beego.TestBeegoInit(apppath)
+
+	// The secrets have no default and are left empty in conf/app.conf
+	beego.AppConfig.Set("presence_revision_secret", "test-presence-revision-secret")
+	beego.AppConfig.Set("secret_encryption_key", "test-secret-encryption-key")
+	beego.AppConfig.Set("email_verification_secret", "test-email-verification-secret")
 }
 
 // TestGet is a sample to run an endpoint test
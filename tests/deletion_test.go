@@ -0,0 +1,28 @@
+package test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/models"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestDeletionDependents checks how the dependents of a deleted department or schedule are reported
+func TestDeletionDependents(t *testing.T) {
+	Convey("Subject: Deletion dependents\n", t, func() {
+		Convey("Blocked deletions report the users to reassign", func() {
+			dependents := models.Dependents{Users: 12, Schedules: 2, Presences: 340}
+			body, _ := json.Marshal(dto.FromDependentsModelToDependentsResponse(dependents, 0))
+			So(string(body), ShouldEqual, `{"users":12,"schedules":2,"presences":340}`)
+		})
+
+		Convey("Reassigned deletions report the target", func() {
+			dependents := models.Dependents{Users: 4, Presences: 20}
+			body, _ := json.Marshal(dto.FromDependentsModelToDependentsResponse(dependents, 3))
+			So(string(body), ShouldEqual, `{"users":4,"presences":20,"reassigned_to":3}`)
+		})
+	})
+}
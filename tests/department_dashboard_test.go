@@ -0,0 +1,155 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/client/orm"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestDepartmentDashboard checks the aggregations of the department dashboard against the test database
+func TestDepartmentDashboard(t *testing.T) {
+	requireTestDatabase(t)
+	resetTestDatabase(t)
+
+	location := helpers.PresenceLocation()
+	at := func(day, clock string) time.Time {
+		parsed, err := time.ParseInLocation("2006-01-02 15:04", day+" "+clock, location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	// Dates are stored as the day of the value in the local time zone of the ORM
+	date := func(day string) time.Time {
+		parsed, err := time.ParseInLocation("2006-01-02", day, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	engineering := seedDepartment(t, "Engineering")
+	sales := seedDepartment(t, "Sales")
+	shift := seedSchedule(t, engineering, "09:00:00", "17:00:00")
+	salesShift := seedSchedule(t, sales, "09:00:00", "17:00:00")
+
+	alice := seedUser(t, "Alice", engineering, shift)
+	bob := seedUser(t, "Bob", engineering, shift)
+	carol := seedUser(t, "Carol", engineering, shift)
+	seedUser(t, "Dave", engineering, nil) // Without a schedule, never checks in
+	erin := seedUser(t, "Erin", sales, salesShift)
+	integration := seedServiceAccount(t, "Integration", engineering, shift) // Not staff, never counted
+	frank := seedUser(t, "Frank", engineering, shift)                       // Left the company, no longer counted
+	if err := models.DeactivateUser(frank, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Monday 2024-03-04 to Saturday 2024-03-09, Wednesday is a holiday and Tuesday and Friday have no presences
+	seedPresence(t, alice, constants.PresenceTypeIn, constants.PresenceStatusOnTime, at("2024-03-04", "08:55"))
+	seedPresence(t, alice, constants.PresenceTypeOut, constants.PresenceStatusOnTime, at("2024-03-04", "17:05"))
+	seedPresence(t, bob, constants.PresenceTypeIn, constants.PresenceStatusLate, at("2024-03-04", "09:20"))
+	seedPresence(t, alice, constants.PresenceTypeIn, constants.PresenceStatusOnTime, at("2024-03-06", "08:50"))
+	seedPresence(t, alice, constants.PresenceTypeIn, constants.PresenceStatusLate, at("2024-03-07", "09:30"))
+	seedPresence(t, bob, constants.PresenceTypeIn, constants.PresenceStatusLate, at("2024-03-07", "09:45"))
+	seedPresence(t, erin, constants.PresenceTypeIn, constants.PresenceStatusLate, at("2024-03-04", "10:00"))
+	// Check-ins outside of the range
+	seedPresence(t, bob, constants.PresenceTypeIn, constants.PresenceStatusLate, at("2024-03-03", "23:59"))
+	seedPresence(t, bob, constants.PresenceTypeIn, constants.PresenceStatusLate, at("2024-03-10", "00:00"))
+
+	o := orm.NewOrm()
+	if _, err := o.Insert(&models.Leave{User: carol, StartDate: date("2024-03-04"), EndDate: date("2024-03-05"), Reason: "Sick"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Insert(&models.Holiday{Date: date("2024-03-06"), Name: "Day of Silence"}); err != nil {
+		t.Fatal(err)
+	}
+
+	from, to := date("2024-03-04"), date("2024-03-09")
+
+	Convey("Subject: Department dashboard\n", t, func() {
+		Convey("The headcount includes users without a schedule", func() {
+			headcount, err := models.CountUsersByDepartmentId(engineering.Id)
+			So(err, ShouldBeNil)
+			So(headcount, ShouldEqual, 4)
+		})
+
+		Convey("Service accounts and deactivated users aren't listed as staff", func() {
+			userIds := func(users []*models.User, err error) []int {
+				So(err, ShouldBeNil)
+				ids := make([]int, 0, len(users))
+				for _, user := range users {
+					ids = append(ids, user.Id)
+				}
+				return ids
+			}
+			for _, id := range []int{integration.Id, frank.Id} {
+				So(userIds(models.GetUsersByDepartmentId(engineering.Id)), ShouldNotContain, id)
+				So(userIds(models.GetUsersWithSchedule()), ShouldNotContain, id)
+				So(userIds(models.GetUsersByRole(constants.RoleEmployee)), ShouldNotContain, id)
+				So(userIds(models.GetDepartmentMembers(engineering.Id)), ShouldContain, id)
+			}
+		})
+
+		Convey("Every day of the range is counted", func() {
+			days, err := models.GetDepartmentDailyAttendance(engineering.Id, from, to)
+			So(err, ShouldBeNil)
+			So(days, ShouldHaveLength, 6)
+
+			expected := []models.DepartmentDailyAttendance{
+				{Day: "2024-03-04", Present: 2, Late: 1, OnLeave: 1, Absent: 1},
+				{Day: "2024-03-05", Present: 0, Late: 0, OnLeave: 1, Absent: 3},
+				{Day: "2024-03-06", IsHoliday: true, Present: 1, Late: 0, OnLeave: 0, Absent: 0},
+				{Day: "2024-03-07", Present: 2, Late: 2, OnLeave: 0, Absent: 2},
+				{Day: "2024-03-08", Present: 0, Late: 0, OnLeave: 0, Absent: 4},
+				{Day: "2024-03-09", IsWeekend: true, Present: 0, Late: 0, OnLeave: 0, Absent: 0},
+			}
+			for i, day := range days {
+				So(*day, ShouldResemble, expected[i])
+			}
+		})
+
+		Convey("Days that didn't end yet have no absences", func() {
+			today := time.Now().In(location)
+			days, err := models.GetDepartmentDailyAttendance(engineering.Id, today, today)
+			So(err, ShouldBeNil)
+			So(days, ShouldHaveLength, 1)
+			So(days[0].Absent, ShouldEqual, 0)
+		})
+
+		Convey("Check-ins are averaged in the presence time zone", func() {
+			stats, err := models.GetDepartmentCheckInStats(engineering.Id, from, to)
+			So(err, ShouldBeNil)
+			So(stats.CheckIns, ShouldEqual, 5)
+			So(stats.OnTime, ShouldEqual, 2)
+			// 08:55, 09:20, 08:50, 09:30 and 09:45 average to 09:16
+			So(stats.AverageCheckInSeconds, ShouldAlmostEqual, float64(9*3600+16*60), 0.001)
+		})
+
+		Convey("A department without check-ins has empty stats", func() {
+			stats, err := models.GetDepartmentCheckInStats(engineering.Id, date("2024-03-05"), date("2024-03-05"))
+			So(err, ShouldBeNil)
+			So(stats.CheckIns, ShouldEqual, 0)
+			So(stats.OnTime, ShouldEqual, 0)
+			So(stats.AverageCheckInSeconds, ShouldEqual, 0)
+		})
+
+		Convey("Late arrivals are ranked by count and minutes", func() {
+			arrivals, err := models.GetDepartmentTopLateArrivals(engineering.Id, from, to, 5)
+			So(err, ShouldBeNil)
+			So(arrivals, ShouldHaveLength, 2)
+			So(*arrivals[0], ShouldResemble, models.DepartmentLateArrival{UserId: bob.Id, Name: "Bob", LateCount: 2, LateMinutes: 65})
+			So(*arrivals[1], ShouldResemble, models.DepartmentLateArrival{UserId: alice.Id, Name: "Alice", LateCount: 1, LateMinutes: 30})
+
+			arrivals, err = models.GetDepartmentTopLateArrivals(engineering.Id, from, to, 1)
+			So(err, ShouldBeNil)
+			So(arrivals, ShouldHaveLength, 1)
+			So(arrivals[0].UserId, ShouldEqual, bob.Id)
+		})
+	})
+}
@@ -0,0 +1,113 @@
+package test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/client/orm"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestEmailVerification checks the signed verification links and the email domains allowed per department
+func TestEmailVerification(t *testing.T) {
+	Convey("Subject: Email verification\n", t, func() {
+		now := time.Now()
+
+		Convey("Signed tokens confirm the user and the email", func() {
+			token := helpers.SignEmailVerificationToken(7, "Employee1@Example.com", now.Add(time.Hour))
+			userId, email, err := helpers.ParseEmailVerificationToken(token, now)
+			So(err, ShouldBeNil)
+			So(userId, ShouldEqual, 7)
+			So(email, ShouldEqual, "employee1@example.com")
+		})
+		Convey("Expired tokens are rejected", func() {
+			token := helpers.SignEmailVerificationToken(7, "employee1@example.com", now.Add(time.Hour))
+			_, _, err := helpers.ParseEmailVerificationToken(token, now.Add(time.Hour))
+			So(err, ShouldEqual, helpers.ErrEmailVerificationTokenInvalid)
+		})
+		Convey("Tampered tokens are rejected", func() {
+			token := helpers.SignEmailVerificationToken(7, "employee1@example.com", now.Add(time.Hour))
+			forged := helpers.SignEmailVerificationToken(8, "employee1@example.com", now.Add(time.Hour))
+			payload, _, _ := strings.Cut(forged, ".")
+			_, signature, _ := strings.Cut(token, ".")
+
+			_, _, err := helpers.ParseEmailVerificationToken(payload+"."+signature, now)
+			So(err, ShouldEqual, helpers.ErrEmailVerificationTokenInvalid)
+			_, _, err = helpers.ParseEmailVerificationToken("not-a-token", now)
+			So(err, ShouldEqual, helpers.ErrEmailVerificationTokenInvalid)
+		})
+		Convey("Departments without allowed domains accept any email", func() {
+			department := &models.Department{Name: "Engineering"}
+			So(department.AllowsEmail("someone@anywhere.org"), ShouldBeTrue)
+		})
+		Convey("Departments with allowed domains only accept those", func() {
+			req := dto.DepartmentRequest{Name: "Engineering", AllowedEmailDomains: []string{"Example.com", "corp.example.com"}}
+			_, err := helpers.ValidatePayloads(req)
+			So(err, ShouldBeNil)
+
+			department := req.ToDepartmentModel()
+			So(department.GetAllowedEmailDomains(), ShouldResemble, []string{"example.com", "corp.example.com"})
+			So(department.AllowsEmail("employee1@EXAMPLE.com"), ShouldBeTrue)
+			So(department.AllowsEmail("employee1@corp.example.com"), ShouldBeTrue)
+			So(department.AllowsEmail("employee1@example.com.evil.org"), ShouldBeFalse)
+			So(department.AllowsEmail("employee1"), ShouldBeFalse)
+		})
+		Convey("Verification emails render the link", func() {
+			message, err := helpers.RenderNotification(constants.NotificationTypeEmailVerification, map[string]interface{}{
+				"User":            map[string]string{"Name": "Employee1"},
+				"VerificationUrl": "http://localhost:8080/api/v1/auth/verify-email?token=abc",
+				"ExpiresHours":    72,
+			})
+			So(err, ShouldBeNil)
+			So(message.Subject, ShouldEqual, "Verify your email address")
+			So(message.Text, ShouldContainSubstring, "verify-email?token=abc")
+			So(message.HTML, ShouldContainSubstring, "verify-email?token=abc")
+		})
+	})
+}
+
+// TestEmailVerificationEvent checks that verifying the email records the event the welcome notification is sent on
+func TestEmailVerificationEvent(t *testing.T) {
+	requireTestDatabase(t)
+	resetTestDatabase(t)
+
+	department := seedDepartment(t, "Engineering")
+	alice := seedUser(t, "Alice", department, nil)
+	bob := seedUser(t, "Bob", department, nil)
+	if _, err := orm.NewOrm().Raw(`UPDATE "user" SET email_verified = false WHERE id = ?`, alice.Id).Exec(); err != nil {
+		t.Fatalf("Failed to unverify %s: %v", alice.Name, err)
+	}
+	countVerifiedEvents := func() int64 {
+		count, err := orm.NewOrm().QueryTable(new(models.OutboxEvent)).Filter("Event", constants.EventUserVerified).Count()
+		So(err, ShouldBeNil)
+		return count
+	}
+
+	Convey("Subject: Email verification event\n", t, func() {
+		now := time.Now()
+
+		Convey("Verifying the email records the event once", func() {
+			token := helpers.SignEmailVerificationToken(alice.Id, alice.Email, now.Add(time.Hour))
+			user, err := helpers.VerifyEmail(token, now)
+			So(err, ShouldBeNil)
+			So(user.EmailVerified, ShouldBeTrue)
+			So(countVerifiedEvents(), ShouldEqual, 1)
+
+			_, err = helpers.VerifyEmail(token, now)
+			So(err, ShouldBeNil)
+			So(countVerifiedEvents(), ShouldEqual, 1)
+		})
+		Convey("Users created verified don't record the event", func() {
+			token := helpers.SignEmailVerificationToken(bob.Id, bob.Email, now.Add(time.Hour))
+			_, err := helpers.VerifyEmail(token, now)
+			So(err, ShouldBeNil)
+			So(countVerifiedEvents(), ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,146 @@
+package test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/controllers"
+	"github.com/snykk/beego-presence-api/helpers"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestExport checks the format negotiation, the column selection and the CSV and XLSX writers of the file exports
+func TestExport(t *testing.T) {
+	columns := []helpers.ExportColumn{{Key: "id", Header: "ID"}, {Key: "name", Header: "Name"}, {Key: "date", Header: "Date"}}
+
+	Convey("Subject: File exports\n", t, func() {
+		Convey("The format parameter takes precedence over the Accept header", func() {
+			format, err := helpers.NegotiateExportFormat("XLSX", constants.ExportMimeCSV)
+			So(err, ShouldBeNil)
+			So(format, ShouldEqual, constants.ExportFormatXLSX)
+
+			format, err = helpers.NegotiateExportFormat("json", constants.ExportMimeCSV)
+			So(err, ShouldBeNil)
+			So(format, ShouldBeEmpty)
+
+			_, err = helpers.NegotiateExportFormat("pdf", "")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("The Accept header selects the first supported media type", func() {
+			format, err := helpers.NegotiateExportFormat("", "application/json;q=0.9, "+constants.ExportMimeXLSX+";q=0.8, text/csv")
+			So(err, ShouldBeNil)
+			So(format, ShouldEqual, constants.ExportFormatXLSX)
+
+			format, err = helpers.NegotiateExportFormat("", "text/csv; charset=utf-8")
+			So(err, ShouldBeNil)
+			So(format, ShouldEqual, constants.ExportFormatCSV)
+
+			format, err = helpers.NegotiateExportFormat("", "application/json, */*")
+			So(err, ShouldBeNil)
+			So(format, ShouldBeEmpty)
+		})
+
+		Convey("Columns are exported in the requested order", func() {
+			selected, err := helpers.SelectExportColumns(columns, "")
+			So(err, ShouldBeNil)
+			So(selected, ShouldResemble, columns)
+
+			selected, err = helpers.SelectExportColumns(columns, "date, id")
+			So(err, ShouldBeNil)
+			So(selected, ShouldResemble, []helpers.ExportColumn{columns[2], columns[0]})
+			So(helpers.ExportRowValues(selected, map[string]string{"id": "7", "name": "Alice", "date": "2024-03-04"}), ShouldResemble, []string{"2024-03-04", "7"})
+
+			_, err = helpers.SelectExportColumns(columns, "id,password")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Unknown locales fall back to ISO 8601", func() {
+			So(helpers.GetExportLayouts("en_US").Date, ShouldEqual, "01/02/2006")
+			So(helpers.GetExportLayouts("xx-XX").Date, ShouldEqual, "2006-01-02")
+		})
+
+		Convey("CSV values that would be evaluated as formulas are neutralized", func() {
+			recorder := httptest.NewRecorder()
+			writer, err := helpers.StartExport(recorder, constants.ExportFormatCSV, "presences", columns[:2])
+			So(err, ShouldBeNil)
+			for _, row := range [][]string{{"1", "=1+2"}, {"2", "+1"}, {"3", "-2+3"}, {"4", "@SUM(A1:A2)"}, {"5", "-cmd|' /C calc'!A0"}, {"6", "\tTab"}, {"7", "Alice - Bob"}} {
+				So(writer.WriteRow(row), ShouldBeNil)
+			}
+			So(writer.Close(), ShouldBeNil)
+
+			So(recorder.Header().Get("Content-Type"), ShouldEqual, "text/csv; charset=utf-8")
+			So(recorder.Header().Get("Content-Disposition"), ShouldEqual, `attachment; filename="presences.csv"`)
+
+			records, err := csv.NewReader(recorder.Body).ReadAll()
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, [][]string{
+				{"ID", "Name"},
+				{"1", "'=1+2"},
+				{"2", "'+1"},
+				{"3", "'-2+3"},
+				{"4", "'@SUM(A1:A2)"},
+				{"5", "'-cmd|' /C calc'!A0"},
+				{"6", "'\tTab"},
+				{"7", "Alice - Bob"},
+			})
+		})
+
+		Convey("XLSX rows are written as numeric and inline string cells", func() {
+			recorder := httptest.NewRecorder()
+			writer, err := helpers.StartExport(recorder, constants.ExportFormatXLSX, "presences", columns)
+			So(err, ShouldBeNil)
+			So(writer.WriteRow([]string{"42", "=HYPERLINK(\"x\") & <b>", "007"}), ShouldBeNil)
+			So(writer.Flush(), ShouldBeNil)
+			So(writer.Close(), ShouldBeNil)
+
+			So(recorder.Header().Get("Content-Type"), ShouldEqual, constants.ExportMimeXLSX)
+			So(recorder.Header().Get("Content-Disposition"), ShouldEqual, `attachment; filename="presences.xlsx"`)
+
+			archive, err := zip.NewReader(bytes.NewReader(recorder.Body.Bytes()), int64(recorder.Body.Len()))
+			So(err, ShouldBeNil)
+			parts := map[string]string{}
+			for _, file := range archive.File {
+				reader, err := file.Open()
+				So(err, ShouldBeNil)
+				content, err := io.ReadAll(reader)
+				So(err, ShouldBeNil)
+				parts[file.Name] = string(content)
+			}
+			So(parts, ShouldContainKey, "[Content_Types].xml")
+			So(parts["xl/workbook.xml"], ShouldContainSubstring, `<sheet name="presences"`)
+
+			sheet := parts["xl/worksheets/sheet1.xml"]
+			So(sheet, ShouldContainSubstring, `<c r="A1" t="inlineStr"><is><t xml:space="preserve">ID</t></is></c>`)
+			So(sheet, ShouldContainSubstring, `<c r="A2"><v>42</v></c>`)
+			// Formulas are only evaluated from <f> elements, text that looks like one stays text
+			So(sheet, ShouldContainSubstring, `<c r="B2" t="inlineStr"><is><t xml:space="preserve">=HYPERLINK(&#34;x&#34;) &amp; &lt;b&gt;</t></is></c>`)
+			So(sheet, ShouldNotContainSubstring, "<f>")
+			// Leading zeros are kept by storing the value as text
+			So(sheet, ShouldContainSubstring, `<c r="C2" t="inlineStr"><is><t xml:space="preserve">007</t></is></c>`)
+		})
+
+		Convey("Invalid export requests of the presences are rejected before the download starts", func() {
+			ctx, recorder := newControllerContext(http.MethodGet, "/api/v1/presences?format=pdf", nil, 1, constants.RoleEmployee)
+			controller := &controllers.PresenceController{}
+			controller.Init(ctx, "PresenceController", "GetAll", controller)
+			controller.GetAll()
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+
+			ctx, recorder = newControllerContext(http.MethodGet, "/api/v1/presences?columns=id,password", nil, 1, constants.RoleEmployee)
+			ctx.Request.Header.Set("Accept", constants.ExportMimeCSV)
+			controller = &controllers.PresenceController{}
+			controller.Init(ctx, "PresenceController", "GetAll", controller)
+			controller.GetAll()
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			So(recorder.Header().Get("Content-Disposition"), ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,258 @@
+package test
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/helpers"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// mockLDAPEntry is an entry of the mock directory
+type mockLDAPEntry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+// values returns the values of the attribute, attribute names are case insensitive
+func (e mockLDAPEntry) values(name string) []string {
+	for attribute, values := range e.attributes {
+		if strings.EqualFold(attribute, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+// mockLDAPServer is a minimal LDAP server answering simple binds and searches with equality, presence, and and or filters
+type mockLDAPServer struct {
+	listener net.Listener
+	entries  []mockLDAPEntry
+}
+
+func newMockLDAPServer(entries []mockLDAPEntry) *mockLDAPServer {
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	server := &mockLDAPServer{listener: listener, entries: entries}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *mockLDAPServer) Url() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *mockLDAPServer) Close() {
+	s.listener.Close()
+}
+
+func (s *mockLDAPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		request, err := ber.ReadPacket(conn)
+		if err != nil || len(request.Children) < 2 {
+			return
+		}
+		messageId := request.Children[0].Value.(int64)
+		operation := request.Children[1]
+
+		switch operation.Tag {
+		case ber.Tag(0): // Bind
+			dn := operation.Children[1].Value.(string)
+			password := operation.Children[2].Data.String()
+			code := int64(49) // Invalid credentials
+			for _, entry := range s.entries {
+				if strings.EqualFold(entry.dn, dn) && entry.password != "" && entry.password == password {
+					code = 0
+				}
+			}
+			conn.Write(mockLDAPMessage(messageId, 1, mockLDAPResult(code)...).Bytes())
+		case ber.Tag(2): // Unbind
+			return
+		case ber.Tag(3): // Search
+			base := strings.ToLower(operation.Children[0].Value.(string))
+			for _, entry := range s.entries {
+				if !strings.HasSuffix(strings.ToLower(entry.dn), base) || !mockLDAPMatch(operation.Children[6], entry) {
+					continue
+				}
+				attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+				for name, values := range entry.attributes {
+					attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+					attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, ""))
+					set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+					for _, value := range values {
+						set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, ""))
+					}
+					attribute.AppendChild(set)
+					attributes.AppendChild(attribute)
+				}
+				dn := ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.dn, "")
+				conn.Write(mockLDAPMessage(messageId, 4, dn, attributes).Bytes())
+			}
+			conn.Write(mockLDAPMessage(messageId, 5, mockLDAPResult(0)...).Bytes())
+		default:
+			conn.Write(mockLDAPMessage(messageId, operation.Tag+1, mockLDAPResult(53)...).Bytes()) // Unwilling to perform
+		}
+	}
+}
+
+func mockLDAPMessage(messageId int64, tag ber.Tag, children ...*ber.Packet) *ber.Packet {
+	message := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	message.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageId, ""))
+	operation := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	for _, child := range children {
+		operation.AppendChild(child)
+	}
+	message.AppendChild(operation)
+	return message
+}
+
+func mockLDAPResult(code int64) []*ber.Packet {
+	return []*ber.Packet{
+		ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, ""),
+		ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""),
+		ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""),
+	}
+}
+
+func mockLDAPMatch(filter *ber.Packet, entry mockLDAPEntry) bool {
+	switch filter.Tag {
+	case ber.Tag(0): // And
+		for _, child := range filter.Children {
+			if !mockLDAPMatch(child, entry) {
+				return false
+			}
+		}
+		return true
+	case ber.Tag(1): // Or
+		for _, child := range filter.Children {
+			if mockLDAPMatch(child, entry) {
+				return true
+			}
+		}
+		return false
+	case ber.Tag(3): // Equality
+		for _, value := range entry.values(filter.Children[0].Value.(string)) {
+			if strings.EqualFold(value, filter.Children[1].Value.(string)) {
+				return true
+			}
+		}
+		return false
+	case ber.Tag(7): // Present
+		return len(entry.values(filter.Data.String())) > 0
+	}
+	return false
+}
+
+func newTestLDAPDirectory(server *mockLDAPServer) *helpers.LDAPDirectory {
+	return helpers.NewLDAPDirectory(helpers.LDAPConfig{
+		Url:              server.Url(),
+		BindDn:           "cn=service,dc=example,dc=org",
+		BindPassword:     "service-secret",
+		BaseDn:           "dc=example,dc=org",
+		GroupDepartments: helpers.ParseLDAPGroupMapping("cn=engineering,ou=groups,dc=example,dc=org:Engineering"),
+		GroupRoles: helpers.ParseLDAPGroupMapping("cn=leads, ou=groups, dc=example, dc=org:MANAGER;" +
+			"CN=Admins,OU=Groups,DC=example,DC=org:ADMIN"),
+		DefaultDepartment: "General",
+	})
+}
+
+func TestLDAPDirectory(t *testing.T) {
+	server := newMockLDAPServer([]mockLDAPEntry{
+		{dn: "cn=service,dc=example,dc=org", password: "service-secret"},
+		{dn: "uid=jane,ou=people,dc=example,dc=org", password: "jane-secret", attributes: map[string][]string{
+			"objectClass": {"person"},
+			"uid":         {"jane"},
+			"mail":        {"Jane@Example.org"},
+			"cn":          {"Jane Doe"},
+			"memberOf":    {"cn=engineering,ou=groups,dc=example,dc=org", "cn=leads,ou=groups,dc=example,dc=org"},
+		}},
+		{dn: "uid=john,ou=people,dc=example,dc=org", password: "john-secret", attributes: map[string][]string{
+			"objectClass": {"person"},
+			"uid":         {"john"},
+			"mail":        {"john@example.org"},
+			"cn":          {"John Roe"},
+			"memberOf":    {"cn=leads,ou=groups,dc=example,dc=org", "cn=admins,ou=groups,dc=example,dc=org"},
+		}},
+		{dn: "cn=printer,ou=devices,dc=example,dc=org", attributes: map[string][]string{
+			"objectClass": {"device"},
+			"mail":        {"printer@example.org"},
+		}},
+	})
+	defer server.Close()
+	directory := newTestLDAPDirectory(server)
+
+	Convey("Subject: LDAP directory\n", t, func() {
+		Convey("A user binds with the password of the directory entry", func() {
+			user, err := directory.Authenticate("jane@example.org", "jane-secret")
+			So(err, ShouldBeNil)
+			So(user.Dn, ShouldEqual, "uid=jane,ou=people,dc=example,dc=org")
+			So(user.Username, ShouldEqual, "jane")
+			So(user.Email, ShouldEqual, "jane@example.org")
+			So(user.Name, ShouldEqual, "Jane Doe")
+		})
+
+		Convey("A wrong or empty password is rejected", func() {
+			_, err := directory.Authenticate("jane@example.org", "wrong")
+			So(err, ShouldEqual, helpers.ErrInvalidCredentials)
+			_, err = directory.Authenticate("jane@example.org", "")
+			So(err, ShouldEqual, helpers.ErrInvalidCredentials)
+		})
+
+		Convey("Unknown emails and entries that aren't users are unknown accounts", func() {
+			_, err := directory.Authenticate("nobody@example.org", "jane-secret")
+			So(err, ShouldEqual, helpers.ErrUnknownAccount)
+			_, err = directory.FindUserByEmail("printer@example.org")
+			So(err, ShouldEqual, helpers.ErrUnknownAccount)
+		})
+
+		Convey("Filter characters in the email can't widen the search", func() {
+			_, err := directory.Authenticate("*)(uid=*", "jane-secret")
+			So(err, ShouldEqual, helpers.ErrUnknownAccount)
+		})
+
+		Convey("A wrong service account fails with a directory error", func() {
+			broken := helpers.NewLDAPDirectory(helpers.LDAPConfig{Url: server.Url(), BindDn: "cn=service,dc=example,dc=org", BindPassword: "wrong", BaseDn: "dc=example,dc=org"})
+			_, err := broken.Authenticate("jane@example.org", "jane-secret")
+			So(err, ShouldNotBeNil)
+			So(err, ShouldNotEqual, helpers.ErrInvalidCredentials)
+		})
+
+		Convey("Listing returns the user entries only", func() {
+			users, err := directory.ListUsers()
+			So(err, ShouldBeNil)
+			So(len(users), ShouldEqual, 2)
+		})
+
+		Convey("Groups map to the highest role and the first mapped department", func() {
+			jane, _ := directory.FindUserByEmail("jane@example.org")
+			So(directory.MapRole(jane), ShouldEqual, constants.RoleManager)
+			So(directory.MapDepartment(jane), ShouldEqual, "Engineering")
+
+			john, _ := directory.FindUserByEmail("john@example.org")
+			So(directory.MapRole(john), ShouldEqual, constants.RoleAdmin)
+			So(directory.MapDepartment(john), ShouldEqual, "General")
+
+			So(directory.MapRole(&helpers.DirectoryUser{}), ShouldEqual, constants.RoleEmployee)
+		})
+	})
+}
+
+func TestParseLDAPGroupMapping(t *testing.T) {
+	Convey("Subject: LDAP group mapping\n", t, func() {
+		mapping := helpers.ParseLDAPGroupMapping(" CN=HR, OU=Groups,DC=example,DC=org : Human Resources ;invalid;:ADMIN;cn=empty,dc=org:")
+		So(mapping, ShouldResemble, map[string]string{"cn=hr,ou=groups,dc=example,dc=org": "Human Resources"})
+	})
+}
@@ -0,0 +1,64 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestLoginGuard checks the progressive delays and the lockout of accounts after failed passwords
+func TestLoginGuard(t *testing.T) {
+	Convey("Subject: Login brute-force protection\n", t, func() {
+		policy := helpers.LoginPolicy{
+			MaxFailures:   5,
+			Lockout:       15 * time.Minute,
+			IpMaxFailures: 20,
+			IpWindow:      15 * time.Minute,
+			DelayBase:     time.Second,
+			DelayMax:      30 * time.Second,
+		}
+		now := time.Date(2024, 12, 2, 8, 0, 0, 0, time.UTC)
+
+		Convey("Accounts without failed passwords can log in right away", func() {
+			retryAfter, reason := policy.AccountRetryAfter(&models.User{}, now)
+			So(retryAfter, ShouldEqual, 0)
+			So(reason, ShouldBeEmpty)
+		})
+		Convey("Every failed password doubles the wait", func() {
+			lastFailure := now.Add(-time.Second)
+			user := &models.User{FailedLoginAttempts: 3, LastFailedLoginAt: &lastFailure}
+			retryAfter, reason := policy.AccountRetryAfter(user, now)
+			So(retryAfter, ShouldEqual, 3*time.Second)
+			So(reason, ShouldEqual, constants.LoginReasonThrottled)
+
+			retryAfter, _ = policy.AccountRetryAfter(user, now.Add(3*time.Second))
+			So(retryAfter, ShouldEqual, 0)
+		})
+		Convey("The wait is capped", func() {
+			user := &models.User{FailedLoginAttempts: 20, LastFailedLoginAt: &now}
+			retryAfter, _ := policy.AccountRetryAfter(user, now)
+			So(retryAfter, ShouldEqual, policy.DelayMax)
+		})
+		Convey("Locked accounts wait until the lock expires", func() {
+			lockedUntil := now.Add(10 * time.Minute)
+			user := &models.User{LockedUntil: &lockedUntil}
+			retryAfter, reason := policy.AccountRetryAfter(user, now)
+			So(retryAfter, ShouldEqual, 10*time.Minute)
+			So(reason, ShouldEqual, constants.LoginReasonLocked)
+
+			retryAfter, _ = policy.AccountRetryAfter(user, lockedUntil)
+			So(retryAfter, ShouldEqual, 0)
+		})
+		Convey("The thresholds are configurable", func() {
+			configured := helpers.GetLoginPolicy()
+			So(configured.MaxFailures, ShouldEqual, constants.LoginDefaultMaxFailures)
+			So(configured.Lockout, ShouldEqual, constants.LoginDefaultLockout)
+			So(configured.IpMaxFailures, ShouldEqual, constants.LoginDefaultIpMaxFailures)
+		})
+	})
+}
@@ -0,0 +1,194 @@
+package test
+
+import (
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/snykk/beego-presence-api/database"
+
+	"github.com/beego/beego/v2/client/orm"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestMigrations checks how the SQL migrations are read
+func TestMigrations(t *testing.T) {
+	Convey("Subject: Migrations\n", t, func() {
+		Convey("The embedded migrations start with the initial schema", func() {
+			migrations, err := database.LoadMigrations()
+			So(err, ShouldBeNil)
+			So(migrations, ShouldNotBeEmpty)
+			So(migrations[0].Version, ShouldEqual, 1)
+			So(migrations[0].Name, ShouldEqual, "initial_schema")
+			So(migrations[0].Up, ShouldContainSubstring, `CREATE TABLE IF NOT EXISTS "presence"`)
+			So(migrations[0].Up, ShouldNotContainSubstring, `"deleted_at"`)
+			So(migrations[0].Down, ShouldContainSubstring, `DROP TABLE IF EXISTS "presence"`)
+		})
+
+		Convey("Later columns are added to the adopted tables with the defaults of the ORM", func() {
+			migrations, err := database.LoadMigrations()
+			So(err, ShouldBeNil)
+			So(len(migrations), ShouldBeGreaterThan, 1)
+			So(migrations[1].Up, ShouldContainSubstring, `ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "email_verified" bool NOT NULL DEFAULT true`)
+			So(migrations[1].Up, ShouldContainSubstring, `ON "presence" ("user_id", "created_at")`)
+		})
+
+		Convey("Migrations are ordered by version", func() {
+			migrations, err := database.ParseMigrations(fstest.MapFS{
+				"migrations/0010_add_index.up.sql":        {Data: []byte("CREATE INDEX")},
+				"migrations/0010_add_index.down.sql":      {Data: []byte("DROP INDEX")},
+				"migrations/0002_add_column.up.sql":       {Data: []byte("ALTER TABLE")},
+				"migrations/0002_add_column.down.sql":     {Data: []byte("ALTER TABLE")},
+				"migrations/0001_initial_schema.up.sql":   {Data: []byte("CREATE TABLE")},
+				"migrations/0001_initial_schema.down.sql": {Data: []byte("DROP TABLE")},
+			}, "migrations")
+			So(err, ShouldBeNil)
+			So(migrations, ShouldHaveLength, 3)
+			So(migrations[1].Version, ShouldEqual, 2)
+			So(migrations[2].Name, ShouldEqual, "add_index")
+			So(migrations[2].Down, ShouldEqual, "DROP INDEX")
+		})
+
+		Convey("Every migration needs an up and a down file", func() {
+			_, err := database.ParseMigrations(fstest.MapFS{
+				"migrations/0001_initial_schema.up.sql": {Data: []byte("CREATE TABLE")},
+			}, "migrations")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Files not following the naming are rejected", func() {
+			_, err := database.ParseMigrations(fstest.MapFS{
+				"migrations/initial_schema.sql": {Data: []byte("CREATE TABLE")},
+			}, "migrations")
+			So(err, ShouldNotBeNil)
+
+			_, err = database.ParseMigrations(fstest.MapFS{
+				"migrations/0001_initial_schema.up.sql": {Data: []byte("CREATE TABLE")},
+				"migrations/0001_renamed.down.sql":      {Data: []byte("DROP TABLE")},
+			}, "migrations")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+// TestMigrationRuns reverts and applies the migrations against the test database
+func TestMigrationRuns(t *testing.T) {
+	requireTestDatabase(t)
+
+	migrations, err := database.LoadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := orm.NewOrm()
+	countApplied := func() int {
+		var count int
+		So(o.Raw(`SELECT COUNT(*) FROM "schema_migrations"`).QueryRow(&count), ShouldBeNil)
+		return count
+	}
+	tableExists := func(name string) bool {
+		var count int
+		So(o.Raw(`SELECT COUNT(*) FROM pg_tables WHERE schemaname = current_schema() AND tablename = ?`, name).QueryRow(&count), ShouldBeNil)
+		return count > 0
+	}
+
+	Convey("Subject: Migration runs\n", t, func() {
+		Convey("Migrations are reverted and applied again", func() {
+			reverted, err := database.Rollback(len(migrations))
+			So(err, ShouldBeNil)
+			So(reverted, ShouldHaveLength, len(migrations))
+			So(countApplied(), ShouldEqual, 0)
+			So(tableExists("user"), ShouldBeFalse)
+			pending, err := database.CountPendingMigrations()
+			So(err, ShouldBeNil)
+			So(pending, ShouldEqual, len(migrations))
+
+			applied, err := database.Migrate()
+			So(err, ShouldBeNil)
+			So(applied, ShouldHaveLength, len(migrations))
+			So(countApplied(), ShouldEqual, len(migrations))
+			So(tableExists("user"), ShouldBeTrue)
+
+			applied, err = database.Migrate()
+			So(err, ShouldBeNil)
+			So(applied, ShouldBeEmpty)
+		})
+
+		Convey("Concurrent first runs apply every migration once", func() {
+			_, err := database.Rollback(len(migrations))
+			So(err, ShouldBeNil)
+			_, err = o.Raw(`DROP TABLE "schema_migrations"`).Exec()
+			So(err, ShouldBeNil)
+
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			var errs []error
+			appliedRuns := 0
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					applied, err := database.Migrate()
+					mu.Lock()
+					defer mu.Unlock()
+					if err != nil {
+						errs = append(errs, err)
+					}
+					appliedRuns += len(applied)
+				}()
+			}
+			wg.Wait()
+
+			So(errs, ShouldBeEmpty)
+			So(appliedRuns, ShouldEqual, len(migrations))
+			So(countApplied(), ShouldEqual, len(migrations))
+		})
+
+		Convey("A database created by orm.RunSyncdb is upgraded", func() {
+			_, err := database.Rollback(len(migrations))
+			So(err, ShouldBeNil)
+			_, err = o.Raw(`DROP TABLE "schema_migrations"`).Exec()
+			So(err, ShouldBeNil)
+
+			// The tables of the initial schema with a row each, and a presence of a user deleted without the keys
+			_, err = o.Raw(migrations[0].Up).Exec()
+			So(err, ShouldBeNil)
+			_, err = o.Raw(`INSERT INTO "department" ("name", "created_at", "updated_at") VALUES ('Engineering', now(), now())`).Exec()
+			So(err, ShouldBeNil)
+			_, err = o.Raw(`INSERT INTO "schedule" ("name", "department_id", "in_time", "out_time", "created_at", "updated_at") SELECT 'Day', "id", '09:00:00', '17:00:00', now(), now() FROM "department"`).Exec()
+			So(err, ShouldBeNil)
+			_, err = o.Raw(`INSERT INTO "user" ("name", "email", "password", "role", "department_id", "created_at", "updated_at") SELECT 'Alice', 'alice@example.com', '', 'EMPLOYEE', "id", now(), now() FROM "department"`).Exec()
+			So(err, ShouldBeNil)
+			_, err = o.Raw(`INSERT INTO "presence" ("user_id", "schedule_id", "type", "status", "created_at", "updated_at") SELECT 999999, "id", 'in', 'ontime', now(), now() FROM "schedule"`).Exec()
+			So(err, ShouldBeNil)
+
+			applied, err := database.Migrate()
+			So(err, ShouldBeNil)
+			So(applied, ShouldHaveLength, len(migrations))
+
+			var verified bool
+			So(o.Raw(`SELECT "email_verified" FROM "user" WHERE "email" = 'alice@example.com'`).QueryRow(&verified), ShouldBeNil)
+			So(verified, ShouldBeTrue)
+
+			keyValidated := func(name string) bool {
+				var validated bool
+				So(o.Raw(`SELECT convalidated FROM pg_constraint WHERE conname = ?`, name).QueryRow(&validated), ShouldBeNil)
+				return validated
+			}
+			So(keyValidated("user_department_id_fkey"), ShouldBeTrue)
+			So(keyValidated("presence_user_id_fkey"), ShouldBeFalse)
+
+			// The key is validated once the orphaned presence is removed
+			_, err = o.Raw(`DELETE FROM "presence" WHERE "user_id" = 999999`).Exec()
+			So(err, ShouldBeNil)
+			_, err = o.Raw(`ALTER TABLE "presence" VALIDATE CONSTRAINT "presence_user_id_fkey"`).Exec()
+			So(err, ShouldBeNil)
+			So(keyValidated("presence_user_id_fkey"), ShouldBeTrue)
+		})
+
+		Convey("Rows referencing missing rows are rejected", func() {
+			_, err := o.Raw(`INSERT INTO "schedule" ("name", "department_id", "in_time", "out_time", "created_at", "updated_at") VALUES ('Night', 999999, '22:00:00', '06:00:00', now(), now())`).Exec()
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "schedule_department_id_fkey")
+		})
+	})
+}
@@ -0,0 +1,69 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestNotification checks the notification templates and the development mailer
+func TestNotification(t *testing.T) {
+	manager := &models.User{Id: 4, Name: "Manager1", Email: "manager1@example.com"}
+
+	Convey("Subject: Email notifications\n", t, func() {
+		Convey("Every notification type has a subject, a text and an HTML body", func() {
+			data := map[string]interface{}{
+				"User":     manager,
+				"Company":  "Beego Presence Inc.",
+				"Date":     "2024-12-02",
+				"CheckIn":  "08:55",
+				"Arrivals": []helpers.LateArrival{{Name: "Employee1 <script>", CheckIn: "09:30", LateMinutes: 30}},
+			}
+			for _, notificationType := range constants.NotificationTypes {
+				message, err := helpers.RenderNotification(notificationType, data)
+				So(err, ShouldBeNil)
+				So(message.Subject, ShouldNotBeEmpty)
+				So(message.Text, ShouldContainSubstring, "Hello Manager1")
+				So(message.HTML, ShouldContainSubstring, "Hello Manager1")
+			}
+		})
+		Convey("The late digest lists the arrivals and escapes them in HTML", func() {
+			message, err := helpers.RenderNotification(constants.NotificationTypeLateDigest, map[string]interface{}{
+				"User":     manager,
+				"Date":     "2024-12-02",
+				"Arrivals": []helpers.LateArrival{{Name: "Employee1 <script>", CheckIn: "09:30", LateMinutes: 30}},
+			})
+			So(err, ShouldBeNil)
+			So(message.Subject, ShouldEqual, "Late arrivals on 2024-12-02: 1")
+			So(message.Text, ShouldContainSubstring, "- Employee1 <script>: checked in at 09:30, 30 minutes late")
+			So(message.HTML, ShouldContainSubstring, "Employee1 &lt;script&gt;")
+		})
+		Convey("The log mailer writes a multipart email file", func() {
+			dir := t.TempDir()
+			mailer := &helpers.LogMailer{Dir: dir, From: "Beego Presence <no-reply@example.com>"}
+			err := mailer.Send(&helpers.MailMessage{To: "manager1@example.com", Subject: "Keterlambatan hari ini", Text: "plain", HTML: "<p>html</p>"})
+			So(err, ShouldBeNil)
+
+			files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+			So(len(files), ShouldEqual, 1)
+			content, _ := os.ReadFile(files[0])
+			So(string(content), ShouldContainSubstring, "To: manager1@example.com\r\n")
+			So(string(content), ShouldContainSubstring, "Content-Type: multipart/alternative")
+			So(strings.Index(string(content), "text/plain"), ShouldBeLessThan, strings.Index(string(content), "text/html"))
+		})
+		Convey("Messages carry an RFC 1123 date", func() {
+			date := time.Date(2024, time.December, 2, 20, 0, 0, 0, time.UTC)
+			body, err := (&helpers.MailMessage{To: "a@example.com", Subject: "s"}).Bytes("b@example.com", date)
+			So(err, ShouldBeNil)
+			So(string(body), ShouldContainSubstring, "Date: Mon, 02 Dec 2024 20:00:00 +0000\r\n")
+		})
+	})
+}
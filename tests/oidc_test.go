@@ -0,0 +1,176 @@
+package test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/snykk/beego-presence-api/helpers"
+
+	"github.com/dgrijalva/jwt-go"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// mockOIDCProvider is a minimal OpenID Connect provider issuing ID tokens for a single authorization code
+type mockOIDCProvider struct {
+	server        *httptest.Server
+	key           *rsa.PrivateKey
+	kid           string
+	codeChallenge string
+	claims        jwt.MapClaims
+}
+
+func newMockOIDCProvider() *mockOIDCProvider {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	provider := &mockOIDCProvider{key: key, kid: "key-1"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 provider.server.URL,
+			"authorization_endpoint": provider.server.URL + "/authorize",
+			"token_endpoint":         provider.server.URL + "/token",
+			"jwks_uri":               provider.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": provider.kid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(provider.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(provider.key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientId, clientSecret, _ := r.BasicAuth()
+		verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if clientId != "presence-api" || clientSecret != "secret" || r.PostFormValue("code") != "valid-code" ||
+			base64.RawURLEncoding.EncodeToString(verifier[:]) != provider.codeChallenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "access", "token_type": "Bearer", "id_token": provider.signIDToken(provider.claims)})
+	})
+	provider.server = httptest.NewServer(mux)
+	return provider
+}
+
+func (p *mockOIDCProvider) signIDToken(claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = p.kid
+	signed, _ := token.SignedString(p.key)
+	return signed
+}
+
+func (p *mockOIDCProvider) idTokenClaims(nonce string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            p.server.URL,
+		"aud":            "presence-api",
+		"sub":            "idp-user-1",
+		"email":          "employee1@example.com",
+		"email_verified": true,
+		"name":           "Employee1",
+		"department":     "Engineering",
+		"nonce":          nonce,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(5 * time.Minute).Unix(),
+	}
+}
+
+// TestOIDC runs the authorization code flow with PKCE against a mock provider and checks the validation of ID tokens
+func TestOIDC(t *testing.T) {
+	Convey("Subject: OpenID Connect single sign-on\n", t, func() {
+		provider := newMockOIDCProvider()
+		defer provider.server.Close()
+
+		client := helpers.NewOIDCClient(helpers.OIDCConfig{
+			Issuer:       provider.server.URL,
+			ClientId:     "presence-api",
+			ClientSecret: "secret",
+			RedirectUrl:  "http://localhost:8080/api/v1/auth/oidc/callback",
+		})
+
+		verifier, challenge, err := helpers.GeneratePKCE()
+		So(err, ShouldBeNil)
+		provider.codeChallenge = challenge
+
+		Convey("The user is redirected with the state, the nonce and the PKCE challenge", func() {
+			authorizationUrl, err := client.AuthorizationUrl("state-1", "nonce-1", challenge)
+			So(err, ShouldBeNil)
+
+			parsed, _ := url.Parse(authorizationUrl)
+			So(parsed.Path, ShouldEqual, "/authorize")
+			So(parsed.Query().Get("response_type"), ShouldEqual, "code")
+			So(parsed.Query().Get("client_id"), ShouldEqual, "presence-api")
+			So(parsed.Query().Get("scope"), ShouldEqual, "openid email profile")
+			So(parsed.Query().Get("state"), ShouldEqual, "state-1")
+			So(parsed.Query().Get("nonce"), ShouldEqual, "nonce-1")
+			So(parsed.Query().Get("code_challenge"), ShouldEqual, challenge)
+			So(parsed.Query().Get("code_challenge_method"), ShouldEqual, "S256")
+		})
+		Convey("The code is exchanged and the ID token validated", func() {
+			provider.claims = provider.idTokenClaims("nonce-1")
+			idToken, err := client.Exchange("valid-code", verifier)
+			So(err, ShouldBeNil)
+
+			claims, err := client.VerifyIDToken(idToken, "nonce-1", time.Now())
+			So(err, ShouldBeNil)
+			So(claims.Subject, ShouldEqual, "idp-user-1")
+			So(claims.Email, ShouldEqual, "employee1@example.com")
+			So(claims.EmailVerified, ShouldBeTrue)
+			So(claims.Department, ShouldEqual, "Engineering")
+		})
+		Convey("The exchange fails without the matching code verifier", func() {
+			provider.claims = provider.idTokenClaims("nonce-1")
+			_, err := client.Exchange("valid-code", "another-verifier")
+			So(err, ShouldNotBeNil)
+		})
+		Convey("ID tokens with another nonce, audience or issuer are rejected", func() {
+			_, err := client.VerifyIDToken(provider.signIDToken(provider.idTokenClaims("nonce-1")), "nonce-2", time.Now())
+			So(err, ShouldNotBeNil)
+
+			claims := provider.idTokenClaims("nonce-1")
+			claims["aud"] = []string{"another-client"}
+			_, err = client.VerifyIDToken(provider.signIDToken(claims), "nonce-1", time.Now())
+			So(err, ShouldNotBeNil)
+
+			claims = provider.idTokenClaims("nonce-1")
+			claims["iss"] = "https://attacker.example.com"
+			_, err = client.VerifyIDToken(provider.signIDToken(claims), "nonce-1", time.Now())
+			So(err, ShouldNotBeNil)
+		})
+		Convey("Expired ID tokens are rejected", func() {
+			claims := provider.idTokenClaims("nonce-1")
+			claims["exp"] = time.Now().Add(-10 * time.Minute).Unix()
+			_, err := client.VerifyIDToken(provider.signIDToken(claims), "nonce-1", time.Now())
+			So(err, ShouldNotBeNil)
+		})
+		Convey("ID tokens signed with another key are rejected", func() {
+			other := newMockOIDCProvider()
+			defer other.server.Close()
+			other.kid = provider.kid
+
+			_, err := client.VerifyIDToken(other.signIDToken(provider.idTokenClaims("nonce-1")), "nonce-1", time.Now())
+			So(err, ShouldNotBeNil)
+		})
+		Convey("Rotated keys are fetched again", func() {
+			rotated, _ := rsa.GenerateKey(rand.Reader, 2048)
+			_, err := client.VerifyIDToken(provider.signIDToken(provider.idTokenClaims("nonce-1")), "nonce-1", time.Now())
+			So(err, ShouldBeNil)
+
+			provider.key, provider.kid = rotated, "key-2"
+			_, err = client.VerifyIDToken(provider.signIDToken(provider.idTokenClaims("nonce-1")), "nonce-1", time.Now())
+			So(err, ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,182 @@
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// flakySink fails the given number of times before accepting events
+type flakySink struct {
+	name      string
+	failures  int
+	published []int
+}
+
+func (s *flakySink) Name() string { return s.name }
+
+func (s *flakySink) Publish(envelope *helpers.EventEnvelope) error {
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("unavailable")
+	}
+	s.published = append(s.published, envelope.Id)
+	return nil
+}
+
+// fakeNATSServer accepts one client, answers its PINGs and reports the published messages
+func fakeNATSServer(listener net.Listener, messages chan<- string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	io.WriteString(conn, "INFO {\"server_id\":\"fake\"}\r\n")
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch {
+		case strings.HasPrefix(line, "PUB "):
+			payload, _ := reader.ReadString('\n')
+			messages <- strings.Fields(line)[1] + " " + strings.TrimSpace(payload)
+		case strings.HasPrefix(line, "PING"):
+			io.WriteString(conn, "PONG\r\n")
+		}
+	}
+}
+
+// TestOutbox checks that outbox events reach every sink at least once and are retried per sink
+func TestOutbox(t *testing.T) {
+	Convey("Subject: Transactional outbox\n", t, func() {
+		newEvent := func() *models.OutboxEvent {
+			return &models.OutboxEvent{Id: 42, Event: constants.EventPresenceCreated, Payload: `{"id":1}`, Status: constants.OutboxStatusPending, Attempts: 1}
+		}
+
+		Convey("Events are built from the saved change", func() {
+			presence := &models.Presence{}
+			build := helpers.NewDomainEvent(constants.EventPresenceCreated, func() interface{} {
+				return map[string]int{"id": presence.Id}
+			})
+			presence.Id = 7
+
+			event, err := build()
+			So(err, ShouldBeNil)
+			So(event.Payload, ShouldEqual, `{"id":7}`)
+		})
+		Convey("Failed sinks are retried without redelivering to the others", func() {
+			healthy := &flakySink{name: "healthy"}
+			flaky := &flakySink{name: "flaky", failures: 1}
+			sinks := []helpers.EventSink{healthy, flaky}
+			event := newEvent()
+
+			helpers.PublishOutboxEvent(sinks, event)
+			So(event.Status, ShouldEqual, constants.OutboxStatusPending)
+			So(event.LastError, ShouldContainSubstring, "flaky")
+			So(event.NextAttemptAt, ShouldHappenAfter, time.Now())
+
+			event.Attempts++
+			helpers.PublishOutboxEvent(sinks, event)
+			So(event.Status, ShouldEqual, constants.OutboxStatusPublished)
+			So(event.PublishedAt, ShouldNotBeNil)
+			So(healthy.published, ShouldResemble, []int{42})
+			So(flaky.published, ShouldResemble, []int{42})
+		})
+		Convey("In-process handlers receive the events they are registered for", func() {
+			sink := helpers.NewHandlerSink()
+			var received []string
+			sink.Handle(constants.EventPresenceCreated, "created", func(envelope *helpers.EventEnvelope) error {
+				received = append(received, "created")
+				return nil
+			})
+			sink.Handle(constants.EventAll, "all", func(envelope *helpers.EventEnvelope) error {
+				received = append(received, "all")
+				return nil
+			})
+			sink.Handle(constants.EventUserCreated, "user", func(envelope *helpers.EventEnvelope) error {
+				received = append(received, "user")
+				return nil
+			})
+
+			So(sink.Publish(helpers.NewEventEnvelope(newEvent())), ShouldBeNil)
+			So(received, ShouldResemble, []string{"created", "all"})
+		})
+		Convey("Failed handlers are retried without running the others again", func() {
+			sink := helpers.NewHandlerSink()
+			healthy := &flakySink{name: "healthy"}
+			flaky := &flakySink{name: "flaky", failures: 1}
+			sink.Handle(constants.EventPresenceCreated, healthy.name, healthy.Publish)
+			sink.Handle(constants.EventAll, flaky.name, flaky.Publish)
+			event := newEvent()
+
+			helpers.PublishOutboxEvent([]helpers.EventSink{sink}, event)
+			So(event.Status, ShouldEqual, constants.OutboxStatusPending)
+			So(event.LastError, ShouldContainSubstring, "handlers:flaky")
+			So(event.IsDeliveredTo("handlers:healthy"), ShouldBeTrue)
+
+			event.Attempts++
+			helpers.PublishOutboxEvent([]helpers.EventSink{sink}, event)
+			So(event.Status, ShouldEqual, constants.OutboxStatusPublished)
+			So(healthy.published, ShouldResemble, []int{42})
+			So(flaky.published, ShouldResemble, []int{42})
+		})
+		Convey("Events are given up after the maximum number of attempts", func() {
+			broken := &flakySink{name: "broken", failures: constants.OutboxMaxAttempts}
+			event := newEvent()
+			event.Attempts = constants.OutboxMaxAttempts - 1
+
+			helpers.PublishOutboxEvent([]helpers.EventSink{broken}, event)
+			So(event.Status, ShouldEqual, constants.OutboxStatusPending)
+
+			event.Attempts++
+			nextAttemptAt := event.NextAttemptAt
+			helpers.PublishOutboxEvent([]helpers.EventSink{broken}, event)
+			So(event.Status, ShouldEqual, constants.OutboxStatusDead)
+			So(event.LastError, ShouldContainSubstring, "broken")
+			So(event.NextAttemptAt, ShouldEqual, nextAttemptAt)
+		})
+		Convey("The HTTP sink posts the envelope", func() {
+			var envelope helpers.EventEnvelope
+			var eventHeader string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				eventHeader = r.Header.Get(constants.OutboxEventHeader)
+				json.NewDecoder(r.Body).Decode(&envelope)
+			}))
+			defer server.Close()
+
+			So(helpers.NewHTTPSink(server.URL).Publish(helpers.NewEventEnvelope(newEvent())), ShouldBeNil)
+			So(eventHeader, ShouldEqual, constants.EventPresenceCreated)
+			So(envelope.Id, ShouldEqual, 42)
+			So(string(envelope.Data), ShouldEqual, `{"id":1}`)
+		})
+		Convey("The NATS sink publishes under the event subject", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			So(err, ShouldBeNil)
+			defer listener.Close()
+
+			messages := make(chan string, 1)
+			go fakeNATSServer(listener, messages)
+
+			sink, err := helpers.NewNATSSink("nats://"+listener.Addr().String(), constants.OutboxDefaultSubject)
+			So(err, ShouldBeNil)
+			So(sink.Publish(helpers.NewEventEnvelope(newEvent())), ShouldBeNil)
+			So(<-messages, ShouldStartWith, "presence.events.presence.created {\"id\":42")
+		})
+	})
+}
@@ -0,0 +1,66 @@
+package test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/helpers"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestPasswordReset checks the reset token handling, the rate limit and the validation of the new password
+func TestPasswordReset(t *testing.T) {
+	Convey("Subject: Password reset\n", t, func() {
+		Convey("Tokens are stored as SHA-256 hashes", func() {
+			hash := helpers.HashToken("token")
+			So(hash, ShouldEqual, "3c469e9d6c5875d37a43f353d4f88e61fcf812c66eee3457465a40b0da4153e0")
+			So(hash, ShouldNotContainSubstring, "token")
+		})
+		Convey("The token is appended to the reset page", func() {
+			resetUrl, err := helpers.BuildPasswordResetUrl("abc")
+			So(err, ShouldBeNil)
+			So(resetUrl, ShouldEndWith, "/reset-password?token=abc")
+		})
+		Convey("Requests are limited per email within the window", func() {
+			limiter := helpers.NewRateLimiter(constants.PasswordResetRateLimit, constants.PasswordResetRateWindow)
+			now := time.Now()
+			for i := 0; i < constants.PasswordResetRateLimit; i++ {
+				So(limiter.Allow("employee1@example.com", now), ShouldBeTrue)
+			}
+			So(limiter.Allow("employee1@example.com", now), ShouldBeFalse)
+			So(limiter.Allow("employee2@example.com", now), ShouldBeTrue)
+			So(limiter.Allow("employee1@example.com", now.Add(constants.PasswordResetRateWindow)), ShouldBeTrue)
+		})
+		Convey("Unknown emails are limited like registered ones", func() {
+			email := "Unknown" + time.Now().Format("150405.000000") + "@example.com"
+			for i := 0; i < constants.PasswordResetRateLimit; i++ {
+				So(helpers.AllowPasswordResetRequest(email, time.Now()), ShouldBeTrue)
+			}
+			So(helpers.AllowPasswordResetRequest(strings.ToLower(email), time.Now()), ShouldBeFalse)
+		})
+		Convey("The new password must be secure", func() {
+			req := dto.ResetPasswordRequest{Token: strings.Repeat("a", 64), Password: "password"}
+			errorsMap, err := helpers.ValidatePayloads(req)
+			So(err, ShouldNotBeNil)
+			So(errorsMap, ShouldContainKey, "password")
+
+			req.Password = "N3wS3cur3P@5s"
+			_, err = helpers.ValidatePayloads(req)
+			So(err, ShouldBeNil)
+		})
+		Convey("Reset emails render the link", func() {
+			message, err := helpers.RenderNotification(constants.NotificationTypePasswordReset, map[string]interface{}{
+				"User":           map[string]string{"Name": "Employee1"},
+				"ResetUrl":       "http://localhost:8080/reset-password?token=abc",
+				"ExpiresMinutes": 30,
+			})
+			So(err, ShouldBeNil)
+			So(message.Text, ShouldContainSubstring, "http://localhost:8080/reset-password?token=abc")
+			So(message.HTML, ShouldContainSubstring, `href="http://localhost:8080/reset-password?token=abc"`)
+		})
+	})
+}
@@ -0,0 +1,107 @@
+package test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestPayrollExport checks rounding, lateness bucket deductions and the file layouts of a payroll template
+func TestPayrollExport(t *testing.T) {
+	location := helpers.PresenceLocation()
+	schedule := &models.Schedule{Id: 1, InTime: "09:00:00", OutTime: "17:00:00"}
+	user := &models.User{Id: 7, Name: "Employee1", Department: &models.Department{Id: 3}, Schedule: schedule}
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, time.December, day, hour, minute, 0, 0, location)
+	}
+
+	presences := []*models.Presence{
+		// Monday 2nd: 5 minutes beyond the shift
+		{Schedule: schedule, Type: constants.PresenceTypeIn, Status: constants.PresenceStatusOnTime, CreatedAt: at(2, 8, 55)},
+		{Schedule: schedule, Type: constants.PresenceTypeOut, Status: constants.PresenceStatusOnTime, CreatedAt: at(2, 17, 0)},
+		// Tuesday 3rd: late by 30 minutes
+		{Schedule: schedule, Type: constants.PresenceTypeIn, Status: constants.PresenceStatusLate, CreatedAt: at(3, 9, 30)},
+		// Saturday 7th: two hours of weekend work, the late check-in is not deducted
+		{Schedule: schedule, Type: constants.PresenceTypeIn, Status: constants.PresenceStatusLate, CreatedAt: at(7, 10, 0)},
+		{Schedule: schedule, Type: constants.PresenceTypeOut, Status: constants.PresenceStatusOnTime, CreatedAt: at(7, 12, 0)},
+		// Monday 9th: late by 20 minutes
+		{Schedule: schedule, Type: constants.PresenceTypeIn, Status: constants.PresenceStatusLate, CreatedAt: at(9, 9, 20)},
+	}
+
+	monthStart, _ := helpers.ParseReportMonth("2024-12", time.Now())
+	report := helpers.BuildMonthlyAttendance(user, monthStart, presences, nil, nil, time.Date(2025, time.January, 2, 8, 0, 0, 0, location))
+
+	template := &models.PayrollTemplate{
+		Format:          constants.PayrollFormatCSV,
+		Delimiter:       ";",
+		IncludeHeader:   true,
+		RoundingMode:    constants.PayrollRoundingDown,
+		RoundingMinutes: 15,
+	}
+	template.SetLateBuckets([]models.PayrollLateBucket{
+		{Code: "L15", MinMinutes: 1, MaxMinutes: 15, Amount: 10000},
+		{Code: "L16", MinMinutes: 16, Amount: 1000, PerMinute: true},
+	})
+	template.SetFields([]models.PayrollField{
+		{Code: "EMPID", Source: constants.PayrollSourceEmployeeId, Width: 6, Pad: "0"},
+		{Code: "NAME", Source: constants.PayrollSourceEmployeeName, Width: 6},
+		{Code: "OT", Source: constants.PayrollSourceOvertimeMinutes, Unit: constants.PayrollUnitHours, Decimals: 2, Width: 5},
+		{Code: "L15", Source: constants.PayrollSourceBucketCount, Bucket: "L15", Width: 3},
+		{Code: "LATEDED", Source: constants.PayrollSourceLateDeduction, Width: 8},
+	})
+
+	record, err := helpers.BuildPayrollRecord(user, report, template)
+
+	Convey("Subject: Payroll export\n", t, func() {
+		Convey("The template is consistent", func() {
+			So(helpers.ValidatePayrollTemplate(template), ShouldBeNil)
+		})
+		Convey("Daily durations are rounded before they are summed", func() {
+			So(err, ShouldBeNil)
+			// 30 and 20 late minutes rounded down to 30 and 15
+			So(record.LateMinutes, ShouldEqual, 45)
+			// The 5 minutes beyond the shift are rounded away, weekend work counts entirely
+			So(record.OvertimeMinutes, ShouldEqual, 120)
+		})
+		Convey("Late days are deducted according to their bucket", func() {
+			So(record.BucketCounts["L15"], ShouldEqual, 1)
+			So(record.BucketCounts["L16"], ShouldEqual, 1)
+			So(record.LateDeduction, ShouldEqual, 40000)
+		})
+		Convey("CSV files use the template delimiter and column codes", func() {
+			var file bytes.Buffer
+			So(helpers.WritePayrollFile(&file, template, []*helpers.PayrollRecord{record}), ShouldBeNil)
+			So(file.String(), ShouldEqual, "EMPID;NAME;OT;L15;LATEDED\n7;Employee1;2.00;1;40000\n")
+		})
+		Convey("Names that would be evaluated as formulas are neutralized in CSV files", func() {
+			hostile := *record
+			hostile.User = &models.User{Id: 8, Name: `=HYPERLINK("http://evil.example","x")`}
+			var file bytes.Buffer
+			So(helpers.WritePayrollFile(&file, template, []*helpers.PayrollRecord{&hostile}), ShouldBeNil)
+			So(file.String(), ShouldEqual, "EMPID;NAME;OT;L15;LATEDED\n8;\"'=HYPERLINK(\"\"http://evil.example\"\",\"\"x\"\")\";2.00;1;40000\n")
+		})
+		Convey("Fixed-width files pad numbers to the right and truncate text", func() {
+			fixed := *template
+			fixed.Format = constants.PayrollFormatFixedWidth
+			fixed.IncludeHeader = false
+
+			var file bytes.Buffer
+			So(helpers.WritePayrollFile(&file, &fixed, []*helpers.PayrollRecord{record}), ShouldBeNil)
+			So(file.String(), ShouldEqual, "000007Employ 2.00  1   40000\n")
+		})
+		Convey("Overlapping buckets are rejected", func() {
+			overlapping := *template
+			overlapping.SetLateBuckets([]models.PayrollLateBucket{
+				{Code: "A", MinMinutes: 1, MaxMinutes: 30},
+				{Code: "L15", MinMinutes: 16},
+			})
+			So(helpers.ValidatePayrollTemplate(&overlapping), ShouldNotBeNil)
+		})
+	})
+}
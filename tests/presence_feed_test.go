@@ -0,0 +1,60 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/helpers"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestPresenceFeed checks the filtering, the resumption and the disconnection of slow clients of the live presence feed
+func TestPresenceFeed(t *testing.T) {
+	Convey("Subject: Live presence feed\n", t, func() {
+		feed := helpers.NewPresenceFeed()
+		publish := func(userId, departmentId int) *helpers.PresenceFeedEvent {
+			event := &helpers.PresenceFeedEvent{Event: constants.EventPresenceCreated, UserId: userId, DepartmentId: departmentId, Data: []byte(`{}`)}
+			feed.Publish(event)
+			return event
+		}
+
+		Convey("Subscribers only receive the presences matching their filter", func() {
+			department, _ := feed.Subscribe(helpers.PresenceFeedFilter{DepartmentId: 2}, 0)
+			own, _ := feed.Subscribe(helpers.PresenceFeedFilter{UserId: 7}, 0)
+			defer department.Close()
+			defer own.Close()
+
+			publish(7, 2)
+			publish(8, 3)
+
+			So(len(department.Events), ShouldEqual, 1)
+			So(len(own.Events), ShouldEqual, 1)
+			So((<-department.Events).UserId, ShouldEqual, 7)
+		})
+		Convey("Streams resume after the last received event", func() {
+			first := publish(7, 2)
+			publish(8, 3)
+			third := publish(9, 2)
+
+			subscription, missed := feed.Subscribe(helpers.PresenceFeedFilter{DepartmentId: 2}, first.Id)
+			defer subscription.Close()
+			So(len(missed), ShouldEqual, 1)
+			So(missed[0].Id, ShouldEqual, third.Id)
+			So(third.Id, ShouldBeGreaterThan, first.Id)
+		})
+		Convey("Slow subscribers are disconnected", func() {
+			subscription, _ := feed.Subscribe(helpers.PresenceFeedFilter{}, 0)
+			for i := 0; i <= constants.PresenceFeedSubscriberBuffer; i++ {
+				publish(7, 2)
+			}
+
+			received := 0
+			for range subscription.Events {
+				received++
+			}
+			So(received, ShouldEqual, constants.PresenceFeedSubscriberBuffer)
+			subscription.Close()
+		})
+	})
+}
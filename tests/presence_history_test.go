@@ -0,0 +1,103 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// appendRevision appends a signed snapshot of the presence to the history
+func appendRevision(history []*models.PresenceRevision, p *models.Presence, action string) []*models.PresenceRevision {
+	revision := &models.PresenceRevision{
+		PresenceId:        p.Id,
+		Revision:          len(history) + 1,
+		Action:            action,
+		UserId:            p.User.Id,
+		ScheduleId:        p.Schedule.Id,
+		Type:              p.Type,
+		Status:            p.Status,
+		PresenceCreatedAt: p.CreatedAt,
+		ActorId:           1,
+		CreatedAt:         time.Date(2024, 12, 2, 9, len(history), 0, 0, time.UTC),
+	}
+	if len(history) > 0 {
+		revision.PreviousHash = history[len(history)-1].Hash
+	}
+	revision.Hash = helpers.SignPresenceRevision(revision.Content())
+	return append(history, revision)
+}
+
+// problemsOf lists the problems found in the history
+func problemsOf(presence *models.Presence, history []*models.PresenceRevision) []string {
+	problems := []string{}
+	for _, problem := range helpers.VerifyPresenceHistory(42, presence, history) {
+		problems = append(problems, problem.Problem)
+	}
+	return problems
+}
+
+// TestPresenceHistory checks the hash chain of the presence revisions and how tampering is detected
+func TestPresenceHistory(t *testing.T) {
+	Convey("Subject: Presence history\n", t, func() {
+		presence := &models.Presence{
+			Id:        42,
+			User:      &models.User{Id: 2},
+			Schedule:  &models.Schedule{Id: 1},
+			Type:      constants.PresenceTypeIn,
+			Status:    constants.PresenceStatusLate,
+			CreatedAt: time.Date(2024, 12, 2, 8, 20, 0, 0, time.UTC),
+		}
+		history := appendRevision(nil, presence, constants.PresenceRevisionCreated)
+		presence.Status = constants.PresenceStatusOnTime
+		history = appendRevision(history, presence, constants.PresenceRevisionUpdated)
+
+		Convey("A history of changes made through the API is intact", func() {
+			So(problemsOf(presence, history), ShouldBeEmpty)
+			So(history[1].PreviousHash, ShouldEqual, history[0].Hash)
+		})
+
+		Convey("Presences changed outside the API no longer match their last revision", func() {
+			presence.Status = constants.PresenceStatusLate
+			So(problemsOf(presence, history), ShouldResemble, []string{constants.PresenceProblemChanged})
+		})
+
+		Convey("Changed revisions no longer match their hash", func() {
+			history[0].Status = constants.PresenceStatusOnTime
+			So(problemsOf(presence, history), ShouldResemble, []string{constants.PresenceProblemRevisionHash})
+		})
+
+		Convey("Removed revisions break the chain", func() {
+			presence.Type = constants.PresenceTypeOut
+			history = appendRevision(history, presence, constants.PresenceRevisionUpdated)
+			history = append(history[:1], history[2:]...)
+			So(problemsOf(presence, history), ShouldResemble, []string{constants.PresenceProblemRevisionChain})
+		})
+
+		Convey("Deleted presences need a deletion revision", func() {
+			So(problemsOf(nil, history), ShouldResemble, []string{constants.PresenceProblemDeleted})
+
+			history = appendRevision(history, presence, constants.PresenceRevisionDeleted)
+			So(problemsOf(nil, history), ShouldBeEmpty)
+			So(problemsOf(presence, history), ShouldResemble, []string{constants.PresenceProblemChanged})
+		})
+
+		Convey("Presences without history are reported", func() {
+			So(problemsOf(presence, nil), ShouldResemble, []string{constants.PresenceProblemUntracked})
+		})
+
+		Convey("Changes found outside the API stay in the history", func() {
+			history = appendRevision(history, presence, constants.PresenceRevisionOutOfBand)
+			So(problemsOf(presence, history), ShouldResemble, []string{constants.PresenceProblemOutOfBand})
+		})
+
+		Convey("Times are compared with seconds precision", func() {
+			presence.CreatedAt = presence.CreatedAt.Add(400 * time.Microsecond)
+			So(history[1].Matches(presence), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,62 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/helpers"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestManagerPresences checks that managers check in like employees and follow the presences of their department
+func TestManagerPresences(t *testing.T) {
+	requireTestDatabase(t)
+	resetTestDatabase(t)
+
+	engineering := seedDepartment(t, "Engineering")
+	sales := seedDepartment(t, "Sales")
+	shift := seedSchedule(t, engineering, "00:00:00", "23:59:59")
+	salesShift := seedSchedule(t, sales, "09:00:00", "17:00:00")
+	manager := seedUser(t, "Manager", engineering, shift)
+	manager.Role = constants.RoleManager
+	if _, err := orm.NewOrm().Update(manager, "Role"); err != nil {
+		t.Fatalf("Failed to promote the manager: %v", err)
+	}
+	alice := seedUser(t, "Alice", engineering, shift)
+	carol := seedUser(t, "Carol", sales, salesShift)
+	checkIn := time.Date(2024, time.March, 4, 8, 55, 0, 0, helpers.PresenceLocation())
+	seedPresence(t, alice, constants.PresenceTypeIn, constants.PresenceStatusOnTime, checkIn)
+	seedPresence(t, carol, constants.PresenceTypeIn, constants.PresenceStatusOnTime, checkIn)
+
+	Convey("Subject: Presences of managers\n", t, func() {
+		Convey("Managers check in to their schedule", func() {
+			recorder := serveAs(t, manager, http.MethodPost, "/api/v1/presences", fmt.Sprintf(`{"schedule_id": %d, "type": "in"}`, shift.Id))
+			So(recorder.Code, ShouldEqual, http.StatusCreated)
+		})
+
+		Convey("Managers list the presences of their department only", func() {
+			recorder := serveAs(t, manager, http.MethodGet, "/api/v1/presences", "")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+
+			var body struct {
+				Data []struct {
+					UserId int `json:"user_id"`
+				} `json:"data"`
+			}
+			So(json.Unmarshal(recorder.Body.Bytes(), &body), ShouldBeNil)
+			userIds := []int{}
+			for _, presence := range body.Data {
+				userIds = append(userIds, presence.UserId)
+			}
+			So(userIds, ShouldContain, alice.Id)
+			So(userIds, ShouldContain, manager.Id)
+			So(userIds, ShouldNotContain, carol.Id)
+		})
+	})
+}
@@ -0,0 +1,46 @@
+package test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	beego "github.com/beego/beego/v2/server/web"
+	beecontext "github.com/beego/beego/v2/server/web/context"
+)
+
+// newControllerContext builds the context of a request that passed the authentication middleware, so controller
+// methods can be called directly. params holds the path parameters, e.g. ":id".
+func newControllerContext(method, target string, params map[string]string, userId int, role string) (*beecontext.Context, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	ctx := beecontext.NewContext()
+	ctx.Reset(recorder, httptest.NewRequest(method, target, nil))
+	for key, value := range params {
+		ctx.Input.SetParam(key, value)
+	}
+	ctx.Input.SetData(constants.CtxAuthenticatedUserId, userId)
+	ctx.Input.SetData(constants.CtxAuthenticatedUserRole, role)
+	return ctx, recorder
+}
+
+// serveAs sends a request through the routes and middlewares of the API, authenticated with a token of the user
+func serveAs(t *testing.T, user *models.User, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	token, err := helpers.GenerateJWT(user.Id, user.Email, user.Role, user.TokenVersion)
+	if err != nil {
+		t.Fatalf("Failed to issue a token for %s: %v", user.Email, err)
+	}
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	request.Header.Set("Authorization", "Bearer "+token)
+	if body != "" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	beego.BeeApp.Handlers.ServeHTTP(recorder, request)
+	return recorder
+}
@@ -0,0 +1,192 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/helpers"
+
+	beego "github.com/beego/beego/v2/server/web"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestScimFilter(t *testing.T) {
+	Convey("Subject: SCIM filters\n", t, func() {
+		Convey("Comparisons are parsed with their typed values", func() {
+			filter, err := helpers.ParseScimFilter(`userName Eq "jane@example.com"`)
+			So(err, ShouldBeNil)
+			So(filter, ShouldResemble, &helpers.ScimFilter{Operator: "eq", Attribute: "userName", Value: "jane@example.com"})
+
+			filter, err = helpers.ParseScimFilter(`active eq false`)
+			So(err, ShouldBeNil)
+			So(filter.Value, ShouldEqual, false)
+
+			filter, err = helpers.ParseScimFilter(`externalId pr`)
+			So(err, ShouldBeNil)
+			So(filter, ShouldResemble, &helpers.ScimFilter{Operator: "pr", Attribute: "externalId"})
+		})
+
+		Convey("And binds stronger than or, parentheses and not group filters", func() {
+			filter, err := helpers.ParseScimFilter(`displayName sw "J" or userName ew "@example.com" and not (active eq false)`)
+			So(err, ShouldBeNil)
+			So(filter.Operator, ShouldEqual, "or")
+			So(filter.Filters[0].Attribute, ShouldEqual, "displayName")
+			So(filter.Filters[1].Operator, ShouldEqual, "and")
+			So(filter.Filters[1].Filters[1].Operator, ShouldEqual, "not")
+			So(filter.Filters[1].Filters[1].Filters[0].Attribute, ShouldEqual, "active")
+		})
+
+		Convey("Escaped quotes are part of the value", func() {
+			filter, err := helpers.ParseScimFilter(`displayName eq "Jane \"JD\" Doe"`)
+			So(err, ShouldBeNil)
+			So(filter.Value, ShouldEqual, `Jane "JD" Doe`)
+		})
+
+		Convey("Malformed filters are rejected", func() {
+			for _, filter := range []string{``, `userName`, `userName eq`, `userName like "x"`, `(userName eq "x"`, `userName eq "x`, `emails[type eq "work"]`, `userName eq "x" foo`} {
+				_, err := helpers.ParseScimFilter(filter)
+				So(err, ShouldNotBeNil)
+			}
+		})
+
+		Convey("Only mapped attributes with matching values can be filtered", func() {
+			_, err := helpers.ParseScimFilterCondition(`urn:ietf:params:scim:schemas:core:2.0:User:userName eq "jane@example.com" and active eq true`, helpers.ScimUserFilterAttributes)
+			So(err, ShouldBeNil)
+			_, err = helpers.ParseScimFilterCondition(`meta.lastModified gt "2024-12-01T00:00:00Z"`, helpers.ScimUserFilterAttributes)
+			So(err, ShouldBeNil)
+
+			for _, filter := range []string{`password eq "secret"`, `active co "t"`, `active pr`, `id eq "abc"`, `meta.created gt "yesterday"`} {
+				_, err := helpers.ParseScimFilterCondition(filter, helpers.ScimUserFilterAttributes)
+				So(err, ShouldNotBeNil)
+				So(err.(*helpers.ScimError).ScimType, ShouldEqual, constants.ScimErrorInvalidFilter)
+			}
+		})
+	})
+}
+
+func TestScimUserPatch(t *testing.T) {
+	Convey("Subject: SCIM user PATCH operations\n", t, func() {
+		active := true
+		current := func() *dto.ScimUserRequest {
+			return &dto.ScimUserRequest{
+				UserName:    "jane@example.com",
+				Name:        &dto.ScimName{Formatted: "Jane Doe"},
+				DisplayName: "Jane Doe",
+				Active:      &active,
+				Roles:       []dto.ScimMultiValue{{Value: constants.RoleEmployee, Primary: true}},
+				Enterprise:  &dto.ScimEnterpriseUser{Department: "Engineering"},
+			}
+		}
+
+		Convey("Booleans sent as strings deactivate the user", func() {
+			req := current()
+			err := helpers.ApplyScimUserPatch(req, []dto.ScimPatchOperation{{Op: "Replace", Path: "active", Value: "False"}})
+			So(err, ShouldBeNil)
+			So(*req.Active, ShouldBeFalse)
+		})
+
+		Convey("Operations without path change the attributes of the value", func() {
+			req := current()
+			err := helpers.ApplyScimUserPatch(req, []dto.ScimPatchOperation{{Op: "replace", Value: map[string]interface{}{
+				"displayName": "Jane Smith",
+				"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department": "Sales",
+				"roles": []interface{}{map[string]interface{}{"value": "MANAGER", "primary": true}},
+			}}})
+			So(err, ShouldBeNil)
+			So(req.DisplayName, ShouldEqual, "Jane Smith")
+			So(req.Enterprise.Department, ShouldEqual, "Sales")
+			So(req.Roles[0].Value, ShouldEqual, constants.RoleManager)
+		})
+
+		Convey("The primary email replaces the userName", func() {
+			req := current()
+			err := helpers.ApplyScimUserPatch(req, []dto.ScimPatchOperation{{Op: "replace", Path: `emails[type eq "work"].value`, Value: "jane.smith@example.com"}})
+			So(err, ShouldBeNil)
+			So(req.UserName, ShouldEqual, "jane.smith@example.com")
+		})
+
+		Convey("Name parts replace the display name", func() {
+			req := current()
+			err := helpers.ApplyScimUserPatch(req, []dto.ScimPatchOperation{
+				{Op: "replace", Path: "name.givenName", Value: "Janet"},
+				{Op: "replace", Path: "name.familyName", Value: "Doe"},
+			})
+			So(err, ShouldBeNil)
+			So(req.DisplayName, ShouldBeEmpty)
+			So(req.Name.GivenName, ShouldEqual, "Janet")
+			So(req.Name.Formatted, ShouldBeEmpty)
+		})
+
+		Convey("Optional attributes can be removed, required ones can't", func() {
+			req := current()
+			req.ExternalId = "00u1"
+			So(helpers.ApplyScimUserPatch(req, []dto.ScimPatchOperation{{Op: "remove", Path: "externalId"}}), ShouldBeNil)
+			So(req.ExternalId, ShouldBeEmpty)
+
+			err := helpers.ApplyScimUserPatch(req, []dto.ScimPatchOperation{{Op: "remove", Path: "userName"}})
+			So(err.(*helpers.ScimError).ScimType, ShouldEqual, constants.ScimErrorMutability)
+		})
+
+		Convey("Unknown operations and attributes are rejected", func() {
+			err := helpers.ApplyScimUserPatch(current(), []dto.ScimPatchOperation{{Op: "move", Path: "active", Value: true}})
+			So(err.(*helpers.ScimError).ScimType, ShouldEqual, constants.ScimErrorInvalidSyntax)
+
+			err = helpers.ApplyScimUserPatch(current(), []dto.ScimPatchOperation{{Op: "add", Path: "nickName", Value: "JD"}})
+			So(err.(*helpers.ScimError).ScimType, ShouldEqual, constants.ScimErrorInvalidPath)
+
+			err = helpers.ApplyScimUserPatch(current(), []dto.ScimPatchOperation{{Op: "replace", Path: "active", Value: "maybe"}})
+			So(err.(*helpers.ScimError).ScimType, ShouldEqual, constants.ScimErrorInvalidValue)
+		})
+	})
+}
+
+func TestScimPaginationAndAuth(t *testing.T) {
+	Convey("Subject: SCIM pagination and authentication\n", t, func() {
+		Convey("Pagination defaults to the first page and caps the count", func() {
+			start, count, err := helpers.ParseScimPagination("", "")
+			So(err, ShouldBeNil)
+			So(start, ShouldEqual, 1)
+			So(count, ShouldEqual, constants.ScimDefaultCount)
+
+			start, count, err = helpers.ParseScimPagination("0", "5000")
+			So(err, ShouldBeNil)
+			So(start, ShouldEqual, 1)
+			So(count, ShouldEqual, constants.ScimMaxCount)
+
+			_, _, err = helpers.ParseScimPagination("first", "")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("SCIM is disabled without a configured token", func() {
+			beego.AppConfig.Set("scim_token", "")
+			So(helpers.IsValidScimToken(""), ShouldBeFalse)
+		})
+
+		Convey("Only the configured token is accepted, employee JWTs are not", func() {
+			beego.AppConfig.Set("scim_token", "scim-secret")
+			defer beego.AppConfig.Set("scim_token", "")
+			So(helpers.IsValidScimToken("scim-secret"), ShouldBeTrue)
+
+			jwtToken, _ := helpers.GenerateJWT(1, "admin@example.com", constants.RoleAdmin, 0)
+			for _, header := range []string{"", "Bearer wrong", "Bearer " + jwtToken, "Basic scim-secret"} {
+				r, _ := http.NewRequest("GET", "/scim/v2/Users", nil)
+				if header != "" {
+					r.Header.Set("Authorization", header)
+				}
+				w := httptest.NewRecorder()
+				beego.BeeApp.Handlers.ServeHTTP(w, r)
+
+				So(w.Code, ShouldEqual, http.StatusUnauthorized)
+				So(w.Header().Get("Content-Type"), ShouldEqual, constants.ScimContentType)
+				var body dto.ScimErrorResponse
+				So(json.Unmarshal(w.Body.Bytes(), &body), ShouldBeNil)
+				So(body.Schemas, ShouldResemble, []string{constants.ScimSchemaError})
+				So(body.Status, ShouldEqual, "401")
+			}
+		})
+	})
+}
@@ -0,0 +1,31 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/snykk/beego-presence-api/helpers"
+
+	beego "github.com/beego/beego/v2/server/web"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestRequiredSecrets checks that the server doesn't start without its secrets
+func TestRequiredSecrets(t *testing.T) {
+	Convey("Subject: Required secrets\n", t, func() {
+		Convey("Configured secrets are accepted", func() {
+			So(helpers.CheckRequiredSecrets(), ShouldBeNil)
+		})
+
+		for _, name := range []string{"presence_revision_secret", "secret_encryption_key", "email_verification_secret"} {
+			Convey("A missing "+name+" is refused", func() {
+				secret := beego.AppConfig.DefaultString(name, "")
+				beego.AppConfig.Set(name, "")
+				defer beego.AppConfig.Set(name, secret)
+
+				err := helpers.CheckRequiredSecrets()
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, name)
+			})
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestShiftReminder checks when shift reminders and missed check-in nudges are due and the reminder channels
+func TestShiftReminder(t *testing.T) {
+	location := helpers.PresenceLocation()
+	schedule := &models.Schedule{Id: 1, InTime: "09:00:00", OutTime: "17:00:00"}
+	early := &models.User{Id: 1, Name: "Employee1", Email: "employee1@example.com", Schedule: schedule}
+	present := &models.User{Id: 2, Name: "Employee2", Schedule: schedule}
+	onLeave := &models.User{Id: 3, Name: "Employee3", Schedule: schedule}
+	night := &models.User{Id: 5, Name: "Employee5", Schedule: &models.Schedule{Id: 2, InTime: "22:00:00", OutTime: "06:00:00"}}
+	users := []*models.User{early, present, onLeave, {Id: 4, Name: "Admin"}, night}
+
+	// Monday 2nd of December 2024
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, time.December, day, hour, minute, 0, 0, location)
+	}
+	presences := []*models.Presence{
+		{User: present, Type: constants.PresenceTypeIn, CreatedAt: at(2, 8, 40)},
+		{User: night, Type: constants.PresenceTypeIn, CreatedAt: at(4, 21, 55)},
+	}
+	leaves := []*models.Leave{{User: onLeave, StartDate: at(1, 0, 0), EndDate: at(3, 0, 0)}}
+
+	due := func(now time.Time, holidays []*models.Holiday) map[int]string {
+		types := make(map[int]string)
+		for _, notification := range helpers.DueShiftNotifications(now, users, presences, holidays, leaves, 15) {
+			types[notification.User.Id] = notification.Type
+		}
+		return types
+	}
+
+	Convey("Subject: Shift reminders\n", t, func() {
+		Convey("Reminders are due within the minutes before the shift", func() {
+			So(due(at(2, 8, 44), nil), ShouldBeEmpty)
+			So(due(at(2, 8, 45), nil), ShouldResemble, map[int]string{1: constants.NotificationTypeShiftReminder})
+			So(due(at(2, 9, 0), nil), ShouldBeEmpty)
+		})
+		Convey("Nudges are due from the late threshold until the shift ends", func() {
+			So(due(at(2, 9, 14), nil), ShouldBeEmpty)
+			So(due(at(2, 9, 15), nil), ShouldResemble, map[int]string{1: constants.NotificationTypeMissedCheckIn})
+			So(due(at(2, 17, 0), nil), ShouldBeEmpty)
+		})
+		Convey("Overnight shifts are nudged until they end on the next day", func() {
+			So(due(at(2, 22, 15), nil), ShouldResemble, map[int]string{5: constants.NotificationTypeMissedCheckIn})
+			So(due(at(3, 2, 0), nil), ShouldResemble, map[int]string{5: constants.NotificationTypeMissedCheckIn})
+			So(due(at(3, 6, 0), nil), ShouldBeEmpty)
+
+			notifications := helpers.DueShiftNotifications(at(3, 2, 0), users, presences, nil, leaves, 15)
+			So(notifications, ShouldHaveLength, 1)
+			So(notifications[0].InTime, ShouldEqual, at(2, 22, 0))
+		})
+		Convey("Overnight shifts checked in before midnight aren't nudged after it", func() {
+			So(due(at(5, 2, 0), nil), ShouldBeEmpty)
+		})
+		Convey("Nothing is due on weekends and holidays", func() {
+			So(due(at(7, 8, 50), nil), ShouldBeEmpty)
+			So(due(at(2, 8, 50), []*models.Holiday{{Date: time.Date(2024, time.December, 2, 0, 0, 0, 0, time.UTC)}}), ShouldBeEmpty)
+		})
+		Convey("Failed notifications are retried with a backoff and given up", func() {
+			failedAt := at(2, 8, 45)
+			So(helpers.ShiftNotificationRetryDue(0, time.Time{}, failedAt), ShouldBeTrue)
+			So(helpers.ShiftNotificationRetryDue(1, failedAt, failedAt.Add(30*time.Second)), ShouldBeFalse)
+			So(helpers.ShiftNotificationRetryDue(1, failedAt, failedAt.Add(time.Minute)), ShouldBeTrue)
+			So(helpers.ShiftNotificationRetryDue(3, failedAt, failedAt.Add(3*time.Minute)), ShouldBeFalse)
+			So(helpers.ShiftNotificationRetryDue(3, failedAt, failedAt.Add(4*time.Minute)), ShouldBeTrue)
+			So(helpers.ShiftNotificationRetryDue(constants.ShiftNotificationMaxAttempts, failedAt, failedAt.Add(time.Hour)), ShouldBeFalse)
+		})
+		Convey("The chat channel posts the text to the webhook", func() {
+			var body map[string]string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&body)
+			}))
+			defer server.Close()
+
+			err := helpers.NewChatWebhookChannel(server.URL).Deliver(early, &helpers.MailMessage{Subject: "Your shift starts at 09:00", Text: "Check in\n"})
+			So(err, ShouldBeNil)
+			So(body["text"], ShouldEqual, "*Your shift starts at 09:00* (Employee1)\nCheck in")
+		})
+		Convey("The local push channel appends the notifications to its file", func() {
+			path := filepath.Join(t.TempDir(), "push.log")
+			channel := &helpers.LocalPushChannel{Path: path}
+			So(channel.Deliver(early, &helpers.MailMessage{Subject: "First"}), ShouldBeNil)
+			So(channel.Deliver(early, &helpers.MailMessage{Subject: "Second"}), ShouldBeNil)
+
+			content, _ := os.ReadFile(path)
+			var lines []string
+			for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+				var notification helpers.PushNotification
+				So(json.Unmarshal([]byte(line), &notification), ShouldBeNil)
+				So(notification.UserId, ShouldEqual, 1)
+				lines = append(lines, notification.Title)
+			}
+			So(lines, ShouldResemble, []string{"First", "Second"})
+		})
+	})
+}
+
+// TestShiftNotificationLock checks that only one instance at a time sends the shift notifications
+func TestShiftNotificationLock(t *testing.T) {
+	requireTestDatabase(t)
+
+	Convey("Subject: Shift notification runs\n", t, func() {
+		Convey("A run is skipped while another instance holds the lock", func() {
+			var nestedRan bool
+			ran, err := models.RunExclusively(constants.ShiftNotificationLockId, func() error {
+				var err error
+				nestedRan, err = models.RunExclusively(constants.ShiftNotificationLockId, func() error { return nil })
+				return err
+			})
+			So(err, ShouldBeNil)
+			So(ran, ShouldBeTrue)
+			So(nestedRan, ShouldBeFalse)
+		})
+		Convey("The lock is released after the run", func() {
+			ran, err := models.RunExclusively(constants.ShiftNotificationLockId, func() error { return nil })
+			So(err, ShouldBeNil)
+			So(ran, ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,95 @@
+package test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/client/orm"
+	beego "github.com/beego/beego/v2/server/web"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestSoftDelete checks the retention period and how deleted rows are reported
+func TestSoftDelete(t *testing.T) {
+	Convey("Subject: Soft delete\n", t, func() {
+		Convey("The retention period is configurable", func() {
+			beego.AppConfig.Set("soft_delete_retention_days", "")
+			So(helpers.SoftDeleteRetentionDays(), ShouldEqual, constants.SoftDeleteDefaultRetentionDays)
+
+			beego.AppConfig.Set("soft_delete_retention_days", "90")
+			defer beego.AppConfig.Set("soft_delete_retention_days", "")
+			So(helpers.SoftDeleteRetentionDays(), ShouldEqual, 90)
+		})
+
+		Convey("Only deleted rows report their deletion time", func() {
+			department := &models.Department{Id: 1, Name: "Engineering"}
+			body, _ := json.Marshal(dto.FromDepartmentModelToDepartmentResponse(department, false, false))
+			So(string(body), ShouldNotContainSubstring, "deleted_at")
+
+			deletedAt := time.Date(2024, 12, 2, 0, 0, 0, 0, time.UTC)
+			schedule := &models.Schedule{Id: 1, Department: department, DeletedAt: &deletedAt}
+			So(dto.FromScheduleModelToScheduleResponse(schedule, false, false, false).DeletedAt, ShouldEqual, &deletedAt)
+
+			user := &models.User{Id: 1, Department: department, DeletedAt: &deletedAt}
+			body, _ = json.Marshal(dto.FromUserModelToUserResponse(user, false, false, false))
+			So(string(body), ShouldContainSubstring, `"deleted_at":"2024-12-02T00:00:00Z"`)
+		})
+	})
+}
+
+// TestPurgeDeleted purges deleted rows from the test database, whose foreign keys have to allow it
+func TestPurgeDeleted(t *testing.T) {
+	requireTestDatabase(t)
+	resetTestDatabase(t)
+
+	engineering := seedDepartment(t, "Engineering")
+	sales := seedDepartment(t, "Sales")
+	shift := seedSchedule(t, engineering, "09:00:00", "17:00:00")
+	alice := seedUser(t, "Alice", engineering, shift)
+	seedPresence(t, alice, constants.PresenceTypeIn, constants.PresenceStatusOnTime, time.Date(2024, time.March, 4, 8, 55, 0, 0, helpers.PresenceLocation()))
+	o := orm.NewOrm()
+	presence := &models.Presence{}
+	if err := o.QueryTable(presence).Filter("User__Id", alice.Id).One(presence); err != nil {
+		t.Fatal(err)
+	}
+	revision := &models.PresenceRevision{PresenceId: presence.Id, Revision: 1, Action: constants.PresenceRevisionCreated, UserId: alice.Id, ScheduleId: shift.Id, PresenceCreatedAt: presence.CreatedAt}
+	if _, err := o.Insert(revision); err != nil {
+		t.Fatal(err)
+	}
+	attempt := &models.LoginAttempt{User: alice, Email: alice.Email, Success: true, Reason: constants.LoginReasonLoggedIn}
+	if _, err := o.Insert(attempt); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.DeleteUser(alice.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Raw(`UPDATE "department" SET deleted_at = now() WHERE id IN (?, ?)`, engineering.Id, sales.Id).Exec(); err != nil {
+		t.Fatal(err)
+	}
+
+	Convey("Subject: Purging deleted rows\n", t, func() {
+		Convey("Purged users take their presences with them and referenced departments are kept", func() {
+			result, err := models.PurgeDeleted(time.Now().Add(time.Minute))
+			So(err, ShouldBeNil)
+			So(result.Users, ShouldEqual, 1)
+			So(result.Departments, ShouldEqual, 1)
+			So(result.Skipped, ShouldEqual, 1)
+
+			presences, err := o.QueryTable(new(models.Presence)).Count()
+			So(err, ShouldBeNil)
+			So(presences, ShouldEqual, 0)
+			revisions, err := o.QueryTable(new(models.PresenceRevision)).Filter("PresenceId", presence.Id).Count()
+			So(err, ShouldBeNil)
+			So(revisions, ShouldEqual, 0)
+
+			So(o.Read(attempt), ShouldBeNil)
+			So(attempt.User, ShouldBeNil)
+		})
+	})
+}
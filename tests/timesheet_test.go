@@ -0,0 +1,80 @@
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/controllers"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestTimesheet checks the rendering of the PDF timesheet and who may download it
+func TestTimesheet(t *testing.T) {
+	Convey("Subject: PDF timesheet\n", t, func() {
+		Convey("A month without presences renders", func() {
+			schedule := &models.Schedule{Id: 1, InTime: "09:00:00", OutTime: "17:00:00"}
+			user := &models.User{Id: 2, Name: "Employee1", Email: "employee1@example.com", Department: &models.Department{Id: 1}, Schedule: schedule}
+			monthStart, _ := helpers.ParseReportMonth("2024-02", time.Now())
+			report := helpers.BuildMonthlyAttendance(user, monthStart, nil, nil, nil, time.Date(2024, time.March, 1, 8, 0, 0, 0, helpers.PresenceLocation()))
+			So(report.Days, ShouldHaveLength, 29)
+
+			var document bytes.Buffer
+			err := helpers.RenderTimesheetPDF(&document, report, helpers.TimesheetCompany{Name: "Beego Presence Inc."}, time.Now())
+			So(err, ShouldBeNil)
+			So(bytes.HasPrefix(document.Bytes(), []byte("%PDF-")), ShouldBeTrue)
+			So(string(bytes.TrimSpace(document.Bytes())), ShouldEndWith, "%%EOF")
+		})
+
+		Convey("Employees can't download the timesheet of another user", func() {
+			ctx, recorder := newControllerContext(http.MethodGet, "/api/v1/reports/users/3/timesheet", map[string]string{":id": "3"}, 2, constants.RoleEmployee)
+			controller := &controllers.ReportController{}
+			controller.Init(ctx, "ReportController", "GetUserTimesheet", controller)
+			controller.GetUserTimesheet()
+			So(recorder.Code, ShouldEqual, http.StatusForbidden)
+			So(recorder.Header().Get("Content-Type"), ShouldNotEqual, "application/pdf")
+		})
+	})
+}
+
+// TestTimesheetEndpoint downloads timesheets through the API from the test database
+func TestTimesheetEndpoint(t *testing.T) {
+	requireTestDatabase(t)
+	resetTestDatabase(t)
+
+	department := seedDepartment(t, "Engineering")
+	shift := seedSchedule(t, department, "09:00:00", "17:00:00")
+	alice := seedUser(t, "Alice", department, shift)
+	bob := seedUser(t, "Bob", department, shift)
+	checkIn := time.Date(2024, time.March, 4, 8, 55, 0, 0, helpers.PresenceLocation())
+	seedPresence(t, alice, constants.PresenceTypeIn, constants.PresenceStatusOnTime, checkIn)
+	seedPresence(t, alice, constants.PresenceTypeOut, constants.PresenceStatusOnTime, checkIn.Add(8*time.Hour))
+
+	Convey("Subject: PDF timesheet endpoint\n", t, func() {
+		Convey("Users download their own timesheet as PDF", func() {
+			recorder := serveAs(t, alice, http.MethodGet, fmt.Sprintf("/api/v1/reports/users/%d/timesheet?month=2024-03", alice.Id), "")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Header().Get("Content-Type"), ShouldEqual, "application/pdf")
+			So(recorder.Header().Get("Content-Disposition"), ShouldEqual, fmt.Sprintf(`attachment; filename="timesheet-%d-2024-03.pdf"`, alice.Id))
+			So(bytes.HasPrefix(recorder.Body.Bytes(), []byte("%PDF-")), ShouldBeTrue)
+		})
+
+		Convey("A month without presences renders", func() {
+			recorder := serveAs(t, bob, http.MethodGet, fmt.Sprintf("/api/v1/reports/users/%d/timesheet?month=2024-03", bob.Id), "")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Header().Get("Content-Type"), ShouldEqual, "application/pdf")
+		})
+
+		Convey("Employees can't download the timesheet of another user", func() {
+			recorder := serveAs(t, bob, http.MethodGet, fmt.Sprintf("/api/v1/reports/users/%d/timesheet?month=2024-03", alice.Id), "")
+			So(recorder.Code, ShouldEqual, http.StatusForbidden)
+			So(recorder.Header().Get("Content-Type"), ShouldNotEqual, "application/pdf")
+		})
+	})
+}
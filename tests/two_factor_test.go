@@ -0,0 +1,88 @@
+package test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/helpers"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestTwoFactor checks the TOTP codes against the RFC 6238 test vectors, the encrypted secrets, the recovery codes and the pre-auth tokens
+func TestTwoFactor(t *testing.T) {
+	Convey("Subject: Two-factor authentication\n", t, func() {
+		// Base32 of the ASCII secret "12345678901234567890" from RFC 6238
+		secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+		Convey("Codes match the RFC 6238 test vectors", func() {
+			code, err := helpers.TOTPCode(secret, helpers.TOTPStep(time.Unix(59, 0)))
+			So(err, ShouldBeNil)
+			So(code, ShouldEqual, "287082")
+
+			code, err = helpers.TOTPCode(secret, helpers.TOTPStep(time.Unix(1111111109, 0)))
+			So(err, ShouldBeNil)
+			So(code, ShouldEqual, "081804")
+		})
+		Convey("Codes of the neighbouring steps are accepted", func() {
+			now := time.Unix(1111111109, 0)
+			previous, _ := helpers.TOTPCode(secret, helpers.TOTPStep(now)-1)
+			step, ok := helpers.ValidateTOTP(secret, previous, now)
+			So(ok, ShouldBeTrue)
+			So(step, ShouldEqual, helpers.TOTPStep(now)-1)
+
+			older, _ := helpers.TOTPCode(secret, helpers.TOTPStep(now)-2)
+			_, ok = helpers.ValidateTOTP(secret, older, now)
+			So(ok, ShouldBeFalse)
+		})
+		Convey("New secrets are enrolled from an otpauth URI", func() {
+			generated, err := helpers.GenerateTOTPSecret()
+			So(err, ShouldBeNil)
+			So(len(generated), ShouldEqual, 32)
+
+			uri := helpers.TOTPUri(generated, "admin@example.com")
+			So(uri, ShouldStartWith, "otpauth://totp/Beego%20Presence%20API:admin@example.com?")
+			So(uri, ShouldContainSubstring, "secret="+generated)
+		})
+		Convey("Secrets are stored encrypted", func() {
+			encrypted, err := helpers.EncryptSecret(secret)
+			So(err, ShouldBeNil)
+			So(encrypted, ShouldNotContainSubstring, secret)
+
+			decrypted, err := helpers.DecryptSecret(encrypted)
+			So(err, ShouldBeNil)
+			So(decrypted, ShouldEqual, secret)
+
+			again, _ := helpers.EncryptSecret(secret)
+			So(again, ShouldNotEqual, encrypted)
+
+			_, err = helpers.DecryptSecret(encrypted[:len(encrypted)-4] + "AAAA")
+			So(err, ShouldNotBeNil)
+		})
+		Convey("Recovery codes are unique and hashed independently of their formatting", func() {
+			codes, hashes, err := helpers.GenerateRecoveryCodes()
+			So(err, ShouldBeNil)
+			So(len(codes), ShouldEqual, constants.RecoveryCodeCount)
+			So(codes[0], ShouldNotEqual, codes[1])
+			So(codes[0], ShouldContainSubstring, "-")
+			So(hashes[0], ShouldEqual, helpers.HashRecoveryCode(strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))))
+		})
+		Convey("Pre-auth tokens are scoped", func() {
+			token, err := helpers.GeneratePreAuthJWT(1, "admin@example.com", constants.RoleAdmin, 0)
+			So(err, ShouldBeNil)
+			claims, err := helpers.ParseJWT(token)
+			So(err, ShouldBeNil)
+			So(helpers.GetScopeFromMapClaims(claims), ShouldEqual, constants.TokenScopePreAuth)
+
+			token, _ = helpers.GenerateJWT(1, "admin@example.com", constants.RoleAdmin, 0)
+			claims, _ = helpers.ParseJWT(token)
+			So(helpers.GetScopeFromMapClaims(claims), ShouldBeEmpty)
+		})
+		Convey("The policy requires two-factor authentication for admins", func() {
+			So(helpers.IsTwoFactorRequired(constants.RoleAdmin), ShouldBeTrue)
+			So(helpers.IsTwoFactorRequired(constants.RoleEmployee), ShouldBeFalse)
+		})
+	})
+}
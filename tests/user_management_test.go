@@ -0,0 +1,146 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	"github.com/beego/beego/v2/client/orm"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestUserManagement checks the users created by admins and the accounts the admin safeguard applies to
+func TestUserManagement(t *testing.T) {
+	Convey("Subject: Admin user management\n", t, func() {
+		Convey("Created users have a role and change the initial password", func() {
+			req := dto.CreateUserRequest{Name: "Najib Fikri", Email: "najibfikri@gmail.com", Password: "Mys3cur3P@5s", Role: constants.RoleManager, DepartmentId: 1}
+			_, err := helpers.ValidatePayloads(req)
+			So(err, ShouldBeNil)
+
+			user := req.ToUserModel(&models.Department{Id: 1}, nil)
+			So(user.Role, ShouldEqual, constants.RoleManager)
+			So(user.MustChangePassword, ShouldBeTrue)
+			So(user.EmailVerified, ShouldBeTrue)
+			So(user.Schedule, ShouldBeNil)
+		})
+
+		Convey("Unknown roles and insecure initial passwords are rejected", func() {
+			req := dto.CreateUserRequest{Name: "Najib Fikri", Email: "najibfikri@gmail.com", Password: "1234", Role: "ROOT", DepartmentId: 1}
+			errorsMap, err := helpers.ValidatePayloads(req)
+			So(err, ShouldNotBeNil)
+			So(errorsMap, ShouldContainKey, "role")
+			So(errorsMap, ShouldContainKey, "password")
+
+			_, err = helpers.ValidatePayloads(dto.ChangeRoleRequest{Role: "root"})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Only active admins count for the last admin safeguard", func() {
+			now := time.Now()
+			for _, user := range []*models.User{
+				{Role: constants.RoleEmployee},
+				{Role: constants.RoleManager},
+				{Role: constants.RoleAdmin, DeactivatedAt: &now},
+				{Role: constants.RoleAdmin, ServiceAccount: true},
+			} {
+				So(helpers.EnsureAdminRemains(user), ShouldBeNil)
+			}
+		})
+
+		Convey("Responses tell deactivated users apart", func() {
+			now := time.Now()
+			user := &models.User{Id: 1, Role: constants.RoleEmployee, Department: &models.Department{Id: 1}, DeactivatedAt: &now}
+			response := dto.FromUserModelToUserResponse(user, false, false, false)
+			So(response.Active, ShouldBeFalse)
+			So(response.Role, ShouldEqual, constants.RoleEmployee)
+		})
+	})
+}
+
+// capturingMailer hands the sent emails to the test
+type capturingMailer struct {
+	messages chan *helpers.MailMessage
+}
+
+func (m *capturingMailer) Send(message *helpers.MailMessage) error {
+	m.messages <- message
+	return nil
+}
+
+// TestUserSelfServiceUpdate checks that users changing their email verify it again and stay within the allowed domains
+func TestUserSelfServiceUpdate(t *testing.T) {
+	requireTestDatabase(t)
+	resetTestDatabase(t)
+
+	department := seedDepartment(t, "Engineering")
+	department.AllowedEmailDomains = "example.com"
+	if _, err := orm.NewOrm().Update(department, "AllowedEmailDomains"); err != nil {
+		t.Fatalf("Failed to restrict the email domains: %v", err)
+	}
+	alice := seedUser(t, "Alice", department, nil)
+	bob := seedUser(t, "Bob", department, nil)
+	admin := seedAdmin(t, "Admin", department)
+
+	mailer := &capturingMailer{messages: make(chan *helpers.MailMessage, 1)}
+	helpers.SetMailer(mailer)
+
+	update := func(actor, user *models.User, email string) *models.User {
+		body := fmt.Sprintf(`{"name": %q, "email": %q, "department_id": %d}`, user.Name, email, department.Id)
+		recorder := serveAs(t, actor, http.MethodPut, fmt.Sprintf("/api/v1/users/%d", user.Id), body)
+		So(recorder.Code, ShouldEqual, http.StatusOK)
+		updated, err := models.GetUserAccountById(user.Id)
+		So(err, ShouldBeNil)
+		return updated
+	}
+
+	Convey("Subject: Self-service user update\n", t, func() {
+		Convey("Users changing their email verify the new address", func() {
+			updated := update(alice, alice, "alice.smith@example.com")
+			So(updated.Email, ShouldEqual, "alice.smith@example.com")
+			So(updated.EmailVerified, ShouldBeFalse)
+
+			select {
+			case message := <-mailer.messages:
+				So(message.To, ShouldEqual, "alice.smith@example.com")
+				So(message.Text, ShouldContainSubstring, "verify-email?token=")
+			case <-time.After(5 * time.Second):
+				t.Fatal("No verification email was sent")
+			}
+		})
+		Convey("Users can't change their email to a domain the department doesn't allow", func() {
+			body := fmt.Sprintf(`{"name": "Bob", "email": "bob@gmail.com", "department_id": %d}`, department.Id)
+			recorder := serveAs(t, bob, http.MethodPut, fmt.Sprintf("/api/v1/users/%d", bob.Id), body)
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+		})
+		Convey("Users can't take the email of another user", func() {
+			body := fmt.Sprintf(`{"name": "Bob", "email": %q, "department_id": %d}`, admin.Email, department.Id)
+			recorder := serveAs(t, bob, http.MethodPut, fmt.Sprintf("/api/v1/users/%d", bob.Id), body)
+			So(recorder.Code, ShouldEqual, http.StatusConflict)
+		})
+		Convey("Profile updates leave the other columns untouched", func() {
+			stale, err := models.GetUserById(bob.Id, false)
+			So(err, ShouldBeNil)
+			// Bumped concurrently, e.g. by a password change
+			_, err = orm.NewOrm().Raw(`UPDATE "user" SET token_version = token_version + 1 WHERE id = ?`, bob.Id).Exec()
+			So(err, ShouldBeNil)
+
+			stale.Name = "Bobby"
+			So(models.UpdateUser(stale), ShouldBeNil)
+			updated, err := models.GetUserAccountById(bob.Id)
+			So(err, ShouldBeNil)
+			So(updated.Name, ShouldEqual, "Bobby")
+			So(updated.TokenVersion, ShouldEqual, stale.TokenVersion+1)
+		})
+		Convey("Emails changed by an admin stay verified", func() {
+			updated := update(admin, bob, "robert@example.com")
+			So(updated.Email, ShouldEqual, "robert@example.com")
+			So(updated.EmailVerified, ShouldBeTrue)
+		})
+	})
+}
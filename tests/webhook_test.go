@@ -0,0 +1,107 @@
+package test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snykk/beego-presence-api/constants"
+	"github.com/snykk/beego-presence-api/dto"
+	"github.com/snykk/beego-presence-api/helpers"
+	"github.com/snykk/beego-presence-api/models"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestWebhook checks the payload signature, the retry backoff and the event subscriptions of webhooks
+func TestWebhook(t *testing.T) {
+	Convey("Subject: Webhook deliveries\n", t, func() {
+		Convey("Payloads are signed over the timestamp and the body", func() {
+			body := []byte(`{"event":"presence.created"}`)
+			mac := hmac.New(sha256.New, []byte("secret"))
+			mac.Write([]byte("1700000000." + string(body)))
+			expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+			So(helpers.SignWebhookPayload("secret", 1700000000, body), ShouldEqual, expected)
+			So(helpers.SignWebhookPayload("other", 1700000000, body), ShouldNotEqual, expected)
+		})
+		Convey("Retries back off exponentially up to the maximum", func() {
+			So(helpers.WebhookBackoff(1), ShouldEqual, constants.WebhookBaseBackoff)
+			So(helpers.WebhookBackoff(3), ShouldEqual, 4*constants.WebhookBaseBackoff)
+			So(helpers.WebhookBackoff(50), ShouldEqual, constants.WebhookMaxBackoff)
+			So(helpers.WebhookBackoff(50), ShouldBeLessThanOrEqualTo, 6*time.Hour)
+		})
+		Convey("Webhooks receive the subscribed events only", func() {
+			webhook := &models.Webhook{Events: constants.EventPresenceLate}
+			So(webhook.IsSubscribed(constants.EventPresenceLate), ShouldBeTrue)
+			So(webhook.IsSubscribed(constants.EventUserCreated), ShouldBeFalse)
+
+			webhook.Events = constants.WebhookEventAll
+			So(webhook.IsSubscribed(constants.EventUserCreated), ShouldBeTrue)
+		})
+		Convey("The longest signing secret still fits the column once encrypted", func() {
+			secret := strings.Repeat("s", 100)
+			encrypted, err := helpers.EncryptSecret(secret)
+			So(err, ShouldBeNil)
+			So(len(encrypted), ShouldBeLessThanOrEqualTo, 255)
+			So(encrypted, ShouldNotContainSubstring, secret)
+		})
+	})
+}
+
+// TestWebhookSecret checks that the signing secrets are stored encrypted, against the test database
+func TestWebhookSecret(t *testing.T) {
+	requireTestDatabase(t)
+	resetTestDatabase(t)
+
+	admin := seedAdmin(t, "Admin", seedDepartment(t, "Management"))
+
+	Convey("Subject: Webhook secrets\n", t, func() {
+		Convey("The secret is returned once and stored encrypted", func() {
+			recorder := serveAs(t, admin, http.MethodPost, "/api/v1/webhooks", `{"url":"https://hris.example.com/hooks","events":["*"],"secret":"0123456789abcdef0123"}`)
+			So(recorder.Code, ShouldEqual, http.StatusCreated)
+
+			var body struct {
+				Data dto.WebhookResponse `json:"data"`
+			}
+			So(json.Unmarshal(recorder.Body.Bytes(), &body), ShouldBeNil)
+			So(body.Data.Secret, ShouldEqual, "0123456789abcdef0123")
+
+			stored, err := models.GetWebhookById(body.Data.Id)
+			So(err, ShouldBeNil)
+			So(stored.Secret, ShouldNotContainSubstring, "0123456789abcdef0123")
+			decrypted, err := helpers.DecryptSecret(stored.Secret)
+			So(err, ShouldBeNil)
+			So(decrypted, ShouldEqual, "0123456789abcdef0123")
+
+			recorder = serveAs(t, admin, http.MethodGet, fmt.Sprintf("/api/v1/webhooks/%d", body.Data.Id), "")
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(recorder.Body.String(), ShouldNotContainSubstring, `"secret"`)
+		})
+
+		Convey("A new secret replaces the stored one encrypted", func() {
+			recorder := serveAs(t, admin, http.MethodPost, "/api/v1/webhooks", `{"url":"https://hris.example.com/hooks","events":["*"]}`)
+			So(recorder.Code, ShouldEqual, http.StatusCreated)
+			var body struct {
+				Data dto.WebhookResponse `json:"data"`
+			}
+			So(json.Unmarshal(recorder.Body.Bytes(), &body), ShouldBeNil)
+			So(body.Data.Secret, ShouldHaveLength, 64)
+
+			recorder = serveAs(t, admin, http.MethodPut, fmt.Sprintf("/api/v1/webhooks/%d", body.Data.Id), `{"url":"https://hris.example.com/hooks","events":["*"],"secret":"fedcba9876543210fedc"}`)
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+
+			stored, err := models.GetWebhookById(body.Data.Id)
+			So(err, ShouldBeNil)
+			decrypted, err := helpers.DecryptSecret(stored.Secret)
+			So(err, ShouldBeNil)
+			So(decrypted, ShouldEqual, "fedcba9876543210fedc")
+		})
+	})
+}